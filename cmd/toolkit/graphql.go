@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/raywall/fast-service-toolkit/pkg/engine"
+	"github.com/raywall/fast-service-toolkit/pkg/graphql"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// runGraphQLSDL imprime em w (ou grava em out) o schema GraphQL gerado pela configuração,
+// em SDL. Retorna o exit code.
+func runGraphQLSDL(path, out string, w io.Writer) int {
+	cfg, err := engine.NewUniversalLoader().Load(context.Background(), path)
+	if err != nil {
+		fmt.Fprintf(w, "❌ Erro de Carregamento/Estrutura:\n%v\n", err)
+		return 1
+	}
+	if !cfg.GraphQL.Enabled {
+		fmt.Fprintln(w, "❌ A configuração não habilita 'graphql'")
+		return 1
+	}
+
+	rm, err := rules.NewRuleManager()
+	if err != nil {
+		fmt.Fprintf(w, "❌ Erro interno: %v\n", err)
+		return 1
+	}
+	ge, err := graphql.NewGraphQLEngine(cfg.GraphQL, rm)
+	if err != nil {
+		fmt.Fprintf(w, "❌ Schema GraphQL inválido: %v\n", err)
+		return 1
+	}
+
+	if out == "" {
+		fmt.Fprint(w, ge.SDL())
+		return 0
+	}
+	if err := os.WriteFile(out, []byte(ge.SDL()), 0o644); err != nil {
+		fmt.Fprintf(w, "❌ %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(w, "✅ Schema gravado em %s\n", out)
+	return 0
+}
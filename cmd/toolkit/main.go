@@ -6,16 +6,43 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/raywall/fast-service-toolkit/pkg/engine"
+	"github.com/raywall/fast-service-toolkit/pkg/testsuite"
 )
 
 func main() {
 	validateCmd := flag.NewFlagSet("validate", flag.ExitOnError)
 	filePtr := validateCmd.String("file", "", "Caminho do arquivo YAML ou S3/DynamoDB URI")
 
+	testCmd := flag.NewFlagSet("test", flag.ExitOnError)
+	testFilePtr := testCmd.String("file", "", "Caminho do arquivo YAML ou S3/DynamoDB URI")
+	suitePtr := testCmd.String("suite", "", "Caminho do arquivo YAML com os casos de teste")
+	formatPtr := testCmd.String("format", testsuite.FormatText, "Formato do relatório: text, json ou junit")
+	outPtr := testCmd.String("out", "", "Arquivo do relatório (default: stdout)")
+
+	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
+	runOpts := runOptions{Headers: kvFlag{}, PathParams: kvFlag{}, Params: kvFlag{}, Mocks: kvFlag{}, Auth: kvFlag{}}
+	runCmd.StringVar(&runOpts.File, "file", "", "Caminho do arquivo YAML ou S3/DynamoDB URI")
+	runCmd.StringVar(&runOpts.Input, "input", "", "Arquivo com o payload (ou as variáveis, com -query)")
+	runCmd.StringVar(&runOpts.Operation, "operation", "", "ID da operação (default: operação padrão)")
+	runCmd.StringVar(&runOpts.Method, "method", "POST", "Método HTTP da requisição")
+	runCmd.StringVar(&runOpts.Query, "query", "", "Query GraphQL executada no mesh")
+	runCmd.Var(runOpts.Headers, "header", "Header da requisição K=V (repetível)")
+	runCmd.Var(runOpts.PathParams, "path", "Path param K=V (repetível)")
+	runCmd.Var(runOpts.Params, "param", "Query param K=V (repetível)")
+	runCmd.Var(runOpts.Mocks, "mock", "Resultado simulado source=arquivo.json (repetível)")
+	runCmd.Var(runOpts.Auth, "auth", "Token fixo de um auth_provider id=token (repetível)")
+	runCmd.BoolVar(&runOpts.Offline, "offline", false, "Bloqueia chamadas externas: sources sem -mock falham e auth_provider sem -auth usa o token de teste")
+	runCmd.BoolVar(&runOpts.Logs, "logs", false, "Exibe os logs da engine")
+
+	sdlCmd := flag.NewFlagSet("graphql sdl", flag.ExitOnError)
+	sdlFilePtr := sdlCmd.String("file", "", "Caminho do arquivo YAML ou S3/DynamoDB URI")
+	sdlOutPtr := sdlCmd.String("out", "", "Arquivo do schema (default: stdout)")
+
 	if len(os.Args) < 2 {
-		fmt.Println("Comandos esperados: validate")
+		fmt.Println("Comandos esperados: validate, test, run, graphql sdl")
 		os.Exit(1)
 	}
 
@@ -27,6 +54,31 @@ func main() {
 			os.Exit(1)
 		}
 		runValidate(*filePtr)
+	case "test":
+		testCmd.Parse(os.Args[2:])
+		if *testFilePtr == "" || *suitePtr == "" {
+			fmt.Println("Erro: flags -file e -suite são obrigatórias")
+			os.Exit(1)
+		}
+		os.Exit(runTest(*testFilePtr, *suitePtr, *formatPtr, *outPtr))
+	case "run":
+		runCmd.Parse(os.Args[2:])
+		if runOpts.File == "" {
+			fmt.Println("Erro: flag -file é obrigatória")
+			os.Exit(1)
+		}
+		os.Exit(runOnce(runOpts, os.Stdout))
+	case "graphql":
+		if len(os.Args) < 3 || os.Args[2] != "sdl" {
+			fmt.Println("Subcomandos esperados: graphql sdl")
+			os.Exit(1)
+		}
+		sdlCmd.Parse(os.Args[3:])
+		if *sdlFilePtr == "" {
+			fmt.Println("Erro: flag -file é obrigatória")
+			os.Exit(1)
+		}
+		os.Exit(runGraphQLSDL(*sdlFilePtr, *sdlOutPtr, os.Stdout))
 	default:
 		fmt.Println("Comando desconhecido")
 		os.Exit(1)
@@ -64,6 +116,54 @@ func runValidate(path string) {
 		jsonOutput, _ := json.Marshal(report)
 		fmt.Println(string(jsonOutput))
 	} else {
+		for _, w := range report.Warnings {
+			fmt.Printf("⚠️  %s\n", w)
+		}
+		for mwID, order := range report.EnrichmentOrder {
+			fmt.Printf("🔗 Enrichment '%s': %s\n", mwID, strings.Join(order, " -> "))
+		}
 		fmt.Println("✅ Configuração Válida e Pronta para Deploy!")
 	}
 }
+
+// runTest executa a suíte contra a configuração e retorna o exit code (1 se algum caso falhar).
+func runTest(path, suitePath, format, out string) int {
+	loader := engine.NewUniversalLoader()
+	cfg, err := loader.Load(context.Background(), path)
+	if err != nil {
+		fmt.Printf("❌ Erro de Carregamento/Estrutura:\n%v\n", err)
+		return 1
+	}
+
+	suite, err := testsuite.Load(suitePath)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	report, err := testsuite.Run(context.Background(), cfg, suite)
+	if err != nil {
+		fmt.Printf("❌ Erro ao executar a suíte: %v\n", err)
+		return 1
+	}
+
+	w := os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			fmt.Printf("❌ Erro ao criar relatório: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := report.Write(w, format); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
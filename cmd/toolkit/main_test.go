@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -38,3 +41,188 @@ steps:
 	// Se passar liso, sucesso.
 	runValidate(tmp.Name())
 }
+
+// TestRunTest executa uma suíte e verifica o exit code e o relatório JUnit.
+func TestRunTest(t *testing.T) {
+	dir := t.TempDir()
+	svc := filepath.Join(dir, "svc.yaml")
+	os.WriteFile(svc, []byte(`
+version: "1.0"
+service:
+  name: "cli-test"
+  runtime: "lambda"
+  route: "/cli"
+  timeout: "1s"
+  on_timeout: {code: 504, msg: "timeout"}
+  logging: {enabled: false, level: "info", format: "json"}
+steps:
+  input:
+    validations:
+      - id: "amount"
+        expr: "input.amount > 0"
+        on_fail: {code: 400, msg: "Invalid amount"}
+  output: {status_code: 200, body: {amount: "${input.amount}"}}
+`), 0o644)
+
+	suite := filepath.Join(dir, "tests.yaml")
+	write := func(status int) {
+		os.WriteFile(suite, []byte(fmt.Sprintf(`
+cases:
+  - name: "valor positivo"
+    request: {body: {amount: 10}}
+    expect: {status: %d, body: {amount: 10}}
+`, status)), 0o644)
+	}
+
+	report := filepath.Join(dir, "report.xml")
+	write(200)
+	if code := runTest(svc, suite, "junit", report); code != 0 {
+		t.Fatalf("Exit code esperado 0, recebido %d", code)
+	}
+	data, _ := os.ReadFile(report)
+	if !strings.Contains(string(data), `<testcase name="valor positivo"`) {
+		t.Fatalf("Relatório JUnit inesperado: %s", data)
+	}
+
+	write(400)
+	if code := runTest(svc, suite, "junit", report); code != 1 {
+		t.Fatalf("Exit code esperado 1, recebido %d", code)
+	}
+}
+
+// TestRunOnce executa a configuração com mock de source e verifica a saída.
+func TestRunOnce(t *testing.T) {
+	dir := t.TempDir()
+	svc := filepath.Join(dir, "svc.yaml")
+	os.WriteFile(svc, []byte(`
+version: "1.0"
+service:
+  name: "cli-run"
+  runtime: "lambda"
+  route: "/cli"
+  timeout: "1s"
+  on_timeout: {code: 504, msg: "timeout"}
+  logging: {enabled: true, level: "info", format: "json"}
+middlewares:
+  - type: "enrichment"
+    id: "enrich"
+    config:
+      sources:
+        - name: "profile"
+          type: "rest"
+          params: {url: "http://127.0.0.1:1/profile"}
+steps:
+  output:
+    status_code: 200
+    headers: {X-Channel: "${header['X-Channel']}"}
+    body: {amount: "${input.amount}", id: "${input.id}", tier: "${detection.profile.tier}"}
+`), 0o644)
+	input := filepath.Join(dir, "payload.json")
+	os.WriteFile(input, []byte(`{"amount": 10}`), 0o644)
+	mock := filepath.Join(dir, "profile.json")
+	os.WriteFile(mock, []byte(`{"tier": "gold"}`), 0o644)
+
+	opts := runOptions{
+		File:       svc,
+		Input:      input,
+		Method:     "POST",
+		Headers:    kvFlag{"x-channel": "app"},
+		PathParams: kvFlag{"id": "5"},
+		Mocks:      kvFlag{"profile": mock},
+	}
+	var out strings.Builder
+	if code := runOnce(opts, &out); code != 0 {
+		t.Fatalf("Exit code esperado 0, recebido %d: %s", code, out.String())
+	}
+	for _, want := range []string{
+		"Status: 200",
+		"X-Channel: app",
+		`"tier": "gold"`,
+		`"id": "5"`,
+		"enrichment/source profile",
+		"output/output",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Saída sem '%s':\n%s", want, out.String())
+		}
+	}
+
+	opts.Mocks = kvFlag{"outra": mock}
+	out.Reset()
+	if code := runOnce(opts, &out); code != 1 || !strings.Contains(out.String(), "stub para source inexistente") {
+		t.Fatalf("Mock inválido deveria falhar: %d %s", code, out.String())
+	}
+
+	// Sem mock, a chamada real é sinalizada; com -offline, bloqueada
+	opts.Mocks = kvFlag{}
+	out.Reset()
+	runOnce(opts, &out)
+	if !strings.Contains(out.String(), "Chamadas reais") || !strings.Contains(out.String(), "source 'profile' (rest)") {
+		t.Errorf("Saída sem o aviso de chamadas reais:\n%s", out.String())
+	}
+	opts.Offline = true
+	out.Reset()
+	runOnce(opts, &out)
+	if strings.Contains(out.String(), "Chamadas reais") || !strings.Contains(out.String(), "sem stub") {
+		t.Errorf("Com -offline a source deveria falhar sem rede:\n%s", out.String())
+	}
+
+	opts.Auth = kvFlag{"sts": "tk"}
+	out.Reset()
+	if code := runOnce(opts, &out); code != 1 || !strings.Contains(out.String(), "stub para auth_provider inexistente") {
+		t.Fatalf("Token para auth_provider inexistente deveria falhar: %d %s", code, out.String())
+	}
+
+	if err := (kvFlag{}).Set("sem-igual"); err == nil {
+		t.Fatalf("Esperado erro para flag sem '='")
+	}
+}
+
+func TestRunGraphQLSDL(t *testing.T) {
+	dir := t.TempDir()
+	svc := filepath.Join(dir, "svc.yaml")
+	os.WriteFile(svc, []byte(`
+version: "1.0"
+service:
+  name: "cli-mesh"
+  runtime: "lambda"
+  timeout: "1s"
+  on_timeout: {code: 504, msg: "timeout"}
+  logging: {enabled: false, level: "info", format: "json"}
+graphql:
+  enabled: true
+  route: "/graphql"
+  types:
+    User:
+      fields:
+        name: {type: "String!"}
+  query:
+    user:
+      type: "User"
+      args: {id: "ID!"}
+      source: {type: "fixed", params: {value: {name: "Ana"}}}
+operations:
+  - id: "health"
+    route: "/health"
+    steps:
+      output: {status_code: 200, body: {ok: true}}
+`), 0o644)
+
+	var out strings.Builder
+	if code := runGraphQLSDL(svc, "", &out); code != 0 {
+		t.Fatalf("Exit code esperado 0, recebido %d: %s", code, out.String())
+	}
+	want := "type Query {\n  user(id: ID!): User\n}\n\ntype User {\n  name: String!\n}\n"
+	if out.String() != want {
+		t.Errorf("SDL inesperado:\n%s", out.String())
+	}
+
+	schema := filepath.Join(dir, "schema.graphql")
+	out.Reset()
+	if code := runGraphQLSDL(svc, schema, &out); code != 0 {
+		t.Fatalf("Exit code esperado 0, recebido %d: %s", code, out.String())
+	}
+	if data, _ := os.ReadFile(schema); string(data) != want {
+		t.Errorf("Arquivo com SDL inesperado:\n%s", data)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/engine"
+	"github.com/raywall/fast-service-toolkit/pkg/testsuite"
+)
+
+// kvFlag acumula flags repetidas no formato chave=valor (ex: -header K=V -header K2=V2).
+type kvFlag map[string]string
+
+func (f kvFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f kvFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("formato esperado chave=valor: '%s'", value)
+	}
+	f[k] = v
+	return nil
+}
+
+// runOptions são os parâmetros do comando run.
+type runOptions struct {
+	File       string
+	Input      string // Payload (REST) ou variáveis (GraphQL) em arquivo
+	Operation  string
+	Method     string
+	Query      string // Query GraphQL; quando informada, a execução vai para o mesh
+	Headers    kvFlag
+	PathParams kvFlag
+	Params     kvFlag // Query params (?k=v)
+	Mocks      kvFlag // Source (ou campo GraphQL "Tipo.campo") → arquivo JSON
+	Auth       kvFlag // ID do middleware auth_provider → token
+	Offline    bool   // Sem rede: sources sem mock falham e auth_provider sem token usa o token de teste
+	Logs       bool   // Mantém os logs da engine (desativados por padrão para não poluir a saída)
+}
+
+// runOnce executa a configuração uma vez, sem abrir porta, e imprime status, headers,
+// body e a duração de cada etapa. Retorna o exit code.
+func runOnce(opts runOptions, w io.Writer) int {
+	ctx := context.Background()
+	loader := engine.NewUniversalLoader()
+	cfg, err := loader.Load(ctx, opts.File)
+	if err != nil {
+		fmt.Fprintf(w, "❌ Erro de Carregamento/Estrutura:\n%v\n", err)
+		return 1
+	}
+
+	stubs := testsuite.Stubs{Sources: make(map[string]testsuite.SourceStub), Auth: opts.Auth, NoNetwork: opts.Offline}
+	for name, path := range opts.Mocks {
+		value, err := readJSON(path)
+		if err != nil {
+			fmt.Fprintf(w, "❌ Mock '%s': %v\n", name, err)
+			return 1
+		}
+		stubs.Sources[name] = testsuite.SourceStub{Value: value}
+	}
+	calls := testsuite.Unstubbed(cfg, stubs)
+	cfg, err = testsuite.Apply(cfg, stubs)
+	if err != nil {
+		fmt.Fprintf(w, "❌ %v\n", err)
+		return 1
+	}
+	if len(calls) > 0 {
+		fmt.Fprintln(w, "⚠️  Chamadas reais (sem -mock/-auth; use -offline para bloqueá-las):")
+		for _, call := range calls {
+			fmt.Fprintf(w, "   - %s\n", call)
+		}
+		fmt.Fprintln(w)
+	}
+	if !opts.Logs {
+		cfg.Service.Logging.Enabled = false
+	}
+
+	svc, err := engine.NewServiceEngine(cfg, opts.File, testsuite.EngineOptions(cfg, stubs)...)
+	if err != nil {
+		fmt.Fprintf(w, "❌ Erro ao iniciar engine: %v\n", err)
+		return 1
+	}
+	defer svc.Shutdown(ctx)
+
+	var input interface{}
+	if opts.Input != "" {
+		if input, err = readInput(opts.Input, opts.Query != ""); err != nil {
+			fmt.Fprintf(w, "❌ Input: %v\n", err)
+			return 1
+		}
+	}
+	req := testsuite.Request{
+		Method:     opts.Method,
+		PathParams: opts.PathParams,
+		Query:      opts.Params,
+		Headers:    opts.Headers,
+		Body:       input,
+	}
+
+	ctx, tr := engine.WithTrace(ctx)
+	start := time.Now()
+	var resp *testsuite.Response
+	if opts.Query != "" {
+		variables, _ := input.(map[string]interface{})
+		resp, err = testsuite.ExecuteGraphQL(ctx, svc, req, opts.Query, variables)
+	} else {
+		resp, err = testsuite.Execute(ctx, svc, opts.Operation, req)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(w, "❌ %v\n", err)
+		return 1
+	}
+
+	printResponse(w, resp, elapsed)
+	if len(tr.Entries) > 0 {
+		fmt.Fprintln(w, "\n⏱️  Etapas:")
+		for _, e := range tr.Entries {
+			fmt.Fprintf(w, "   %8.2fms  %s\n", e.DurationMs, describeEntry(e))
+		}
+	}
+	return 0
+}
+
+// readInput lê o payload. Como variáveis GraphQL, o conteúdo deve ser um objeto JSON;
+// como payload REST, JSON válido é enviado como objeto e o restante como texto bruto.
+func readInput(path string, variables bool) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		if variables {
+			return nil, fmt.Errorf("variáveis devem ser um objeto JSON: %w", err)
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+func readJSON(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("JSON inválido em '%s': %w", path, err)
+	}
+	return value, nil
+}
+
+func printResponse(w io.Writer, resp *testsuite.Response, elapsed time.Duration) {
+	fmt.Fprintf(w, "▶️  Status: %d (%.2fms)\n", resp.Status, float64(elapsed.Microseconds())/1000)
+
+	names := make([]string, 0, len(resp.Headers))
+	for name := range resp.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, resp.Headers[name])
+	}
+
+	fmt.Fprintln(w)
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, resp.Body, "", "  ") == nil {
+		fmt.Fprintln(w, pretty.String())
+	} else {
+		fmt.Fprintln(w, string(resp.Body))
+	}
+}
+
+func describeEntry(e engine.TraceEntry) string {
+	desc := e.Step + "/" + e.Kind
+	if e.ID != "" {
+		desc += " " + e.ID
+	}
+	switch {
+	case e.Error != "":
+		desc += " ❌ " + e.Error
+	case e.Skipped:
+		desc += " (ignorada)"
+	case e.Attempts > 1:
+		desc += fmt.Sprintf(" (%d tentativas)", e.Attempts)
+	}
+	return desc
+}
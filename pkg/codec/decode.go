@@ -0,0 +1,272 @@
+// Package codec converte corpos de requisição (JSON, formulários, multipart, XML e
+// texto) no mapa 'input' usado pelas expressões CEL.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+const (
+	// RawKey guarda o corpo de tipos sem decoder estruturado (ex: text/plain).
+	RawKey = "_raw"
+	// BodyKey guarda corpos JSON que não são objetos (arrays, números, strings).
+	BodyKey = "_body"
+)
+
+// DefaultContentTypes são aceitos quando a rota não declara 'content_types'.
+var DefaultContentTypes = []string{
+	"application/json",
+	"application/x-www-form-urlencoded",
+	"multipart/form-data",
+	"application/xml",
+	"text/xml",
+	"text/plain",
+}
+
+// ErrUnsupportedMediaType indica um Content-Type fora da lista aceita pela rota.
+var ErrUnsupportedMediaType = errors.New("content-type não suportado")
+
+// MediaType normaliza o header Content-Type (sem parâmetros, minúsculo).
+// Vazio é tratado como JSON, formato histórico do toolkit.
+func MediaType(contentType string) string {
+	if strings.TrimSpace(contentType) == "" {
+		return "application/json"
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mt
+}
+
+// Accepted verifica se o Content-Type casa com algum item da lista. Os itens podem
+// ser exatos ("application/json") ou curingas ("text/*", "*/*").
+func Accepted(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		allowed = DefaultContentTypes
+	}
+	mt := MediaType(contentType)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "*/*", pattern == mt:
+			return true
+		case strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// ValidMediaRange indica se o item de 'content_types' é um media type ou curinga válido.
+func ValidMediaRange(pattern string) bool {
+	typ, sub, ok := strings.Cut(strings.TrimSpace(pattern), "/")
+	if !ok || typ == "" || sub == "" || strings.ContainsAny(pattern, " ;,") {
+		return false
+	}
+	return typ != "*" || sub == "*"
+}
+
+// Decode converte o corpo em mapa de acordo com o Content-Type:
+//   - JSON: objetos viram o próprio mapa; demais valores ficam em '_body'
+//   - application/x-www-form-urlencoded: campos (repetidos viram lista)
+//   - multipart/form-data: campos e metadados de arquivos (filename, content_type, size)
+//   - XML: elementos viram chaves, atributos '@nome' e texto misto '#text'
+//   - demais tipos: o corpo como string em '_raw'
+func Decode(contentType string, body []byte) (map[string]interface{}, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	mt := MediaType(contentType)
+	switch {
+	case isJSON(mt):
+		return decodeJSON(body)
+	case mt == "application/x-www-form-urlencoded":
+		return decodeForm(body)
+	case mt == "multipart/form-data":
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil || params["boundary"] == "" {
+			return nil, fmt.Errorf("multipart sem boundary")
+		}
+		return decodeMultipart(body, params["boundary"])
+	case isXML(mt):
+		return decodeXML(body)
+	default:
+		return map[string]interface{}{RawKey: string(body)}, nil
+	}
+}
+
+func isJSON(mt string) bool {
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+func isXML(mt string) bool {
+	return mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+func decodeJSON(body []byte) (map[string]interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, nil
+	}
+	return map[string]interface{}{BodyKey: v}, nil
+}
+
+func decodeForm(body []byte) (map[string]interface{}, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("formulário inválido: %w", err)
+	}
+	out := make(map[string]interface{}, len(values))
+	for k, vs := range values {
+		for _, v := range vs {
+			addValue(out, k, v)
+		}
+	}
+	return out, nil
+}
+
+func decodeMultipart(body []byte, boundary string) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("multipart inválido: %w", err)
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+		if filename := part.FileName(); filename != "" {
+			// Arquivos expõem apenas metadados; o conteúdo não vai para o contexto CEL
+			size, err := io.Copy(io.Discard, part)
+			if err != nil {
+				return nil, fmt.Errorf("multipart inválido: %w", err)
+			}
+			addValue(out, name, map[string]interface{}{
+				"filename":     filename,
+				"content_type": part.Header.Get("Content-Type"),
+				"size":         size,
+			})
+		} else {
+			data, err := io.ReadAll(part)
+			if err != nil {
+				return nil, fmt.Errorf("multipart inválido: %w", err)
+			}
+			addValue(out, name, string(data))
+		}
+		part.Close()
+	}
+}
+
+func decodeXML(body []byte) (map[string]interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charsetReader
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return make(map[string]interface{}), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("XML inválido: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			value, err := decodeXMLElement(dec, start)
+			if err != nil {
+				return nil, fmt.Errorf("XML inválido: %w", err)
+			}
+			return map[string]interface{}{start.Name.Local: value}, nil
+		}
+	}
+}
+
+// decodeXMLElement converte um elemento em string (apenas texto) ou em mapa com
+// filhos, atributos e texto misto. Elementos irmãos repetidos viram lista.
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		node["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			addValue(node, t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				node["#text"] = content
+			}
+			return node, nil
+		}
+	}
+}
+
+// charsetReader aceita, além de UTF-8, o ISO-8859-1 ainda comum em integrações XML legadas.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8", "us-ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	return nil, fmt.Errorf("charset não suportado: %s", label)
+}
+
+// addValue inclui o valor na chave; a partir da segunda ocorrência, a chave vira lista.
+func addValue(m map[string]interface{}, key string, value interface{}) {
+	current, exists := m[key]
+	if !exists {
+		m[key] = value
+		return
+	}
+	if list, ok := current.([]interface{}); ok {
+		m[key] = append(list, value)
+		return
+	}
+	m[key] = []interface{}{current, value}
+}
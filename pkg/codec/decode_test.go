@@ -0,0 +1,117 @@
+package codec
+
+import (
+	"bytes"
+	"mime/multipart"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode_JSON(t *testing.T) {
+	m, err := Decode("application/json; charset=utf-8", []byte(`{"id": 1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": 1.0}, m)
+
+	// Sem Content-Type, o corpo é tratado como JSON
+	m, err = Decode("", []byte(`[1, 2]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0, 2.0}, m[BodyKey])
+
+	m, err = Decode("application/vnd.api+json", []byte(`{"a": "b"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", m["a"])
+
+	_, err = Decode("application/json", []byte(`{invalid`))
+	assert.Error(t, err)
+}
+
+func TestDecode_Form(t *testing.T) {
+	m, err := Decode("application/x-www-form-urlencoded", []byte("status=paid&id=42&tag=a&tag=b&msg=ol%C3%A1+mundo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "paid", m["status"])
+	assert.Equal(t, "42", m["id"])
+	assert.Equal(t, []interface{}{"a", "b"}, m["tag"])
+	assert.Equal(t, "olá mundo", m["msg"])
+}
+
+func TestDecode_Multipart(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("description", "contrato")
+	fw, _ := w.CreateFormFile("document", "contrato.pdf")
+	_, _ = fw.Write([]byte("%PDF-1.4 conteudo"))
+	_ = w.Close()
+
+	m, err := Decode(w.FormDataContentType(), buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "contrato", m["description"])
+
+	doc := m["document"].(map[string]interface{})
+	assert.Equal(t, "contrato.pdf", doc["filename"])
+	assert.Equal(t, "application/octet-stream", doc["content_type"])
+	assert.EqualValues(t, 17, doc["size"])
+
+	_, err = Decode("multipart/form-data", buf.Bytes())
+	assert.ErrorContains(t, err, "boundary")
+}
+
+func TestDecode_XML(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<order xmlns="urn:legacy" id="7">
+  <customer>Ana</customer>
+  <item sku="A1">2</item>
+  <item sku="B2">1</item>
+  <note/>
+</order>`
+
+	m, err := Decode("application/xml", []byte(body))
+	assert.NoError(t, err)
+
+	order := m["order"].(map[string]interface{})
+	assert.Equal(t, "7", order["@id"])
+	assert.NotContains(t, order, "@xmlns")
+	assert.Equal(t, "Ana", order["customer"])
+	assert.Equal(t, "", order["note"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"@sku": "A1", "#text": "2"},
+		map[string]interface{}{"@sku": "B2", "#text": "1"},
+	}, order["item"])
+
+	latin1 := append([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><msg>`), 0xe7, 0xe3, 'o')
+	latin1 = append(latin1, []byte(`</msg>`)...)
+	m, err = Decode("text/xml", latin1)
+	assert.NoError(t, err)
+	assert.Equal(t, "ção", m["msg"])
+
+	_, err = Decode("application/xml", []byte(`<a><b></a>`))
+	assert.Error(t, err)
+}
+
+func TestDecode_Raw(t *testing.T) {
+	m, err := Decode("text/plain", []byte("linha 1\nlinha 2"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{RawKey: "linha 1\nlinha 2"}, m)
+
+	m, err = Decode("text/csv", []byte(" "))
+	assert.NoError(t, err)
+	assert.Empty(t, m)
+}
+
+func TestAccepted(t *testing.T) {
+	assert.True(t, Accepted(nil, ""))
+	assert.True(t, Accepted(nil, "application/json; charset=utf-8"))
+	assert.True(t, Accepted(nil, "Application/XML"))
+	assert.False(t, Accepted(nil, "text/csv"))
+	assert.False(t, Accepted(nil, "application/vnd.api+json"))
+
+	allowed := []string{"application/json", "text/*"}
+	assert.True(t, Accepted(allowed, "text/csv"))
+	assert.False(t, Accepted(allowed, "application/xml"))
+	assert.True(t, Accepted([]string{"*/*"}, "application/octet-stream"))
+
+	assert.True(t, ValidMediaRange("text/*"))
+	assert.False(t, ValidMediaRange("json"))
+	assert.False(t, ValidMediaRange("*/json"))
+	assert.False(t, ValidMediaRange("text/plain; charset=utf-8"))
+}
@@ -0,0 +1,380 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+// DefaultFormat é o encoder usado quando o output não declara 'format'.
+const DefaultFormat = "json"
+
+// ErrNotAcceptable indica que nenhum formato oferecido atende ao header Accept.
+var ErrNotAcceptable = errors.New("nenhum formato aceito pelo cliente")
+
+// Encoder serializa o body avaliado do output.
+type Encoder interface {
+	ContentType() string
+	Encode(v interface{}) ([]byte, error)
+}
+
+// EncoderFactory cria um Encoder a partir das opções do step output (csv, xml, template...).
+type EncoderFactory func(conf config.OutputStep) (Encoder, error)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = make(map[string]EncoderFactory)
+)
+
+func init() {
+	RegisterEncoder("json", func(config.OutputStep) (Encoder, error) { return jsonEncoder{}, nil })
+	RegisterEncoder("xml", newXMLEncoder)
+	RegisterEncoder("csv", newCSVEncoder)
+	RegisterEncoder("text", func(config.OutputStep) (Encoder, error) { return textEncoder{}, nil })
+	RegisterEncoder("template", newTemplateEncoder)
+}
+
+// RegisterEncoder disponibiliza um formato para 'output.format' e 'output.formats'.
+// Registrar um nome existente substitui o encoder anterior.
+func RegisterEncoder(name string, factory EncoderFactory) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[name] = factory
+}
+
+// NewEncoder cria o encoder registrado com o nome informado.
+func NewEncoder(name string, conf config.OutputStep) (Encoder, error) {
+	encodersMu.RLock()
+	factory, ok := encoders[name]
+	encodersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("formato de output desconhecido: '%s' (disponíveis: %s)", name, strings.Join(Encoders(), ", "))
+	}
+	return factory(conf)
+}
+
+// Encoders lista os formatos registrados, em ordem alfabética.
+func Encoders() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Negotiate escolhe, entre os Content-Types oferecidos, o de maior preferência no
+// header Accept (q-values e curingas). Accept vazio seleciona a primeira oferta.
+func Negotiate(accept string, offers []string) (int, error) {
+	if strings.TrimSpace(accept) == "" {
+		return 0, nil
+	}
+
+	type mediaRange struct {
+		value string
+		q     float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		r := mediaRange{value: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, param := range fields[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		if r.value != "" && r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		for i, offer := range offers {
+			if Accepted([]string{r.value}, offer) {
+				return i, nil
+			}
+		}
+	}
+	return 0, ErrNotAcceptable
+}
+
+// --- JSON ---
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// --- Texto ---
+
+type textEncoder struct{}
+
+func (textEncoder) ContentType() string { return "text/plain; charset=utf-8" }
+
+// Encode escreve strings como estão; mapas viram linhas "chave: valor" em ordem alfabética.
+func (textEncoder) Encode(v interface{}) ([]byte, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return []byte(scalarString(v)), nil
+	}
+	keys := sortedKeys(m)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\n", k, scalarString(m[k]))
+	}
+	return buf.Bytes(), nil
+}
+
+// --- Template ---
+
+type templateEncoder struct {
+	tmpl *template.Template
+}
+
+func newTemplateEncoder(conf config.OutputStep) (Encoder, error) {
+	if conf.Template == "" {
+		return nil, fmt.Errorf("format 'template' exige output.template")
+	}
+	tmpl, err := template.New("output").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Option("missingkey=zero").Parse(conf.Template)
+	if err != nil {
+		return nil, fmt.Errorf("template inválido: %w", err)
+	}
+	return templateEncoder{tmpl: tmpl}, nil
+}
+
+func (templateEncoder) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (e templateEncoder) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, v); err != nil {
+		return nil, fmt.Errorf("erro executando template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// --- CSV ---
+
+type csvEncoder struct {
+	rows      string
+	columns   []string
+	delimiter rune
+}
+
+func newCSVEncoder(conf config.OutputStep) (Encoder, error) {
+	e := csvEncoder{rows: conf.CSV.Rows, columns: conf.CSV.Columns, delimiter: ','}
+	if conf.CSV.Delimiter != "" {
+		runes := []rune(conf.CSV.Delimiter)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("csv.delimiter deve ter um caractere: '%s'", conf.CSV.Delimiter)
+		}
+		e.delimiter = runes[0]
+	}
+	return e, nil
+}
+
+func (csvEncoder) ContentType() string { return "text/csv; charset=utf-8" }
+
+// Encode gera uma linha por item da lista em 'csv.rows' (ou um único registro com o
+// body inteiro). Sem 'csv.columns', as colunas são a união das chaves, em ordem alfabética.
+func (e csvEncoder) Encode(v interface{}) ([]byte, error) {
+	data := v
+	if e.rows != "" {
+		m, _ := v.(map[string]interface{})
+		data = m[e.rows]
+	}
+
+	var records []map[string]interface{}
+	switch d := data.(type) {
+	case []interface{}:
+		for i, item := range d {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("csv: item[%d] não é um objeto", i)
+			}
+			records = append(records, row)
+		}
+	case map[string]interface{}:
+		records = []map[string]interface{}{d}
+	case nil:
+	default:
+		return nil, fmt.Errorf("csv: esperado lista de objetos, recebido %T", data)
+	}
+
+	columns := e.columns
+	if len(columns) == 0 {
+		seen := make(map[string]interface{})
+		for _, r := range records {
+			for k := range r {
+				seen[k] = nil
+			}
+		}
+		columns = sortedKeys(seen)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = e.delimiter
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	line := make([]string, len(columns))
+	for _, r := range records {
+		for i, col := range columns {
+			line[i] = scalarString(r[col])
+		}
+		if err := w.Write(line); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// --- XML ---
+
+type xmlEncoder struct {
+	root string
+}
+
+func newXMLEncoder(conf config.OutputStep) (Encoder, error) {
+	root := conf.XML.Root
+	if root == "" {
+		root = "response"
+	}
+	return xmlEncoder{root: root}, nil
+}
+
+func (xmlEncoder) ContentType() string { return "application/xml" }
+
+// Encode é o inverso do decoder XML: chaves '@nome' viram atributos, '#text' o texto
+// do elemento e listas repetem o elemento (itens de uma lista na raiz viram <item>).
+func (e xmlEncoder) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if list, ok := v.([]interface{}); ok {
+		v = map[string]interface{}{"item": list}
+	}
+	if err := encodeXMLElement(enc, e.root, v); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, v interface{}) error {
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if err := encodeXMLElement(enc, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	m, isMap := v.(map[string]interface{})
+	if isMap {
+		for _, k := range sortedKeys(m) {
+			if strings.HasPrefix(k, "@") {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlName(k[1:])}, Value: scalarString(m[k])})
+			}
+		}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if isMap {
+		if text, ok := m["#text"]; ok {
+			if err := enc.EncodeToken(xml.CharData(scalarString(text))); err != nil {
+				return err
+			}
+		}
+		for _, k := range sortedKeys(m) {
+			if strings.HasPrefix(k, "@") || k == "#text" {
+				continue
+			}
+			if err := encodeXMLElement(enc, k, m[k]); err != nil {
+				return err
+			}
+		}
+	} else if v != nil {
+		if err := enc.EncodeToken(xml.CharData(scalarString(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName troca caracteres inválidos em nomes de elementos por '_'.
+func xmlName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || r == '-' || r == '.' || r == ':' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r > 127 ||
+			(i > 0 && r >= '0' && r <= '9')
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// --- Helpers ---
+
+// scalarString formata valores simples; objetos e listas são serializados em JSON.
+func scalarString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(x)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
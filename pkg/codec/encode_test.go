@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func encode(t *testing.T, name string, conf config.OutputStep, v interface{}) string {
+	t.Helper()
+	enc, err := NewEncoder(name, conf)
+	if err != nil {
+		t.Fatalf("Erro criando encoder '%s': %v", name, err)
+	}
+	out, err := enc.Encode(v)
+	if err != nil {
+		t.Fatalf("Erro codificando com '%s': %v", name, err)
+	}
+	return string(out)
+}
+
+func TestEncoder_CSV(t *testing.T) {
+	body := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": "1", "name": "Ana", "score": 9.5, "tags": []interface{}{"a"}},
+			map[string]interface{}{"id": "2", "name": "Silva, Bia"},
+		},
+	}
+
+	out := encode(t, "csv", config.OutputStep{CSV: config.CSVConf{Rows: "items"}}, body)
+	assert.Equal(t, "id,name,score,tags\n1,Ana,9.5,\"[\"\"a\"\"]\"\n2,\"Silva, Bia\",,\n", out)
+
+	conf := config.OutputStep{CSV: config.CSVConf{Rows: "items", Columns: []string{"name", "id"}, Delimiter: ";"}}
+	assert.Equal(t, "name;id\nAna;1\nSilva, Bia;2\n", encode(t, "csv", conf, body))
+
+	// Sem 'rows', o body é um único registro
+	assert.Equal(t, "a,b\n1,x\n", encode(t, "csv", config.OutputStep{}, map[string]interface{}{"b": "x", "a": int64(1)}))
+
+	enc, _ := NewEncoder("csv", config.OutputStep{CSV: config.CSVConf{Rows: "items"}})
+	_, err := enc.Encode(map[string]interface{}{"items": []interface{}{"texto"}})
+	assert.ErrorContains(t, err, "item[0]")
+
+	_, err = NewEncoder("csv", config.OutputStep{CSV: config.CSVConf{Delimiter: ";;"}})
+	assert.Error(t, err)
+}
+
+func TestEncoder_XML(t *testing.T) {
+	body := map[string]interface{}{
+		"@id":      "7",
+		"customer": "Ana & Bia",
+		"item":     []interface{}{"a", "b"},
+		"empty":    nil,
+	}
+	out := encode(t, "xml", config.OutputStep{XML: config.XMLConf{Root: "order"}}, body)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<order id="7"><customer>Ana &amp; Bia</customer><empty></empty><item>a</item><item>b</item></order>`, out)
+
+	// O decoder XML lê de volta a mesma estrutura
+	decoded, err := Decode("application/xml", []byte(out))
+	assert.NoError(t, err)
+	order := decoded["order"].(map[string]interface{})
+	assert.Equal(t, "7", order["@id"])
+	assert.Equal(t, []interface{}{"a", "b"}, order["item"])
+
+	out = encode(t, "xml", config.OutputStep{}, map[string]interface{}{"1st key": true})
+	assert.Contains(t, out, "<response><_st_key>true</_st_key></response>")
+}
+
+func TestEncoder_TextAndTemplate(t *testing.T) {
+	assert.Equal(t, "a: 1\nb: x\n", encode(t, "text", config.OutputStep{}, map[string]interface{}{"b": "x", "a": 1.0}))
+	assert.Equal(t, "ok", encode(t, "text", config.OutputStep{}, "ok"))
+
+	conf := config.OutputStep{Template: `Olá {{.name}}!{{range .items}} [{{.}}]{{end}} {{json .meta}}`}
+	out := encode(t, "template", conf, map[string]interface{}{
+		"name":  "Ana",
+		"items": []interface{}{"a", "b"},
+		"meta":  map[string]interface{}{"v": 1},
+	})
+	assert.Equal(t, `Olá Ana! [a] [b] {"v":1}`, out)
+
+	_, err := NewEncoder("template", config.OutputStep{})
+	assert.ErrorContains(t, err, "output.template")
+	_, err = NewEncoder("template", config.OutputStep{Template: "{{.x"})
+	assert.ErrorContains(t, err, "template inválido")
+}
+
+type upperEncoder struct{}
+
+func (upperEncoder) ContentType() string { return "text/x-upper" }
+func (upperEncoder) Encode(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(scalarString(v))), nil
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	_, err := NewEncoder("upper", config.OutputStep{})
+	assert.ErrorContains(t, err, "formato de output desconhecido: 'upper'")
+
+	RegisterEncoder("upper", func(config.OutputStep) (Encoder, error) { return upperEncoder{}, nil })
+	assert.Contains(t, Encoders(), "upper")
+	assert.Equal(t, "ABC", encode(t, "upper", config.OutputStep{}, "abc"))
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "text/csv; charset=utf-8", "application/xml"}
+
+	cases := []struct {
+		accept string
+		want   int
+	}{
+		{"", 0},
+		{"*/*", 0},
+		{"text/csv", 1},
+		{"application/xml, application/json", 2},
+		{"application/json;q=0.5, application/xml", 2},
+		{"text/*", 1},
+		{"text/html, */*;q=0.1", 0},
+	}
+	for _, tc := range cases {
+		idx, err := Negotiate(tc.accept, offers)
+		assert.NoError(t, err, tc.accept)
+		assert.Equal(t, tc.want, idx, tc.accept)
+	}
+
+	_, err := Negotiate("text/html", offers)
+	assert.ErrorIs(t, err, ErrNotAcceptable)
+	_, err = Negotiate("application/json;q=0", offers)
+	assert.ErrorIs(t, err, ErrNotAcceptable)
+}
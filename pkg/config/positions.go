@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position é a localização (1-based) de um nó no YAML de origem.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("linha %d, coluna %d", p.Line, p.Column)
+}
+
+// Positions indexa a posição das chaves e dos itens de lista do YAML pelo caminho,
+// no formato "middlewares[1].config.sources[0].params.url".
+type Positions map[string]Position
+
+// IndexPositions percorre o YAML e registra a posição de cada chave e item de lista.
+// Conteúdo que não é YAML válido resulta em um índice vazio.
+func IndexPositions(data []byte) Positions {
+	positions := make(Positions)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return positions
+	}
+	indexNode(doc.Content[0], "", positions)
+	return positions
+}
+
+func indexNode(n *yaml.Node, path string, positions Positions) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			child := key.Value
+			if path != "" {
+				child = path + "." + key.Value
+			}
+			positions[child] = Position{Line: key.Line, Column: key.Column}
+			indexNode(n.Content[i+1], child, positions)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			positions[child] = Position{Line: item.Line, Column: item.Column}
+			indexNode(item, child, positions)
+		}
+	case yaml.AliasNode:
+		if n.Alias != nil {
+			indexNode(n.Alias, path, positions)
+		}
+	}
+}
+
+// Lookup retorna a posição do caminho ou, se ele não existir no YAML (ex: parâmetro
+// obrigatório ausente), a do ancestral mais próximo.
+func (p Positions) Lookup(path string) (Position, bool) {
+	for path != "" {
+		if pos, ok := p[path]; ok {
+			return pos, true
+		}
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			break
+		}
+		path = path[:cut]
+	}
+	return Position{}, false
+}
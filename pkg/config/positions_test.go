@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexPositions(t *testing.T) {
+	data := []byte(`version: "1.0"
+middlewares:
+  - id: enrich
+    config:
+      sources:
+        - name: bureau
+          params:
+            url: "http://x"
+`)
+	positions := IndexPositions(data)
+
+	assert.Equal(t, Position{Line: 1, Column: 1}, positions["version"])
+	assert.Equal(t, Position{Line: 3, Column: 5}, positions["middlewares[0]"])
+	assert.Equal(t, Position{Line: 8, Column: 13}, positions["middlewares[0].config.sources[0].params.url"])
+
+	// Caminho ausente usa o ancestral mais próximo
+	pos, ok := positions.Lookup("middlewares[0].config.sources[0].params.method")
+	assert.True(t, ok)
+	assert.Equal(t, "linha 7, coluna 11", pos.String())
+
+	_, ok = positions.Lookup("graphql.query")
+	assert.False(t, ok)
+	assert.Empty(t, IndexPositions([]byte("a: [")))
+}
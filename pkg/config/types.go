@@ -8,20 +8,76 @@ type ServiceConfig struct {
 	Service     ServiceDetails   `yaml:"service" validate:"required"`
 	Middlewares []MiddlewareConf `yaml:"middlewares" validate:"dive"`
 	Steps       *StepsConf       `yaml:"steps"` // Ponteiro para ser opcional no GraphQL
+	Operations  []OperationConf  `yaml:"operations" validate:"dive"`
 	GraphQL     GraphQLConf      `yaml:"graphql"`
+
+	// Positions localiza no YAML de origem os nós da configuração (preenchido pelo loader).
+	Positions Positions `yaml:"-" json:"-"`
+}
+
+// OperationConf define uma rota adicional (método + path) com seus próprios steps,
+// permitindo expor vários endpoints em um único arquivo de configuração.
+type OperationConf struct {
+	ID           string         `yaml:"id" validate:"required"`
+	Method       string         `yaml:"method"`                    // Vazio aceita qualquer método
+	Route        string         `yaml:"route" validate:"required"` // Ex: "/customers/{id}" ou "GET /customers/{id}"
+	Middlewares  []string       `yaml:"middlewares"`               // IDs dos middlewares aplicados (omitido: todos)
+	Timeout      string         `yaml:"timeout"`                   // Default: service.timeout
+	OnTimeout    *ErrorResponse `yaml:"on_timeout"`                // Default: service.on_timeout
+	ContentTypes []string       `yaml:"content_types"`             // Default: service.content_types
+	Steps        *StepsConf     `yaml:"steps" validate:"required"`
 }
 
 // ServiceDetails contém os metadados e configurações de runtime do serviço.
 type ServiceDetails struct {
-	Name      string        `yaml:"name" validate:"required,hostname_rfc1123"`
-	Runtime   string        `yaml:"runtime" validate:"required,oneof=local lambda ecs eks ec2"`
-	Type      string        `yaml:"type"`
-	Port      int           `yaml:"port" validate:"required_if=Runtime local"` // Obrigatório apenas se local
-	Route     string        `yaml:"route" validate:"required,startswith=/"`
-	Timeout   string        `yaml:"timeout" validate:"required"` // Ex: "500ms", "2s"
-	OnTimeout ErrorResponse `yaml:"on_timeout"`
-	Logging   LoggingConf   `yaml:"logging"`
-	Metrics   MetricsConf   `yaml:"metrics"`
+	Name         string        `yaml:"name" validate:"required,hostname_rfc1123"`
+	Runtime      string        `yaml:"runtime" validate:"required,oneof=local lambda ecs eks ec2"`
+	Type         string        `yaml:"type"`
+	Port         int           `yaml:"port" validate:"required_if=Runtime local"` // Obrigatório apenas se local
+	Route        string        `yaml:"route"`                                     // Ex: "/payments" ou "POST /payments"; obrigatório quando não há operations
+	Timeout      string        `yaml:"timeout" validate:"required"`               // Ex: "500ms", "2s"
+	OnTimeout    ErrorResponse `yaml:"on_timeout"`
+	ContentTypes []string      `yaml:"content_types"`                   // Content-Types aceitos no body (default: JSON, form, multipart, XML e texto)
+	MaxBodyBytes int64         `yaml:"max_body_bytes" validate:"gte=0"` // Tamanho máximo do body no servidor HTTP (default: 10 MiB)
+	Errors       ErrorsConf    `yaml:"errors"`
+	Logging      LoggingConf   `yaml:"logging"`
+	Metrics      MetricsConf   `yaml:"metrics"`
+	Shutdown     ShutdownConf  `yaml:"shutdown"`
+	Trace        TraceConf     `yaml:"trace"`
+
+	TrustedProxies TrustedProxiesConf `yaml:"trusted_proxies"`
+}
+
+// TrustedProxiesConf define os proxies à frente do serviço (ALB, CDN) confiáveis para
+// determinar o IP do cliente (request.client_ip) a partir do X-Forwarded-For. Sem
+// configuração, vale o endereço da conexão: o header é controlado pelo cliente.
+type TrustedProxiesConf struct {
+	Count int      `yaml:"count" validate:"gte=0"`     // Número de proxies confiáveis; o cliente é a entrada adicionada pelo último
+	CIDRs []string `yaml:"cidrs" validate:"dive,cidr"` // Redes dos proxies; o cliente é a entrada mais à direita fora delas
+}
+
+// ErrorsConf define o contrato das respostas de erro geradas pela engine.
+// Sem template, o corpo segue a RFC 7807 (application/problem+json).
+type ErrorsConf struct {
+	Template    map[string]interface{} `yaml:"template"`     // Avaliado como o output.body; a variável 'error' traz o problema
+	ContentType string                 `yaml:"content_type"` // Default com template: application/json
+}
+
+// TraceConf habilita o trace de execução por requisição (modo explain). Uma requisição é
+// rastreada quando traz um header X-Trace-Token assinado com 'secret' ou quando 'all' está ativo.
+type TraceConf struct {
+	Enabled  bool     `yaml:"enabled"`
+	Secret   string   `yaml:"secret"`                                            // Chave HMAC dos tokens (aceita env://, ssm:// e secret://)
+	TokenTTL string   `yaml:"token_ttl"`                                         // Validade do token (default: 5m)
+	All      bool     `yaml:"all"`                                               // Rastreia todas as requisições; apenas para ambientes de debug
+	Output   string   `yaml:"output" validate:"omitempty,oneof=log header both"` // Default: log
+	Redact   []string `yaml:"redact"`                                            // Campos mascarados além dos padrões (password, token, cpf...)
+}
+
+// ShutdownConf controla o encerramento gracioso do servidor HTTP (SIGTERM/SIGINT).
+type ShutdownConf struct {
+	DrainPeriod string `yaml:"drain_period"` // Tempo com a readiness falhando antes de parar de aceitar conexões (default: 5s)
+	Timeout     string `yaml:"timeout"`      // Prazo para concluir as requisições em andamento (default: 30s)
 }
 
 type GraphQLConf struct {
@@ -46,14 +102,34 @@ type GQLField struct {
 }
 
 type EnrichmentSourceConfig struct {
-	Type    string                 `yaml:"type"`
-	Params  map[string]interface{} `yaml:"params"`
-	Headers map[string]string      `yaml:"headers"`
+	Type           string                 `yaml:"type"`
+	Params         map[string]interface{} `yaml:"params"`
+	Headers        map[string]string      `yaml:"headers"`
+	CallPolicyConf `yaml:",inline"`
+}
+
+// CallPolicyConf define timeout e retentativas de uma chamada de source.
+// É compartilhado pelas sources de enrichment (REST) e pelos campos GraphQL.
+type CallPolicyConf struct {
+	Timeout string      `yaml:"timeout" json:"timeout"`   // Ex: "2s" (default: prazo restante da requisição)
+	Retries int         `yaml:"retries" json:"retries"`   // Retentativas após a primeira tentativa
+	Backoff BackoffConf `yaml:"backoff" json:"backoff"`   // Espera entre tentativas
+	RetryOn []string    `yaml:"retry_on" json:"retry_on"` // Ex: ["5xx", "429", "timeout", "network"]
+}
+
+// BackoffConf define a espera entre tentativas.
+type BackoffConf struct {
+	Type     string `yaml:"type" json:"type"`           // constant | exponential (default: constant)
+	Delay    string `yaml:"delay" json:"delay"`         // Espera base (default: 100ms)
+	MaxDelay string `yaml:"max_delay" json:"max_delay"` // Teto da espera exponencial (default: 5s)
+	Jitter   bool   `yaml:"jitter" json:"jitter"`       // Aleatoriza a espera para evitar rajadas sincronizadas
 }
 
 type ErrorResponse struct {
-	Code int    `yaml:"code" validate:"gte=400,lt=600"`
-	Msg  string `yaml:"msg" validate:"required"`
+	Code    int                    `yaml:"code" validate:"gte=400,lt=600"`
+	Msg     string                 `yaml:"msg" validate:"required"`
+	Type    string                 `yaml:"type"`    // URI do tipo de problema (default: about:blank)
+	Details map[string]interface{} `yaml:"details"` // Informações extras; aceita "${expr}" como o output.body
 }
 
 type LoggingConf struct {
@@ -92,7 +168,9 @@ type StepsConf struct {
 }
 
 type InputStep struct {
-	Validations []ValidationRule `yaml:"validations" validate:"dive"`
+	Schema       interface{}      `yaml:"schema"`         // JSON Schema (inline, file:// ou s3://) ou mapa compacto de tipos; tipa 'input' nas expressões CEL
+	SchemaOnFail *ErrorResponse   `yaml:"schema_on_fail"` // Resposta quando o payload viola o JSON Schema (default: 422)
+	Validations  []ValidationRule `yaml:"validations" validate:"dive"`
 }
 
 type ProcessingStep struct {
@@ -101,14 +179,42 @@ type ProcessingStep struct {
 }
 
 type OutputStep struct {
-	StatusCode  int                      `yaml:"status_code" validate:"gte=200,lt=600"`
-	Body        map[string]interface{}   `yaml:"body" validate:"required"` // Mantido como MAP para o analyzer funcionar
+	StatusCode  interface{}              `yaml:"status_code" validate:"required"` // Inteiro (200-599) ou expressão CEL
+	Body        map[string]interface{}   `yaml:"body" validate:"required"`        // Mantido como MAP para o analyzer funcionar
 	Headers     map[string]string        `yaml:"headers"`
+	Variants    []OutputVariant          `yaml:"variants" validate:"dive"` // Avaliadas em ordem; sem match, usa status_code/body/headers acima
+	Format      string                   `yaml:"format"`                   // json | xml | csv | text | template (default: json)
+	Formats     []string                 `yaml:"formats"`                  // Formatos adicionais negociados pelo header Accept
+	ContentType string                   `yaml:"content_type"`             // Sobrescreve o Content-Type do formato principal
+	Template    string                   `yaml:"template"`                 // text/template usado pelo format 'template' (dados: body avaliado)
+	CSV         CSVConf                  `yaml:"csv"`
+	XML         XMLConf                  `yaml:"xml"`
 	Target      TargetConf               `yaml:"target"`
 	Validations []ValidationRule         `yaml:"validations" validate:"dive"`
 	Metrics     []MetricRegistrationRule `yaml:"metrics" validate:"dive"`
 }
 
+// OutputVariant é uma resposta alternativa, usada quando 'when' é verdadeiro.
+// Campos omitidos herdam os valores do output; headers são somados aos do output.
+type OutputVariant struct {
+	When       string                 `yaml:"when" validate:"required"`
+	StatusCode interface{}            `yaml:"status_code"` // Inteiro ou expressão CEL
+	Body       map[string]interface{} `yaml:"body"`
+	Headers    map[string]string      `yaml:"headers"`
+}
+
+// CSVConf controla o format 'csv' do output.
+type CSVConf struct {
+	Rows      string   `yaml:"rows"`      // Campo do body com a lista de linhas (default: o body é a única linha)
+	Columns   []string `yaml:"columns"`   // Ordem das colunas (default: chaves em ordem alfabética)
+	Delimiter string   `yaml:"delimiter"` // Default: ","
+}
+
+// XMLConf controla o format 'xml' do output.
+type XMLConf struct {
+	Root string `yaml:"root"` // Elemento raiz (default: "response")
+}
+
 type TargetConf struct {
 	URL     string `yaml:"url"`
 	Method  string `yaml:"method"`
@@ -123,10 +229,19 @@ type ValidationRule struct {
 
 type TransformationRule struct {
 	Name      string `yaml:"name" validate:"required"`
-	Condition string `yaml:"condition" validate:"required"`
-	Value     string `yaml:"value" validate:"required"`
+	Type      string `yaml:"type" validate:"omitempty,oneof=foreach map filter reduce"` // Default: valor escalar
+	Condition string `yaml:"condition"`                                                 // Default: sempre aplica
+	Value     string `yaml:"value" validate:"required_unless=Type foreach"`
 	ElseValue string `yaml:"else_value"`
 	Target    string `yaml:"target" validate:"required"`
+
+	// Transformações de coleção (type foreach, map, filter e reduce)
+	Items           string               `yaml:"items" validate:"required_with=Type"`        // Expressão CEL que resulta na lista
+	As              string               `yaml:"as"`                                         // Alias do item (default: item)
+	IndexAs         string               `yaml:"index_as"`                                   // Alias da posição (default: index)
+	Initial         string               `yaml:"initial" validate:"required_if=Type reduce"` // Valor inicial do acumulador
+	Accumulator     string               `yaml:"accumulator"`                                // Alias do acumulador no reduce (default: acc)
+	Transformations []TransformationRule `yaml:"transformations" validate:"dive"`            // Executadas por item, antes de 'value'
 }
 
 type MetricRegistrationRule struct {
@@ -142,3 +257,29 @@ func (s ServiceDetails) GetTimeout() time.Duration {
 	}
 	return d
 }
+
+// DefaultMaxBodyBytes é o limite do body quando service.max_body_bytes não é informado.
+const DefaultMaxBodyBytes int64 = 10 << 20
+
+func (s ServiceDetails) GetMaxBodyBytes() int64 {
+	if s.MaxBodyBytes <= 0 {
+		return DefaultMaxBodyBytes
+	}
+	return s.MaxBodyBytes
+}
+
+func (s ShutdownConf) GetDrainPeriod() time.Duration {
+	d, err := time.ParseDuration(s.DrainPeriod)
+	if err != nil {
+		return 5 * time.Second
+	}
+	return d
+}
+
+func (s ShutdownConf) GetTimeout() time.Duration {
+	d, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return 30 * time.Second
+	}
+	return d
+}
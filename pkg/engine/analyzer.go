@@ -2,9 +2,12 @@ package engine
 
 import (
 	"fmt"
-	"strings"
 
+	"github.com/raywall/fast-service-toolkit/pkg/codec"
 	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
+	"github.com/raywall/fast-service-toolkit/pkg/graphql"
+	"github.com/raywall/fast-service-toolkit/pkg/responder"
 	"github.com/raywall/fast-service-toolkit/pkg/rules"
 )
 
@@ -13,6 +16,8 @@ type ValidationReport struct {
 	Valid    bool     `json:"valid"`
 	Errors   []string `json:"errors,omitempty"`
 	Warnings []string `json:"warnings,omitempty"`
+	// EnrichmentOrder contém, por ID de middleware, a ordem resolvida de execução das sources.
+	EnrichmentOrder map[string][]string `json:"enrichment_order,omitempty"`
 }
 
 // Analyze realiza uma inspeção profunda na configuração.
@@ -21,6 +26,8 @@ func Analyze(cfg *config.ServiceConfig) (*ValidationReport, error) {
 		Valid:    true,
 		Errors:   []string{},
 		Warnings: []string{},
+
+		EnrichmentOrder: map[string][]string{},
 	}
 
 	// 1. Inicializa dependências necessárias para checagem (ex: compilador CEL)
@@ -42,62 +49,154 @@ func Analyze(cfg *config.ServiceConfig) (*ValidationReport, error) {
 					if src.Name == "" {
 						report.Errors = append(report.Errors, fmt.Sprintf("Middleware[%d]: Source sem nome definido", i))
 					}
-					// Aqui poderíamos validar se params obrigatórios para cada 'type' existem
+					if _, err := enrichment.PolicyForSource(src.CallPolicyConf, src.Params); err != nil {
+						report.Errors = append(report.Errors, fmt.Sprintf("Middleware[%d] Source '%s': %v", i, src.Name, err))
+					}
+				}
+
+				order, err := ResolveEnrichmentOrder(eConf)
+				if err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("Middleware[%d] Enrichment '%s': %v", i, mw.ID, err))
+					continue
 				}
+				names := make([]string, len(order))
+				for j, src := range order {
+					names[j] = src.Name
+				}
+				report.EnrichmentOrder[mw.ID] = names
 			}
 		}
 	}
 
-	// 3. Validação de Regras CEL (Input)
-	for _, rule := range cfg.Steps.Input.Validations {
-		if _, err := rm.CompileProgram(rule.Expr); err != nil {
-			report.Errors = append(report.Errors, fmt.Sprintf("Steps.Input.Rule[%s]: Erro de sintaxe CEL: %v", rule.ID, err))
+	// 2.1 Schema GraphQL (tipos, argumentos, alcance) e sources dos resolvers
+	if cfg.GraphQL.Enabled {
+		for _, issue := range graphql.Validate(cfg.GraphQL) {
+			msg := fmt.Sprintf("%s: %s", located(cfg, issue.Where, issue.Path), issue.Message)
+			if issue.Warning {
+				report.Warnings = append(report.Warnings, msg)
+			} else {
+				report.Errors = append(report.Errors, msg)
+			}
 		}
+
+		check := func(scope string, fields map[string]config.GQLField) {
+			for name, field := range fields {
+				if field.Source == nil {
+					continue
+				}
+				if _, err := enrichment.PolicyForSource(field.Source.CallPolicyConf, field.Source.Params); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("GraphQL.%s.%s: %v", scope, name, err))
+				}
+			}
+		}
+		for typeName, typeDef := range cfg.GraphQL.Types {
+			check("Types."+typeName, typeDef.Fields)
+		}
+		check("Query", cfg.GraphQL.Query)
+		check("Mutation", cfg.GraphQL.Mutation)
 	}
 
-	// 4. Validação de Regras CEL (Processing)
-	declaredVars := make(map[string]bool)
+	// 2.2 Params das sources: schema do adapter (obrigatórios, tipos, desconhecidos) e
+	// sintaxe das interpolações, com a posição no YAML quando carregado pelo loader
+	for _, ref := range declaredSources(cfg) {
+		errs, warnings := checkSourceParams(cfg, ref, rm)
+		report.Errors = append(report.Errors, errs...)
+		report.Warnings = append(report.Warnings, warnings...)
+	}
 
-	for _, rule := range cfg.Steps.Processing.Validations {
-		if _, err := rm.CompileProgram(rule.Expr); err != nil {
-			report.Errors = append(report.Errors, fmt.Sprintf("Steps.Processing.Validation[%s]: Erro CEL: %v", rule.ID, err))
-		}
+	// 2.3 Operações (rotas adicionais)
+	if err := validateOperations(cfg); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("Operations: %v", err))
 	}
 
-	for _, trans := range cfg.Steps.Processing.Transformations {
-		// Valida Condição
-		if _, err := rm.CompileProgram(trans.Condition); err != nil {
-			report.Errors = append(report.Errors, fmt.Sprintf("Steps.Processing.Transform[%s]: Erro na Condição: %v", trans.Name, err))
+	// 2.4 Template das respostas de erro
+	if _, err := buildErrorTemplate(cfg.Service.Errors, rm); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("Service.Errors: %v", err))
+	}
+
+	// Serviços apenas GraphQL não possuem steps. Com schemas (input.schema e result_schema
+	// das sources), as expressões são checadas no ambiente tipado da operação. As referências
+	// a vars, detection e auth são conferidas com os middlewares aplicados a cada bloco.
+	usedSources := make(map[string]bool)
+	analyze := func(prefix string, steps *config.StepsConf, mws []config.MiddlewareConf) {
+		stepsRm, err := stepsRuleManager(rm, steps, mws)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Schema: %v", prefix, err))
+			stepsRm = rm
 		}
-		// Valida Valor
-		if _, err := rm.CompileProgram(trans.Value); err != nil {
-			report.Errors = append(report.Errors, fmt.Sprintf("Steps.Processing.Transform[%s]: Erro no Valor: %v", trans.Name, err))
+		if _, err := inputSchema(steps); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Schema: %v", prefix, err))
 		}
-		// Rastreia variáveis criadas (análise estática simples)
-		if strings.HasPrefix(trans.Target, "vars.") {
-			varName := strings.TrimPrefix(trans.Target, "vars.")
-			declaredVars[varName] = true
+		analyzeSteps(report, stepsRm, prefix, steps)
+		analyzeReferences(report, rm, prefix, steps, mws, usedSources)
+	}
+	if cfg.Steps != nil {
+		analyze("Steps", cfg.Steps, cfg.Middlewares)
+	}
+	for _, op := range cfg.Operations {
+		if op.Steps != nil {
+			mws, err := operationMiddlewares(cfg, op)
+			if err != nil {
+				mws = cfg.Middlewares // Referência inválida já reportada em Operations
+			}
+			analyze("Operations["+op.ID+"].Steps", op.Steps, mws)
 		}
 	}
+	analyzeSourceReferences(report, rm, cfg, usedSources, cfg.Steps != nil || len(cfg.Operations) > 0)
 
-	// 5. Validação de Output
-	for field, expr := range cfg.Steps.Output.Body {
-		if _, err := rm.CompileProgram(expr.(string)); err != nil {
-			report.Errors = append(report.Errors, fmt.Sprintf("Steps.Output.Field[%s]: Erro CEL: %v", field, err))
+	if len(report.Errors) > 0 {
+		report.Valid = false
+	}
+
+	return report, nil
+}
+
+// analyzeSteps valida as expressões CEL de um bloco de steps.
+func analyzeSteps(report *ValidationReport, rm *rules.RuleManager, prefix string, steps *config.StepsConf) {
+	// 3. Validação de Regras CEL (Input)
+	for _, rule := range steps.Input.Validations {
+		if _, err := rm.CompileProgram(rule.Expr); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Input.Rule[%s]: Erro de sintaxe CEL: %v", prefix, rule.ID, err))
 		}
+	}
 
-		// Análise Heurística: Tentar detectar uso de variáveis não declaradas
-		// (Isso é uma verificação simples de string, uma análise AST completa seria mais robusta mas complexa)
-		for v := range declaredVars {
-			// Se o output usa "vars.x" mas x não está em declaredVars (lógica inversa complexa aqui,
-			// vamos apenas emitir warning se virmos "vars." e não acharmos match, simplificado para PoC)
-			_ = v
+	// 4. Validação de Regras CEL (Processing)
+	for _, rule := range steps.Processing.Validations {
+		if _, err := rm.CompileProgram(rule.Expr); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Processing.Validation[%s]: Erro CEL: %v", prefix, rule.ID, err))
 		}
 	}
 
-	if len(report.Errors) > 0 {
-		report.Valid = false
+	for _, trans := range steps.Processing.Transformations {
+		// Valida estrutura, condição e valores (inclusive das transformações aninhadas)
+		for _, err := range rm.ValidateTransformation(trans) {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Processing.Transform[%s]: %v", prefix, trans.Name, err))
+		}
 	}
 
-	return report, nil
+	// 5. Validação de Output (body aninhado e headers; textos sem "${...}" são literais)
+	if err := responder.ValidateBody(steps.Output.Body, steps.Output.Headers, rm); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s.Output.Body: Erro CEL: %v", prefix, err))
+	}
+
+	// 5.1 Status dinâmico e variantes do output
+	if err := responder.ValidateStatusCode(steps.Output.StatusCode, rm); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s.Output.StatusCode: %v", prefix, err))
+	}
+	for i, variant := range steps.Output.Variants {
+		if err := responder.ValidateVariant(variant, rm); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Output.Variants[%d]: %v", prefix, i, err))
+		}
+	}
+
+	// 5.2 Formatos do output (encoders registrados e suas opções)
+	formats := append([]string{steps.Output.Format}, steps.Output.Formats...)
+	if formats[0] == "" {
+		formats[0] = codec.DefaultFormat
+	}
+	for _, name := range formats {
+		if _, err := codec.NewEncoder(name, steps.Output); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s.Output.Format[%s]: %v", prefix, name, err))
+		}
+	}
 }
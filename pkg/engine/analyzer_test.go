@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/raywall/fast-service-toolkit/pkg/config"
@@ -39,3 +40,318 @@ func TestAnalyze_Detection(t *testing.T) {
 		t.Errorf("Esperado pelo menos 2 erros, encontrados %d", len(report.Errors))
 	}
 }
+
+func TestAnalyze_SourceParams(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{"name": "ok", "type": "rest", "params": map[string]interface{}{"url": "http://x"}},
+						map[string]interface{}{"name": "sem_url", "type": "rest", "params": map[string]interface{}{"method": "GET"}},
+						map[string]interface{}{"name": "desconhecida", "type": "ftp"},
+					},
+				},
+			},
+		},
+		GraphQL: config.GraphQLConf{
+			Enabled: true,
+			Query: map[string]config.GQLField{
+				"user": {Type: "String", Source: &config.EnrichmentSourceConfig{Type: "aws_s3", Params: map[string]interface{}{"bucket": "b"}}},
+			},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if report.Valid {
+		t.Error("Deveria ser inválido devido às sources incorretas")
+	}
+	if len(report.Errors) != 3 {
+		t.Errorf("Esperados 3 erros (rest sem url, tipo desconhecido, s3 sem key), encontrados %d: %v", len(report.Errors), report.Errors)
+	}
+}
+
+func TestAnalyze_GraphQLSourceParams(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		GraphQL: config.GraphQLConf{
+			Enabled: true,
+			Query: map[string]config.GQLField{
+				"user": {Type: "String", Source: &config.EnrichmentSourceConfig{Type: "rest", Params: map[string]interface{}{
+					"url":    "http://x/${args.}",
+					"metod":  "GET",
+					"method": "GET",
+				}}},
+			},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "GraphQL.Query.user: parâmetro 'url': erro de sintaxe CEL") {
+		t.Errorf("Esperado erro de sintaxe na interpolação, recebidos: %v", report.Errors)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "parâmetro desconhecido 'metod' para source 'rest' (quis dizer 'method'?)") {
+		t.Errorf("Esperado aviso de parâmetro desconhecido, recebidos: %v", report.Warnings)
+	}
+}
+
+func TestAnalyze_GraphQLSchema(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		GraphQL: config.GraphQLConf{
+			Enabled: true,
+			Types: map[string]config.GQLType{
+				"Orphan": {Fields: map[string]config.GQLField{"id": {Type: "ID"}}},
+			},
+			Query: map[string]config.GQLField{
+				"user": {Type: "Usr", Source: &config.EnrichmentSourceConfig{Type: "fixed"}},
+			},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if len(report.Errors) != 1 || report.Errors[0] != "GraphQL.Query.user: tipo desconhecido: 'Usr'" {
+		t.Errorf("Esperado erro de tipo desconhecido, recebidos: %v", report.Errors)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "GraphQL.Types.Orphan: tipo não é alcançável") {
+		t.Errorf("Esperado aviso de tipo inalcançável, recebidos: %v", report.Warnings)
+	}
+}
+
+func TestAnalyze_OutputVariants(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Steps: &config.StepsConf{
+			Processing: config.ProcessingStep{
+				Transformations: []config.TransformationRule{{Name: "x", Value: "1", Target: "vars.x"}},
+			},
+			Output: config.OutputStep{
+				StatusCode: "vars.ok ? 200 :",
+				Body:       map[string]interface{}{"result": "vars.x"},
+				Variants: []config.OutputVariant{
+					{When: "vars.x > 10", StatusCode: 202},
+					{When: "vars.x >", StatusCode: 202},
+					{When: "true", StatusCode: 99},
+				},
+			},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	want := []string{"Steps.Output.StatusCode", "Steps.Output.Variants[1]", "Steps.Output.Variants[2]"}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("Esperados %d erros, encontrados %d: %v", len(want), len(report.Errors), report.Errors)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(report.Errors[i], prefix) {
+			t.Errorf("Erro %d deveria começar com '%s': %s", i, prefix, report.Errors[i])
+		}
+	}
+}
+
+func TestAnalyze_TransformationTargets(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Steps: &config.StepsConf{
+			Processing: config.ProcessingStep{
+				Transformations: []config.TransformationRule{
+					{Name: "ok", Value: "1", Target: "vars.a.b[0].c"},
+					{Name: "header", Value: "'x'", Target: "response.headers.X-Trace"},
+					{Name: "input", Value: "1", Target: "input.amount"},
+					{Name: "index", Value: "1", Target: "vars.items[a]"},
+					{Name: "nested", Type: "foreach", Items: "input.items", Target: "vars.lines", Transformations: []config.TransformationRule{
+						{Name: "h", Value: "item.id", Target: "response.headers.X-Id"},
+					}},
+				},
+			},
+			Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{"a": "vars.a"}},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	want := []string{"Transform[input]", "Transform[index]", "Transform[nested]"}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("Esperados %d erros, encontrados %d: %v", len(want), len(report.Errors), report.Errors)
+	}
+	for i, part := range want {
+		if !strings.Contains(report.Errors[i], part) {
+			t.Errorf("Erro %d deveria mencionar '%s': %s", i, part, report.Errors[i])
+		}
+	}
+}
+
+func TestAnalyze_References(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type:   "auth_provider",
+				ID:     "sts",
+				Config: map[string]interface{}{"token_url": "http://sts", "output_var": "token"},
+			},
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{"name": "profile", "type": "rest", "params": map[string]interface{}{
+							"url": "http://api/${input.id}?t=${vars.tier}",
+						}},
+						map[string]interface{}{"name": "score", "type": "rest", "params": map[string]interface{}{
+							"url": "http://score/${detection.profile.id}",
+						}},
+						map[string]interface{}{"name": "unused", "type": "fixed"},
+					},
+				},
+			},
+		},
+		Steps: &config.StepsConf{
+			Input: config.InputStep{
+				Validations: []config.ValidationRule{
+					{ID: "early", Expr: "vars.tier != 'x'"},
+					{ID: "guard", Expr: "!has(vars.tier) || true"},
+				},
+			},
+			Processing: config.ProcessingStep{
+				Transformations: []config.TransformationRule{
+					{Name: "tier", Value: "detection.score.value > 700 ? 'gold' : 'basic'", Target: "vars.tier"},
+					{Name: "typo", Value: "detection.scor.value", Target: "vars.raw"},
+					{Name: "lines", Type: "foreach", Items: "input.items", Target: "vars.lines", Transformations: []config.TransformationRule{
+						{Name: "qty", Value: "item.qty", Target: "vars.qty"},
+						{Name: "total", Value: "vars.qty * vars.rate", Target: "vars.total"},
+					}},
+				},
+			},
+			Output: config.OutputStep{
+				StatusCode: 200,
+				Body: map[string]interface{}{
+					"tier":  "${vars.tier}",
+					"lines": "${vars.lines}",
+					"nested": map[interface{}]interface{}{
+						"list":  []interface{}{"${vars.missing}", 1},
+						"token": "${auth.sts.access_token}",
+						"other": "${auth.other.token}",
+					},
+				},
+				Headers: map[string]string{"X-Token": "${auth.sts.token}"},
+			},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	wantErrors := []string{
+		"Steps.Input.Rule[early]: vars.tier é usada antes da transformação 'tier', que a define",
+		"Steps.Processing.Transform[typo]: detection.scor não corresponde a nenhuma source de enrichment",
+		"Steps.Processing.Transform[lines].Transform[total]: vars.rate não é definida por nenhuma transformação",
+		"Steps.Output.body.nested.list[0]: vars.missing não é definida por nenhuma transformação",
+		"Steps.Output.body.nested.other: auth.other não corresponde a nenhum middleware auth_provider",
+		"Steps.Output.body.nested.token: auth.sts.access_token não existe: o output_var do middleware é 'token'",
+		"Middleware[1].Source[profile]: vars.tier não está disponível no enrichment, executado antes das transformações",
+	}
+	if len(report.Errors) != len(wantErrors) {
+		t.Fatalf("Esperados %d erros, encontrados %d: %v", len(wantErrors), len(report.Errors), report.Errors)
+	}
+	for i, want := range wantErrors {
+		if report.Errors[i] != want {
+			t.Errorf("Erro %d:\n esperado: %s\n recebido: %s", i, want, report.Errors[i])
+		}
+	}
+
+	wantWarnings := []string{
+		"Steps.Processing.Transform[typo]: vars.raw é definida e nunca é usada",
+		"Middleware[1] Enrichment 'enrich': source 'unused' nunca é usada",
+	}
+	for _, want := range wantWarnings {
+		found := false
+		for _, w := range report.Warnings {
+			found = found || w == want
+		}
+		if !found {
+			t.Errorf("Warning ausente: %s (recebidos: %v)", want, report.Warnings)
+		}
+	}
+}
+
+func TestAnalyze_MiddlewareOrder(t *testing.T) {
+	enrich := func(id, name, url string) config.MiddlewareConf {
+		return config.MiddlewareConf{Type: "enrichment", ID: id, Config: map[string]interface{}{
+			"sources": []interface{}{map[string]interface{}{"name": name, "type": "rest", "params": map[string]interface{}{"url": url}}},
+		}}
+	}
+	cfg := &config.ServiceConfig{
+		Middlewares: []config.MiddlewareConf{
+			enrich("first", "profile", "http://api/${detection.limits.max}?t=${auth.sts.token}"),
+			{Type: "auth_provider", ID: "sts", Config: map[string]interface{}{"token_url": "http://sts", "output_var": "token"}},
+			enrich("second", "limits", "http://limits/${detection.profile.id}?t=${auth.sts.token}"),
+		},
+		Steps: &config.StepsConf{
+			Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{
+				"profile": "${detection.profile}",
+				"limits":  "${detection.limits}",
+			}},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	// Apenas o enrichment declarado antes do auth_provider e da source 'limits' é rejeitado
+	wantErrors := map[string]bool{
+		"Middleware[0].Source[profile]: detection.limits ainda não existe: a source pertence ao middleware 'second', declarado depois": true,
+		"Middleware[0].Source[profile]: auth.sts ainda não existe: o middleware auth_provider é declarado depois":                      true,
+	}
+	if len(report.Errors) != len(wantErrors) {
+		t.Fatalf("Esperados %d erros, encontrados %d: %v", len(wantErrors), len(report.Errors), report.Errors)
+	}
+	for _, got := range report.Errors {
+		if !wantErrors[got] {
+			t.Errorf("Erro inesperado: %s", got)
+		}
+	}
+	if report.Valid {
+		t.Error("Configuração deveria ser inválida")
+	}
+}
+
+func TestAnalyze_NestedOutputBody(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Steps: &config.StepsConf{
+			Output: config.OutputStep{
+				StatusCode: 200,
+				Body: map[string]interface{}{
+					"customer": map[interface{}]interface{}{"id": "${input.id}", "tags": []interface{}{"a", 1, true}},
+					"count":    3,
+					"label":    "texto literal",
+				},
+			},
+		},
+	}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if !report.Valid {
+		t.Errorf("Body aninhado e literais deveriam ser válidos: %v", report.Errors)
+	}
+}
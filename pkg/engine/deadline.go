@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+// deadlineExceeded indica se o prazo total da requisição (service.timeout) expirou.
+func deadlineExceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// timeoutProblem monta o problema configurado em on_timeout (default: 504).
+func timeoutProblem(resp config.ErrorResponse) Problem {
+	if resp.Code == 0 {
+		resp.Code = http.StatusGatewayTimeout
+	}
+	if resp.Msg == "" {
+		resp.Msg = "Gateway Timeout"
+	}
+	return problemFrom(resp, StepTimeout, "")
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
+	"github.com/raywall/fast-service-toolkit/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// Adapter que só retorna quando o contexto expira
+	enrichment.Register(enrichment.NewAdapter("test_block", nil, func(ctx context.Context, req enrichment.Request) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+}
+
+func deadlineConfig(required bool) *config.ServiceConfig {
+	return &config.ServiceConfig{
+		Service: config.ServiceDetails{
+			Name:      "deadline",
+			Timeout:   "50ms",
+			OnTimeout: config.ErrorResponse{Code: 503, Msg: "Tempo esgotado"},
+		},
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{
+							"name":     "slow",
+							"type":     "test_block",
+							"timeout":  "5s", // O prazo da requisição prevalece sobre o da source
+							"required": required,
+							"on_error": map[string]interface{}{"code": 500, "msg": "falhou"},
+						},
+					},
+				},
+			},
+		},
+		Steps: &config.StepsConf{
+			Output: config.OutputStep{
+				StatusCode: 200,
+				Body:       map[string]interface{}{"ok": "true"},
+			},
+		},
+	}
+}
+
+func TestExecute_OnTimeout(t *testing.T) {
+	for _, required := range []bool{true, false} {
+		svc, err := NewServiceEngine(deadlineConfig(required), "memory")
+		if err != nil {
+			t.Fatalf("Erro init engine: %v", err)
+		}
+
+		start := time.Now()
+		code, resp, _, err := svc.Execute(context.Background(), []byte(`{}`))
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), time.Second, "A source deveria receber apenas o prazo restante")
+		assert.Equal(t, 503, code, "required=%v", required)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503,"step":"timeout","detail":"Tempo esgotado"}`, string(resp))
+	}
+}
+
+func TestExecute_OnTimeoutDefault(t *testing.T) {
+	cfg := deadlineConfig(false)
+	cfg.Service.OnTimeout = config.ErrorResponse{}
+
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	code, _, _, _ := svc.Execute(context.Background(), []byte(`{}`))
+	assert.Equal(t, 504, code)
+}
+
+// slowProvider atrasa o registro das métricas até depois do prazo da requisição.
+type slowProvider struct{ delay time.Duration }
+
+func (s slowProvider) Count(name string, value float64, tags []string) error {
+	time.Sleep(s.delay)
+	return nil
+}
+func (s slowProvider) Gauge(name string, value float64, tags []string) error     { return nil }
+func (s slowProvider) Histogram(name string, value float64, tags []string) error { return nil }
+
+func TestExecute_OnTimeoutKeepsCompletedResponse(t *testing.T) {
+	cfg := deadlineConfig(false)
+	cfg.Middlewares = nil
+	cfg.Steps.Output.Metrics = []config.MetricRegistrationRule{{MetricID: "hits", Value: "1"}}
+
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	svc.MetricProcessor = metrics.NewProcessor(
+		[]config.CustomMetricDefinition{{ID: "hits", Name: "hits", Type: "count"}},
+		slowProvider{delay: 100 * time.Millisecond},
+		svc.RuleManager,
+	)
+
+	code, resp, _, err := svc.Execute(context.Background(), []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code, "Métricas atrasadas não devem trocar a resposta por on_timeout")
+	assert.JSONEq(t, `{"ok":"true"}`, string(resp))
+}
@@ -0,0 +1,359 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// Estratégias de execução das fontes de um middleware enrichment.
+const (
+	StrategyParallel   = "parallel"
+	StrategySequential = "sequential"
+	StrategyDAG        = "dag"
+)
+
+// SourceError indica a falha de uma source que aborta o enrichment
+// (source obrigatória ou middleware com stop_on_error).
+type SourceError struct {
+	Source   string
+	Err      error
+	Response config.ErrorResponse
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("falha na source '%s': %v", e.Source, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// enrichmentRun guarda o estado compartilhado de uma execução de enrichment.
+// O lock protege o mapa 'detection', lido pelas expressões CEL enquanto outras fontes escrevem.
+type enrichmentRun struct {
+	mu          sync.RWMutex
+	rules       *rules.RuleManager // Resolve params, headers e fallbacks
+	detection   map[string]interface{}
+	failed      map[string]bool
+	stopOnError bool
+	fatal       error
+	cancel      context.CancelFunc
+}
+
+func (r *enrichmentRun) markFailed(name string) {
+	r.mu.Lock()
+	r.failed[name] = true
+	r.mu.Unlock()
+}
+
+func (r *enrichmentRun) failedDependency(deps []string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, dep := range deps {
+		if r.failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+func (r *enrichmentRun) store(name string, value interface{}) {
+	r.mu.Lock()
+	r.detection[name] = value
+	r.mu.Unlock()
+}
+
+// abort registra a primeira falha fatal e cancela as chamadas em andamento.
+func (r *enrichmentRun) abort(err error) {
+	r.mu.Lock()
+	if r.fatal == nil {
+		r.fatal = err
+	}
+	r.mu.Unlock()
+	r.cancel()
+}
+
+func (r *enrichmentRun) aborted() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fatal != nil
+}
+
+func (se *ServiceEngine) executeEnrichmentMiddleware(ctx context.Context, rm *rules.RuleManager, mwConf config.MiddlewareConf, execCtx map[string]interface{}) error {
+	var eConfig EnrichmentConfig
+	if err := decodeConfig(mwConf.Config, &eConfig); err != nil {
+		return fmt.Errorf("configuração enrichment inválida: %w", err)
+	}
+
+	order, err := ResolveEnrichmentOrder(eConfig)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	run := &enrichmentRun{
+		rules:       rm,
+		detection:   execCtx["detection"].(map[string]interface{}),
+		failed:      make(map[string]bool),
+		stopOnError: eConfig.StopOnError,
+		cancel:      cancel,
+	}
+
+	strategy := eConfig.effectiveStrategy()
+	if strategy == StrategySequential {
+		for _, src := range order {
+			if run.aborted() {
+				break
+			}
+			if dep := run.failedDependency(src.DependsOn); dep != "" {
+				se.handleSourceFailure(src, fmt.Errorf("dependência '%s' falhou", dep), execCtx, run)
+				continue
+			}
+			se.runEnrichmentSource(runCtx, src, execCtx, run)
+		}
+		return run.fatal
+	}
+
+	// parallel e dag: todas as fontes são disparadas juntas. No dag, cada fonte
+	// aguarda apenas as suas dependências, iniciando assim que elas chegam em 'detection'.
+	done := make(map[string]chan struct{}, len(order))
+	for _, src := range order {
+		done[src.Name] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for _, source := range order {
+		wg.Add(1)
+		go func(src EnrichmentSource) {
+			defer wg.Done()
+			defer close(done[src.Name])
+
+			if strategy == StrategyDAG {
+				for _, dep := range src.DependsOn {
+					select {
+					case <-done[dep]:
+					case <-runCtx.Done():
+					}
+				}
+				if run.aborted() {
+					return
+				}
+				if err := runCtx.Err(); err != nil {
+					se.handleSourceFailure(src, fmt.Errorf("cancelada aguardando dependências: %w", err), execCtx, run)
+					return
+				}
+				if dep := run.failedDependency(src.DependsOn); dep != "" {
+					se.handleSourceFailure(src, fmt.Errorf("dependência '%s' falhou", dep), execCtx, run)
+					return
+				}
+			}
+
+			se.runEnrichmentSource(runCtx, src, execCtx, run)
+		}(source)
+	}
+
+	wg.Wait()
+	return run.fatal
+}
+
+// runEnrichmentSource resolve os parâmetros de uma fonte, executa a chamada e grava o resultado em 'detection'.
+func (se *ServiceEngine) runEnrichmentSource(ctx context.Context, src EnrichmentSource, execCtx map[string]interface{}, run *enrichmentRun) {
+	started := time.Now()
+	run.mu.RLock()
+	resolvedParams, err := resolveParams(src.Params, execCtx, run.rules)
+	var resolvedHeaders map[string]string
+	if err == nil {
+		resolvedHeaders, err = resolveHeaders(src.Headers, execCtx, run.rules)
+	}
+	run.mu.RUnlock()
+	if err != nil {
+		se.handleSourceFailure(src, fmt.Errorf("erro resolvendo params: %w", err), execCtx, run)
+		return
+	}
+
+	policy, err := enrichment.PolicyForSource(src.CallPolicyConf, src.Params)
+	if err != nil {
+		se.handleSourceFailure(src, err, execCtx, run)
+		return
+	}
+
+	result, attempts, callErr := policy.Do(ctx, func(ctx context.Context) (interface{}, error) {
+		return enrichment.Fetch(ctx, src.Type, resolvedParams, resolvedHeaders)
+	})
+	se.recordSourceAttempts(src, attempts, callErr)
+	if tr := traceFrom(ctx); tr != nil {
+		entry := TraceEntry{Step: StepEnrichment, Kind: "source", ID: src.Name, Attempts: attempts, Error: errString(callErr)}
+		if callErr == nil {
+			entry.Result, entry.Size = result, payloadSize(result)
+		}
+		tr.add(entry, started)
+	}
+	if callErr != nil {
+		if run.aborted() {
+			// Chamada cancelada por falha fatal de outra source
+			return
+		}
+		se.handleSourceFailure(src, callErr, execCtx, run)
+		return
+	}
+
+	run.store(src.Name, result)
+}
+
+// recordSourceAttempts registra em log e métrica quantas tentativas a chamada consumiu.
+func (se *ServiceEngine) recordSourceAttempts(src EnrichmentSource, attempts int, callErr error) {
+	status := "ok"
+	if callErr != nil {
+		status = "error"
+	}
+	if attempts > 1 {
+		se.Logger.Info().Str("source", src.Name).Int("attempts", attempts).Str("status", status).Msg("Fonte de dados executada com retentativas")
+	}
+	if se.Metrics != nil {
+		tags := []string{"source:" + src.Name, "type:" + src.Type, "status:" + status}
+		if err := se.Metrics.Histogram(enrichment.MetricSourceAttempts, float64(attempts), tags); err != nil {
+			se.Logger.Debug().Err(err).Msg("Falha ao registrar métrica de tentativas")
+		}
+	}
+}
+
+// handleSourceFailure aplica a política de erro da source: fallback, falha fatal
+// (required ou stop_on_error) ou registro de null em 'detection' para sources opcionais.
+func (se *ServiceEngine) handleSourceFailure(src EnrichmentSource, cause error, execCtx map[string]interface{}, run *enrichmentRun) {
+	se.Logger.Warn().Err(cause).Str("source", src.Name).Msg("Falha na fonte de dados")
+
+	if src.Fallback != nil {
+		run.mu.RLock()
+		val, err := resolveValue(src.Fallback, execCtx, run.rules)
+		run.mu.RUnlock()
+		if err == nil {
+			run.store(src.Name, val)
+			return
+		}
+		cause = fmt.Errorf("%v (fallback inválido: %w)", cause, err)
+	}
+
+	run.markFailed(src.Name)
+
+	if src.Required || run.stopOnError {
+		resp := config.ErrorResponse{Code: 500, Msg: "Enrichment failed"}
+		if src.OnError != nil {
+			resp = *src.OnError
+		}
+		run.abort(&SourceError{Source: src.Name, Err: cause, Response: resp})
+		return
+	}
+
+	// Source opcional: a chave existe como null para que expressões possam testá-la
+	run.store(src.Name, nil)
+}
+
+// effectiveStrategy resolve a estratégia omitida: dag quando alguma fonte declara
+// depends_on, para que a dependência seja respeitada, e parallel caso contrário.
+func (c EnrichmentConfig) effectiveStrategy() string {
+	if c.Strategy != "" {
+		return c.Strategy
+	}
+	for _, src := range c.Sources {
+		if len(src.DependsOn) > 0 {
+			return StrategyDAG
+		}
+	}
+	return StrategyParallel
+}
+
+// ResolveEnrichmentOrder valida a estratégia e as dependências (depends_on) das fontes e
+// retorna a ordem de execução resolvida. A ordem é topológica e, entre fontes independentes,
+// preserva a ordem declarada. Dependências desconhecidas, ciclos e depends_on com a
+// estratégia parallel declarada são rejeitados.
+func ResolveEnrichmentOrder(eConfig EnrichmentConfig) ([]EnrichmentSource, error) {
+	switch eConfig.Strategy {
+	case "", StrategyParallel, StrategySequential, StrategyDAG:
+	default:
+		return nil, fmt.Errorf("estratégia de enrichment desconhecida: '%s'", eConfig.Strategy)
+	}
+	if eConfig.Strategy == StrategyParallel {
+		for _, src := range eConfig.Sources {
+			if len(src.DependsOn) > 0 {
+				return nil, fmt.Errorf("source '%s' declara depends_on, que a estratégia parallel não respeita; use sequential ou dag (ou omita strategy)", src.Name)
+			}
+		}
+	}
+
+	byName := make(map[string]EnrichmentSource, len(eConfig.Sources))
+	for _, src := range eConfig.Sources {
+		if _, dup := byName[src.Name]; dup {
+			return nil, fmt.Errorf("source duplicada: '%s'", src.Name)
+		}
+		byName[src.Name] = src
+	}
+
+	pending := make(map[string]int, len(eConfig.Sources))
+	for _, src := range eConfig.Sources {
+		for _, dep := range src.DependsOn {
+			if dep == src.Name {
+				return nil, fmt.Errorf("source '%s' depende de si mesma", src.Name)
+			}
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("source '%s' depende de source inexistente '%s'", src.Name, dep)
+			}
+		}
+		pending[src.Name] = len(src.DependsOn)
+	}
+
+	order := make([]EnrichmentSource, 0, len(eConfig.Sources))
+	resolved := make(map[string]bool, len(eConfig.Sources))
+	for len(order) < len(eConfig.Sources) {
+		progressed := false
+		for _, src := range eConfig.Sources {
+			if resolved[src.Name] || pending[src.Name] > 0 {
+				continue
+			}
+			resolved[src.Name] = true
+			order = append(order, src)
+			progressed = true
+			for _, other := range eConfig.Sources {
+				for _, dep := range other.DependsOn {
+					if dep == src.Name {
+						pending[other.Name]--
+					}
+				}
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, src := range eConfig.Sources {
+				if !resolved[src.Name] {
+					cycle = append(cycle, src.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependência cíclica entre sources: %v", cycle)
+		}
+	}
+	return order, nil
+}
+
+// validateEnrichmentDependencies aplica ResolveEnrichmentOrder em todos os middlewares enrichment.
+func validateEnrichmentDependencies(cfg *config.ServiceConfig) error {
+	for _, mw := range cfg.Middlewares {
+		if mw.Type != "enrichment" {
+			continue
+		}
+		var eConfig EnrichmentConfig
+		if err := decodeConfig(mw.Config, &eConfig); err != nil {
+			return fmt.Errorf("middleware '%s': configuração enrichment inválida: %w", mw.ID, err)
+		}
+		if _, err := ResolveEnrichmentOrder(eConfig); err != nil {
+			return fmt.Errorf("middleware '%s': %w", mw.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// dependentSources monta: customer -> account -> limits (cadeia de dependências)
+func dependentSources() []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"name":       "limits",
+			"type":       "fixed",
+			"depends_on": []interface{}{"account"},
+			"params": map[string]interface{}{
+				"owner": "${detection.account.id}",
+			},
+		},
+		map[string]interface{}{
+			"name": "customer",
+			"type": "fixed",
+			"params": map[string]interface{}{
+				"value": map[string]interface{}{"account_id": "ACC-1"},
+			},
+		},
+		map[string]interface{}{
+			"name":       "account",
+			"type":       "fixed",
+			"depends_on": []interface{}{"customer"},
+			"params": map[string]interface{}{
+				"id": "${detection.customer.account_id}",
+			},
+		},
+	}
+}
+
+func TestEnrichment_DependencyStrategies(t *testing.T) {
+	rm, err := rules.NewRuleManager()
+	assert.NoError(t, err)
+	se := &ServiceEngine{Logger: zerolog.Nop(), RuleManager: rm}
+
+	// Sem strategy, depends_on torna a execução um dag
+	for _, strategy := range []string{StrategySequential, StrategyDAG, ""} {
+		t.Run("strategy="+strategy, func(t *testing.T) {
+			mwConf := config.MiddlewareConf{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"strategy": strategy,
+					"sources":  dependentSources(),
+				},
+			}
+			execCtx := map[string]interface{}{
+				"detection": make(map[string]interface{}),
+				"env":       map[string]string{},
+			}
+
+			err := se.executeEnrichmentMiddleware(context.Background(), se.RuleManager, mwConf, execCtx)
+			assert.NoError(t, err)
+
+			detection := execCtx["detection"].(map[string]interface{})
+			assert.Equal(t, "ACC-1", detection["account"].(map[string]interface{})["id"])
+			assert.Equal(t, "ACC-1", detection["limits"].(map[string]interface{})["owner"])
+		})
+	}
+}
+
+func TestEnrichment_FailedDependencySkipsDependents(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+	se := &ServiceEngine{Logger: zerolog.Nop(), RuleManager: rm}
+
+	mwConf := config.MiddlewareConf{
+		Type: "enrichment",
+		ID:   "enrich",
+		Config: map[string]interface{}{
+			"strategy": StrategyDAG,
+			"sources": []interface{}{
+				map[string]interface{}{"name": "broken", "type": "unknown_type"},
+				map[string]interface{}{
+					"name":       "child",
+					"type":       "fixed",
+					"depends_on": []interface{}{"broken"},
+					"params":     map[string]interface{}{"x": "${detection.broken.id}"},
+				},
+			},
+		},
+	}
+	execCtx := map[string]interface{}{"detection": make(map[string]interface{})}
+
+	err := se.executeEnrichmentMiddleware(context.Background(), se.RuleManager, mwConf, execCtx)
+	assert.NoError(t, err)
+
+	// Sources opcionais que falham ficam como null em 'detection'
+	detection := execCtx["detection"].(map[string]interface{})
+	assert.Contains(t, detection, "broken")
+	assert.Nil(t, detection["broken"])
+	assert.Contains(t, detection, "child")
+	assert.Nil(t, detection["child"])
+}
+
+func TestEnrichment_Fallback(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+	se := &ServiceEngine{Logger: zerolog.Nop(), RuleManager: rm}
+
+	mwConf := config.MiddlewareConf{
+		Type: "enrichment",
+		ID:   "enrich",
+		Config: map[string]interface{}{
+			"stop_on_error": true,
+			"sources": []interface{}{
+				map[string]interface{}{
+					"name":     "static",
+					"type":     "unknown_type",
+					"fallback": map[string]interface{}{"score": 0},
+				},
+				map[string]interface{}{
+					"name":     "dynamic",
+					"type":     "unknown_type",
+					"fallback": "${input.default_score}",
+				},
+			},
+		},
+	}
+	execCtx := map[string]interface{}{
+		"input":     map[string]interface{}{"default_score": 300},
+		"detection": make(map[string]interface{}),
+	}
+
+	err := se.executeEnrichmentMiddleware(context.Background(), se.RuleManager, mwConf, execCtx)
+	assert.NoError(t, err)
+
+	detection := execCtx["detection"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"score": float64(0)}, detection["static"])
+	assert.EqualValues(t, 300, detection["dynamic"])
+}
+
+func TestEnrichment_RequiredAndStopOnError(t *testing.T) {
+	newCfg := func(enrichConf map[string]interface{}) *config.ServiceConfig {
+		return &config.ServiceConfig{
+			Service: config.ServiceDetails{Name: "enrich-errors", Timeout: "1s"},
+			Middlewares: []config.MiddlewareConf{
+				{Type: "enrichment", ID: "enrich", Config: enrichConf},
+			},
+			Steps: &config.StepsConf{
+				Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{"ok": "true"}},
+			},
+		}
+	}
+
+	t.Run("Source obrigatória devolve on_error", func(t *testing.T) {
+		svc, err := NewServiceEngine(newCfg(map[string]interface{}{
+			"sources": []interface{}{
+				map[string]interface{}{
+					"name":     "bureau",
+					"type":     "unknown_type",
+					"required": true,
+					"on_error": map[string]interface{}{"code": 503, "msg": "Bureau indisponível"},
+				},
+			},
+		}), "memory")
+		assert.NoError(t, err)
+
+		code, body, _, err := svc.Execute(context.Background(), []byte(`{}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 503, code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503,"step":"enrichment","rule_id":"bureau","detail":"Bureau indisponível"}`, string(body))
+	})
+
+	t.Run("stop_on_error aborta com source opcional", func(t *testing.T) {
+		svc, err := NewServiceEngine(newCfg(map[string]interface{}{
+			"stop_on_error": true,
+			"sources": []interface{}{
+				map[string]interface{}{"name": "optional", "type": "unknown_type"},
+			},
+		}), "memory")
+		assert.NoError(t, err)
+
+		code, _, _, _ := svc.Execute(context.Background(), []byte(`{}`))
+		assert.Equal(t, 500, code)
+	})
+
+	t.Run("Sem stop_on_error a requisição segue", func(t *testing.T) {
+		svc, err := NewServiceEngine(newCfg(map[string]interface{}{
+			"sources": []interface{}{
+				map[string]interface{}{"name": "optional", "type": "unknown_type"},
+			},
+		}), "memory")
+		assert.NoError(t, err)
+
+		code, _, _, _ := svc.Execute(context.Background(), []byte(`{}`))
+		assert.Equal(t, 200, code)
+	})
+}
+
+func TestResolveEnrichmentOrder(t *testing.T) {
+	src := func(name string, deps ...string) EnrichmentSource {
+		return EnrichmentSource{Name: name, Type: "fixed", DependsOn: deps}
+	}
+
+	t.Run("Ordem topológica preservando a declaração", func(t *testing.T) {
+		order, err := ResolveEnrichmentOrder(EnrichmentConfig{
+			Strategy: StrategyDAG,
+			Sources:  []EnrichmentSource{src("c", "b"), src("a"), src("b", "a"), src("d")},
+		})
+		assert.NoError(t, err)
+		var names []string
+		for _, s := range order {
+			names = append(names, s.Name)
+		}
+		assert.Equal(t, []string{"a", "b", "d", "c"}, names)
+	})
+
+	t.Run("Ciclo é rejeitado", func(t *testing.T) {
+		_, err := ResolveEnrichmentOrder(EnrichmentConfig{
+			Strategy: StrategyDAG,
+			Sources:  []EnrichmentSource{src("a", "b"), src("b", "a")},
+		})
+		assert.ErrorContains(t, err, "cíclica")
+	})
+
+	t.Run("Dependência inexistente é rejeitada", func(t *testing.T) {
+		_, err := ResolveEnrichmentOrder(EnrichmentConfig{
+			Sources: []EnrichmentSource{src("a", "ghost")},
+		})
+		assert.ErrorContains(t, err, "ghost")
+	})
+
+	t.Run("depends_on com parallel declarado é rejeitado", func(t *testing.T) {
+		_, err := ResolveEnrichmentOrder(EnrichmentConfig{
+			Strategy: StrategyParallel,
+			Sources:  []EnrichmentSource{src("a"), src("b", "a")},
+		})
+		assert.ErrorContains(t, err, "source 'b' declara depends_on")
+		assert.Equal(t, StrategyDAG, EnrichmentConfig{Sources: []EnrichmentSource{src("a"), src("b", "a")}}.effectiveStrategy())
+		assert.Equal(t, StrategyParallel, EnrichmentConfig{Sources: []EnrichmentSource{src("a")}}.effectiveStrategy())
+	})
+
+	t.Run("Estratégia desconhecida é rejeitada", func(t *testing.T) {
+		_, err := ResolveEnrichmentOrder(EnrichmentConfig{Strategy: "random"})
+		assert.Error(t, err)
+	})
+}
+
+func TestAnalyze_EnrichmentOrder(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"strategy": StrategyDAG,
+					"sources":  dependentSources(),
+				},
+			},
+		},
+		Steps: &config.StepsConf{},
+	}
+
+	report, err := Analyze(cfg)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, []string{"customer", "account", "limits"}, report.EnrichmentOrder["enrich"])
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/jsonschema"
+	"github.com/raywall/fast-service-toolkit/pkg/responder"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// ContentTypeProblem é o Content-Type das respostas de erro no formato padrão.
+const ContentTypeProblem = "application/problem+json"
+
+// Etapas do pipeline informadas em Problem.Step.
+const (
+	StepRouting       = "routing"
+	StepConfiguration = "configuration"
+	StepInput         = "input"
+	StepEnrichment    = "enrichment"
+	StepRateLimit     = "rate_limit"
+	StepAuth          = "auth"
+	StepProcessing    = "processing"
+	StepOutput        = "output"
+	StepInterceptor   = "interceptor"
+	StepTimeout       = "timeout"
+)
+
+// Problem é o modelo de erro da engine (RFC 7807). rule_id, step, correlation_id,
+// details e errors são membros de extensão que identificam onde e por que a requisição falhou.
+type Problem struct {
+	Type          string                 `json:"type"`
+	Title         string                 `json:"title"`
+	Status        int                    `json:"status"`
+	Detail        string                 `json:"detail,omitempty"`
+	Instance      string                 `json:"instance,omitempty"`
+	RuleID        string                 `json:"rule_id,omitempty"`
+	Step          string                 `json:"step,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Details       map[string]interface{} `json:"details,omitempty"`
+	Errors        []jsonschema.Violation `json:"errors,omitempty"` // Campos que violam o input.schema
+}
+
+// newProblem cria um problema com o título padrão do status HTTP.
+func newProblem(status int, step, ruleID, detail string) Problem {
+	title := http.StatusText(status)
+	if title == "" {
+		title = "Error"
+	}
+	return Problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Step:   step,
+		RuleID: ruleID,
+	}
+}
+
+// problemFrom converte um ErrorResponse declarado no YAML (on_fail, on_error, on_timeout).
+func problemFrom(resp config.ErrorResponse, step, ruleID string) Problem {
+	p := newProblem(resp.Code, step, ruleID, resp.Msg)
+	if resp.Type != "" {
+		p.Type = resp.Type
+	}
+	p.Details = resp.Details
+	return p
+}
+
+// schemaProblem descreve um payload que viola o input.schema: todas as violações vão em
+// 'errors'. A resposta segue o schema_on_fail declarado ou, sem ele, 422.
+func schemaProblem(onFail *config.ErrorResponse, violations []jsonschema.Violation) Problem {
+	p := newProblem(http.StatusUnprocessableEntity, StepInput, "schema", "Payload does not match the input schema")
+	if onFail != nil {
+		p = problemFrom(*onFail, StepInput, "schema")
+	}
+	p.Errors = violations
+	return p
+}
+
+// toMap expõe o problema ao errors.template como a variável 'error'. Todos os
+// membros estão presentes (vazios quando ausentes) para que o template não falhe.
+func (p Problem) toMap() map[string]interface{} {
+	details := p.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	violations := make([]interface{}, len(p.Errors))
+	for i, v := range p.Errors {
+		violations[i] = map[string]interface{}{"path": v.Path, "message": v.Message}
+	}
+	return map[string]interface{}{
+		"type":           p.Type,
+		"title":          p.Title,
+		"status":         p.Status,
+		"detail":         p.Detail,
+		"instance":       p.Instance,
+		"rule_id":        p.RuleID,
+		"step":           p.Step,
+		"correlation_id": p.CorrelationID,
+		"details":        details,
+		"errors":         violations,
+	}
+}
+
+// buildErrorTemplate compila service.errors.template, quando declarado.
+func buildErrorTemplate(conf config.ErrorsConf, rm *rules.RuleManager) (*responder.ResponseBuilder, error) {
+	if conf.Template == nil {
+		return nil, nil
+	}
+	contentType := conf.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	rb, err := responder.NewResponseBuilder(config.OutputStep{
+		Body:        conf.Template,
+		ContentType: contentType,
+	}, rm)
+	if err != nil {
+		return nil, fmt.Errorf("errors.template inválido: %w", err)
+	}
+	return rb, nil
+}
+
+// ErrorResponse monta uma resposta de erro no contrato do serviço. É usada pelos
+// transports para falhas anteriores à engine (rota inexistente, body ilegível).
+func (se *ServiceEngine) ErrorResponse(ctx context.Context, status int, step, detail string) (int, []byte, map[string]string) {
+	return se.renderError(ctx, se.state(), nil, newProblem(status, step, "", detail))
+}
+
+// MiddlewareErrorResponse monta a resposta de uma falha de RunMiddlewares no contrato
+// de erro do serviço, mantendo os headers da rejeição (ex: Retry-After). Falhas que não
+// são rejeições de middleware viram 500.
+func (se *ServiceEngine) MiddlewareErrorResponse(ctx context.Context, err error) (int, []byte, map[string]string) {
+	var mwErr *MiddlewareError
+	if !errors.As(err, &mwErr) {
+		return se.ErrorResponse(ctx, http.StatusInternalServerError, "", "Middleware error")
+	}
+	code, body, headers := se.renderError(ctx, se.state(), nil, mwErr.problem())
+	return code, body, mergeHeaders(mwErr.Headers, headers)
+}
+
+// problem converte a rejeição no modelo de erro da engine.
+func (e *MiddlewareError) problem() Problem {
+	return newProblem(e.Code, e.Step, e.Middleware, e.Msg)
+}
+
+// renderError completa o problema com os dados da requisição e o serializa como
+// problem+json ou, se configurado, com o errors.template do serviço.
+func (se *ServiceEngine) renderError(ctx context.Context, st engineState, execCtx map[string]interface{}, p Problem) (int, []byte, map[string]string) {
+	if corrID, ok := ctx.Value("correlation_id").(string); ok {
+		p.CorrelationID = corrID
+	}
+	if path, ok := requestInfo(ctx)["path"].(string); ok {
+		p.Instance = path
+	}
+
+	evalCtx := map[string]interface{}{
+		"header":  requestHeaders(ctx),
+		"env":     getEnvVars(),
+		"request": requestInfo(ctx),
+	}
+	for k, v := range execCtx {
+		evalCtx[k] = v
+	}
+
+	if p.Details != nil {
+		details, err := resolveParams(p.Details, evalCtx, st.rules)
+		if err != nil {
+			se.Logger.Warn().Err(err).Msg("Falha ao avaliar details do erro")
+		}
+		p.Details = details
+	}
+
+	if tmpl := st.errorTemplate; tmpl != nil {
+		evalCtx["error"] = p.toMap()
+		resp, err := tmpl.BuildFor(evalCtx, "")
+		if err == nil {
+			return p.Status, resp.Body, resp.Headers
+		}
+		se.Logger.Error().Err(err).Msg("Falha ao montar errors.template; usando problem+json")
+	}
+
+	body, _ := json.Marshal(p)
+	return p.Status, body, map[string]string{"Content-Type": ContentTypeProblem}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func errorsConfig(errs config.ErrorsConf) *config.ServiceConfig {
+	return &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "payments", Timeout: "1s", Route: "/payments", Errors: errs},
+		Steps: &config.StepsConf{
+			Input: config.InputStep{
+				Validations: []config.ValidationRule{
+					{
+						ID:   "chk_amount",
+						Expr: "input.amount > 0",
+						OnFail: config.ErrorResponse{
+							Code:    422,
+							Msg:     `Campo "amount" inválido`,
+							Type:    "https://errors.example.com/invalid-amount",
+							Details: map[string]interface{}{"received": "${input.amount}", "min": 1},
+						},
+					},
+				},
+			},
+			Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{"ok": "true"}},
+		},
+	}
+}
+
+func errorsContext() context.Context {
+	ctx := context.WithValue(context.Background(), "correlation_id", "corr-1")
+	return context.WithValue(ctx, "request_info", map[string]interface{}{"path": "/payments", "method": "POST"})
+}
+
+func TestExecute_ProblemJSON(t *testing.T) {
+	svc, err := NewServiceEngine(errorsConfig(config.ErrorsConf{}), "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	code, body, headers, err := svc.Execute(errorsContext(), []byte(`{"amount": -5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 422, code)
+	assert.Equal(t, ContentTypeProblem, headers["Content-Type"])
+	assert.JSONEq(t, `{
+		"type": "https://errors.example.com/invalid-amount",
+		"title": "Unprocessable Entity",
+		"status": 422,
+		"detail": "Campo \"amount\" inválido",
+		"instance": "/payments",
+		"rule_id": "chk_amount",
+		"step": "input",
+		"correlation_id": "corr-1",
+		"details": {"received": -5, "min": 1}
+	}`, string(body))
+}
+
+func TestExecute_ErrorsTemplate(t *testing.T) {
+	svc, err := NewServiceEngine(errorsConfig(config.ErrorsConf{
+		Template: map[string]interface{}{
+			"error": "${error.detail}",
+			"code":  "${error.rule_id}",
+			"trace": "${error.correlation_id}",
+		},
+	}), "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	code, body, headers, err := svc.Execute(errorsContext(), []byte(`{"amount": 0}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 422, code)
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.JSONEq(t, `{"error": "Campo \"amount\" inválido", "code": "chk_amount", "trace": "corr-1"}`, string(body))
+
+	// Falhas dos transports seguem o mesmo contrato
+	code, body, _ = svc.ErrorResponse(errorsContext(), 404, StepRouting, "rota não encontrada")
+	assert.Equal(t, 404, code)
+	assert.JSONEq(t, `{"error": "rota não encontrada", "code": "", "trace": "corr-1"}`, string(body))
+}
+
+func TestAnalyze_ErrorsTemplate(t *testing.T) {
+	cfg := errorsConfig(config.ErrorsConf{Template: map[string]interface{}{"error": "${error.detail +}"}})
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if len(report.Errors) != 1 || !strings.HasPrefix(report.Errors[0], "Service.Errors") {
+		t.Errorf("Esperado erro em Service.Errors, encontrados: %v", report.Errors)
+	}
+
+	_, err = NewServiceEngine(cfg, "memory")
+	assert.ErrorContains(t, err, "errors.template inválido")
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/raywall/fast-service-toolkit/pkg/codec"
+)
+
+// parseInput decodifica o body conforme o Content-Type da requisição e aplica por cima
+// os parâmetros de query string e de path injetados pelo transport ("request_params").
+// Retorna status e mensagem de erro quando o corpo é recusado (415) ou inválido (400).
+func (se *ServiceEngine) parseInput(ctx context.Context, op *operation, payload []byte) (map[string]interface{}, int, string) {
+	contentType := headerValue(requestHeaders(ctx), "Content-Type")
+
+	input := make(map[string]interface{})
+	if len(payload) > 0 {
+		if !codec.Accepted(op.contentTypes, contentType) {
+			se.Logger.Warn().Str("operation", op.id).Str("content_type", contentType).Msg("Content-Type não aceito pela rota")
+			return nil, http.StatusUnsupportedMediaType, "Unsupported Media Type"
+		}
+		decoded, err := codec.Decode(contentType, payload)
+		if err != nil {
+			se.Logger.Error().Err(err).Str("content_type", contentType).Msg("Payload inválido")
+			if codec.MediaType(contentType) == "application/json" {
+				return nil, http.StatusBadRequest, "Invalid JSON payload"
+			}
+			return nil, http.StatusBadRequest, "Invalid request body"
+		}
+		input = decoded
+	}
+
+	for k, v := range requestParams(ctx) {
+		input[k] = v
+	}
+	return input, 0, ""
+}
+
+// requestParams recupera query string e path params injetados pelo transport.
+func requestParams(ctx context.Context) map[string]string {
+	if p, ok := ctx.Value("request_params").(map[string]string); ok {
+		return p
+	}
+	return nil
+}
+
+// headerValue busca o header ignorando maiúsculas/minúsculas (o API Gateway pode
+// entregar os nomes em minúsculo).
+func headerValue(headers map[string]string, name string) string {
+	if v, ok := headers[name]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func withHeaders(contentType string, params map[string]string) context.Context {
+	ctx := context.WithValue(context.Background(), "request_headers", map[string]string{"content-type": contentType})
+	return context.WithValue(ctx, "request_params", params)
+}
+
+func TestExecute_ContentTypes(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "callbacks", Timeout: "1s", Route: "/callback"},
+		Steps:   outputBody(map[string]interface{}{"status": "${input.status}", "id": "${input.id}"}),
+		Operations: []config.OperationConf{
+			{
+				ID:           "legacy_xml",
+				Route:        "/legacy",
+				ContentTypes: []string{"application/xml", "text/xml"},
+				Steps:        outputBody(map[string]interface{}{"customer": "${input.order.customer}"}),
+			},
+			{
+				ID:    "batch",
+				Route: "/batch",
+				Steps: outputBody(map[string]interface{}{"total": "${size(input._body)}"}),
+			},
+			{
+				ID:           "text",
+				Route:        "/text",
+				ContentTypes: []string{"text/*"},
+				Steps:        outputBody(map[string]interface{}{"raw": "${input._raw}"}),
+			},
+		},
+	}
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	cases := []struct {
+		name, op, contentType, body string
+		params                      map[string]string
+		wantCode                    int
+		wantBody                    string
+	}{
+		{"Formulário", DefaultOperationID, "application/x-www-form-urlencoded", "status=paid&id=1", nil, 200, `{"status":"paid","id":"1"}`},
+		{"Path/query prevalecem sobre o body", DefaultOperationID, "application/json", `{"status":"paid","id":"1"}`, map[string]string{"id": "99"}, 200, `{"status":"paid","id":"99"}`},
+		{"XML", "legacy_xml", "text/xml; charset=utf-8", "<order><customer>Ana</customer></order>", nil, 200, `{"customer":"Ana"}`},
+		{"JSON fora da lista da rota", "legacy_xml", "application/json", `{}`, nil, 415, `{"type":"about:blank","title":"Unsupported Media Type","status":415,"step":"input","detail":"Unsupported Media Type"}`},
+		{"Tipo fora da lista padrão", DefaultOperationID, "text/csv", "a,b", nil, 415, `{"type":"about:blank","title":"Unsupported Media Type","status":415,"step":"input","detail":"Unsupported Media Type"}`},
+		{"Array JSON", "batch", "application/json", `[1,2,3]`, nil, 200, `{"total":3}`},
+		{"Texto bruto", "text", "text/csv", "a,b", nil, 200, `{"raw":"a,b"}`},
+		{"JSON inválido", DefaultOperationID, "", `{invalid`, nil, 400, `{"type":"about:blank","title":"Bad Request","status":400,"step":"input","detail":"Invalid JSON payload"}`},
+		{"XML inválido", "legacy_xml", "application/xml", `<a>`, nil, 400, `{"type":"about:blank","title":"Bad Request","status":400,"step":"input","detail":"Invalid request body"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp, _, err := svc.ExecuteOperation(withHeaders(tc.contentType, tc.params), tc.op, []byte(tc.body))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantCode, code)
+			assert.JSONEq(t, tc.wantBody, string(resp))
+		})
+	}
+}
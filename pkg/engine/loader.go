@@ -6,8 +6,10 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -57,29 +59,35 @@ func NewUniversalLoader() *UniversalLoader {
 func (ul *UniversalLoader) Load(ctx context.Context, source string) (*localConfig.ServiceConfig, error) {
 	var rawData []byte
 	var err error
+	// Referências file:// relativas no YAML são resolvidas a partir do diretório do
+	// arquivo de configuração. Em fontes remotas, a partir do diretório de trabalho.
+	baseDir := ""
 
 	if strings.HasPrefix(source, "s3://") {
 		// Inicializa cliente real S3
-		cfg, _ := config.LoadDefaultConfig(ctx)
-		client := s3.NewFromConfig(cfg)
-		rawData, err = ul.loadFromS3Internal(ctx, client, source)
+		var cfg aws.Config
+		if cfg, err = config.LoadDefaultConfig(ctx); err == nil {
+			rawData, err = ul.loadFromS3Internal(ctx, s3.NewFromConfig(cfg), source)
+		}
 
 	} else if strings.HasPrefix(source, "dynamodb://") {
 		// Inicializa cliente real DynamoDB
-		cfg, _ := config.LoadDefaultConfig(ctx)
-		client := dynamodb.NewFromConfig(cfg)
-		rawData, err = ul.loadFromDynamoDBInternal(ctx, client, source)
+		var cfg aws.Config
+		if cfg, err = config.LoadDefaultConfig(ctx); err == nil {
+			rawData, err = ul.loadFromDynamoDBInternal(ctx, dynamodb.NewFromConfig(cfg), source)
+		}
 
 	} else {
 		// Default: Arquivo Local
 		rawData, err = ul.loadFromFile(source)
+		baseDir = filepath.Dir(strings.TrimPrefix(source, "file://"))
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("falha leitura config (%s): %w", source, err)
 	}
 
-	return ul.parseAndValidate(ctx, rawData)
+	return ul.parseAndValidate(ctx, rawData, baseDir)
 }
 
 // --- Estratégias de carregamento (métodos internos testáveis) ---
@@ -159,8 +167,9 @@ func (ul *UniversalLoader) loadFromDynamoDBInternal(ctx context.Context, client
 	return []byte(content), nil
 }
 
-// parseAndValidate agora aceita context para passar ao Injector
-func (ul *UniversalLoader) parseAndValidate(ctx context.Context, data []byte) (*localConfig.ServiceConfig, error) {
+// parseAndValidate agora aceita context para passar ao Injector. baseDir é o diretório
+// usado para resolver referências file:// relativas (vazio: diretório de trabalho).
+func (ul *UniversalLoader) parseAndValidate(ctx context.Context, data []byte, baseDir string) (*localConfig.ServiceConfig, error) {
 	var cfg localConfig.ServiceConfig
 
 	// 1. Unmarshal (YAML -> Struct)
@@ -175,12 +184,98 @@ func (ul *UniversalLoader) parseAndValidate(ctx context.Context, data []byte) (*
 		return nil, fmt.Errorf("falha na injeção de variáveis: %w", err)
 	}
 
-	// 3. Validation
+	// 3. Schemas externos (steps.input.schema: "file://..." ou "s3://...")
+	if err := ul.resolveSchemaRefs(ctx, &cfg, baseDir); err != nil {
+		return nil, err
+	}
+
+	// 4. Validation
 	if ul.validator != nil {
 		if err := ul.validator.Validate(&cfg); err != nil {
 			return nil, fmt.Errorf("validação da configuração falhou: %w", err)
 		}
 	}
 
+	// 5. Dependências entre sources de enrichment (ciclos, nomes inexistentes)
+	if err := validateEnrichmentDependencies(&cfg); err != nil {
+		return nil, fmt.Errorf("validação da configuração falhou: %w", err)
+	}
+
+	// 6. Params das sources (enrichment e resolvers GraphQL), com a posição no YAML
+	cfg.Positions = localConfig.IndexPositions(data)
+	if err := validateSourceParams(&cfg); err != nil {
+		return nil, fmt.Errorf("validação da configuração falhou: %w", err)
+	}
+
+	// 7. Rotas e referências das operações
+	if len(cfg.Operations) == 0 && cfg.Service.Route == "" {
+		return nil, fmt.Errorf("validação da configuração falhou: service.route é obrigatório quando 'operations' não é definido")
+	}
+	if err := validateOperations(&cfg); err != nil {
+		return nil, fmt.Errorf("validação da configuração falhou: %w", err)
+	}
+
 	return &cfg, nil
 }
+
+// resolveSchemaRefs substitui os schemas de entrada declarados como referência
+// (file:// ou s3://) pelo conteúdo do arquivo, em YAML ou JSON. Caminhos file://
+// relativos partem de baseDir, para que o YAML não dependa de onde o processo roda.
+func (ul *UniversalLoader) resolveSchemaRefs(ctx context.Context, cfg *localConfig.ServiceConfig, baseDir string) error {
+	resolve := func(steps *localConfig.StepsConf, where string) error {
+		if steps == nil {
+			return nil
+		}
+		ref, ok := steps.Input.Schema.(string)
+		if !ok || !(strings.HasPrefix(ref, "file://") || strings.HasPrefix(ref, "s3://")) {
+			return nil
+		}
+		schema, err := ul.loadSchemaRef(ctx, ref, baseDir)
+		if err != nil {
+			return fmt.Errorf("%sinput.schema '%s': %w", where, ref, err)
+		}
+		steps.Input.Schema = schema
+		return nil
+	}
+
+	if err := resolve(cfg.Steps, ""); err != nil {
+		return err
+	}
+	for _, op := range cfg.Operations {
+		if err := resolve(op.Steps, fmt.Sprintf("operação '%s': ", op.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ul *UniversalLoader) loadSchemaRef(ctx context.Context, ref, baseDir string) (interface{}, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(ref, "s3://") {
+		var cfg aws.Config
+		if cfg, err = config.LoadDefaultConfig(ctx); err != nil {
+			return nil, fmt.Errorf("falha ao carregar configuração AWS: %w", err)
+		}
+		data, err = ul.loadFromS3Internal(ctx, s3.NewFromConfig(cfg), ref)
+	} else {
+		path := strings.TrimPrefix(ref, "file://")
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err = ul.loadFromFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON é um subconjunto de YAML: o mesmo parser atende os dois formatos
+	var schema interface{}
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("schema malformado: %w", err)
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema vazio")
+	}
+	return schema, nil
+}
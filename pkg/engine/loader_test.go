@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/raywall/fast-service-toolkit/pkg/config"
 )
 
 // --- Mocks ---
@@ -139,3 +141,64 @@ func TestUniversalLoader_Dynamo_Internal(t *testing.T) {
 		t.Errorf("Conteúdo incorreto")
 	}
 }
+
+func TestUniversalLoader_Load_SourceParams(t *testing.T) {
+	base := `
+version: "1.0"
+service:
+  name: "params"
+  runtime: "local"
+  port: 8080
+  route: "/p"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+middlewares:
+  - type: "enrichment"
+    id: "enrich"
+    config:
+      sources:
+        - name: "customer"
+          type: "aws_dynamodb"
+          params:
+            table: "customers"
+%s
+steps:
+  output: { status_code: 200, body: {} }
+`
+	load := func(params string) (*config.ServiceConfig, error) {
+		tmpFile, _ := os.CreateTemp("", "config_*.yaml")
+		defer os.Remove(tmpFile.Name())
+		tmpFile.WriteString(fmt.Sprintf(base, params))
+		tmpFile.Close()
+		return NewUniversalLoader().Load(context.Background(), tmpFile.Name())
+	}
+
+	_, err := load(`            key: "${input.id"`)
+	if err == nil || !strings.Contains(err.Error(), "Middleware[0] Source 'customer' (linha 20, coluna 13): parâmetro 'key': interpolação sem '}' de fechamento") {
+		t.Errorf("Esperado erro de interpolação com posição, recebido: %v", err)
+	}
+
+	_, err = load(`            key: "id"`)
+	if err == nil || !strings.Contains(err.Error(), "(linha 20, coluna 13): parâmetro 'key' deve ser map, recebido 'id'") {
+		t.Errorf("Esperado erro de tipo com posição, recebido: %v", err)
+	}
+
+	// Avisos (parâmetro desconhecido) não impedem o carregamento; o analisador os reporta
+	cfg, err := load("            key: { id: \"${input.id}\" }\n            regoin: \"us-east-1\"")
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	want := "Middleware[0] Source 'customer' (linha 21, coluna 13): parâmetro desconhecido 'regoin' para source 'aws_dynamodb' (quis dizer 'region'?)"
+	found := false
+	for _, w := range report.Warnings {
+		found = found || w == want
+	}
+	if !found {
+		t.Errorf("Warning ausente: %s (recebidos: %v)", want, report.Warnings)
+	}
+}
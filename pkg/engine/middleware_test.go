@@ -63,7 +63,7 @@ func TestMiddleware_Enrichment_Execution(t *testing.T) {
 	}
 
 	// 3. Execução
-	err = se.executeEnrichmentMiddleware(context.Background(), mwConf, execCtx)
+	err = se.executeEnrichmentMiddleware(context.Background(), se.RuleManager, mwConf, execCtx)
 	assert.NoError(t, err)
 
 	// 4. Asserts
@@ -121,3 +121,22 @@ func TestMiddleware_Auth_Injection(t *testing.T) {
 
 	assert.Equal(t, "mocked-jwt-token-123", partnersScope["partner_token"])
 }
+
+func TestMiddleware_Auth_NotReady(t *testing.T) {
+	// Manager nunca iniciado: Get falha como em uma renovação que não obteve token
+	se := &ServiceEngine{
+		Config: &config.ServiceConfig{
+			Middlewares: []config.MiddlewareConf{{Type: "auth_provider", ID: "auth_partners"}},
+		},
+		AuthManagers: map[string]*auth.Manager{
+			"auth_partners": auth.NewManager(mockTokenFetcher("unused")),
+		},
+		Logger: zerolog.Nop(),
+	}
+
+	_, err := se.RunMiddlewares(context.Background())
+	code, body, headers := se.MiddlewareErrorResponse(context.Background(), err)
+	assert.Equal(t, 500, code)
+	assert.Equal(t, ContentTypeProblem, headers["Content-Type"])
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"step":"auth","rule_id":"auth_partners","detail":"Auth dependency failed"}`, string(body))
+}
@@ -1,21 +1,36 @@
 package engine
 
+import "github.com/raywall/fast-service-toolkit/pkg/config"
+
 // Estruturas auxiliares para decodificar a configuração dos middlewares "on the fly"
 type EnrichmentConfig struct {
-	Strategy string             `json:"strategy"`
-	Sources  []EnrichmentSource `json:"sources"`
+	Strategy    string             `json:"strategy"`      // Omitida: dag se houver depends_on, senão parallel
+	StopOnError bool               `json:"stop_on_error"` // Qualquer source sem fallback que falhe aborta o middleware
+	Sources     []EnrichmentSource `json:"sources"`
 }
 
 type EnrichmentSource struct {
-	Name    string                 `json:"name"`
-	Type    string                 `json:"type"`
-	Params  map[string]interface{} `json:"params"`
-	Headers map[string]string      `json:"headers"`
+	Name      string                 `json:"name"`
+	Type      string                 `json:"type"`
+	Params    map[string]interface{} `json:"params"`
+	Headers   map[string]string      `json:"headers"`
+	DependsOn []string               `json:"depends_on"` // Usado pelas estratégias sequential e dag
+	Required  bool                   `json:"required"`   // Falha da source aborta a requisição
+	Fallback  interface{}            `json:"fallback"`   // Valor estático ou "${expr}" usado quando a source falha
+	OnError   *config.ErrorResponse  `json:"on_error"`   // Resposta devolvida quando a falha aborta a requisição
+
+	// Formato da resposta (JSON Schema ou mapa compacto); tipa detection.<name> nas expressões CEL
+	ResultSchema interface{} `json:"result_schema"`
+
+	// Timeout e retentativas da chamada (timeout, retries, backoff, retry_on)
+	config.CallPolicyConf
 }
 
 type RateLimitConfig struct {
-	RPS   int `json:"rps"`
-	Burst int `json:"burst"`
+	RPS     int                   `json:"rps"`
+	Burst   int                   `json:"burst"`
+	Key     string                `json:"key"`      // Expressão CEL opcional para buckets por chamador
+	OnLimit *config.ErrorResponse `json:"on_limit"` // Resposta customizada (default: 429)
 }
 
 // AuthConfig define a configuração para obtenção de tokens
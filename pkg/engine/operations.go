@@ -0,0 +1,364 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/codec"
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/jsonschema"
+	"github.com/raywall/fast-service-toolkit/pkg/responder"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// DefaultOperationID identifica a operação derivada do formato de rota única
+// (service.route + steps), mantida para compatibilidade.
+const DefaultOperationID = "default"
+
+var (
+	// ErrRouteNotFound indica que nenhuma operação declara o path requisitado.
+	ErrRouteNotFound = errors.New("rota não encontrada")
+	// ErrMethodNotAllowed indica que o path existe, mas não para o método requisitado.
+	ErrMethodNotAllowed = errors.New("método não permitido")
+)
+
+// operation é a forma compilada de uma rota: steps, responder, middlewares e prazo.
+type operation struct {
+	id           string
+	method       string
+	route        routePattern
+	steps        *config.StepsConf
+	middlewares  []config.MiddlewareConf
+	timeout      time.Duration
+	onTimeout    config.ErrorResponse
+	contentTypes []string
+	rules        *rules.RuleManager // Ambiente CEL dos steps (tipado quando há schemas)
+	inputSchema  *jsonschema.Schema // steps.input.schema no formato JSON Schema, validado a cada requisição
+	responder    *responder.ResponseBuilder
+}
+
+// buildOperations compila a operação padrão (quando há steps no nível raiz) e as
+// operações declaradas em 'operations'.
+func buildOperations(cfg *config.ServiceConfig, rm *rules.RuleManager) (map[string]*operation, []*operation, error) {
+	byID := make(map[string]*operation)
+	var ordered []*operation
+
+	add := func(opConf config.OperationConf) error {
+		if _, dup := byID[opConf.ID]; dup {
+			return fmt.Errorf("operação duplicada: '%s'", opConf.ID)
+		}
+		op, err := compileOperation(cfg, opConf, rm)
+		if err != nil {
+			return fmt.Errorf("operação '%s': %w", opConf.ID, err)
+		}
+		byID[op.id] = op
+		ordered = append(ordered, op)
+		return nil
+	}
+
+	if cfg.Steps != nil {
+		if err := add(defaultOperation(cfg)); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, opConf := range cfg.Operations {
+		if err := add(opConf); err != nil {
+			return nil, nil, err
+		}
+	}
+	return byID, ordered, nil
+}
+
+// defaultOperation converte o formato de rota única em uma OperationConf.
+func defaultOperation(cfg *config.ServiceConfig) config.OperationConf {
+	return config.OperationConf{
+		ID:    DefaultOperationID,
+		Route: cfg.Service.Route,
+		Steps: cfg.Steps,
+	}
+}
+
+// SplitRoute separa o método opcional da rota, no formato de padrão do http.ServeMux
+// ("POST /payments"). Sem prefixo, method é vazio e a rota aceita qualquer método.
+func SplitRoute(route string) (method, path string) {
+	route = strings.TrimSpace(route)
+	if i := strings.IndexAny(route, " \t"); i > 0 && !strings.HasPrefix(route, "/") {
+		return strings.ToUpper(route[:i]), strings.TrimSpace(route[i+1:])
+	}
+	return "", route
+}
+
+// operationRoute retorna o método e o path da operação. O método pode vir do campo
+// 'method' ou do prefixo da rota; declarados nos dois, precisam coincidir.
+func operationRoute(opConf config.OperationConf) (string, string, error) {
+	method, path := SplitRoute(opConf.Route)
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return "", "", fmt.Errorf("rota inválida '%s': o path deve começar com '/'", opConf.Route)
+	}
+	for _, r := range method {
+		if r < 'A' || r > 'Z' {
+			return "", "", fmt.Errorf("rota inválida '%s': método '%s'", opConf.Route, method)
+		}
+	}
+	if opConf.Method != "" {
+		if method != "" && method != strings.ToUpper(opConf.Method) {
+			return "", "", fmt.Errorf("method '%s' diverge do método da rota '%s'", opConf.Method, opConf.Route)
+		}
+		method = strings.ToUpper(opConf.Method)
+	}
+	return method, path, nil
+}
+
+func compileOperation(cfg *config.ServiceConfig, opConf config.OperationConf, rm *rules.RuleManager) (*operation, error) {
+	mws, err := operationMiddlewares(cfg, opConf)
+	if err != nil {
+		return nil, err
+	}
+	method, path, err := operationRoute(opConf)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &operation{
+		id:           opConf.ID,
+		method:       method,
+		route:        parseRoute(path),
+		steps:        opConf.Steps,
+		middlewares:  mws,
+		timeout:      cfg.Service.GetTimeout(),
+		onTimeout:    cfg.Service.OnTimeout,
+		contentTypes: cfg.Service.ContentTypes,
+	}
+	if opConf.Timeout != "" {
+		if op.timeout, err = time.ParseDuration(opConf.Timeout); err != nil {
+			return nil, fmt.Errorf("timeout inválido '%s': %w", opConf.Timeout, err)
+		}
+	}
+	if opConf.OnTimeout != nil {
+		op.onTimeout = *opConf.OnTimeout
+	}
+	if opConf.ContentTypes != nil {
+		op.contentTypes = opConf.ContentTypes
+	}
+	op.rules = rm
+	if op.steps != nil {
+		if op.rules, err = stepsRuleManager(rm, op.steps, mws); err != nil {
+			return nil, err
+		}
+		if op.inputSchema, err = inputSchema(op.steps); err != nil {
+			return nil, err
+		}
+		if op.rules != rm {
+			if err := op.rules.PrecompileSteps(op.steps); err != nil {
+				return nil, err
+			}
+		}
+		if op.responder, err = responder.NewResponseBuilder(op.steps.Output, op.rules); err != nil {
+			return nil, fmt.Errorf("falha responder: %w", err)
+		}
+	}
+	return op, nil
+}
+
+// operationMiddlewares resolve os middlewares referenciados pela operação, na ordem
+// declarada em 'middlewares' do nível raiz. Sem referências, todos são aplicados.
+func operationMiddlewares(cfg *config.ServiceConfig, opConf config.OperationConf) ([]config.MiddlewareConf, error) {
+	if opConf.Middlewares == nil {
+		return cfg.Middlewares, nil
+	}
+	wanted := make(map[string]bool, len(opConf.Middlewares))
+	for _, id := range opConf.Middlewares {
+		wanted[id] = true
+	}
+	var mws []config.MiddlewareConf
+	for _, mw := range cfg.Middlewares {
+		if wanted[mw.ID] {
+			mws = append(mws, mw)
+			delete(wanted, mw.ID)
+		}
+	}
+	for id := range wanted {
+		return nil, fmt.Errorf("middleware inexistente: '%s'", id)
+	}
+	return mws, nil
+}
+
+// validateOperations verifica IDs duplicados, rotas, referências a middlewares e os
+// content_types declarados no serviço e nas operações.
+func validateOperations(cfg *config.ServiceConfig) error {
+	if err := validateContentTypes(cfg.Service.ContentTypes); err != nil {
+		return fmt.Errorf("service: %w", err)
+	}
+	hasREST := len(cfg.Operations) > 0
+	seen := make(map[string]bool)
+	if cfg.Steps != nil {
+		hasREST = true
+		seen[DefaultOperationID] = true
+		if _, _, err := operationRoute(defaultOperation(cfg)); err != nil {
+			return fmt.Errorf("service.route: %w", err)
+		}
+	}
+	// O endpoint GraphQL é registrado à parte; em "/" ele capturaria todas as rotas REST
+	if cfg.GraphQL.Enabled && hasREST && strings.TrimSpace(cfg.GraphQL.Route) == "/" {
+		return fmt.Errorf("graphql.route '/' conflita com as rotas REST; use um path próprio (ex: /graphql)")
+	}
+	for _, opConf := range cfg.Operations {
+		if seen[opConf.ID] {
+			return fmt.Errorf("operação duplicada: '%s'", opConf.ID)
+		}
+		seen[opConf.ID] = true
+		if _, _, err := operationRoute(opConf); err != nil {
+			return fmt.Errorf("operação '%s': %w", opConf.ID, err)
+		}
+		if _, err := operationMiddlewares(cfg, opConf); err != nil {
+			return fmt.Errorf("operação '%s': %w", opConf.ID, err)
+		}
+		if err := validateContentTypes(opConf.ContentTypes); err != nil {
+			return fmt.Errorf("operação '%s': %w", opConf.ID, err)
+		}
+	}
+	return nil
+}
+
+func validateContentTypes(contentTypes []string) error {
+	for _, ct := range contentTypes {
+		if !codec.ValidMediaRange(ct) {
+			return fmt.Errorf("content_types inválido: '%s'", ct)
+		}
+	}
+	return nil
+}
+
+// MatchOperation encontra a operação para o método e path informados e extrai os
+// parâmetros de path. Rotas com mais segmentos estáticos, exatas (sem "/" final ou
+// "{nome...}") e com método explícito têm prioridade; em caso de empate, vale a ordem
+// de declaração.
+func (se *ServiceEngine) MatchOperation(method, path string) (string, map[string]string, error) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	var (
+		best       *operation
+		bestParams map[string]string
+		bestScore  = -1
+		pathFound  bool
+	)
+	for _, op := range se.operationOrder {
+		params, ok := op.route.match(path)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if op.method != "" && op.method != strings.ToUpper(method) {
+			continue
+		}
+		score := op.route.static * 4
+		if !op.route.subtree {
+			score += 2
+		}
+		if op.method != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestParams, bestScore = op, params, score
+		}
+	}
+
+	if best == nil {
+		if pathFound {
+			return "", nil, ErrMethodNotAllowed
+		}
+		return "", nil, ErrRouteNotFound
+	}
+	return best.id, bestParams, nil
+}
+
+// ServesPath indica se alguma operação REST atende o path, em qualquer método,
+// considerando parâmetros ({id}) e subárvores ("/", {rest...}).
+func (se *ServiceEngine) ServesPath(path string) bool {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	for _, op := range se.operationOrder {
+		if _, ok := op.route.match(path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasOperations indica se há alguma operação REST configurada.
+func (se *ServiceEngine) HasOperations() bool {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return len(se.operationOrder) > 0
+}
+
+// RouteStatus converte erros de roteamento em status HTTP.
+func RouteStatus(err error) int {
+	if errors.Is(err, ErrMethodNotAllowed) {
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusNotFound
+}
+
+// routePattern é um path com segmentos estáticos, parâmetros "{nome}" e, no
+// último segmento, o curinga "{nome...}" que captura o restante do path. Como no
+// http.ServeMux, uma rota terminada em "/" casa com toda a subárvore ("/" casa com tudo).
+type routePattern struct {
+	segments []string
+	static   int
+	subtree  bool // Termina em "/" ou "{nome...}"
+}
+
+func parseRoute(route string) routePattern {
+	p := routePattern{subtree: strings.HasSuffix(route, "/")}
+	p.segments = splitPath(strings.TrimSuffix(route, "/"))
+	for _, seg := range p.segments {
+		if !strings.HasPrefix(seg, "{") {
+			p.static++
+		} else if strings.HasSuffix(seg, "...}") {
+			p.subtree = true
+		}
+	}
+	return p
+}
+
+func (p routePattern) match(path string) (map[string]string, bool) {
+	parts := splitPath(path)
+	params := make(map[string]string)
+
+	for i, seg := range p.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}") {
+			params[seg[1:len(seg)-4]] = strings.Join(parts[min(i, len(parts)):], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = parts[i]
+			continue
+		}
+		if seg != parts[i] {
+			return nil, false
+		}
+	}
+	if len(parts) != len(p.segments) && !(p.subtree && len(parts) > len(p.segments)) {
+		return nil, false
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func outputBody(body map[string]interface{}) *config.StepsConf {
+	return &config.StepsConf{
+		Output: config.OutputStep{StatusCode: 200, Body: body},
+	}
+}
+
+func operationsConfig() *config.ServiceConfig {
+	return &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "customers", Timeout: "1s"},
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{
+							"name":   "profile",
+							"type":   "fixed",
+							"params": map[string]interface{}{"value": map[string]interface{}{"tier": "gold"}},
+						},
+					},
+				},
+			},
+		},
+		Operations: []config.OperationConf{
+			{
+				ID:     "get_customer",
+				Method: "GET",
+				Route:  "/customers/{id}",
+				Steps:  outputBody(map[string]interface{}{"id": "${input.id}", "tier": "${detection.profile.tier}"}),
+			},
+			{
+				ID:     "get_me",
+				Method: "get",
+				Route:  "/customers/me",
+				Steps:  outputBody(map[string]interface{}{"id": "me"}),
+			},
+			{
+				ID:          "create_customer",
+				Method:      "POST",
+				Route:       "/customers",
+				Middlewares: []string{}, // Nenhum middleware
+				Steps:       outputBody(map[string]interface{}{"enriched": "${size(detection)}"}),
+			},
+			{
+				ID:    "files",
+				Route: "/files/{path...}",
+				Steps: outputBody(map[string]interface{}{"path": "${input.path}"}),
+			},
+		},
+	}
+}
+
+func TestMatchOperation(t *testing.T) {
+	svc, err := NewServiceEngine(operationsConfig(), "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	cases := []struct {
+		method, path string
+		wantID       string
+		wantParams   map[string]string
+		wantErr      error
+	}{
+		{"GET", "/customers/42", "get_customer", map[string]string{"id": "42"}, nil},
+		{"GET", "/customers/me", "get_me", map[string]string{}, nil}, // Segmento estático vence o parâmetro
+		{"POST", "/customers", "create_customer", map[string]string{}, nil},
+		{"DELETE", "/files/a/b/c.txt", "files", map[string]string{"path": "a/b/c.txt"}, nil},
+		{"GET", "/files", "files", map[string]string{"path": ""}, nil},
+		{"DELETE", "/customers/42", "", nil, ErrMethodNotAllowed},
+		{"GET", "/orders", "", nil, ErrRouteNotFound},
+		{"GET", "/customers/42/extra", "", nil, ErrRouteNotFound},
+	}
+	for _, tc := range cases {
+		id, params, err := svc.MatchOperation(tc.method, tc.path)
+		if tc.wantErr != nil {
+			assert.ErrorIs(t, err, tc.wantErr, "%s %s", tc.method, tc.path)
+			continue
+		}
+		assert.NoError(t, err, "%s %s", tc.method, tc.path)
+		assert.Equal(t, tc.wantID, id, "%s %s", tc.method, tc.path)
+		assert.Equal(t, tc.wantParams, params, "%s %s", tc.method, tc.path)
+	}
+
+	assert.Equal(t, 405, RouteStatus(ErrMethodNotAllowed))
+	assert.Equal(t, 404, RouteStatus(ErrRouteNotFound))
+}
+
+func TestMatchOperation_RouteFormats(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "payments", Timeout: "1s", Route: "POST /payments"},
+		Steps:   outputBody(nil),
+		Operations: []config.OperationConf{
+			{ID: "api", Route: "/api/", Steps: outputBody(nil)},
+			{ID: "api_item", Route: "/api/{id}", Steps: outputBody(nil)},
+			{ID: "api_status", Route: "GET /api/status", Steps: outputBody(nil)},
+		},
+	}
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	cases := []struct {
+		method, path string
+		wantID       string
+		wantErr      error
+	}{
+		{"POST", "/payments", DefaultOperationID, nil}, // Método no prefixo da rota única
+		{"GET", "/payments", "", ErrMethodNotAllowed},
+		{"GET", "/api/status", "api_status", nil},
+		{"GET", "/api/42", "api_item", nil}, // Rota exata vence a subárvore
+		{"PUT", "/api/a/b/c", "api", nil},   // "/" final casa com a subárvore
+		{"DELETE", "/api/", "api", nil},
+		{"GET", "/other", "", ErrRouteNotFound},
+	}
+	for _, tc := range cases {
+		id, _, err := svc.MatchOperation(tc.method, tc.path)
+		if tc.wantErr != nil {
+			assert.ErrorIs(t, err, tc.wantErr, "%s %s", tc.method, tc.path)
+			continue
+		}
+		assert.NoError(t, err, "%s %s", tc.method, tc.path)
+		assert.Equal(t, tc.wantID, id, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestExecuteOperation(t *testing.T) {
+	svc, err := NewServiceEngine(operationsConfig(), "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("Steps da operação com middlewares do serviço", func(t *testing.T) {
+		code, resp, _, err := svc.ExecuteOperation(ctx, "get_customer", []byte(`{"id":"42"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, code)
+		assert.JSONEq(t, `{"id":"42","tier":"gold"}`, string(resp))
+	})
+
+	t.Run("Lista vazia de middlewares desativa o enrichment", func(t *testing.T) {
+		code, resp, _, err := svc.ExecuteOperation(ctx, "create_customer", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, code)
+
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(resp, &body))
+		assert.EqualValues(t, 0, body["enriched"])
+	})
+
+	t.Run("Operação inexistente", func(t *testing.T) {
+		code, _, _, _ := svc.ExecuteOperation(ctx, "ghost", nil)
+		assert.Equal(t, 404, code)
+	})
+
+	t.Run("Sem formato de rota única não há operação padrão", func(t *testing.T) {
+		code, _, _, _ := svc.Execute(ctx, nil)
+		assert.Equal(t, 500, code)
+	})
+}
+
+func TestExecuteOperation_Timeout(t *testing.T) {
+	cfg := deadlineConfig(true)
+	cfg.Service.Timeout = "5s"
+	cfg.Service.Route = "/slow"
+	cfg.Operations = []config.OperationConf{
+		{
+			ID:        "fast_fail",
+			Route:     "/fast",
+			Timeout:   "30ms",
+			OnTimeout: &config.ErrorResponse{Code: 504, Msg: "Operação expirou"},
+			Steps:     cfg.Steps,
+		},
+	}
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	start := time.Now()
+	code, resp, _, _ := svc.ExecuteOperation(context.Background(), "fast_fail", nil)
+	assert.Equal(t, 504, code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Gateway Timeout","status":504,"step":"timeout","detail":"Operação expirou"}`, string(resp))
+	assert.Less(t, time.Since(start), time.Second, "O timeout da operação deve prevalecer sobre o do serviço")
+}
+
+func TestValidateOperations(t *testing.T) {
+	base := func() *config.ServiceConfig {
+		cfg := operationsConfig()
+		cfg.Service.Route = "/legacy"
+		return cfg
+	}
+
+	cfg := base()
+	assert.NoError(t, validateOperations(cfg))
+
+	cfg = base()
+	cfg.Operations = append(cfg.Operations, config.OperationConf{ID: "get_me", Route: "/x", Steps: outputBody(nil)})
+	assert.ErrorContains(t, validateOperations(cfg), "duplicada")
+
+	cfg = base()
+	cfg.Steps = outputBody(nil)
+	cfg.Operations[0].ID = DefaultOperationID
+	assert.ErrorContains(t, validateOperations(cfg), "duplicada")
+
+	cfg = base()
+	cfg.Operations[0].Middlewares = []string{"auth"}
+	assert.ErrorContains(t, validateOperations(cfg), "middleware inexistente: 'auth'")
+
+	cfg = base()
+	cfg.Operations[1].ContentTypes = []string{"xml"}
+	assert.ErrorContains(t, validateOperations(cfg), "content_types inválido: 'xml'")
+
+	cfg = base()
+	cfg.Operations[0].Route = "POST /customers/{id}"
+	assert.ErrorContains(t, validateOperations(cfg), "method 'GET' diverge do método da rota")
+
+	cfg = base()
+	cfg.Operations[0].Route = "customers/{id}"
+	assert.ErrorContains(t, validateOperations(cfg), "o path deve começar com '/'")
+
+	cfg = base()
+	cfg.GraphQL = config.GraphQLConf{Enabled: true, Route: "/"}
+	assert.ErrorContains(t, validateOperations(cfg), "graphql.route '/' conflita com as rotas REST")
+}
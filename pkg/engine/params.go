@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// sourceRef é uma source declarada na configuração, com o caminho do seu nó no YAML.
+type sourceRef struct {
+	where       string // Rótulo usado nas mensagens (ex: "Middleware[0] Source 'bureau'")
+	path        string // Ex: "middlewares[0].config.sources[1]" ou "graphql.query.user.source"
+	srcType     string
+	params      map[string]interface{}
+	expressions bool // Params de resolvers GraphQL podem ser expressões CEL sem "${...}"
+}
+
+// declaredSources lista as sources dos middlewares enrichment e, com GraphQL habilitado,
+// dos resolvers. Middlewares com configuração inválida são ignorados (já reportados).
+func declaredSources(cfg *config.ServiceConfig) []sourceRef {
+	var refs []sourceRef
+	for i, mw := range cfg.Middlewares {
+		if mw.Type != "enrichment" {
+			continue
+		}
+		var eConf EnrichmentConfig
+		if err := decodeConfig(mw.Config, &eConf); err != nil {
+			continue
+		}
+		for j, src := range eConf.Sources {
+			refs = append(refs, sourceRef{
+				where:   fmt.Sprintf("Middleware[%d] Source '%s'", i, src.Name),
+				path:    fmt.Sprintf("middlewares[%d].config.sources[%d]", i, j),
+				srcType: src.Type,
+				params:  src.Params,
+			})
+		}
+	}
+
+	if !cfg.GraphQL.Enabled {
+		return refs
+	}
+	add := func(scope, path string, fields map[string]config.GQLField) {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if src := fields[name].Source; src != nil {
+				refs = append(refs, sourceRef{
+					where:       fmt.Sprintf("GraphQL.%s.%s", scope, name),
+					path:        fmt.Sprintf("%s.%s.source", path, name),
+					srcType:     src.Type,
+					params:      src.Params,
+					expressions: true,
+				})
+			}
+		}
+	}
+	typeNames := make([]string, 0, len(cfg.GraphQL.Types))
+	for name := range cfg.GraphQL.Types {
+		typeNames = append(typeNames, name)
+	}
+	sort.Strings(typeNames)
+	for _, name := range typeNames {
+		add("Types."+name, "graphql.types."+name+".fields", cfg.GraphQL.Types[name].Fields)
+	}
+	add("Query", "graphql.query", cfg.GraphQL.Query)
+	add("Mutation", "graphql.mutation", cfg.GraphQL.Mutation)
+	return refs
+}
+
+// checkSourceParams confere os params da source contra o schema do adapter e, com rm,
+// a sintaxe CEL das interpolações. As mensagens apontam a linha e a coluna do YAML.
+func checkSourceParams(cfg *config.ServiceConfig, ref sourceRef, rm *rules.RuleManager) (errs, warnings []string) {
+	at := func(param string) string {
+		path := ref.path + ".params"
+		if param != "" {
+			path += "." + param
+		}
+		return located(cfg, ref.where, path)
+	}
+
+	for _, issue := range enrichment.CheckParams(ref.srcType, ref.params, ref.expressions) {
+		msg := fmt.Sprintf("%s: %s", at(issue.Param), issue.Message)
+		if issue.Warning {
+			warnings = append(warnings, msg)
+		} else {
+			errs = append(errs, msg)
+		}
+	}
+	if rm == nil {
+		return errs, warnings
+	}
+
+	names := make([]string, 0, len(ref.params))
+	for name := range ref.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		walkInterpolations(ref.params[name], func(expr string) {
+			if _, err := rm.References(expr); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: parâmetro '%s': %v", at(name), name, err))
+			}
+		})
+	}
+	return errs, warnings
+}
+
+// located acrescenta ao rótulo a posição do caminho no YAML, quando conhecida.
+func located(cfg *config.ServiceConfig, where, path string) string {
+	if pos, ok := cfg.Positions.Lookup(path); ok {
+		return fmt.Sprintf("%s (%s)", where, pos)
+	}
+	return where
+}
+
+// validateSourceParams é a checagem de params feita pelo loader: apenas erros, que
+// impedem a execução. Os avisos ficam para o analisador (toolkit validate).
+func validateSourceParams(cfg *config.ServiceConfig) error {
+	var errs []string
+	for _, ref := range declaredSources(cfg) {
+		refErrs, _ := checkSourceParams(cfg, ref, nil)
+		errs = append(errs, refErrs...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("params de sources inválidos:\n- %s", strings.Join(errs, "\n- "))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/ratelimit"
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// MiddlewareError representa uma rejeição de middleware com a resposta a ser devolvida ao cliente.
+type MiddlewareError struct {
+	Code       int
+	Msg        string
+	Step       string // Etapa informada em Problem.Step (ex: rate_limit, auth)
+	Middleware string // ID do middleware, informado em Problem.RuleID
+	Headers    map[string]string
+}
+
+func (e *MiddlewareError) Error() string {
+	return fmt.Sprintf("middleware rejeitou a requisição (%d): %s", e.Code, e.Msg)
+}
+
+// rateLimiter agrupa a configuração decodificada e o estado de um middleware rate_limit.
+type rateLimiter struct {
+	conf    RateLimitConfig
+	limiter *ratelimit.Limiter
+}
+
+// buildRateLimiters decodifica os middlewares rate_limit e cria seus limitadores.
+func buildRateLimiters(cfg *config.ServiceConfig) (map[string]*rateLimiter, error) {
+	limiters := make(map[string]*rateLimiter)
+	for _, mw := range cfg.Middlewares {
+		if mw.Type != "rate_limit" {
+			continue
+		}
+		var rlConf RateLimitConfig
+		if err := decodeConfig(mw.Config, &rlConf); err != nil {
+			return nil, fmt.Errorf("erro config rate_limit '%s': %w", mw.ID, err)
+		}
+		if rlConf.RPS <= 0 {
+			return nil, fmt.Errorf("rate_limit '%s': rps deve ser maior que zero", mw.ID)
+		}
+		limiters[mw.ID] = &rateLimiter{
+			conf:    rlConf,
+			limiter: ratelimit.New(rlConf.RPS, rlConf.Burst),
+		}
+	}
+	return limiters, nil
+}
+
+// applyRateLimit consome um token do bucket do chamador e escreve os headers X-RateLimit-*.
+// Retorna um MiddlewareError quando a requisição excede o limite.
+func (se *ServiceEngine) applyRateLimit(st engineState, mw config.MiddlewareConf, evalCtx map[string]interface{}, headers map[string]string) *MiddlewareError {
+	rl, ok := st.rateLimiters[mw.ID]
+	if !ok {
+		return nil
+	}
+
+	key := ""
+	if rl.conf.Key != "" {
+		val, err := st.rules.EvaluateValue(rl.conf.Key, evalCtx)
+		if err != nil {
+			// Sem chave resolvível, o chamador cai no bucket global
+			se.Logger.Warn().Err(err).Str("mw_id", mw.ID).Msg("Falha ao avaliar chave do rate limit")
+		} else if val != nil {
+			key = toString(val)
+		}
+	}
+
+	d := rl.limiter.Allow(key)
+	headers[HeaderRateLimitLimit] = strconv.Itoa(d.Limit)
+	headers[HeaderRateLimitRemaining] = strconv.Itoa(d.Remaining)
+	headers[HeaderRateLimitReset] = strconv.Itoa(int(math.Ceil(d.Reset.Seconds())))
+
+	if d.Allowed {
+		return nil
+	}
+
+	headers[HeaderRetryAfter] = strconv.Itoa(int(math.Max(1, math.Ceil(d.RetryAfter.Seconds()))))
+
+	rejection := &MiddlewareError{
+		Code:       http.StatusTooManyRequests,
+		Msg:        "Too Many Requests",
+		Step:       StepRateLimit,
+		Middleware: mw.ID,
+		Headers:    headers,
+	}
+	if rl.conf.OnLimit != nil {
+		if rl.conf.OnLimit.Code != 0 {
+			rejection.Code = rl.conf.OnLimit.Code
+		}
+		if rl.conf.OnLimit.Msg != "" {
+			rejection.Msg = rl.conf.OnLimit.Msg
+		}
+	}
+	return rejection
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRateLimitedConfig(rlConf map[string]interface{}) *config.ServiceConfig {
+	return &config.ServiceConfig{
+		Service: config.ServiceDetails{
+			Name:    "rl-test",
+			Timeout: "1s",
+			Logging: config.LoggingConf{Enabled: false},
+		},
+		Middlewares: []config.MiddlewareConf{
+			{Type: "rate_limit", ID: "ddos_protection", Config: rlConf},
+		},
+		Steps: &config.StepsConf{
+			Output: config.OutputStep{
+				StatusCode: 200,
+				Body:       map[string]interface{}{"ok": "true"},
+			},
+		},
+	}
+}
+
+func TestRateLimit_Execute(t *testing.T) {
+	svc, err := NewServiceEngine(newRateLimitedConfig(map[string]interface{}{
+		"rps":   1,
+		"burst": 1,
+		"key":   "header['X-Api-Key']",
+		"on_limit": map[string]interface{}{
+			"code": 429,
+			"msg":  "Slow down",
+		},
+	}), "memory")
+	assert.NoError(t, err)
+
+	ctxA := context.WithValue(context.Background(), "request_headers", map[string]string{"X-Api-Key": "a"})
+	ctxB := context.WithValue(context.Background(), "request_headers", map[string]string{"X-Api-Key": "b"})
+
+	code, _, headers, err := svc.Execute(ctxA, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "1", headers[HeaderRateLimitLimit])
+	assert.Equal(t, "0", headers[HeaderRateLimitRemaining])
+
+	code, body, headers, err := svc.Execute(ctxA, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 429, code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Too Many Requests","status":429,"step":"rate_limit","rule_id":"ddos_protection","detail":"Slow down"}`, string(body))
+	assert.Equal(t, "1", headers[HeaderRetryAfter])
+
+	// Outro chamador possui seu próprio bucket
+	code, _, _, _ = svc.Execute(ctxB, []byte(`{}`))
+	assert.Equal(t, 200, code)
+}
+
+func TestRateLimit_RunMiddlewares(t *testing.T) {
+	svc, err := NewServiceEngine(newRateLimitedConfig(map[string]interface{}{
+		"rps": 1,
+	}), "memory")
+	assert.NoError(t, err)
+
+	ctx, err := svc.RunMiddlewares(context.Background())
+	assert.NoError(t, err)
+	mwHeaders, ok := ctx.Value("response_headers").(map[string]string)
+	assert.True(t, ok)
+	assert.Equal(t, "1", mwHeaders[HeaderRateLimitLimit])
+
+	_, err = svc.RunMiddlewares(context.Background())
+	var mwErr *MiddlewareError
+	assert.True(t, errors.As(err, &mwErr))
+	assert.Equal(t, 429, mwErr.Code)
+	assert.NotEmpty(t, mwErr.Headers[HeaderRetryAfter])
+
+	// Transports respondem a rejeição no contrato de erro, com os headers do limite
+	code, body, headers := svc.MiddlewareErrorResponse(context.Background(), err)
+	assert.Equal(t, 429, code)
+	assert.Equal(t, ContentTypeProblem, headers["Content-Type"])
+	assert.NotEmpty(t, headers[HeaderRetryAfter])
+	assert.Contains(t, string(body), `"step":"rate_limit"`)
+}
+
+func TestRateLimit_InvalidConfig(t *testing.T) {
+	_, err := NewServiceEngine(newRateLimitedConfig(map[string]interface{}{"rps": 0}), "memory")
+	assert.Error(t, err)
+}
+
+func TestRateLimit_BeforeInput(t *testing.T) {
+	cfg := newRateLimitedConfig(map[string]interface{}{"rps": 1, "burst": 1})
+	// Declarado depois do enrichment, o rate limit ainda roda antes da leitura do corpo
+	cfg.Middlewares = append([]config.MiddlewareConf{{
+		Type: "enrichment",
+		ID:   "enrich",
+		Config: map[string]interface{}{
+			"sources": []interface{}{map[string]interface{}{"name": "fixed", "type": "fixed", "params": map[string]interface{}{"value": 1}}},
+		},
+	}}, cfg.Middlewares...)
+	svc, err := NewServiceEngine(cfg, "memory")
+	assert.NoError(t, err)
+
+	code, _, _, _ := svc.Execute(context.Background(), []byte(`{}`))
+	assert.Equal(t, 200, code)
+	code, body, _, _ := svc.Execute(context.Background(), []byte(`{invalid`))
+	assert.Equal(t, 429, code, string(body))
+
+	// A chave não pode depender do payload nem de etapas posteriores
+	for _, key := range []string{"input.customer_id", "header['X-Tenant'] + detection.fixed.id"} {
+		_, err := NewServiceEngine(newRateLimitedConfig(map[string]interface{}{"rps": 1, "key": key}), "memory")
+		assert.ErrorContains(t, err, "só pode usar header, request e env", key)
+	}
+}
+
+const reloadRaceYAML = `
+version: "1.0"
+service:
+  name: "reload-race"
+  runtime: "local"
+  port: 8080
+  route: "/test"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Gateway Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+  metrics:
+    datadog:
+      enabled: false
+      custom_definitions:
+        - { id: "hits", name: "hits", type: "count" }
+middlewares:
+  - type: rate_limit
+    id: limiter
+    config: { rps: 1000, burst: 1000, key: "header['X-Api-Key']" }
+steps:
+  input: {}
+  processing: {}
+  output:
+    status_code: 200
+    body: { ok: "true" }
+    metrics:
+      - { metric_id: "hits", value: "1" }
+`
+
+// Com -race, acusa leituras dos componentes recarregáveis fora de se.mu.
+func TestRateLimit_ConcurrentReload(t *testing.T) {
+	path := writeTemp(t, reloadRaceYAML)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	svc, err := NewServiceEngine(cfg, path)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, svc.Reload())
+		}
+	}()
+
+	ctx := context.WithValue(context.Background(), "request_headers", map[string]string{"X-Api-Key": "a"})
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		code, _, _, err := svc.Execute(ctx, []byte(`{}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, code)
+	}
+}
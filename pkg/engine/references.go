@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/responder"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// refChecker confere as referências das expressões aos nomes declarados na configuração:
+// sources de enrichment (detection.<source>), output_var dos auth_provider
+// (auth.<middleware>.<var>) e variáveis criadas pelas transformações (vars.<nome>).
+type refChecker struct {
+	rm     *rules.RuleManager
+	report *ValidationReport
+	prefix string
+
+	sources   map[string]bool   // Chaves disponíveis em detection
+	auth      map[string]string // ID do auth_provider → output_var
+	pending   map[string]string // "detection.<source>" ou "auth.<id>" de middlewares declarados depois → ID do middleware
+	definedBy map[string]string // vars.<nome> → primeira transformação que a define
+	noVars    bool              // Expressões avaliadas antes das transformações (enrichment)
+
+	usedVars    map[string]bool
+	allVars     bool            // 'vars' usada inteira (ex: "${vars}" ou vars[key])
+	usedSources map[string]bool // Compartilhado entre os blocos de steps
+	seen        map[string]bool
+}
+
+func newRefChecker(rm *rules.RuleManager, report *ValidationReport, prefix string, mws []config.MiddlewareConf, usedSources map[string]bool) *refChecker {
+	c := &refChecker{
+		rm:          rm,
+		report:      report,
+		prefix:      prefix,
+		sources:     make(map[string]bool),
+		auth:        make(map[string]string),
+		pending:     make(map[string]string),
+		definedBy:   make(map[string]string),
+		usedVars:    make(map[string]bool),
+		usedSources: usedSources,
+		seen:        make(map[string]bool),
+	}
+	for _, mw := range mws {
+		switch mw.Type {
+		case "enrichment":
+			var eConf EnrichmentConfig
+			if err := decodeConfig(mw.Config, &eConf); err == nil {
+				for _, src := range eConf.Sources {
+					c.sources[src.Name] = true
+				}
+			}
+		case "auth_provider":
+			outVar, _ := mw.Config["output_var"].(string)
+			c.auth[mw.ID] = outVar
+		}
+	}
+	return c
+}
+
+// declaredAfter registra as saídas dos middlewares executados depois do que está sendo
+// conferido: existem na configuração, mas ainda estão vazias quando ele roda.
+func (c *refChecker) declaredAfter(mws []config.MiddlewareConf) {
+	for _, mw := range mws {
+		switch mw.Type {
+		case "enrichment":
+			var eConf EnrichmentConfig
+			if err := decodeConfig(mw.Config, &eConf); err == nil {
+				for _, src := range eConf.Sources {
+					c.pending["detection."+src.Name] = mw.ID
+				}
+			}
+		case "auth_provider":
+			c.pending["auth."+mw.ID] = mw.ID
+		}
+	}
+}
+
+// analyzeReferences confere as referências de um bloco de steps, seguindo a ordem do
+// pipeline: vars só existem a partir da transformação que as define.
+func analyzeReferences(report *ValidationReport, rm *rules.RuleManager, prefix string, steps *config.StepsConf, mws []config.MiddlewareConf, usedSources map[string]bool) {
+	c := newRefChecker(rm, report, prefix, mws, usedSources)
+	for _, trans := range steps.Processing.Transformations {
+		target, err := rules.ParseTarget(trans.Target)
+		if err != nil || len(target.Path) == 0 || target.Path[0].IsIndex {
+			continue
+		}
+		switch target.Root {
+		case rules.TargetVars:
+			if _, exists := c.definedBy[target.Path[0].Key]; !exists {
+				c.definedBy[target.Path[0].Key] = trans.Name
+			}
+		case rules.TargetDetection:
+			c.sources[target.Path[0].Key] = true
+		}
+	}
+
+	available := make(map[string]bool)
+	for _, rule := range steps.Input.Validations {
+		c.check(fmt.Sprintf("Input.Rule[%s]", rule.ID), rule.Expr, available)
+	}
+	for _, rule := range steps.Processing.Validations {
+		c.check(fmt.Sprintf("Processing.Validation[%s]", rule.ID), rule.Expr, available)
+	}
+	for _, trans := range steps.Processing.Transformations {
+		c.transformation(fmt.Sprintf("Processing.Transform[%s]", trans.Name), trans, available)
+		if key := varsKey(trans.Target); key != "" {
+			available[key] = true
+		}
+	}
+
+	for _, rule := range steps.Output.Validations {
+		c.check(fmt.Sprintf("Output.Validation[%s]", rule.ID), rule.Expr, available)
+	}
+	exprs := responder.Expressions(steps.Output)
+	places := make([]string, 0, len(exprs))
+	for place := range exprs {
+		places = append(places, place)
+	}
+	sort.Strings(places)
+	for _, place := range places {
+		c.check("Output."+place, exprs[place], available)
+	}
+	for _, metric := range steps.Output.Metrics {
+		c.check(fmt.Sprintf("Output.Metric[%s]", metric.MetricID), metric.Value, available)
+		for _, expr := range metric.Tags {
+			c.check(fmt.Sprintf("Output.Metric[%s]", metric.MetricID), expr, available)
+		}
+	}
+	for _, expr := range rules.Interpolations(steps.Output.Target.URL) {
+		c.check("Output.Target.URL", expr, available)
+	}
+
+	if c.allVars {
+		return
+	}
+	names := make([]string, 0, len(c.definedBy))
+	for name := range c.definedBy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !c.usedVars[name] {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s.Processing.Transform[%s]: vars.%s é definida e nunca é usada", prefix, c.definedBy[name], name))
+		}
+	}
+}
+
+// transformation confere condição, valores e, nas coleções, as transformações aninhadas,
+// que enxergam também as vars gravadas pelas anteriores no mesmo item.
+func (c *refChecker) transformation(where string, trans config.TransformationRule, available map[string]bool) {
+	for _, expr := range []string{trans.Condition, trans.ElseValue, trans.Items, trans.Initial} {
+		c.check(where, expr, available)
+	}
+	if trans.Type == "" {
+		c.check(where, trans.Value, available)
+		return
+	}
+
+	inner := make(map[string]bool, len(available))
+	for name := range available {
+		inner[name] = true
+	}
+	for _, child := range trans.Transformations {
+		c.transformation(fmt.Sprintf("%s.Transform[%s]", where, child.Name), child, inner)
+		if key := varsKey(child.Target); key != "" {
+			inner[key] = true
+		}
+	}
+	c.check(where, trans.Value, inner)
+}
+
+// check confere as referências de uma expressão. Erros de sintaxe são ignorados: já são
+// reportados pela compilação.
+func (c *refChecker) check(where, expr string, available map[string]bool) {
+	if expr == "" {
+		return
+	}
+	refs, err := c.rm.References(expr)
+	if err != nil {
+		return
+	}
+	for _, ref := range refs {
+		switch ref.Root {
+		case "vars":
+			c.checkVar(where, ref, available)
+		case "detection":
+			if len(ref.Path) == 0 {
+				for name := range c.sources {
+					c.usedSources[name] = true
+				}
+				continue
+			}
+			c.usedSources[ref.Path[0]] = true
+			if c.sources[ref.Path[0]] {
+				continue
+			}
+			if mw, later := c.pending["detection."+ref.Path[0]]; later {
+				c.fail(where, "detection.%s ainda não existe: a source pertence ao middleware '%s', declarado depois", ref.Path[0], mw)
+			} else {
+				c.fail(where, "detection.%s não corresponde a nenhuma source de enrichment", ref.Path[0])
+			}
+		case "auth":
+			if len(ref.Path) == 0 {
+				continue
+			}
+			outVar, exists := c.auth[ref.Path[0]]
+			_, later := c.pending["auth."+ref.Path[0]]
+			switch {
+			case !exists && later:
+				c.fail(where, "auth.%s ainda não existe: o middleware auth_provider é declarado depois", ref.Path[0])
+			case !exists:
+				c.fail(where, "auth.%s não corresponde a nenhum middleware auth_provider", ref.Path[0])
+			case len(ref.Path) > 1 && outVar == "":
+				c.fail(where, "auth.%s.%s não existe: o middleware não declara output_var", ref.Path[0], ref.Path[1])
+			case len(ref.Path) > 1 && ref.Path[1] != outVar:
+				c.fail(where, "auth.%s.%s não existe: o output_var do middleware é '%s'", ref.Path[0], ref.Path[1], outVar)
+			}
+		}
+	}
+}
+
+func (c *refChecker) checkVar(where string, ref rules.Reference, available map[string]bool) {
+	if len(ref.Path) == 0 {
+		c.allVars = true
+		return
+	}
+	name := ref.Path[0]
+	c.usedVars[name] = true
+	if available[name] {
+		return
+	}
+	definer, defined := c.definedBy[name]
+	switch {
+	case c.noVars:
+		c.fail(where, "vars.%s não está disponível no enrichment, executado antes das transformações", name)
+	case !defined:
+		c.fail(where, "vars.%s não é definida por nenhuma transformação", name)
+	case !ref.Test:
+		// has(vars.x) antes da definição é uma checagem legítima
+		c.fail(where, "vars.%s é usada antes da transformação '%s', que a define", name, definer)
+	}
+}
+
+func (c *refChecker) fail(where, format string, args ...interface{}) {
+	msg := fmt.Sprintf("%s.%s: %s", c.prefix, where, fmt.Sprintf(format, args...))
+	if c.seen[msg] {
+		return
+	}
+	c.seen[msg] = true
+	c.report.Errors = append(c.report.Errors, msg)
+}
+
+// analyzeSourceReferences confere as interpolações "${...}" de params, headers e
+// fallback das sources de enrichment e, se houver steps, aponta as sources sem uso.
+// Os middlewares rodam na ordem declarada: uma source só enxerga o auth e o detection
+// dos middlewares anteriores (e as sources do próprio enrichment).
+func analyzeSourceReferences(report *ValidationReport, rm *rules.RuleManager, cfg *config.ServiceConfig, usedSources map[string]bool, hasSteps bool) {
+	enrichments := make(map[int]EnrichmentConfig)
+	for i, mw := range cfg.Middlewares {
+		if mw.Type != "enrichment" {
+			continue
+		}
+		var eConf EnrichmentConfig
+		if err := decodeConfig(mw.Config, &eConf); err != nil {
+			continue // Já reportado na validação dos middlewares
+		}
+		enrichments[i] = eConf
+
+		c := newRefChecker(rm, report, fmt.Sprintf("Middleware[%d]", i), cfg.Middlewares[:i+1], usedSources)
+		c.declaredAfter(cfg.Middlewares[i+1:])
+		c.noVars = true
+		for _, src := range eConf.Sources {
+			where := fmt.Sprintf("Source[%s]", src.Name)
+			walkInterpolations([]interface{}{src.Params, src.Headers, src.Fallback}, func(expr string) {
+				c.check(where, expr, nil)
+			})
+			for _, dep := range src.DependsOn {
+				usedSources[dep] = true
+			}
+		}
+	}
+
+	if !hasSteps {
+		return
+	}
+	for i, mw := range cfg.Middlewares {
+		eConf, ok := enrichments[i]
+		if !ok {
+			continue
+		}
+		for _, src := range eConf.Sources {
+			if !usedSources[src.Name] {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Middleware[%d] Enrichment '%s': source '%s' nunca é usada", i, mw.ID, src.Name))
+			}
+		}
+	}
+}
+
+func walkInterpolations(v interface{}, fn func(string)) {
+	switch x := v.(type) {
+	case string:
+		for _, expr := range rules.Interpolations(x) {
+			fn(expr)
+		}
+	case map[string]interface{}:
+		for _, val := range x {
+			walkInterpolations(val, fn)
+		}
+	case map[interface{}]interface{}:
+		for _, val := range x {
+			walkInterpolations(val, fn)
+		}
+	case map[string]string:
+		for _, val := range x {
+			walkInterpolations(val, fn)
+		}
+	case []interface{}:
+		for _, val := range x {
+			walkInterpolations(val, fn)
+		}
+	}
+}
+
+// varsKey retorna a variável de primeiro nível gravada pelo target (vars.<nome>...).
+func varsKey(target string) string {
+	t, err := rules.ParseTarget(target)
+	if err != nil || t.Root != rules.TargetVars || len(t.Path) == 0 || t.Path[0].IsIndex {
+		return ""
+	}
+	return t.Path[0].Key
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/jsonschema"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// stepSchemas reúne os schemas que tipam o contexto CEL de uma operação: 'input' a partir
+// de steps.input.schema e 'detection' a partir do result_schema das sources dos middlewares
+// enrichment aplicados. Sem nenhum schema declarado, retorna nil (ambiente dinâmico).
+func stepSchemas(steps *config.StepsConf, mws []config.MiddlewareConf) (map[string]*rules.Schema, error) {
+	schemas := make(map[string]*rules.Schema)
+	if steps.Input.Schema != nil {
+		input, err := rules.ParseSchema(steps.Input.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("input.schema: %w", err)
+		}
+		schemas["input"] = input
+	}
+
+	// Sources sem result_schema (e destinos detection.* das transformações) ficam dinâmicos
+	sources := make(map[string]*rules.Schema)
+	typed := false
+	for _, mw := range mws {
+		if mw.Type != "enrichment" {
+			continue
+		}
+		var eConf EnrichmentConfig
+		if err := decodeConfig(mw.Config, &eConf); err != nil {
+			return nil, fmt.Errorf("middleware '%s': %w", mw.ID, err)
+		}
+		for _, src := range eConf.Sources {
+			sources[src.Name] = nil
+			if src.ResultSchema == nil {
+				continue
+			}
+			schema, err := rules.ParseSchema(src.ResultSchema)
+			if err != nil {
+				return nil, fmt.Errorf("middleware '%s' source '%s': result_schema: %w", mw.ID, src.Name, err)
+			}
+			sources[src.Name] = schema
+			typed = true
+		}
+	}
+	if typed {
+		for _, trans := range steps.Processing.Transformations {
+			target, err := rules.ParseTarget(trans.Target)
+			if err == nil && target.Root == rules.TargetDetection && !target.Path[0].IsIndex {
+				if _, ok := sources[target.Path[0].Key]; !ok {
+					sources[target.Path[0].Key] = nil
+				}
+			}
+		}
+		schemas["detection"] = rules.ObjectSchema(sources)
+	}
+
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+	return schemas, nil
+}
+
+// stepsRuleManager retorna o RuleManager das expressões da operação: o padrão (dinâmico)
+// ou, quando há schemas, um ambiente tipado próprio.
+func stepsRuleManager(rm *rules.RuleManager, steps *config.StepsConf, mws []config.MiddlewareConf) (*rules.RuleManager, error) {
+	schemas, err := stepSchemas(steps, mws)
+	if err != nil || schemas == nil {
+		return rm, err
+	}
+	typed, err := rules.NewTypedRuleManager(schemas)
+	if err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+// inputSchema compila o steps.input.schema declarado como JSON Schema ($schema ou
+// format: jsonschema). O formato compacto apenas tipa as expressões e não valida o payload.
+func inputSchema(steps *config.StepsConf) (*jsonschema.Schema, error) {
+	raw, isJSONSchema := rules.AsJSONSchema(steps.Input.Schema)
+	if !isJSONSchema {
+		return nil, nil
+	}
+	schema, err := jsonschema.Compile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("input.schema: %w", err)
+	}
+	return schema, nil
+}
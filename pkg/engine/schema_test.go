@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const schemaServiceYAML = `
+version: "1.0"
+service:
+  name: "loans"
+  runtime: "lambda"
+  route: "/loans/{id}"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+middlewares:
+  - type: "enrichment"
+    id: "enrich"
+    config:
+      sources:
+        - name: "bureau"
+          type: "fixed"
+          params: { value: { score: 720, flags: ["pep"] } }
+          result_schema:
+            format: jsonschema
+            type: object
+            properties:
+              score: { type: integer }
+              flags: { type: array, items: { type: string } }
+        - name: "legacy"
+          type: "fixed"
+          params: { value: { anything: true } }
+steps:
+  input:
+    schema:
+      id: int
+      amount: double
+      installments: int
+      items:
+        - sku: string
+          qty: int
+    validations:
+      - id: "amount"
+        expr: "input.amount > 0.0 && input.installments <= 24"
+        on_fail: { code: 400, msg: "Invalid amount" }
+  processing:
+    transformations:
+      - name: "qty"
+        type: "reduce"
+        items: "input.items"
+        initial: "0"
+        value: "acc + item.qty"
+        target: "vars.qty"
+      - name: "installment"
+        value: "input.amount / double(input.installments)"
+        target: "vars.installment"
+      - name: "tier"
+        value: "detection.bureau.score >= 700 && !('fraud' in detection.bureau.flags) ? 'gold' : 'basic'"
+        target: "vars.tier"
+  output:
+    status_code: 200
+    body:
+      id: "${input.id * 10}"
+      qty: "${vars.qty}"
+      installment: "${vars.installment}"
+      tier: "${vars.tier}"
+      legacy: "${detection.legacy.anything}"
+`
+
+func loadSchemaService(t *testing.T, yamlContent string) (*ServiceEngine, error) {
+	t.Helper()
+	tmpFile, _ := os.CreateTemp("", "schema_*.yaml")
+	defer os.Remove(tmpFile.Name())
+	_, _ = tmpFile.WriteString(yamlContent)
+	tmpFile.Close()
+
+	cfg, err := Load(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	return NewServiceEngine(cfg, tmpFile.Name())
+}
+
+func TestServiceEngine_Execute_Schemas(t *testing.T) {
+	svc, err := loadSchemaService(t, schemaServiceYAML)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	// O path param "id" chega como texto e é convertido para o int declarado
+	ctx := context.WithValue(context.Background(), "request_params", map[string]string{"id": "7"})
+	payload := `{"amount": 1200, "installments": 12, "items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 3}]}`
+	code, resp, _, err := svc.Execute(ctx, []byte(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"id": 70, "qty": 5, "installment": 100, "tier": "gold", "legacy": true}`, string(resp))
+
+	// Erros de tipo e campos inexistentes impedem o boot
+	broken := map[string]string{
+		`expr: "input.amount > 0.0 && input.installments <= 24"`: `expr: "input.ammount > 0.0"`,
+		`value: "acc + item.qty"`:                                `value: "acc + item.qtd"`,
+		`value: "input.amount / double(input.installments)"`:     `value: "input.amount / input.installments"`,
+		`detection.bureau.score >= 700`:                          `detection.bureau.score >= '700'`,
+	}
+	for from, to := range broken {
+		_, err := loadSchemaService(t, strings.Replace(schemaServiceYAML, from, to, 1))
+		assert.ErrorContains(t, err, "expressões CEL inválidas", to)
+	}
+	_, err = loadSchemaService(t, strings.Replace(schemaServiceYAML, "amount: double", "amount: money", 1))
+	assert.ErrorContains(t, err, "input.schema: '.amount': tipo desconhecido: 'money'")
+}
+
+func TestAnalyze_Schemas(t *testing.T) {
+	yamlContent := strings.NewReplacer(
+		`expr: "input.amount > 0.0 && input.installments <= 24"`, `expr: "input.ammount > 0"`,
+		`value: "acc + item.qty"`, `value: "acc + item.qtd"`,
+		`'fraud' in detection.bureau.flags`, `detection.bureau.flag`,
+	).Replace(schemaServiceYAML)
+
+	tmpFile, _ := os.CreateTemp("", "schema_*.yaml")
+	defer os.Remove(tmpFile.Name())
+	_, _ = tmpFile.WriteString(yamlContent)
+	tmpFile.Close()
+	cfg, err := Load(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	cfg.Steps.Output.Body = map[string]interface{}{"tier": "vars.tier"}
+
+	report, err := Analyze(cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	want := []string{
+		"Steps.Input.Rule[amount]: Erro de sintaxe CEL: erro de compilação CEL 'input.ammount > 0': ERROR: <input>:1:6: undefined field 'ammount'",
+		"Steps.Processing.Transform[qty]: value: erro de compilação CEL 'acc + item.qtd'",
+		"Steps.Processing.Transform[tier]: value: erro de compilação CEL",
+	}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("Esperados %d erros, encontrados %d: %v", len(want), len(report.Errors), report.Errors)
+	}
+	for i, part := range want {
+		if !strings.Contains(report.Errors[i], part) {
+			t.Errorf("Erro %d deveria conter '%s': %s", i, part, report.Errors[i])
+		}
+	}
+	assert.Contains(t, report.Errors[2], "undefined field 'flag'")
+}
+
+const payloadSchemaYAML = `
+version: "1.0"
+service:
+  name: "proposals"
+  runtime: "lambda"
+  route: "/proposals"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+steps:
+  input:
+    schema: "file://SCHEMA_PATH"
+    validations:
+      - id: "limit"
+        expr: "input.amount <= 10000.0"
+        on_fail: { code: 400, msg: "Limit exceeded" }
+  output:
+    status_code: 201
+    body:
+      amount: "${input.amount}"
+`
+
+func TestServiceEngine_Execute_InputJSONSchema(t *testing.T) {
+	schemaFile, _ := os.CreateTemp("", "proposal_*.json")
+	defer os.Remove(schemaFile.Name())
+	_, _ = schemaFile.WriteString(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"required": ["amount", "document"],
+		"properties": {
+			"amount": {"type": "number", "exclusiveMinimum": 0},
+			"document": {"type": "string", "pattern": "^[0-9]{11}$"}
+		}
+	}`)
+	schemaFile.Close()
+	yamlContent := strings.Replace(payloadSchemaYAML, "SCHEMA_PATH", schemaFile.Name(), 1)
+
+	svc, err := loadSchemaService(t, yamlContent)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	code, resp, _, err := svc.Execute(context.Background(), []byte(`{"amount": 1500, "document": "52998224725"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, code)
+	assert.JSONEq(t, `{"amount": 1500}`, string(resp))
+
+	// O schema é verificado antes das validações CEL e lista todos os campos inválidos
+	code, resp, headers, err := svc.Execute(context.Background(), []byte(`{"amount": -1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 422, code)
+	assert.Equal(t, ContentTypeProblem, headers["Content-Type"])
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Unprocessable Entity",
+		"status": 422,
+		"detail": "Payload does not match the input schema",
+		"rule_id": "schema",
+		"step": "input",
+		"errors": [
+			{"path": "$.amount", "message": "deve ser maior que 0"},
+			{"path": "$.document", "message": "campo obrigatório"}
+		]
+	}`, string(resp))
+
+	// schema_on_fail e errors.template usam o mesmo formato do on_fail
+	custom := strings.Replace(yamlContent, `    validations:`, `    schema_on_fail: { code: 400, msg: "Invalid payload", type: "https://errors.example.com/payload" }
+    validations:`, 1)
+	custom = strings.Replace(custom, `  logging:`, `  errors:
+    template:
+      message: "${error.detail}"
+      fields: "${error.errors.map(e, e.path)}"
+  logging:`, 1)
+	svc, err = loadSchemaService(t, custom)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	code, resp, _, err = svc.Execute(context.Background(), []byte(`{"amount": "abc", "document": "1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, code)
+	assert.JSONEq(t, `{"message": "Invalid payload", "fields": ["$.amount", "$.document"]}`, string(resp))
+
+	// Sem marcador, chaves como type e description são campos do formato compacto
+	compact := strings.NewReplacer(
+		`"file://`+schemaFile.Name()+`"`, `{type: string, description: string}`,
+		`input.amount <= 10000.0`, `input.type != ''`,
+		`${input.amount}`, `${input.description}`,
+	).Replace(yamlContent)
+	svc, err = loadSchemaService(t, compact)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	code, resp, _, err = svc.Execute(context.Background(), []byte(`{"type": "pj", "description": "capital de giro"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, code)
+	assert.JSONEq(t, `{"amount": "capital de giro"}`, string(resp))
+
+	// Referência inexistente ou schema inválido impedem o boot
+	_, err = Load(writeTemp(t, strings.Replace(payloadSchemaYAML, "SCHEMA_PATH", "/nao/existe.json", 1)))
+	assert.ErrorContains(t, err, "input.schema 'file:///nao/existe.json'")
+	_, err = loadSchemaService(t, strings.Replace(yamlContent, `"file://`+schemaFile.Name()+`"`, `{format: jsonschema, type: object, properties: {amount: {type: number, minimum: "zero"}}}`, 1))
+	assert.ErrorContains(t, err, "input.schema: 'properties.amount.minimum': deve ser numérico")
+}
+
+func TestLoad_SchemaRefRelativeToConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/schemas", 0o755); err != nil {
+		t.Fatalf("Erro ao criar diretório: %v", err)
+	}
+	schema := `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object", "required": ["amount"]}`
+	if err := os.WriteFile(dir+"/schemas/proposal.json", []byte(schema), 0o644); err != nil {
+		t.Fatalf("Erro ao gravar schema: %v", err)
+	}
+	path := dir + "/service.yaml"
+	content := strings.Replace(payloadSchemaYAML, "SCHEMA_PATH", "schemas/proposal.json", 1)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Erro ao gravar arquivo: %v", err)
+	}
+
+	// O diretório de trabalho não contém o schema: a referência parte do YAML
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Erro ao carregar config: %v", err)
+	}
+	assert.Equal(t, []interface{}{"amount"}, cfg.Steps.Input.Schema.(map[interface{}]interface{})["required"])
+
+	_, err = Load("file://" + path)
+	assert.NoError(t, err)
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := t.TempDir() + "/service.yaml"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Erro ao gravar arquivo: %v", err)
+	}
+	return path
+}
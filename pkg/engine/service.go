@@ -3,13 +3,19 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/raywall/fast-service-toolkit/pkg/auth"
+	"github.com/raywall/fast-service-toolkit/pkg/codec"
 	"github.com/raywall/fast-service-toolkit/pkg/config"
 	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
 	"github.com/raywall/fast-service-toolkit/pkg/graphql"
@@ -35,9 +41,42 @@ type ServiceEngine struct {
 	Responder       *responder.ResponseBuilder
 	GraphQLEngine   *graphql.GraphQLEngine
 	AuthManagers    map[string]*auth.Manager
+
+	rateLimiters   map[string]*rateLimiter
+	operations     map[string]*operation
+	operationOrder []*operation
+	errorTemplate  *responder.ResponseBuilder   // service.errors.template (nil: problem+json)
+	tracer         *tracer                      // service.trace (nil: desabilitado)
+	clients        *enrichment.Clients          // Pools Redis/SQL adquiridos pela configuração vigente
+	tokenFetchers  map[string]auth.TokenFetcher // Substitutos do token_url por auth_provider (ver WithTokenFetcher)
+
+	// Estado do encerramento gracioso (ver shutdown.go)
+	draining     atomic.Bool
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// Option ajusta a criação da engine (ver NewServiceEngine).
+type Option func(*ServiceEngine)
+
+// WithTokenFetcher substitui a busca de token do middleware auth_provider 'id' (ex: um
+// token fixo em testes). Não há equivalente no YAML: uma configuração implantada sempre
+// obtém o token pelo token_url. Vale também para os reloads da engine.
+func WithTokenFetcher(id string, fetcher auth.TokenFetcher) Option {
+	return func(se *ServiceEngine) {
+		if se.tokenFetchers == nil {
+			se.tokenFetchers = make(map[string]auth.TokenFetcher)
+		}
+		se.tokenFetchers[id] = fetcher
+	}
 }
 
-func NewServiceEngine(cfg *config.ServiceConfig, configSource string) (*ServiceEngine, error) {
+func NewServiceEngine(cfg *config.ServiceConfig, configSource string, opts ...Option) (*ServiceEngine, error) {
+	se := &ServiceEngine{}
+	for _, opt := range opts {
+		opt(se)
+	}
+
 	log := logger.Configure(cfg.Service.Logging)
 
 	metricProvider, err := observability.SetupMetrics(cfg.Service.Metrics)
@@ -49,33 +88,33 @@ func NewServiceEngine(cfg *config.ServiceConfig, configSource string) (*ServiceE
 	if err != nil {
 		return nil, fmt.Errorf("falha fatal ao iniciar RuleManager: %w", err)
 	}
+	if err := rm.Precompile(cfg); err != nil {
+		return nil, fmt.Errorf("falha ao pré-compilar regras: %w", err)
+	}
 
-	// CHECK: Só inicializa Responder se Steps existirem
+	// Operações REST (rota única legada + 'operations'), cada uma com seu Responder
+	operations, operationOrder, err := buildOperations(cfg, rm)
+	if err != nil {
+		return nil, err
+	}
 	var respBuilder *responder.ResponseBuilder
-	if cfg.Steps != nil {
-		var err error
-		respBuilder, err = responder.NewResponseBuilder(cfg.Steps.Output, rm)
-		if err != nil {
-			return nil, fmt.Errorf("falha responder: %w", err)
-		}
+	if op, ok := operations[DefaultOperationID]; ok {
+		respBuilder = op.responder
+	}
+	errorTemplate, err := buildErrorTemplate(cfg.Service.Errors, rm)
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := buildTracer(cfg.Service.Trace)
+	if err != nil {
+		return nil, err
 	}
 
 	metricProcessor := metrics.NewProcessor(cfg.Service.Metrics.Datadog.CustomDefinitions, metricProvider, rm)
 
-	authManagers := make(map[string]*auth.Manager)
-	for _, mw := range cfg.Middlewares {
-		if mw.Type == "auth_provider" {
-			var authCfg auth.AuthConfig
-			if err := decodeConfig(mw.Config, &authCfg); err != nil {
-				return nil, fmt.Errorf("erro config auth '%s': %w", mw.ID, err)
-			}
-			mgr := auth.NewOAuth2Manager(authCfg)
-			log.Info().Str("middleware_id", mw.ID).Msg("Iniciando Auth Manager...")
-			if err := mgr.Start(context.Background()); err != nil {
-				return nil, fmt.Errorf("erro fatal iniciando auth '%s': %w", mw.ID, err)
-			}
-			authManagers[mw.ID] = mgr
-		}
+	rateLimiters, err := buildRateLimiters(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	var gqlEngine *graphql.GraphQLEngine
@@ -84,67 +123,225 @@ func NewServiceEngine(cfg *config.ServiceConfig, configSource string) (*ServiceE
 		if err != nil {
 			return nil, fmt.Errorf("falha ao iniciar engine graphql: %w", err)
 		}
+		gqlEngine.Metrics = metricProvider
 	}
 
-	return &ServiceEngine{
-		ConfigSource:    configSource,
-		Config:          cfg,
-		Logger:          log,
-		Metrics:         metricProvider,
-		MetricProcessor: metricProcessor,
-		RuleManager:     rm,
-		Responder:       respBuilder,
-		GraphQLEngine:   gqlEngine,
-		AuthManagers:    authManagers,
-	}, nil
+	// Por último: os managers iniciam goroutines de renovação que não podem vazar se algo acima falhar
+	authManagers, err := startAuthManagers(cfg, se.tokenFetchers, log)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := enrichment.NewClients()
+	preloadClients(cfg, rm, clients, log)
+
+	se.ConfigSource = configSource
+	se.Config = cfg
+	se.Logger = log
+	se.Metrics = metricProvider
+	se.MetricProcessor = metricProcessor
+	se.RuleManager = rm
+	se.Responder = respBuilder
+	se.GraphQLEngine = gqlEngine
+	se.AuthManagers = authManagers
+	se.rateLimiters = rateLimiters
+	se.operations = operations
+	se.operationOrder = operationOrder
+	se.errorTemplate = errorTemplate
+	se.tracer = tracer
+	se.clients = clients
+	return se, nil
+}
+
+// engineState reúne os componentes que Reload substitui. A requisição os lê uma única vez
+// sob se.mu e segue com o mesmo retrato até o fim, mesmo que um reload ocorra no meio.
+type engineState struct {
+	rules         *rules.RuleManager
+	rateLimiters  map[string]*rateLimiter
+	authManagers  map[string]*auth.Manager
+	metrics       *metrics.Processor
+	errorTemplate *responder.ResponseBuilder
+	tracer        *tracer
+	clients       *enrichment.Clients
+}
+
+// state retorna o retrato atual dos componentes recarregáveis.
+func (se *ServiceEngine) state() engineState {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.stateLocked()
+}
+
+// stateLocked é state para quem já segura se.mu.
+func (se *ServiceEngine) stateLocked() engineState {
+	return engineState{
+		rules:         se.RuleManager,
+		rateLimiters:  se.rateLimiters,
+		authManagers:  se.AuthManagers,
+		metrics:       se.MetricProcessor,
+		errorTemplate: se.errorTemplate,
+		tracer:        se.tracer,
+		clients:       se.clients,
+	}
 }
 
+// Execute processa a requisição na operação padrão (formato de rota única).
 func (se *ServiceEngine) Execute(ctx context.Context, payload []byte) (int, []byte, map[string]string, error) {
-	// Proteção para não quebrar se Steps for nil
-	if se.Config.Steps == nil {
-		return 500, errorJSON("Configuration Error: Steps not defined"), nil, nil
+	return se.ExecuteOperation(ctx, DefaultOperationID, payload)
+}
+
+// ExecuteOperation processa a requisição seguindo os middlewares e steps da operação informada.
+func (se *ServiceEngine) ExecuteOperation(ctx context.Context, operationID string, payload []byte) (int, []byte, map[string]string, error) {
+	return se.executeOperation(ctx, operationID, func() ([]byte, error) { return payload, nil })
+}
+
+// ExecuteOperationReader é o ExecuteOperation para bodies ainda não lidos (servidor HTTP):
+// o body só é consumido depois do rate limit, e requisições excedentes não chegam a lê-lo.
+// Um body cortado por http.MaxBytesReader responde 413.
+func (se *ServiceEngine) ExecuteOperationReader(ctx context.Context, operationID string, body io.Reader) (int, []byte, map[string]string, error) {
+	return se.executeOperation(ctx, operationID, func() ([]byte, error) { return io.ReadAll(body) })
+}
+
+func (se *ServiceEngine) executeOperation(ctx context.Context, operationID string, readBody func() ([]byte, error)) (statusCode int, respBody []byte, respHeaders map[string]string, err error) {
+	se.mu.RLock()
+	op := se.operations[operationID]
+	st := se.stateLocked()
+	se.mu.RUnlock()
+
+	if op == nil || op.steps == nil {
+		if operationID == DefaultOperationID {
+			// Proteção para não quebrar se Steps for nil
+			statusCode, respBody, respHeaders = se.renderError(ctx, st, nil, newProblem(500, StepConfiguration, "", "Configuration Error: Steps not defined"))
+			return statusCode, respBody, respHeaders, nil
+		}
+		statusCode, respBody, respHeaders = se.renderError(ctx, st, nil, newProblem(404, StepRouting, "", "Operation not found"))
+		return statusCode, respBody, respHeaders, nil
+	}
+
+	// Contexto CEL da requisição; também disponível para errors.template e details
+	var execCtx map[string]interface{}
+	// timedOut indica que o prazo interrompeu o pipeline; uma resposta já montada não é
+	// substituída por on_timeout só porque as métricas terminaram depois do prazo
+	var timedOut bool
+	fail := func(p Problem) (int, []byte, map[string]string, error) {
+		if deadlineExceeded(ctx) {
+			timedOut = true
+		}
+		code, body, headers := se.renderError(ctx, st, execCtx, p)
+		return code, body, headers, nil
 	}
 
-	// 1. Parse Input
-	var inputMap map[string]interface{}
-	if len(payload) > 0 {
-		if err := json.Unmarshal(payload, &inputMap); err != nil {
-			se.Logger.Error().Err(err).Msg("JSON payload inválido")
-			return 400, errorJSON("Invalid JSON payload"), nil, nil
+	// Headers produzidos por middlewares (ex: X-RateLimit-*) acompanham qualquer resposta.
+	// Erros trazem o Content-Type do problem+json (ou do errors.template); o output, o do encoder.
+	mwHeaders := make(map[string]string)
+	defer func() {
+		respHeaders = mergeHeaders(mwHeaders, respHeaders)
+		if headerValue(respHeaders, "Content-Type") == "" {
+			respHeaders = mergeHeaders(respHeaders, map[string]string{"Content-Type": "application/json"})
+		}
+	}()
+
+	// Trace de execução (service.trace ou WithTrace); nil quando a requisição não é rastreada
+	forced := traceFrom(ctx) != nil
+	ctx, tr := st.tracer.start(ctx, op.id)
+	if tr != nil {
+		defer func() {
+			tr.finish(ctx, op.id, statusCode)
+			if !forced {
+				se.emitTrace(tr, st.tracer.conf.Output, mwHeaders)
+			}
+		}()
+	}
+
+	// Prazo total da requisição: middlewares, enrichment, validações e interceptor
+	// compartilham o mesmo orçamento, e as chamadas filhas recebem o tempo restante.
+	ctx, cancel := context.WithTimeout(ctx, op.timeout)
+	defer cancel()
+
+	// Sources redis/sql usam os pools desta geração da configuração, que um reload
+	// só libera depois do fim da requisição
+	defer st.clients.Begin()()
+	ctx = enrichment.WithClients(ctx, st.clients)
+	defer func() {
+		if timedOut {
+			se.Logger.Warn().Str("operation", op.id).Dur("timeout", op.timeout).Msg("Prazo da requisição excedido")
+			statusCode, respBody, respHeaders = se.renderError(ctx, st, execCtx, timeoutProblem(op.onTimeout))
+			err = nil
+		}
+	}()
+
+	// 0. Rate limit: antes da leitura do corpo, para que requisições excedentes não paguem
+	// o parse e a validação do payload. A chave só pode usar header, request e env.
+	rlCtx := map[string]interface{}{
+		"header":  requestHeaders(ctx),
+		"env":     getEnvVars(),
+		"request": requestInfo(ctx),
+	}
+	for _, mw := range op.middlewares {
+		if mw.Type != "rate_limit" {
+			continue
+		}
+		started := time.Now()
+		rejection := se.applyRateLimit(st, mw, rlCtx, mwHeaders)
+		tr.add(TraceEntry{Step: StepRateLimit, Kind: "middleware", ID: mw.ID, Result: rejection == nil}, started)
+		if rejection != nil {
+			return fail(rejection.problem())
 		}
-	} else {
-		inputMap = make(map[string]interface{})
 	}
 
-	inputHeaders := make(map[string]string)
-	if h, ok := ctx.Value("request_headers").(map[string]string); ok {
-		inputHeaders = h
+	// 1. Parse Input (JSON, formulário, multipart, XML ou texto, conforme o Content-Type)
+	payload, err := readBody()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fail(newProblem(http.StatusRequestEntityTooLarge, StepInput, "", "Request body too large"))
+		}
+		return fail(newProblem(http.StatusBadRequest, StepInput, "", "Invalid request body"))
+	}
+	inputMap, code, msg := se.parseInput(ctx, op, payload)
+	if code != 0 {
+		return fail(newProblem(code, StepInput, "", msg))
+	}
+
+	// 2. JSON Schema do payload (antes dos middlewares e das validações CEL)
+	if op.inputSchema != nil {
+		started := time.Now()
+		violations := op.inputSchema.Validate(inputMap)
+		tr.add(TraceEntry{Step: StepInput, Kind: "schema", ID: "schema", Result: len(violations) == 0}, started)
+		if len(violations) > 0 {
+			return fail(schemaProblem(op.steps.Input.SchemaOnFail, violations))
+		}
 	}
 
-	execCtx := map[string]interface{}{
+	execCtx = map[string]interface{}{
 		"input":     inputMap,
-		"header":    inputHeaders,
+		"header":    requestHeaders(ctx),
 		"env":       getEnvVars(),
 		"vars":      make(map[string]interface{}),
 		"detection": make(map[string]interface{}),
+		"request":   requestInfo(ctx),
 	}
 
-	// 3. Middlewares
-	for _, mw := range se.Config.Middlewares {
+	// 3. Middlewares (rate_limit já aplicado no passo 0)
+	for _, mw := range op.middlewares {
+		started := time.Now()
 		switch mw.Type {
 		case "enrichment":
-			if err := se.executeEnrichmentMiddleware(ctx, mw, execCtx); err != nil {
+			if err := se.executeEnrichmentMiddleware(ctx, st.rules, mw, execCtx); err != nil {
 				se.Logger.Error().Err(err).Msg("Falha crítica no Enrichment")
-				return 500, errorJSON("Enrichment failed"), nil, nil
+				var srcErr *SourceError
+				if errors.As(err, &srcErr) {
+					return fail(problemFrom(srcErr.Response, StepEnrichment, srcErr.Source))
+				}
+				return fail(newProblem(500, StepEnrichment, mw.ID, "Enrichment failed"))
 			}
-		case "rate_limit":
-			// TODO
 		case "auth_provider":
-			if mgr, exists := se.AuthManagers[mw.ID]; exists {
+			if mgr, exists := st.authManagers[mw.ID]; exists {
 				token, err := mgr.Get()
+				tr.add(TraceEntry{Step: StepAuth, Kind: "middleware", ID: mw.ID, Error: errString(err)}, started)
 				if err != nil {
 					se.Logger.Error().Err(err).Str("mw_id", mw.ID).Msg("Falha ao recuperar token")
-					return 500, errorJSON("Auth dependency failed"), nil, nil
+					return fail(newProblem(500, StepAuth, mw.ID, "Auth dependency failed"))
 				}
 				if outVar, ok := mw.Config["output_var"].(string); ok && outVar != "" {
 					if _, ok := execCtx["auth"]; !ok {
@@ -160,94 +357,140 @@ func (se *ServiceEngine) Execute(ctx context.Context, payload []byte) (int, []by
 		}
 	}
 
+	if deadlineExceeded(ctx) {
+		timedOut = true
+		return
+	}
+
 	// 4. Input Validation
-	for _, rule := range se.Config.Steps.Input.Validations {
-		ok, err := se.RuleManager.EvaluateBool(rule.Expr, execCtx)
+	for _, rule := range op.steps.Input.Validations {
+		started := time.Now()
+		ok, err := op.rules.EvaluateBool(rule.Expr, execCtx)
+		tr.add(TraceEntry{Step: StepInput, Kind: "validation", ID: rule.ID, Expr: rule.Expr, Result: ok, Error: errString(err)}, started)
 		if err != nil {
 			se.Logger.Error().Err(err).Str("rule_id", rule.ID).Msg("Erro validação input")
-			return 500, errorJSON("Internal logic error"), nil, nil
+			return fail(newProblem(500, StepInput, rule.ID, "Internal logic error"))
 		}
 		if !ok {
-			return rule.OnFail.Code, errorJSON(rule.OnFail.Msg), nil, nil
+			return fail(problemFrom(rule.OnFail, StepInput, rule.ID))
 		}
 	}
 
 	// 5. Processing
-	for _, rule := range se.Config.Steps.Processing.Validations {
-		ok, err := se.RuleManager.EvaluateBool(rule.Expr, execCtx)
+	for _, rule := range op.steps.Processing.Validations {
+		started := time.Now()
+		ok, err := op.rules.EvaluateBool(rule.Expr, execCtx)
+		tr.add(TraceEntry{Step: StepProcessing, Kind: "validation", ID: rule.ID, Expr: rule.Expr, Result: ok, Error: errString(err)}, started)
 		if err != nil {
 			se.Logger.Error().Err(err).Str("rule_id", rule.ID).Msg("Erro validação processing")
-			return 500, errorJSON("Internal logic error"), nil, nil
+			return fail(newProblem(500, StepProcessing, rule.ID, "Internal logic error"))
 		}
 		if !ok {
-			return rule.OnFail.Code, errorJSON(rule.OnFail.Msg), nil, nil
+			return fail(problemFrom(rule.OnFail, StepProcessing, rule.ID))
 		}
 	}
 
-	vars := execCtx["vars"].(map[string]interface{})
-	for _, transform := range se.Config.Steps.Processing.Transformations {
-		res, err := se.RuleManager.ExecuteTransformation(transform, execCtx)
+	for _, transform := range op.steps.Processing.Transformations {
+		started := time.Now()
+		res, err := op.rules.ExecuteTransformation(transform, execCtx)
+		if err == nil && res.Applied {
+			err = applyTarget(execCtx, mwHeaders, res)
+		}
+		if tr != nil {
+			entry := TraceEntry{Step: StepProcessing, Kind: "transformation", ID: transform.Name, Expr: transform.Value, Error: errString(err)}
+			if res != nil {
+				entry.Result, entry.Skipped = res.Value, !res.Applied
+			}
+			tr.add(entry, started)
+		}
 		if err != nil {
 			se.Logger.Error().Err(err).Str("transform", transform.Name).Msg("Erro transformação")
-			return 500, errorJSON("Transformation error"), nil, nil
-		}
-		if res.Applied {
-			key := strings.TrimPrefix(res.Target, "vars.")
-			vars[key] = res.Value
+			return fail(newProblem(500, StepProcessing, transform.Name, "Transformation error"))
 		}
 	}
 
 	// 6. Output Validation
-	for _, rule := range se.Config.Steps.Output.Validations {
-		ok, err := se.RuleManager.EvaluateBool(rule.Expr, execCtx)
+	for _, rule := range op.steps.Output.Validations {
+		started := time.Now()
+		ok, err := op.rules.EvaluateBool(rule.Expr, execCtx)
+		tr.add(TraceEntry{Step: StepOutput, Kind: "validation", ID: rule.ID, Expr: rule.Expr, Result: ok, Error: errString(err)}, started)
 		if err != nil {
 			se.Logger.Error().Err(err).Str("rule_id", rule.ID).Msg("Erro validação output")
-			return 500, errorJSON("Internal output error"), nil, nil
+			return fail(newProblem(500, StepOutput, rule.ID, "Internal output error"))
 		}
 		if !ok {
-			return rule.OnFail.Code, errorJSON(rule.OnFail.Msg), nil, nil
+			return fail(problemFrom(rule.OnFail, StepOutput, rule.ID))
 		}
 	}
 
-	// 7. Output Build
-	statusCode, respBody, respHeaders, err := se.Responder.Build(execCtx)
+	// 7. Output Build (formato negociado pelo header Accept)
+	started := time.Now()
+	built, err := op.responder.BuildFor(execCtx, headerValue(requestHeaders(ctx), "Accept"))
+	if tr != nil {
+		entry := TraceEntry{Step: StepOutput, Kind: "output", Error: errString(err)}
+		if err == nil {
+			entry.Result, entry.Size = built.StatusCode, len(built.Body)
+		}
+		tr.add(entry, started)
+	}
 	if err != nil {
+		if errors.Is(err, codec.ErrNotAcceptable) {
+			return fail(newProblem(406, StepOutput, "", "Not Acceptable"))
+		}
 		se.Logger.Error().Err(err).Msg("Erro output build")
-		return 500, errorJSON(fmt.Sprintf("Output build error: %v", err)), nil, nil
+		return fail(newProblem(500, StepOutput, "", fmt.Sprintf("Output build error: %v", err)))
 	}
+	statusCode, respBody, respHeaders = built.StatusCode, built.Body, built.Headers
+	respData := built.Data
 
 	// 8. Interceptor
-	if se.Config.Steps.Output.Target.URL != "" {
-		targetURL, err := se.interpolateString(se.Config.Steps.Output.Target.URL, execCtx)
+	if op.steps.Output.Target.URL != "" {
+		if deadlineExceeded(ctx) {
+			timedOut = true
+			return
+		}
+
+		targetURL, err := interpolateString(op.steps.Output.Target.URL, execCtx, st.rules)
 		if err != nil {
 			se.Logger.Error().Err(err).Msg("Erro interpolando Target URL")
-			return 500, errorJSON("Invalid Target URL"), nil, nil
+			return fail(newProblem(500, StepInterceptor, "", "Invalid Target URL"))
 		}
 
-		method := se.Config.Steps.Output.Target.Method
+		method := op.steps.Output.Target.Method
 		if method == "" {
 			method = "POST"
 		}
 
 		se.Logger.Info().Str("target", targetURL).Msg("Interceptor: encaminhando requisição")
-		downstreamResp, err := proxy.ForwardRequest(ctx, method, targetURL, respBody, respHeaders, se.Config.Steps.Output.Target.Timeout)
+		started := time.Now()
+		downstreamResp, err := proxy.ForwardRequest(ctx, method, targetURL, respBody, respHeaders, op.steps.Output.Target.Timeout)
+		if tr != nil {
+			entry := TraceEntry{Step: StepInterceptor, Kind: "interceptor", ID: method + " " + targetURL, Error: errString(err)}
+			if err == nil {
+				entry.Result, entry.Size = downstreamResp.StatusCode, len(downstreamResp.Body)
+			}
+			tr.add(entry, started)
+		}
 		if err != nil {
 			se.Logger.Error().Err(err).Str("target", targetURL).Msg("Falha na chamada downstream")
-			return 502, errorJSON(fmt.Sprintf("Downstream error: %v", err)), nil, nil
+			return fail(newProblem(502, StepInterceptor, "", fmt.Sprintf("Downstream error: %v", err)))
 		}
 
 		statusCode = downstreamResp.StatusCode
 		respBody = downstreamResp.Body
 		respHeaders = downstreamResp.Headers
+
+		var downstreamData map[string]interface{}
+		_ = json.Unmarshal(respBody, &downstreamData)
+		respData = downstreamData
 	}
 
 	// 9. Métricas
-	var respMap map[string]interface{}
-	_ = json.Unmarshal(respBody, &respMap)
+	respMap, _ := respData.(map[string]interface{})
 	execCtx["response"] = respMap
 
-	if len(se.Config.Steps.Output.Metrics) > 0 {
-		if err := se.MetricProcessor.ProcessRules(se.Config.Steps.Output.Metrics, execCtx); err != nil {
+	if len(op.steps.Output.Metrics) > 0 {
+		if err := st.metrics.ProcessRules(op.steps.Output.Metrics, execCtx); err != nil {
 			se.Logger.Warn().Err(err).Msg("Falha ao registrar métricas de output")
 		}
 	}
@@ -267,21 +510,28 @@ func (se *ServiceEngine) Reload() error {
 	if err != nil {
 		return err
 	}
+	if err := newRm.Precompile(newCfg); err != nil {
+		return fmt.Errorf("falha ao pré-compilar regras: %w", err)
+	}
 
 	newMetricProcessor := metrics.NewProcessor(newCfg.Service.Metrics.Datadog.CustomDefinitions, se.Metrics, newRm)
-	newAuthManagers := make(map[string]*auth.Manager)
-	for _, mw := range newCfg.Middlewares {
-		if mw.Type == "auth_provider" {
-			var authCfg auth.AuthConfig
-			if err := decodeConfig(mw.Config, &authCfg); err != nil {
-				return fmt.Errorf("erro config auth reload '%s': %w", mw.ID, err)
-			}
-			mgr := auth.NewOAuth2Manager(authCfg)
-			if err := mgr.Start(context.Background()); err != nil {
-				return fmt.Errorf("erro iniciando auth reload '%s': %w", mw.ID, err)
-			}
-			newAuthManagers[mw.ID] = mgr
-		}
+
+	newRateLimiters, err := buildRateLimiters(newCfg)
+	if err != nil {
+		return err
+	}
+
+	newOperations, newOperationOrder, err := buildOperations(newCfg, newRm)
+	if err != nil {
+		return err
+	}
+	newErrorTemplate, err := buildErrorTemplate(newCfg.Service.Errors, newRm)
+	if err != nil {
+		return err
+	}
+	newTracer, err := buildTracer(newCfg.Service.Trace)
+	if err != nil {
+		return err
 	}
 
 	var newGqlEngine *graphql.GraphQLEngine
@@ -290,32 +540,61 @@ func (se *ServiceEngine) Reload() error {
 		if err != nil {
 			return fmt.Errorf("falha ao recriar engine graphql: %w", err)
 		}
+		newGqlEngine.Metrics = se.Metrics
+	}
+
+	// Os managers novos só iniciam depois de todo o resto montado; em caso de falha a
+	// configuração atual (e seus managers) continua ativa
+	newAuthManagers, err := startAuthManagers(newCfg, se.tokenFetchers, se.Logger)
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
 	}
 
+	newClients := enrichment.NewClients()
+	preloadClients(newCfg, newRm, newClients, se.Logger)
+
 	se.mu.Lock()
 	oldAuthManagers := se.AuthManagers
+	oldClients := se.clients
 	se.Config = newCfg
 	se.RuleManager = newRm
 	se.GraphQLEngine = newGqlEngine
 	se.AuthManagers = newAuthManagers
 	se.MetricProcessor = newMetricProcessor
+	se.rateLimiters = newRateLimiters
 
-	if newCfg.Steps != nil {
-		newRespBuilder, err := responder.NewResponseBuilder(newCfg.Steps.Output, newRm)
-		if err == nil {
-			se.Responder = newRespBuilder
-		}
+	se.operations = newOperations
+	se.operationOrder = newOperationOrder
+	se.errorTemplate = newErrorTemplate
+	se.tracer = newTracer
+	se.clients = newClients
+	se.Responder = nil
+	if op, ok := newOperations[DefaultOperationID]; ok {
+		se.Responder = op.responder
 	}
 	se.mu.Unlock()
 
 	for _, mgr := range oldAuthManagers {
 		mgr.Stop()
 	}
+	// Pools que a nova configuração não usa são fechados; os que continuam declarados
+	// já foram adquiridos por newClients e seguem abertos
+	if err := oldClients.Close(); err != nil {
+		se.Logger.Warn().Err(err).Msg("Falha ao fechar conexões da configuração anterior")
+	}
 
 	se.Logger.Info().Msg("✅ Hot Reload concluído com sucesso!")
 	return nil
 }
 
+// GetConfig retorna a configuração vigente; transports a consultam por requisição,
+// concorrendo com Reload.
+func (se *ServiceEngine) GetConfig() *config.ServiceConfig {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	return se.Config
+}
+
 func (se *ServiceEngine) GetGraphQLEngine() *graphql.GraphQLEngine {
 	se.mu.RLock()
 	defer se.mu.RUnlock()
@@ -327,14 +606,26 @@ func (se *ServiceEngine) RunMiddlewares(ctx context.Context) (context.Context, e
 	defer se.mu.RUnlock()
 
 	authContext := make(map[string]interface{})
+	respHeaders := make(map[string]string)
+
+	evalCtx := map[string]interface{}{
+		"header":  requestHeaders(ctx),
+		"env":     getEnvVars(),
+		"request": requestInfo(ctx),
+	}
 
 	for _, mw := range se.Config.Middlewares {
 		switch mw.Type {
+		case "rate_limit":
+			if rejection := se.applyRateLimit(se.stateLocked(), mw, evalCtx, respHeaders); rejection != nil {
+				return nil, rejection
+			}
 		case "auth_provider":
 			if mgr, exists := se.AuthManagers[mw.ID]; exists {
 				token, err := mgr.Get()
 				if err != nil {
-					return nil, fmt.Errorf("auth '%s' not ready: %w", mw.ID, err)
+					se.Logger.Error().Err(err).Str("mw_id", mw.ID).Msg("Falha ao recuperar token")
+					return nil, &MiddlewareError{Code: 500, Msg: "Auth dependency failed", Step: StepAuth, Middleware: mw.ID}
 				}
 				if outVar, ok := mw.Config["output_var"].(string); ok && outVar != "" {
 					if _, ok := authContext[mw.ID]; !ok {
@@ -346,99 +637,12 @@ func (se *ServiceEngine) RunMiddlewares(ctx context.Context) (context.Context, e
 		}
 	}
 	newCtx := context.WithValue(ctx, "auth_context", authContext)
+	newCtx = enrichment.WithClients(newCtx, se.clients)
+	newCtx = context.WithValue(newCtx, "response_headers", respHeaders)
 	return newCtx, nil
 }
 
-func (se *ServiceEngine) executeEnrichmentMiddleware(ctx context.Context, mwConf config.MiddlewareConf, execCtx map[string]interface{}) error {
-	var eConfig EnrichmentConfig
-	if err := decodeConfig(mwConf.Config, &eConfig); err != nil {
-		return fmt.Errorf("configuração enrichment inválida: %w", err)
-	}
-
-	detection := execCtx["detection"].(map[string]interface{})
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	errChan := make(chan error, len(eConfig.Sources))
-
-	for _, source := range eConfig.Sources {
-		wg.Add(1)
-		go func(src EnrichmentSource) {
-			defer wg.Done()
-
-			resolvedParams, err := se.resolveParams(src.Params, execCtx)
-			if err != nil {
-				errChan <- fmt.Errorf("erro resolvendo params source '%s': %w", src.Name, err)
-				return
-			}
-			resolvedHeaders, err := se.resolveHeaders(src.Headers, execCtx)
-			if err != nil {
-				errChan <- fmt.Errorf("erro resolvendo headers source '%s': %w", src.Name, err)
-				return
-			}
-
-			var result interface{}
-			var callErr error
-
-			switch src.Type {
-			case "fixed":
-				result, callErr = enrichment.ProcessFixed(resolvedParams)
-			case "rest":
-				method := toString(resolvedParams["method"])
-				url := toString(resolvedParams["url"])
-				body := resolvedParams["body"]
-				result, callErr = enrichment.ProcessRest(ctx, method, url, resolvedHeaders, body)
-			case "graphql":
-				endpoint := toString(resolvedParams["endpoint"])
-				query := toString(resolvedParams["query"])
-				vars, _ := resolvedParams["variables"].(map[string]interface{})
-				result, callErr = enrichment.ProcessGraphQL(ctx, endpoint, query, vars, resolvedHeaders)
-			case "aws_parameter_store":
-				region := toString(resolvedParams["region"])
-				path := toString(resolvedParams["path"])
-				decrypt := toBool(resolvedParams["with_decryption"])
-				result, callErr = enrichment.ProcessAWSParameterStore(ctx, region, path, decrypt)
-			case "aws_secrets_manager":
-				region := toString(resolvedParams["region"])
-				secretID := toString(resolvedParams["secret_id"])
-				result, callErr = enrichment.ProcessAWSSecretsManager(ctx, region, secretID)
-			case "aws_s3":
-				region := toString(resolvedParams["region"])
-				bucket := toString(resolvedParams["bucket"])
-				key := toString(resolvedParams["key"])
-				format := toString(resolvedParams["format"])
-				result, callErr = enrichment.ProcessS3(ctx, region, bucket, key, format)
-			case "aws_dynamodb":
-				region := toString(resolvedParams["region"])
-				table := toString(resolvedParams["table"])
-				keyMap := toMap(resolvedParams["key"])
-				if len(keyMap) == 0 {
-					callErr = fmt.Errorf("key vazia")
-				} else {
-					result, callErr = enrichment.ProcessDynamoDB(ctx, region, table, keyMap)
-				}
-			default:
-				callErr = fmt.Errorf("tipo de source desconhecido: %s", src.Type)
-			}
-
-			if callErr != nil {
-				se.Logger.Warn().Err(callErr).Str("source", src.Name).Msg("Falha na fonte de dados")
-				return
-			}
-			mu.Lock()
-			detection[src.Name] = result
-			mu.Unlock()
-		}(source)
-	}
-
-	wg.Wait()
-	close(errChan)
-	if len(errChan) > 0 {
-		return <-errChan
-	}
-	return nil
-}
-
-func (se *ServiceEngine) interpolateString(input string, ctx map[string]interface{}) (string, error) {
+func interpolateString(input string, ctx map[string]interface{}, rm *rules.RuleManager) (string, error) {
 	if !strings.Contains(input, "${") {
 		return input, nil
 	}
@@ -446,7 +650,7 @@ func (se *ServiceEngine) interpolateString(input string, ctx map[string]interfac
 	var replaceErr error
 	result := interpolationRegex.ReplaceAllStringFunc(input, func(match string) string {
 		expr := match[2 : len(match)-1]
-		val, err := se.RuleManager.EvaluateValue(expr, ctx)
+		val, err := rm.EvaluateValue(expr, ctx)
 		if err != nil {
 			replaceErr = fmt.Errorf("falha ao interpolar '%s': %w", match, err)
 			return match
@@ -460,26 +664,57 @@ func (se *ServiceEngine) interpolateString(input string, ctx map[string]interfac
 	return result, nil
 }
 
-func (se *ServiceEngine) resolveParams(raw map[string]interface{}, ctx map[string]interface{}) (map[string]interface{}, error) {
+func resolveParams(raw map[string]interface{}, ctx map[string]interface{}, rm *rules.RuleManager) (map[string]interface{}, error) {
 	resolved := make(map[string]interface{})
 	for k, v := range raw {
-		if strVal, ok := v.(string); ok {
-			val, err := se.interpolateString(strVal, ctx)
+		val, err := resolveValue(v, ctx, rm)
+		if err != nil {
+			return nil, err
+		}
+		resolved[k] = val
+	}
+	return resolved, nil
+}
+
+// resolveValue resolve recursivamente mapas e listas. Strings que são exatamente "${expr}"
+// retornam o valor CEL tipado; demais strings são interpoladas e outros valores são mantidos.
+func resolveValue(v interface{}, ctx map[string]interface{}, rm *rules.RuleManager) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		if match := interpolationRegex.FindStringSubmatch(x); match != nil && match[0] == x {
+			return rm.EvaluateValue(match[1], ctx)
+		}
+		return interpolateString(x, ctx, rm)
+	case map[string]interface{}, map[interface{}]interface{}:
+		m := toMap(x)
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			r, err := resolveValue(val, ctx, rm)
 			if err != nil {
 				return nil, err
 			}
-			resolved[k] = val
-		} else {
-			resolved[k] = v
+			out[k] = r
 		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, val := range x {
+			r, err := resolveValue(val, ctx, rm)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
 	}
-	return resolved, nil
 }
 
-func (se *ServiceEngine) resolveHeaders(raw map[string]string, ctx map[string]interface{}) (map[string]string, error) {
+func resolveHeaders(raw map[string]string, ctx map[string]interface{}, rm *rules.RuleManager) (map[string]string, error) {
 	resolved := make(map[string]string)
 	for k, v := range raw {
-		val, err := se.interpolateString(v, ctx)
+		val, err := interpolateString(v, ctx, rm)
 		if err != nil {
 			return nil, err
 		}
@@ -488,6 +723,60 @@ func (se *ServiceEngine) resolveHeaders(raw map[string]string, ctx map[string]in
 	return resolved, nil
 }
 
+// preloadClients adquire para c os pools das sources redis e sql cuja conexão já é
+// conhecida na carga: valores fixos ou que dependem apenas de env. As demais abrem o
+// pool na primeira chamada.
+func preloadClients(cfg *config.ServiceConfig, rm *rules.RuleManager, c *enrichment.Clients, log zerolog.Logger) {
+	evalCtx := map[string]interface{}{"env": getEnvVars()}
+	for _, ref := range declaredSources(cfg) {
+		if ref.expressions {
+			continue
+		}
+		params, err := resolveParams(ref.params, evalCtx, rm)
+		if err != nil {
+			continue
+		}
+		if err := c.Preload(ref.srcType, params); err != nil {
+			log.Warn().Err(err).Str("source", ref.where).Msg("Falha ao abrir conexão da source")
+		}
+	}
+}
+
+// startAuthManagers inicia os managers dos middlewares auth_provider, usando o fetcher
+// informado para o ID (WithTokenFetcher) no lugar do token_url. Se algum falhar, os já
+// iniciados são encerrados antes de retornar o erro.
+func startAuthManagers(cfg *config.ServiceConfig, fetchers map[string]auth.TokenFetcher, log zerolog.Logger) (map[string]*auth.Manager, error) {
+	managers := make(map[string]*auth.Manager)
+	stopAll := func() {
+		for _, mgr := range managers {
+			mgr.Stop()
+		}
+	}
+	for _, mw := range cfg.Middlewares {
+		if mw.Type != "auth_provider" {
+			continue
+		}
+		var authCfg auth.AuthConfig
+		if err := decodeConfig(mw.Config, &authCfg); err != nil {
+			stopAll()
+			return nil, fmt.Errorf("erro config auth '%s': %w", mw.ID, err)
+		}
+		var mgr *auth.Manager
+		if fetcher, ok := fetchers[mw.ID]; ok {
+			mgr = auth.NewManager(fetcher)
+		} else {
+			mgr = auth.NewOAuth2Manager(authCfg)
+		}
+		log.Info().Str("middleware_id", mw.ID).Msg("Iniciando Auth Manager...")
+		if err := mgr.Start(context.Background()); err != nil {
+			stopAll()
+			return nil, fmt.Errorf("erro fatal iniciando auth '%s': %w", mw.ID, err)
+		}
+		managers[mw.ID] = mgr
+	}
+	return managers, nil
+}
+
 func decodeConfig(input interface{}, output interface{}) error {
 	cleanInput := sanitizeMap(input)
 	data, err := json.Marshal(cleanInput)
@@ -522,8 +811,55 @@ func sanitizeMap(input interface{}) interface{} {
 	}
 }
 
-func errorJSON(msg string) []byte {
-	return []byte(fmt.Sprintf(`{"error": "%s"}`, msg))
+// requestHeaders recupera os headers de entrada injetados pelo transport.
+func requestHeaders(ctx context.Context) map[string]string {
+	if h, ok := ctx.Value("request_headers").(map[string]string); ok {
+		return h
+	}
+	return make(map[string]string)
+}
+
+// requestInfo recupera os metadados da requisição (client_ip, method, path) injetados pelo transport.
+func requestInfo(ctx context.Context) map[string]interface{} {
+	if info, ok := ctx.Value("request_info").(map[string]interface{}); ok {
+		return info
+	}
+	return make(map[string]interface{})
+}
+
+// mergeHeaders combina dois conjuntos de headers; valores de override prevalecem.
+func mergeHeaders(base, override map[string]string) map[string]string {
+	if len(base) == 0 {
+		return override
+	}
+	out := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+	return out
+}
+
+// applyTarget grava o resultado da transformação no destino: caminhos em vars ou
+// detection (mesclados em profundidade) ou um header da resposta.
+func applyTarget(execCtx map[string]interface{}, headers map[string]string, res *rules.TransformationResult) error {
+	target, err := rules.ParseTarget(res.Target)
+	if err != nil {
+		return err
+	}
+	if target.Root == rules.TargetResponseHeaders {
+		headers[target.Path[0].Key] = fmt.Sprintf("%v", rules.Native(res.Value))
+		return nil
+	}
+	root, _ := execCtx[target.Root].(map[string]interface{})
+	updated, err := rules.SetPath(root, target.Path, res.Value)
+	if err != nil {
+		return fmt.Errorf("target '%s': %w", res.Target, err)
+	}
+	execCtx[target.Root] = updated
+	return nil
 }
 
 func getEnvVars() map[string]string {
@@ -544,13 +880,6 @@ func toString(v interface{}) string {
 	return fmt.Sprintf("%v", v)
 }
 
-func toBool(v interface{}) bool {
-	if b, ok := v.(bool); ok {
-		return b
-	}
-	return false
-}
-
 func toMap(v interface{}) map[string]interface{} {
 	if v == nil {
 		return nil
@@ -3,9 +3,13 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"testing"
 
 	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -96,6 +100,252 @@ func TestServiceEngine_Execute_Flow(t *testing.T) {
 		t.Errorf("Code esperado 400 para erro de input, recebido %d", codeFail)
 	}
 
-	expectedErrorJSON := `{"error": "Invalid amount"}`
+	expectedErrorJSON := `{"type": "about:blank", "title": "Bad Request", "status": 400, "step": "input", "rule_id": "chk_valor", "detail": "Invalid amount"}`
 	assert.JSONEq(t, expectedErrorJSON, string(respFail))
 }
+
+func TestServiceEngine_ResolveParams_Nested(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+	se := &ServiceEngine{Logger: zerolog.Nop(), RuleManager: rm}
+
+	raw := map[string]interface{}{
+		"key":  "customer:${input.id}",
+		"args": []interface{}{"${input.id}", "fixo"},
+		"named": map[interface{}]interface{}{
+			"limit": "${input.limit}",
+		},
+		"db": 2,
+	}
+	evalCtx := map[string]interface{}{
+		"input": map[string]interface{}{"id": "C-1", "limit": 10},
+	}
+
+	resolved, err := resolveParams(raw, evalCtx, se.RuleManager)
+	assert.NoError(t, err)
+	assert.Equal(t, "customer:C-1", resolved["key"])
+	assert.Equal(t, []interface{}{"C-1", "fixo"}, resolved["args"])
+	// "${expr}" isolado preserva o tipo do valor CEL
+	assert.EqualValues(t, 10, resolved["named"].(map[string]interface{})["limit"])
+	assert.Equal(t, 2, resolved["db"])
+}
+
+func TestServiceEngine_Execute_OutputFormats(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "export", Timeout: "1s", Route: "/export"},
+		Steps: &config.StepsConf{
+			Output: config.OutputStep{
+				StatusCode: 200,
+				Formats:    []string{"csv"},
+				Body:       map[string]interface{}{"id": "${input.id}"},
+			},
+		},
+	}
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	exec := func(accept string) (int, string, map[string]string) {
+		ctx := context.WithValue(context.Background(), "request_headers", map[string]string{"Accept": accept})
+		code, resp, headers, err := svc.Execute(ctx, []byte(`{"id":"7"}`))
+		assert.NoError(t, err)
+		return code, string(resp), headers
+	}
+
+	code, body, headers := exec("")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.JSONEq(t, `{"id":"7"}`, body)
+
+	code, body, headers = exec("text/csv")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "text/csv; charset=utf-8", headers["Content-Type"])
+	assert.Equal(t, "id\n7\n", body)
+
+	code, _, headers = exec("application/pdf")
+	assert.Equal(t, 406, code)
+	assert.Equal(t, ContentTypeProblem, headers["Content-Type"], "Erros da engine usam problem+json, independente do Accept")
+}
+
+func TestServiceEngine_Execute_Variants(t *testing.T) {
+	yamlContent := `
+version: "1.0"
+service:
+  name: "credit"
+  runtime: "lambda"
+  route: "/analyze"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+steps:
+  processing:
+    transformations:
+      - name: "decision"
+        condition: "input.score >= 700"
+        value: "'approved'"
+        else_value: "input.score >= 400 ? 'review' : 'denied'"
+        target: "vars.decision"
+  output:
+    status_code: "${vars.decision == 'denied' ? 422 : 200}"
+    body:
+      decision: "${vars.decision}"
+    variants:
+      - when: "vars.decision == 'review'"
+        status_code: 202
+        body:
+          decision: "${vars.decision}"
+          queue: "manual"
+`
+	tmpFile, _ := os.CreateTemp("", "variants_*.yaml")
+	defer os.Remove(tmpFile.Name())
+	_, _ = tmpFile.WriteString(yamlContent)
+	tmpFile.Close()
+
+	cfg, err := Load(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	svc, err := NewServiceEngine(cfg, tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	cases := []struct {
+		score    int
+		wantCode int
+		wantBody string
+	}{
+		{800, 200, `{"decision":"approved"}`},
+		{500, 202, `{"decision":"review","queue":"manual"}`},
+		{100, 422, `{"decision":"denied"}`},
+	}
+	for _, tc := range cases {
+		code, resp, _, err := svc.Execute(context.Background(), []byte(fmt.Sprintf(`{"score": %d}`, tc.score)))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.wantCode, code, "score %d", tc.score)
+		assert.JSONEq(t, tc.wantBody, string(resp), "score %d", tc.score)
+	}
+}
+
+func TestServiceEngine_Execute_CollectionTransformations(t *testing.T) {
+	yamlContent := `
+version: "1.0"
+service:
+  name: "orders"
+  runtime: "lambda"
+  route: "/summary"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+steps:
+  processing:
+    transformations:
+      - name: "paid"
+        type: "filter"
+        items: "input.orders"
+        as: "order"
+        value: "order.status == 'paid'"
+        target: "vars.paid"
+      - name: "total"
+        type: "reduce"
+        items: "vars.paid"
+        initial: "0.0"
+        value: "acc + item.total"
+        target: "vars.total"
+      - name: "lines"
+        type: "foreach"
+        items: "vars.paid"
+        as: "order"
+        transformations:
+          - name: "id"
+            value: "order.id"
+            target: "vars.id"
+          - name: "share"
+            value: "order.total / vars.total"
+            target: "vars.share"
+        target: "vars.lines"
+  output:
+    status_code: 200
+    body:
+      total: "${vars.total}"
+      lines: "${vars.lines}"
+`
+	tmpFile, _ := os.CreateTemp("", "collections_*.yaml")
+	defer os.Remove(tmpFile.Name())
+	_, _ = tmpFile.WriteString(yamlContent)
+	tmpFile.Close()
+
+	cfg, err := Load(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	svc, err := NewServiceEngine(cfg, tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	payload := `{"orders": [
+		{"id": "a", "total": 30.0, "status": "paid"},
+		{"id": "b", "total": 99.0, "status": "open"},
+		{"id": "c", "total": 10.0, "status": "paid"}
+	]}`
+	code, resp, _, err := svc.Execute(context.Background(), []byte(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"total": 40, "lines": [{"id": "a", "share": 0.75}, {"id": "c", "share": 0.25}]}`, string(resp))
+}
+
+func TestServiceEngine_Execute_NestedTargets(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "risk", Timeout: "1s", Route: "/risk"},
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{
+							"name":   "bureau",
+							"type":   "fixed",
+							"params": map[string]interface{}{"value": map[string]interface{}{"score": 300, "name": "Ana"}},
+						},
+					},
+				},
+			},
+		},
+		Steps: &config.StepsConf{
+			Processing: config.ProcessingStep{
+				Transformations: []config.TransformationRule{
+					{Name: "score", Condition: "input.manual", Value: "900", Target: "detection.bureau.score"},
+					{Name: "tier", Value: "detection.bureau.score > 700 ? 'gold' : 'basic'", Target: "vars.customer.risk.tier"},
+					{Name: "customer", Value: "{'name': detection.bureau.name}", Target: "vars.customer"},
+					{Name: "first_tag", Value: "'vip'", Target: "vars.customer.tags[0]"},
+					{Name: "header", Value: "vars.customer.risk.tier", Target: "response.headers.X-Risk-Tier"},
+				},
+			},
+			Output: config.OutputStep{
+				StatusCode: 200,
+				Body:       map[string]interface{}{"customer": "${vars.customer}"},
+			},
+		},
+	}
+	svc, err := NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	code, resp, headers, err := svc.Execute(context.Background(), []byte(`{"manual": true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "gold", headers["X-Risk-Tier"])
+	assert.JSONEq(t, `{"customer": {"name": "Ana", "risk": {"tier": "gold"}, "tags": ["vip"]}}`, string(resp))
+
+	// A sobrescrita de detection vale só para a requisição
+	_, resp, headers, _ = svc.Execute(context.Background(), []byte(`{"manual": false}`))
+	assert.Equal(t, "basic", headers["X-Risk-Tier"])
+	assert.JSONEq(t, `{"customer": {"name": "Ana", "risk": {"tier": "basic"}, "tags": ["vip"]}}`, string(resp))
+
+	cfg.Steps.Processing.Transformations[0].Target = "input.manual"
+	_, err = NewServiceEngine(cfg, "memory")
+	assert.ErrorContains(t, err, "deve começar com")
+}
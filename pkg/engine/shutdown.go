@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/raywall/fast-service-toolkit/pkg/auth"
+)
+
+// Garante em tempo de compilação que a ServiceEngine atende ao contrato de runtime.
+var _ Executor = (*ServiceEngine)(nil)
+
+// Ready indica se o serviço deve receber tráfego (readiness probe).
+// Passa a falhar assim que o encerramento começa.
+func (se *ServiceEngine) Ready() bool {
+	return !se.draining.Load()
+}
+
+// Drain sinaliza o início do encerramento: a readiness passa a falhar para que o
+// balanceador retire a instância antes de as conexões serem fechadas.
+func (se *ServiceEngine) Drain() {
+	if se.draining.CompareAndSwap(false, true) {
+		se.Logger.Info().Msg("Encerramento iniciado: readiness marcada como indisponível")
+	}
+}
+
+// Shutdown libera os recursos da engine: para os auth managers, envia as métricas
+// pendentes e libera os clientes Redis/SQL adquiridos por ela. Deve ser chamado após o
+// término das requisições em andamento. Chamadas repetidas retornam o mesmo resultado.
+func (se *ServiceEngine) Shutdown(ctx context.Context) error {
+	se.Drain()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		se.shutdownOnce.Do(func() {
+			se.shutdownErr = se.releaseResources()
+		})
+	}()
+
+	select {
+	case <-done:
+		return se.shutdownErr
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown interrompido: %w", ctx.Err())
+	}
+}
+
+func (se *ServiceEngine) releaseResources() error {
+	var errs []error
+
+	se.mu.Lock()
+	for id, mgr := range se.AuthManagers {
+		mgr.Stop()
+		se.Logger.Debug().Str("middleware_id", id).Msg("Auth Manager encerrado")
+	}
+	se.AuthManagers = map[string]*auth.Manager{}
+	clients := se.clients
+	se.mu.Unlock()
+
+	if closer, ok := se.Metrics.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("falha ao encerrar métricas: %w", err))
+		}
+	}
+
+	// Só os pools desta engine; os compartilhados com outras continuam abertos
+	if err := clients.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("falha ao fechar conexões: %w", err))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	se.Logger.Info().Msg("Recursos da engine liberados")
+	return nil
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/auth"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// closableProvider registra chamadas a Close para validar o flush das métricas.
+type closableProvider struct {
+	closed int
+}
+
+func (c *closableProvider) Count(name string, value float64, tags []string) error     { return nil }
+func (c *closableProvider) Gauge(name string, value float64, tags []string) error     { return nil }
+func (c *closableProvider) Histogram(name string, value float64, tags []string) error { return nil }
+func (c *closableProvider) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestServiceEngine_Shutdown(t *testing.T) {
+	mgr := auth.NewManager(func(ctx context.Context) (string, time.Duration, error) {
+		return "token", time.Hour, nil
+	})
+	assert.NoError(t, mgr.Start(context.Background()))
+
+	provider := &closableProvider{}
+	se := &ServiceEngine{
+		Logger:       zerolog.Nop(),
+		Metrics:      provider,
+		AuthManagers: map[string]*auth.Manager{"oauth": mgr},
+	}
+
+	assert.True(t, se.Ready())
+
+	assert.NoError(t, se.Shutdown(context.Background()))
+	assert.False(t, se.Ready(), "Readiness deveria falhar após o shutdown")
+	assert.Equal(t, 1, provider.closed, "Métricas deveriam ser encerradas")
+	assert.Empty(t, se.AuthManagers, "Auth managers deveriam ser encerrados")
+
+	// Chamadas repetidas não devem parar os managers ou fechar o provider novamente
+	assert.NoError(t, se.Shutdown(context.Background()))
+	assert.Equal(t, 1, provider.closed)
+}
+
+func TestServiceEngine_Drain(t *testing.T) {
+	se := &ServiceEngine{Logger: zerolog.Nop()}
+	se.Drain()
+	se.Drain()
+	assert.False(t, se.Ready())
+}
+
+// countingDriver é um driver SQL falso que conta as conexões abertas por DSN e
+// responde qualquer query com uma linha {"ok": "true"}.
+type countingDriver struct {
+	mu   sync.Mutex
+	open map[string]int
+}
+
+func (d *countingDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.open[dsn]++
+	return &countingConn{driver: d, dsn: dsn}, nil
+}
+
+func (d *countingDriver) count(dsn string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.open[dsn]
+}
+
+type countingConn struct {
+	driver *countingDriver
+	dsn    string
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) { return countingStmt{}, nil }
+func (c *countingConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+func (c *countingConn) Close() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.open[c.dsn]--
+	return nil
+}
+
+type countingStmt struct{}
+
+func (countingStmt) Close() error                                    { return nil }
+func (countingStmt) NumInput() int                                   { return -1 }
+func (countingStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (countingStmt) Query(args []driver.Value) (driver.Rows, error)  { return &countingRows{}, nil }
+
+type countingRows struct{ done bool }
+
+func (r *countingRows) Columns() []string { return []string{"ok"} }
+func (r *countingRows) Close() error      { return nil }
+func (r *countingRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = "true"
+	return nil
+}
+
+var sharedSQL = &countingDriver{open: make(map[string]int)}
+
+func init() {
+	sql.Register("engine_counting", sharedSQL)
+}
+
+const sqlSourceYAML = `
+version: "1.0"
+service:
+  name: "sql-owner"
+  runtime: "local"
+  port: 8080
+  route: "/test"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Gateway Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+middlewares:
+  - type: enrichment
+    id: db
+    config:
+      sources:
+        - name: row
+          type: sql
+          required: true
+          params: { driver: "engine_counting", dsn: "DSN", query: "SELECT 1" }
+steps:
+  output:
+    status_code: 200
+    body: { ok: "${detection.row[0].ok}" }
+`
+
+func newSQLEngine(t *testing.T, dsn string) (*ServiceEngine, string) {
+	t.Helper()
+	path := writeTemp(t, strings.Replace(sqlSourceYAML, "DSN", dsn, 1))
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Erro load: %v", err)
+	}
+	svc, err := NewServiceEngine(cfg, path)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	return svc, path
+}
+
+func TestServiceEngine_ShutdownKeepsSharedPools(t *testing.T) {
+	a, _ := newSQLEngine(t, "shared")
+	b, _ := newSQLEngine(t, "shared")
+
+	for _, svc := range []*ServiceEngine{a, b} {
+		code, resp, _, _ := svc.Execute(context.Background(), []byte(`{}`))
+		assert.Equal(t, 200, code, string(resp))
+	}
+
+	// O shutdown de a não fecha o pool que b ainda usa
+	assert.NoError(t, a.Shutdown(context.Background()))
+	code, resp, _, _ := b.Execute(context.Background(), []byte(`{}`))
+	assert.Equal(t, 200, code, string(resp))
+	assert.JSONEq(t, `{"ok":"true"}`, string(resp))
+
+	assert.NoError(t, b.Shutdown(context.Background()))
+	assert.Zero(t, sharedSQL.count("shared"), "Conexões deveriam ser fechadas com o último dono")
+}
+
+func TestServiceEngine_ReloadReleasesUnusedPools(t *testing.T) {
+	svc, path := newSQLEngine(t, "before")
+	defer svc.Shutdown(context.Background())
+
+	code, _, _, _ := svc.Execute(context.Background(), []byte(`{}`))
+	assert.Equal(t, 200, code)
+	assert.Equal(t, 1, sharedSQL.count("before"))
+
+	if err := os.WriteFile(path, []byte(strings.Replace(sqlSourceYAML, "DSN", "after", 1)), 0o644); err != nil {
+		t.Fatalf("Erro ao gravar arquivo: %v", err)
+	}
+	assert.NoError(t, svc.Reload())
+	assert.Zero(t, sharedSQL.count("before"), "Pool fora da nova configuração deveria ser fechado")
+
+	code, _, _, _ = svc.Execute(context.Background(), []byte(`{}`))
+	assert.Equal(t, 200, code)
+	assert.Equal(t, 1, sharedSQL.count("after"))
+}
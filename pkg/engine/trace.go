@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+const (
+	// TraceTokenHeader é o header que ativa o trace de uma requisição (ver TraceToken).
+	TraceTokenHeader = "X-Trace-Token"
+	// TraceHeader devolve o trace na resposta (JSON em base64url) quando trace.output inclui "header".
+	TraceHeader = "X-Trace"
+
+	// Destinos do trace (trace.output).
+	TraceOutputLog    = "log"
+	TraceOutputHeader = "header"
+	TraceOutputBoth   = "both"
+
+	defaultTraceTokenTTL = 5 * time.Minute
+	maxTraceHeaderSize   = 16 * 1024
+	maxTraceStringSize   = 256
+	redactedValue        = "***"
+)
+
+// defaultRedact são os trechos de nome de campo cujos valores nunca aparecem no trace.
+var defaultRedact = []string{
+	"password", "senha", "secret", "token", "authorization", "api_key", "apikey",
+	"cpf", "cnpj", "document", "card", "cvv",
+}
+
+// Trace registra a execução de uma requisição: cada etapa, regra, expressão CEL,
+// resultado e duração. Valores de campos sensíveis são mascarados.
+type Trace struct {
+	mu     sync.Mutex
+	redact []string
+	start  time.Time
+
+	Operation     string       `json:"operation"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	Status        int          `json:"status"`
+	DurationMs    float64      `json:"duration_ms"`
+	Entries       []TraceEntry `json:"entries"`
+}
+
+// TraceEntry é um passo registrado no trace.
+type TraceEntry struct {
+	Step       string      `json:"step"`               // Etapa do pipeline (input, enrichment, processing...)
+	Kind       string      `json:"kind"`               // schema, validation, source, transformation, middleware, output
+	ID         string      `json:"id,omitempty"`       // rule_id, nome da transformação, source ou middleware
+	Expr       string      `json:"expr,omitempty"`     // Expressão CEL avaliada
+	Result     interface{} `json:"result,omitempty"`   // Resultado (mascarado e truncado)
+	Skipped    bool        `json:"skipped,omitempty"`  // Transformação com 'condition' falsa e sem else_value
+	Error      string      `json:"error,omitempty"`    // Falha da avaliação ou da chamada
+	Size       int         `json:"size,omitempty"`     // Tamanho em bytes do payload da source
+	Attempts   int         `json:"attempts,omitempty"` // Tentativas consumidas pela source
+	DurationMs float64     `json:"duration_ms"`
+}
+
+// WithTrace ativa o trace da requisição independentemente de service.trace (uso
+// administrativo: CLI e testes). O Trace retornado é preenchido durante o Execute.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	tr := newTrace(nil)
+	return context.WithValue(ctx, "execution_trace", tr), tr
+}
+
+// TraceToken gera o valor do header X-Trace-Token: "<unix>.<hmac-sha256 hex>" assinado
+// com trace.secret sobre o timestamp, o método HTTP e o ID da operação. O token só ativa
+// o trace dessa operação e método, por trace.token_ttl a partir do instante informado.
+func TraceToken(secret, operation, method string, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return ts + "." + signTrace(secret, ts, operation, method)
+}
+
+func signTrace(secret, ts, operation, method string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + strings.ToUpper(method) + "\n" + operation))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tracer é a forma compilada de service.trace.
+type tracer struct {
+	conf     config.TraceConf
+	tokenTTL time.Duration
+	redact   []string
+}
+
+func buildTracer(conf config.TraceConf) (*tracer, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+	if conf.Secret == "" && !conf.All {
+		return nil, fmt.Errorf("trace: 'secret' é obrigatório quando 'all' não está ativo")
+	}
+	t := &tracer{conf: conf, tokenTTL: defaultTraceTokenTTL}
+	if conf.TokenTTL != "" {
+		ttl, err := time.ParseDuration(conf.TokenTTL)
+		if err != nil {
+			return nil, fmt.Errorf("trace: token_ttl inválido '%s': %w", conf.TokenTTL, err)
+		}
+		t.tokenTTL = ttl
+	}
+	for _, field := range append(append([]string{}, defaultRedact...), conf.Redact...) {
+		t.redact = append(t.redact, strings.ToLower(field))
+	}
+	return t, nil
+}
+
+// allows indica se a requisição deve ser rastreada: trace.all ou um X-Trace-Token válido,
+// assinado para esta operação e método. Um token capturado não serve para outra rota, e
+// timestamps no futuro são recusados para que a validade não passe de trace.token_ttl.
+func (t *tracer) allows(ctx context.Context, operation string) bool {
+	if t.conf.All {
+		return true
+	}
+	method, _ := requestInfo(ctx)["method"].(string)
+	ts, sig, ok := strings.Cut(headerValue(requestHeaders(ctx), TraceTokenHeader), ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signTrace(t.conf.Secret, ts, operation, method))) {
+		return false
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(unix, 0))
+	return age >= 0 && age <= t.tokenTTL
+}
+
+// start retorna o trace da requisição: o informado por WithTrace ou, quando o tracer
+// permite, um novo trace guardado no contexto. Sem trace, retorna nil.
+func (t *tracer) start(ctx context.Context, operation string) (context.Context, *Trace) {
+	if tr := traceFrom(ctx); tr != nil {
+		return ctx, tr
+	}
+	if t == nil || !t.allows(ctx, operation) {
+		return ctx, nil
+	}
+	tr := newTrace(t.redact)
+	return context.WithValue(ctx, "execution_trace", tr), tr
+}
+
+func newTrace(redact []string) *Trace {
+	if redact == nil {
+		redact = defaultRedact
+	}
+	return &Trace{redact: redact, start: time.Now(), Entries: []TraceEntry{}}
+}
+
+func traceFrom(ctx context.Context) *Trace {
+	tr, _ := ctx.Value("execution_trace").(*Trace)
+	return tr
+}
+
+// add registra um passo iniciado em 'since'. O resultado é mascarado quando o ID (nome
+// da regra, transformação ou source) indica um campo sensível. Seguro para trace nil.
+func (tr *Trace) add(entry TraceEntry, since time.Time) {
+	if tr == nil {
+		return
+	}
+	entry.DurationMs = durationMs(time.Since(since))
+	if tr.sensitive(entry.ID) && entry.Result != nil {
+		entry.Result = redactedValue
+	} else {
+		entry.Result = tr.sanitize(entry.Result)
+	}
+	tr.mu.Lock()
+	tr.Entries = append(tr.Entries, entry)
+	tr.mu.Unlock()
+}
+
+// finish fecha o trace com o status da resposta.
+func (tr *Trace) finish(ctx context.Context, operation string, status int) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.Operation = operation
+	tr.Status = status
+	tr.DurationMs = durationMs(time.Since(tr.start))
+	if corrID, ok := ctx.Value("correlation_id").(string); ok {
+		tr.CorrelationID = corrID
+	}
+}
+
+func (tr *Trace) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range tr.redact {
+		if name != "" && strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitize converte o valor para tipos nativos, mascara campos sensíveis e trunca textos longos.
+func (tr *Trace) sanitize(v interface{}) interface{} {
+	switch x := rules.Native(v).(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			if tr.sensitive(k) {
+				out[k] = redactedValue
+			} else {
+				out[k] = tr.sanitize(val)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, val := range x {
+			out[i] = tr.sanitize(val)
+		}
+		return out
+	case string:
+		if runes := []rune(x); len(runes) > maxTraceStringSize {
+			return string(runes[:maxTraceStringSize]) + "...(truncado)"
+		}
+		return x
+	default:
+		return x
+	}
+}
+
+// emitTrace entrega o trace conforme trace.output: no log (default) e/ou no header X-Trace.
+func (se *ServiceEngine) emitTrace(tr *Trace, output string, headers map[string]string) {
+	tr.mu.Lock()
+	data, err := json.Marshal(tr)
+	tr.mu.Unlock()
+	if err != nil {
+		se.Logger.Warn().Err(err).Msg("Falha ao serializar trace")
+		return
+	}
+
+	toLog := output == "" || output == TraceOutputLog || output == TraceOutputBoth
+	if output == TraceOutputHeader || output == TraceOutputBoth {
+		encoded := base64.RawURLEncoding.EncodeToString(data)
+		if len(encoded) <= maxTraceHeaderSize {
+			headers[TraceHeader] = encoded
+		} else {
+			headers[TraceHeader] = "omitted: trace too large, see logs"
+			toLog = true
+		}
+	}
+	if toLog {
+		se.Logger.Info().RawJSON("trace", data).Msg("Trace de execução")
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// payloadSize estima o tamanho do payload retornado por uma source.
+func payloadSize(v interface{}) int {
+	data, err := json.Marshal(rules.Native(v))
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const traceServiceYAML = `
+version: "1.0"
+service:
+  name: "credit"
+  runtime: "lambda"
+  route: "/credit"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+  trace:
+    enabled: true
+    secret: "s3cr3t"
+    output: "header"
+    redact: ["income"]
+middlewares:
+  - type: "enrichment"
+    id: "enrich"
+    config:
+      sources:
+        - name: "bureau"
+          type: "fixed"
+          params: { value: { score: 720, document: "52998224725", income: 9000 } }
+steps:
+  input:
+    validations:
+      - id: "amount"
+        expr: "input.amount > 0"
+        on_fail: { code: 400, msg: "Invalid amount" }
+  processing:
+    transformations:
+      - name: "tier"
+        value: "detection.bureau.score >= 700 ? 'gold' : 'basic'"
+        target: "vars.tier"
+      - name: "bonus"
+        condition: "vars.tier == 'basic'"
+        value: "10"
+        target: "vars.bonus"
+  output:
+    status_code: 200
+    body:
+      tier: "${vars.tier}"
+`
+
+func decodeTraceHeader(t *testing.T, value string) *Trace {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatalf("Header X-Trace inválido: %v", err)
+	}
+	tr := &Trace{}
+	if err := json.Unmarshal(data, tr); err != nil {
+		t.Fatalf("Trace inválido: %v", err)
+	}
+	return tr
+}
+
+func traceRequest(token string) context.Context {
+	ctx := context.WithValue(context.Background(), "correlation_id", "corr-9")
+	ctx = context.WithValue(ctx, "request_info", map[string]interface{}{"method": "POST", "path": "/proposals"})
+	return context.WithValue(ctx, "request_headers", map[string]string{"x-trace-token": token})
+}
+
+func TestExecute_Trace(t *testing.T) {
+	svc, err := loadSchemaService(t, traceServiceYAML)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	payload := []byte(`{"amount": 100}`)
+
+	// Sem token válido a requisição não é rastreada: segredo errado, expirado, datado no
+	// futuro ou assinado para outra operação ou método
+	for _, token := range []string{
+		"",
+		TraceToken("outro-segredo", DefaultOperationID, "POST", time.Now()),
+		TraceToken("s3cr3t", DefaultOperationID, "POST", time.Now().Add(-10*time.Minute)),
+		TraceToken("s3cr3t", DefaultOperationID, "POST", time.Now().Add(2*time.Minute)),
+		TraceToken("s3cr3t", "outra-operacao", "POST", time.Now()),
+		TraceToken("s3cr3t", DefaultOperationID, "GET", time.Now()),
+	} {
+		_, _, headers, err := svc.Execute(traceRequest(token), payload)
+		assert.NoError(t, err)
+		assert.NotContains(t, headers, TraceHeader, token)
+	}
+
+	code, _, headers, err := svc.Execute(traceRequest(TraceToken("s3cr3t", DefaultOperationID, "post", time.Now())), payload)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	if !assert.Contains(t, headers, TraceHeader) {
+		return
+	}
+	tr := decodeTraceHeader(t, headers[TraceHeader])
+	assert.Equal(t, DefaultOperationID, tr.Operation)
+	assert.Equal(t, "corr-9", tr.CorrelationID)
+	assert.Equal(t, 200, tr.Status)
+
+	var summary []string
+	for _, e := range tr.Entries {
+		summary = append(summary, e.Step+"/"+e.Kind+"/"+e.ID)
+	}
+	assert.Equal(t, []string{
+		"enrichment/source/bureau",
+		"input/validation/amount",
+		"processing/transformation/tier",
+		"processing/transformation/bonus",
+		"output/output/",
+	}, summary)
+
+	source := tr.Entries[0]
+	assert.Equal(t, map[string]interface{}{"score": 720.0, "document": "***", "income": "***"}, source.Result)
+	assert.Equal(t, 1, source.Attempts)
+	assert.Greater(t, source.Size, 0)
+	assert.Equal(t, "input.amount > 0", tr.Entries[1].Expr)
+	assert.Equal(t, true, tr.Entries[1].Result)
+	assert.Equal(t, "gold", tr.Entries[2].Result)
+	assert.True(t, tr.Entries[3].Skipped)
+	assert.Equal(t, 200.0, tr.Entries[4].Result)
+}
+
+func TestExecute_TraceOnError(t *testing.T) {
+	svc, err := loadSchemaService(t, strings.Replace(traceServiceYAML, `enabled: true`, "enabled: true\n    all: true", 1))
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	// Com 'all', o token não é necessário; a falha também fica registrada
+	code, _, headers, err := svc.Execute(context.Background(), []byte(`{"amount": 0}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, code)
+	tr := decodeTraceHeader(t, headers[TraceHeader])
+	assert.Equal(t, 400, tr.Status)
+	last := tr.Entries[len(tr.Entries)-1]
+	assert.Equal(t, "amount", last.ID)
+	assert.Equal(t, false, last.Result)
+}
+
+func TestWithTrace(t *testing.T) {
+	svc, err := loadSchemaService(t, strings.Replace(traceServiceYAML, "enabled: true", "enabled: false", 1))
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	ctx, tr := WithTrace(context.Background())
+	code, _, headers, err := svc.Execute(ctx, []byte(`{"amount": 10}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.NotContains(t, headers, TraceHeader)
+	assert.Equal(t, 200, tr.Status)
+	assert.Len(t, tr.Entries, 5)
+}
+
+func TestBuildTracer(t *testing.T) {
+	tracer, err := buildTracer(config.TraceConf{})
+	assert.NoError(t, err)
+	assert.Nil(t, tracer)
+
+	_, err = buildTracer(config.TraceConf{Enabled: true})
+	assert.ErrorContains(t, err, "'secret' é obrigatório")
+	_, err = buildTracer(config.TraceConf{Enabled: true, Secret: "x", TokenTTL: "5 minutos"})
+	assert.ErrorContains(t, err, "token_ttl inválido")
+}
@@ -0,0 +1,142 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ParamType é o tipo esperado do valor de um parâmetro. Vazio aceita qualquer valor.
+type ParamType string
+
+const (
+	ParamAny    ParamType = ""
+	ParamString ParamType = "string" // Texto ou outro escalar, convertido para texto
+	ParamBool   ParamType = "bool"
+	ParamInt    ParamType = "int"
+	ParamMap    ParamType = "map"
+	ParamList   ParamType = "list"
+)
+
+// AnyParam, usado como Name de uma ParamSpec, indica que o adapter aceita parâmetros
+// livres além dos declarados (ex: fixed, que devolve o próprio mapa de params).
+const AnyParam = "*"
+
+// ParamSpec descreve um parâmetro aceito por um adapter.
+type ParamSpec struct {
+	Name        string
+	Aliases     []string // Nomes alternativos aceitos para o mesmo parâmetro
+	Required    bool
+	Type        ParamType
+	Description string
+}
+
+// Request reúne os parâmetros e headers já resolvidos de uma chamada de source.
+type Request struct {
+	Params  map[string]interface{}
+	Headers map[string]string
+}
+
+// Adapter é um conector de fonte de dados utilizável em qualquer 'source:'
+// (middlewares enrichment e resolvers GraphQL).
+type Adapter interface {
+	// Name é o valor de 'type' que seleciona o adapter.
+	Name() string
+	// Params descreve os parâmetros aceitos, usado na validação da configuração.
+	Params() []ParamSpec
+	// Fetch executa a chamada com os parâmetros resolvidos.
+	Fetch(ctx context.Context, req Request) (interface{}, error)
+}
+
+// funcAdapter implementa Adapter a partir de uma função.
+type funcAdapter struct {
+	name   string
+	params []ParamSpec
+	fetch  func(ctx context.Context, req Request) (interface{}, error)
+}
+
+func (a *funcAdapter) Name() string        { return a.name }
+func (a *funcAdapter) Params() []ParamSpec { return a.params }
+func (a *funcAdapter) Fetch(ctx context.Context, req Request) (interface{}, error) {
+	return a.fetch(ctx, req)
+}
+
+// NewAdapter cria um Adapter a partir do nome, do schema de parâmetros e da função de execução.
+func NewAdapter(name string, params []ParamSpec, fetch func(ctx context.Context, req Request) (interface{}, error)) Adapter {
+	return &funcAdapter{name: name, params: params, fetch: fetch}
+}
+
+var (
+	registryMu sync.RWMutex
+	adapters   = make(map[string]Adapter)
+	aliases    = make(map[string]string)
+)
+
+// Register adiciona (ou substitui) um adapter no registro global.
+func Register(a Adapter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	adapters[a.Name()] = a
+}
+
+// RegisterAlias faz 'alias' apontar para o adapter 'name' (ex.: dynamodb -> aws_dynamodb).
+func RegisterAlias(alias, name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	aliases[alias] = name
+}
+
+// Lookup retorna o adapter registrado para o tipo informado, considerando aliases.
+func Lookup(srcType string) (Adapter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if target, ok := aliases[srcType]; ok {
+		srcType = target
+	}
+	a, ok := adapters[srcType]
+	return a, ok
+}
+
+// Adapters retorna os nomes dos adapters registrados, em ordem alfabética.
+func Adapters() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fetch localiza o adapter do tipo de source e executa a chamada.
+func Fetch(ctx context.Context, srcType string, params map[string]interface{}, headers map[string]string) (interface{}, error) {
+	a, ok := Lookup(srcType)
+	if !ok {
+		return nil, fmt.Errorf("tipo de source desconhecido: %s", srcType)
+	}
+	return a.Fetch(ctx, Request{Params: params, Headers: headers})
+}
+
+// ValidateParams retorna o primeiro erro de CheckParams (avisos são ignorados), ou nil.
+func ValidateParams(srcType string, params map[string]interface{}) error {
+	for _, issue := range CheckParams(srcType, params, false) {
+		if !issue.Warning {
+			return issue
+		}
+	}
+	return nil
+}
+
+func hasParam(params map[string]interface{}, spec ParamSpec) bool {
+	if v, ok := params[spec.Name]; ok && v != nil {
+		return true
+	}
+	for _, alias := range spec.Aliases {
+		if v, ok := params[alias]; ok && v != nil {
+			return true
+		}
+	}
+	return false
+}
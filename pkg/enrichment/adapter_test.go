@@ -0,0 +1,66 @@
+package enrichment
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_CustomAdapter(t *testing.T) {
+	Register(NewAdapter("test_echo", []ParamSpec{
+		{Name: "msg", Aliases: []string{"message"}, Required: true},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		return stringParam(req.Params, "msg") + stringParam(req.Params, "message") + req.Headers["X-Suffix"], nil
+	}))
+	RegisterAlias("echo", "test_echo")
+
+	t.Run("Deve executar o adapter pelo nome e pelo alias", func(t *testing.T) {
+		for _, srcType := range []string{"test_echo", "echo"} {
+			res, err := Fetch(context.Background(), srcType, map[string]interface{}{"msg": "oi"}, map[string]string{"X-Suffix": "!"})
+			if err != nil {
+				t.Fatalf("Erro inesperado: %v", err)
+			}
+			if res != "oi!" {
+				t.Errorf("Esperado 'oi!', recebido %v", res)
+			}
+		}
+	})
+
+	t.Run("Deve validar parâmetros obrigatórios considerando aliases", func(t *testing.T) {
+		if err := ValidateParams("echo", map[string]interface{}{"message": "oi"}); err != nil {
+			t.Errorf("Alias deveria satisfazer o parâmetro obrigatório: %v", err)
+		}
+		err := ValidateParams("test_echo", map[string]interface{}{})
+		if err == nil || !strings.Contains(err.Error(), "msg") {
+			t.Errorf("Esperado erro citando 'msg', recebido %v", err)
+		}
+	})
+}
+
+func TestRegistry_BuiltinAdapters(t *testing.T) {
+	expected := []string{"fixed", "rest", "graphql", "aws_parameter_store", "aws_secrets_manager", "aws_s3", "aws_dynamodb", "redis", "sql"}
+	for _, name := range expected {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("Adapter nativo '%s' não registrado", name)
+		}
+	}
+	for _, alias := range []string{"dynamodb", "aws_redis"} {
+		if _, ok := Lookup(alias); !ok {
+			t.Errorf("Alias '%s' não registrado", alias)
+		}
+	}
+
+	if _, err := Fetch(context.Background(), "inexistente", nil, nil); err == nil {
+		t.Error("Esperado erro para tipo desconhecido")
+	}
+	if err := ValidateParams("rest", map[string]interface{}{"method": "GET"}); err == nil {
+		t.Error("Esperado erro para rest sem url")
+	}
+	// Mesmas regras de CheckParams: tipos conferidos, parâmetros desconhecidos são apenas avisos
+	if err := ValidateParams("rest", map[string]interface{}{"url": map[string]interface{}{}}); err == nil || !strings.Contains(err.Error(), "deve ser") {
+		t.Errorf("Esperado erro de tipo para url, recebido %v", err)
+	}
+	if err := ValidateParams("rest", map[string]interface{}{"url": "http://x", "methd": "GET"}); err != nil {
+		t.Errorf("Parâmetro desconhecido não deveria ser erro: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+)
+
+// Adapters nativos do toolkit.
+func init() {
+	Register(NewAdapter("fixed", []ParamSpec{
+		{Name: "value", Description: "Valor retornado; sem ele, retorna o mapa de params"},
+		{Name: "error", Type: ParamString, Description: "Mensagem de uma falha simulada (testes)"},
+		{Name: AnyParam, Description: "Demais params compõem o valor retornado quando não há value"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		return ProcessFixed(req.Params)
+	}))
+
+	Register(NewAdapter("rest", []ParamSpec{
+		{Name: "url", Required: true, Type: ParamString, Description: "URL da chamada"},
+		{Name: "method", Type: ParamString, Description: "Método HTTP (default: GET)"},
+		{Name: "body", Description: "Corpo enviado como JSON"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		return ProcessRest(ctx, stringParam(p, "method"), stringParam(p, "url"), req.Headers, p["body"])
+	}))
+
+	Register(NewAdapter("graphql", []ParamSpec{
+		{Name: "endpoint", Required: true, Type: ParamString, Description: "URL do endpoint GraphQL"},
+		{Name: "query", Required: true, Type: ParamString, Description: "Query ou mutation"},
+		{Name: "variables", Type: ParamMap, Description: "Variáveis da operação"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		return ProcessGraphQL(ctx, stringParam(p, "endpoint"), stringParam(p, "query"), mapParam(p, "variables"), req.Headers)
+	}))
+
+	Register(NewAdapter("aws_parameter_store", []ParamSpec{
+		{Name: "path", Required: true, Type: ParamString, Description: "Nome do parâmetro"},
+		{Name: "region", Type: ParamString, Description: "Região AWS"},
+		{Name: "with_decryption", Type: ParamBool, Description: "Descriptografa SecureString"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		decrypt, _ := p["with_decryption"].(bool)
+		return ProcessAWSParameterStore(ctx, stringParam(p, "region"), stringParam(p, "path"), decrypt)
+	}))
+
+	Register(NewAdapter("aws_secrets_manager", []ParamSpec{
+		{Name: "secret_id", Required: true, Type: ParamString, Description: "ID ou ARN do segredo"},
+		{Name: "region", Type: ParamString, Description: "Região AWS"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		return ProcessAWSSecretsManager(ctx, stringParam(p, "region"), stringParam(p, "secret_id"))
+	}))
+
+	Register(NewAdapter("aws_s3", []ParamSpec{
+		{Name: "bucket", Required: true, Type: ParamString, Description: "Nome do bucket"},
+		{Name: "key", Required: true, Type: ParamString, Description: "Chave do objeto"},
+		{Name: "region", Type: ParamString, Description: "Região AWS"},
+		{Name: "format", Type: ParamString, Description: "json, csv ou text"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		return ProcessS3(ctx, stringParam(p, "region"), stringParam(p, "bucket"), stringParam(p, "key"), stringParam(p, "format"))
+	}))
+
+	Register(NewAdapter("aws_dynamodb", []ParamSpec{
+		{Name: "table", Required: true, Type: ParamString, Description: "Nome da tabela"},
+		{Name: "key", Required: true, Type: ParamMap, Description: "Mapa com a chave primária"},
+		{Name: "region", Type: ParamString, Description: "Região AWS"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		keyMap := mapParam(p, "key")
+		if len(keyMap) == 0 {
+			return nil, fmt.Errorf("key vazia")
+		}
+		return ProcessDynamoDB(ctx, stringParam(p, "region"), stringParam(p, "table"), keyMap)
+	}))
+	RegisterAlias("dynamodb", "aws_dynamodb")
+
+	Register(NewAdapter("redis", []ParamSpec{
+		{Name: "addr", Aliases: []string{"host"}, Required: true, Type: ParamString, Description: "Endereço host:porta"},
+		{Name: "key", Required: true, Type: ParamString, Description: "Chave consultada"},
+		{Name: "command", Type: ParamString, Description: "GET (default) ou HGETALL"},
+		{Name: "password", Type: ParamString, Description: "Senha"},
+		{Name: "db", Type: ParamInt, Description: "Database"},
+		{Name: "pool_size", Type: ParamInt, Description: "Tamanho do pool de conexões"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		return ProcessRedis(ctx, RedisConnFromParams(p), stringParam(p, "command"), stringParam(p, "key"))
+	}))
+	RegisterAlias("aws_redis", "redis")
+
+	Register(NewAdapter("sql", []ParamSpec{
+		{Name: "dsn", Required: true, Type: ParamString, Description: "Connection string"},
+		{Name: "query", Required: true, Type: ParamString, Description: "Query com placeholders posicionais ou ':nome'"},
+		{Name: "driver", Type: ParamString, Description: "Driver database/sql (default: postgres)"},
+		{Name: "args", Description: "Lista (posicional) ou mapa (nomeado) de argumentos"},
+		{Name: "max_open_conns", Type: ParamInt, Description: "Máximo de conexões abertas"},
+		{Name: "max_idle_conns", Type: ParamInt, Description: "Máximo de conexões ociosas"},
+	}, func(ctx context.Context, req Request) (interface{}, error) {
+		p := req.Params
+		return ProcessSQL(ctx, SQLConnFromParams(p), stringParam(p, "query"), p["args"])
+	}))
+}
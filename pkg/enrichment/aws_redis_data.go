@@ -4,33 +4,61 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// Cache simples para evitar recriar clientes Redis a cada request (Opcional, mas recomendado)
-var redisClients = make(map[string]*redis.Client)
+// RedisConn descreve a conexão declarada em uma source redis.
+type RedisConn struct {
+	Addr     string
+	Password string
+	DB       int
+	PoolSize int // Opcional: 0 usa o default do go-redis
+}
 
-func getRedisClient(addr, password string) *redis.Client {
-	key := addr + password
-	if client, ok := redisClients[key]; ok {
-		return client
+// RedisConnFromParams extrai a conexão dos params da source ('addr' ou 'host', 'password', 'db', 'pool_size').
+func RedisConnFromParams(params map[string]interface{}) RedisConn {
+	addr := stringParam(params, "addr")
+	if addr == "" {
+		addr = stringParam(params, "host")
 	}
-	client := redis.NewClient(&redis.Options{
+	return RedisConn{
 		Addr:     addr,
-		Password: password,
-		DB:       0,
+		Password: stringParam(params, "password"),
+		DB:       intParam(params, "db"),
+		PoolSize: intParam(params, "pool_size"),
+	}
+}
+
+// redis retorna o cliente compartilhado da conexão, criando-o na primeira chamada.
+// As opções de pool da primeira declaração prevalecem.
+func (c *Clients) redis(conn RedisConn) *redis.Client {
+	key := fmt.Sprintf("redis|%s|%s|%d", conn.Addr, conn.Password, conn.DB)
+	client, _ := c.acquire(key, func() (io.Closer, error) {
+		return redis.NewClient(&redis.Options{
+			Addr:     conn.Addr,
+			Password: conn.Password,
+			DB:       conn.DB,
+			PoolSize: conn.PoolSize,
+		}), nil
 	})
-	redisClients[key] = client
-	return client
+	return client.(*redis.Client)
 }
 
 // ProcessRedis executa comandos GET ou HGETALL.
-func ProcessRedis(ctx context.Context, addr, password, command, key string) (interface{}, error) {
-	client := getRedisClient(addr, password)
+func ProcessRedis(ctx context.Context, conn RedisConn, command, key string) (interface{}, error) {
+	if conn.Addr == "" {
+		return nil, fmt.Errorf("redis: 'addr' não informado")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("redis: 'key' vazia")
+	}
+	client := clientsFrom(ctx).redis(conn)
 
-	switch command {
-	case "get":
+	switch strings.ToLower(command) {
+	case "", "get":
 		val, err := client.Get(ctx, key).Result()
 		if err == redis.Nil {
 			return nil, nil // Key not found
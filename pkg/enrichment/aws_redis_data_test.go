@@ -0,0 +1,51 @@
+package enrichment
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRedisConnFromParams(t *testing.T) {
+	conn := RedisConnFromParams(map[string]interface{}{
+		"host":      "cache:6379",
+		"password":  "secret",
+		"db":        float64(2), // números chegam como float64 após o round-trip JSON
+		"pool_size": "20",
+	})
+	expected := RedisConn{Addr: "cache:6379", Password: "secret", DB: 2, PoolSize: 20}
+	if conn != expected {
+		t.Errorf("Esperado %+v, recebido %+v", expected, conn)
+	}
+}
+
+func TestClients_RedisShared(t *testing.T) {
+	owner := NewClients()
+	defer owner.Close()
+
+	a := owner.redis(RedisConn{Addr: "localhost:6379", DB: 1})
+	b := owner.redis(RedisConn{Addr: "localhost:6379", DB: 1})
+	c := owner.redis(RedisConn{Addr: "localhost:6379", DB: 2})
+
+	if a != b {
+		t.Error("Mesma conexão deveria reutilizar o cliente")
+	}
+	if a == c {
+		t.Error("Databases diferentes deveriam usar clientes distintos")
+	}
+}
+
+func TestProcessRedis_InvalidParams(t *testing.T) {
+	owner := NewClients()
+	defer owner.Close()
+
+	ctx := WithClients(context.Background(), owner)
+	if _, err := ProcessRedis(ctx, RedisConn{}, "get", "k"); err == nil {
+		t.Error("Esperado erro sem addr")
+	}
+	if _, err := ProcessRedis(ctx, RedisConn{Addr: "localhost:6379"}, "get", ""); err == nil {
+		t.Error("Esperado erro com key vazia")
+	}
+	if _, err := ProcessRedis(ctx, RedisConn{Addr: "localhost:6379"}, "del", "k"); err == nil {
+		t.Error("Esperado erro para comando não suportado")
+	}
+}
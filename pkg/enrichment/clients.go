@@ -0,0 +1,211 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// pooled é um cliente Redis ou pool SQL compartilhado no processo, com o número de
+// donos (Clients) que o adquiriram.
+type pooled struct {
+	conn io.Closer
+	refs int
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = make(map[string]*pooled)
+)
+
+// Clients registra os clientes Redis e pools SQL usados por um dono (uma ServiceEngine,
+// por geração de configuração). Os pools são compartilhados no processo, um por conexão,
+// e contados por referência: Close libera apenas as referências deste dono, e o pool só
+// é fechado quando nenhum outro dono o usa.
+type Clients struct {
+	mu       sync.Mutex
+	keys     map[string]bool
+	active   int  // Requisições em andamento (Begin)
+	closing  bool // Close chamado; libera ao fim da última requisição
+	released bool
+}
+
+// NewClients cria um dono sem pools adquiridos.
+func NewClients() *Clients {
+	return &Clients{keys: make(map[string]bool)}
+}
+
+// defaultClients atende chamadas sem Clients no contexto (ex: uso direto do pacote)
+// e donos já liberados. Suas referências duram até o fim do processo.
+var defaultClients = NewClients()
+
+type clientsKey struct{}
+
+// WithClients associa ao contexto o dono dos pools usados pelas sources da requisição.
+func WithClients(ctx context.Context, c *Clients) context.Context {
+	return context.WithValue(ctx, clientsKey{}, c)
+}
+
+func clientsFrom(ctx context.Context) *Clients {
+	if c, ok := ctx.Value(clientsKey{}).(*Clients); ok && c != nil {
+		return c
+	}
+	return defaultClients
+}
+
+// Begin marca uma requisição em andamento e retorna a função que a encerra. Se Close
+// for chamado antes, as referências só são liberadas quando a última requisição termina.
+func (c *Clients) Begin() (end func()) {
+	if c == nil {
+		return func() {}
+	}
+	c.mu.Lock()
+	c.active++
+	c.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.active--
+			if c.closing && c.active == 0 {
+				_ = c.releaseLocked()
+			}
+		})
+	}
+}
+
+// Close libera as referências do dono, fechando os pools que ninguém mais usa. Com
+// requisições em andamento, a liberação fica para o fim da última. Chamadas repetidas
+// não têm efeito.
+func (c *Clients) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	if c.active > 0 {
+		return nil
+	}
+	return c.releaseLocked()
+}
+
+func (c *Clients) releaseLocked() error {
+	if c.released {
+		return nil
+	}
+	c.released = true
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	var errs []error
+	for key := range c.keys {
+		p := pools[key]
+		if p.refs--; p.refs == 0 {
+			if err := p.conn.Close(); err != nil {
+				errs = append(errs, err)
+			}
+			delete(pools, key)
+		}
+	}
+	c.keys = nil
+	return errors.Join(errs...)
+}
+
+// acquire retorna o pool compartilhado da chave, abrindo-o com open na primeira vez,
+// e registra a referência deste dono.
+func (c *Clients) acquire(key string, open func() (io.Closer, error)) (io.Closer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.released {
+		// Requisição que começou antes de um reload e chegou depois da liberação
+		return defaultClients.acquire(key, open)
+	}
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	p, ok := pools[key]
+	if !ok {
+		conn, err := open()
+		if err != nil {
+			return nil, err
+		}
+		p = &pooled{conn: conn}
+		pools[key] = p
+	}
+	if !c.keys[key] {
+		c.keys[key] = true
+		p.refs++
+	}
+	return p.conn, nil
+}
+
+// Preload adquire, antes da primeira chamada, o pool de uma source cujo tipo mantém
+// conexões (redis e sql); os demais tipos são ignorados. params deve estar resolvido.
+func (c *Clients) Preload(srcType string, params map[string]interface{}) error {
+	a, ok := Lookup(srcType)
+	if !ok {
+		return nil
+	}
+	switch a.Name() {
+	case "redis":
+		if conn := RedisConnFromParams(params); conn.Addr != "" {
+			c.redis(conn)
+		}
+	case "sql":
+		if conn := SQLConnFromParams(params); conn.DSN != "" {
+			if _, err := c.sqlDB(conn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func stringParam(params map[string]interface{}, key string) string {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// intParam aceita inteiros vindos do YAML, números do round-trip JSON e strings interpoladas.
+func intParam(params map[string]interface{}, key string) int {
+	switch v := params[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+func mapParam(params map[string]interface{}, key string) map[string]interface{} {
+	switch m := params[key].(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[fmt.Sprintf("%v", k)] = v
+		}
+		return out
+	}
+	return nil
+}
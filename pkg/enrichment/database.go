@@ -4,26 +4,88 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"time"
+	"io"
+	"strconv"
+	"strings"
 
 	_ "github.com/lib/pq" // Driver Postgres (Importe outros no main.go se necessário)
 )
 
-// ProcessSQL executa uma query e retorna uma lista de mapas.
-// driver: "postgres", "mysql", etc.
-// dsn: Connection string.
-func ProcessSQL(ctx context.Context, driver, dsn, query string, args []interface{}) ([]map[string]interface{}, error) {
-	db, err := sql.Open(driver, dsn)
+// SQLConn descreve a conexão declarada em uma source sql.
+type SQLConn struct {
+	Driver       string
+	DSN          string
+	MaxOpenConns int // Opcional: 0 = sem limite
+	MaxIdleConns int // Opcional: 0 usa o default do database/sql
+}
+
+// SQLConnFromParams extrai a conexão dos params da source ('driver', 'dsn', 'max_open_conns', 'max_idle_conns').
+// O driver padrão é postgres.
+func SQLConnFromParams(params map[string]interface{}) SQLConn {
+	driver := stringParam(params, "driver")
+	if driver == "" {
+		driver = "postgres"
+	}
+	return SQLConn{
+		Driver:       driver,
+		DSN:          stringParam(params, "dsn"),
+		MaxOpenConns: intParam(params, "max_open_conns"),
+		MaxIdleConns: intParam(params, "max_idle_conns"),
+	}
+}
+
+// sqlDB retorna o pool compartilhado da conexão, abrindo-o na primeira chamada.
+// As opções de pool da primeira declaração prevalecem.
+func (c *Clients) sqlDB(conn SQLConn) (*sql.DB, error) {
+	key := "sql|" + conn.Driver + "|" + conn.DSN
+	db, err := c.acquire(key, func() (io.Closer, error) {
+		db, err := sql.Open(conn.Driver, conn.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao abrir conexão SQL: %w", err)
+		}
+		if conn.MaxOpenConns > 0 {
+			db.SetMaxOpenConns(conn.MaxOpenConns)
+		}
+		if conn.MaxIdleConns > 0 {
+			db.SetMaxIdleConns(conn.MaxIdleConns)
+		}
+		return db, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("erro ao abrir conexão SQL: %w", err)
+		return nil, err
 	}
-	defer db.Close()
+	return db.(*sql.DB), nil
+}
 
-	// Timeout de segurança para banco de dados
-	ctxDb, cancel := context.WithTimeout(ctx, 5*time.Second) // Poderia ser configurável
-	defer cancel()
+// ProcessSQL executa uma query e retorna uma lista de mapas.
+// args pode ser uma lista (placeholders posicionais do driver) ou um mapa,
+// caso em que a query usa placeholders nomeados no formato ':nome'.
+func ProcessSQL(ctx context.Context, conn SQLConn, query string, args interface{}) ([]map[string]interface{}, error) {
+	if conn.DSN == "" {
+		return nil, fmt.Errorf("sql: 'dsn' não informado")
+	}
+
+	var positional []interface{}
+	switch a := args.(type) {
+	case nil:
+	case []interface{}:
+		positional = a
+	case map[string]interface{}:
+		var err error
+		if query, positional, err = BindNamed(conn.Driver, query, a); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("sql: 'args' deve ser uma lista ou um mapa, recebido %T", args)
+	}
+
+	db, err := clientsFrom(ctx).sqlDB(conn)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := db.QueryContext(ctxDb, query, args...)
+	// O timeout é controlado pela política de chamada da source (ctx)
+	rows, err := db.QueryContext(ctx, query, positional...)
 	if err != nil {
 		return nil, fmt.Errorf("erro na query SQL: %w", err)
 	}
@@ -61,5 +123,69 @@ func ProcessSQL(ctx context.Context, driver, dsn, query string, args []interface
 		finalResult = append(finalResult, entry)
 	}
 
-	return finalResult, nil
+	return finalResult, rows.Err()
+}
+
+// BindNamed reescreve placeholders ':nome' para o formato posicional do driver
+// ($1, $2... para postgres; ? para os demais) e retorna os argumentos na ordem.
+// Literais entre aspas simples e casts '::tipo' são preservados.
+func BindNamed(driver, query string, named map[string]interface{}) (string, []interface{}, error) {
+	dollar := driver == "postgres" || driver == "pgx"
+
+	var (
+		out      strings.Builder
+		args     []interface{}
+		position = make(map[string]int)
+		inQuote  bool
+	)
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			inQuote = !inQuote
+		}
+		if inQuote || c != ':' {
+			out.WriteByte(c)
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == ':' {
+			out.WriteString("::")
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && isIdentChar(query[j]) {
+			j++
+		}
+		name := query[i+1 : j]
+		if name == "" {
+			out.WriteByte(c)
+			continue
+		}
+
+		val, ok := named[name]
+		if !ok {
+			return "", nil, fmt.Errorf("sql: argumento nomeado ':%s' não informado em 'args'", name)
+		}
+		if dollar {
+			idx, seen := position[name]
+			if !seen {
+				args = append(args, val)
+				idx = len(args)
+				position[name] = idx
+			}
+			out.WriteString("$" + strconv.Itoa(idx))
+		} else {
+			args = append(args, val)
+			out.WriteByte('?')
+		}
+		i = j - 1
+	}
+
+	return out.String(), args, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
@@ -0,0 +1,169 @@
+package enrichment
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// --- Driver SQL falso: devolve os argumentos recebidos como colunas ---
+
+var fakeOpens int32
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	atomic.AddInt32(&fakeOpens, 1)
+	return &fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{query: query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct{ query string }
+
+func (s *fakeStmt) Close() error                                    { return nil }
+func (s *fakeStmt) NumInput() int                                   { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{query: s.query, args: args}, nil
+}
+
+type fakeRows struct {
+	query string
+	args  []driver.Value
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"query", "arg"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = []byte(r.query)
+	if len(r.args) > 0 {
+		dest[1] = r.args[0]
+	}
+	return nil
+}
+
+func init() {
+	sql.Register("fakesql", fakeDriver{})
+}
+
+func TestProcessSQL(t *testing.T) {
+	conn := SQLConn{Driver: "fakesql", DSN: "memory"}
+	owner := NewClients()
+	defer owner.Close()
+	ctx := WithClients(context.Background(), owner)
+
+	t.Run("Deve aceitar argumentos posicionais", func(t *testing.T) {
+		rows, err := ProcessSQL(ctx, conn, "SELECT ?", []interface{}{"C-1"})
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["arg"] != "C-1" {
+			t.Errorf("Resultado inesperado: %v", rows)
+		}
+	})
+
+	t.Run("Deve reescrever argumentos nomeados", func(t *testing.T) {
+		rows, err := ProcessSQL(ctx, conn, "SELECT :id", map[string]interface{}{"id": "C-2"})
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if rows[0]["query"] != "SELECT ?" || rows[0]["arg"] != "C-2" {
+			t.Errorf("Resultado inesperado: %v", rows)
+		}
+	})
+
+	t.Run("Deve reutilizar o pool entre chamadas", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			if _, err := ProcessSQL(ctx, conn, "SELECT 1", nil); err != nil {
+				t.Fatalf("Erro inesperado: %v", err)
+			}
+		}
+		if opens := atomic.LoadInt32(&fakeOpens); opens != 1 {
+			t.Errorf("Esperada 1 conexão aberta, recebido %d", opens)
+		}
+	})
+
+	t.Run("Deve rejeitar dsn vazio", func(t *testing.T) {
+		if _, err := ProcessSQL(ctx, SQLConn{Driver: "fakesql"}, "SELECT 1", nil); err == nil {
+			t.Error("Esperado erro para dsn vazio")
+		}
+	})
+}
+
+func TestClients_RefCount(t *testing.T) {
+	conn := SQLConn{Driver: "fakesql", DSN: "shared"}
+	a, b := NewClients(), NewClients()
+
+	dbA, err := a.sqlDB(conn)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	dbB, _ := b.sqlDB(conn)
+	if dbA != dbB {
+		t.Fatal("Donos diferentes deveriam compartilhar o pool da mesma conexão")
+	}
+
+	// O pool continua aberto enquanto outro dono o usa
+	if err := a.Close(); err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if _, err := ProcessSQL(WithClients(context.Background(), b), conn, "SELECT 1", nil); err != nil {
+		t.Errorf("Pool de b não deveria ser fechado por a: %v", err)
+	}
+
+	// Com uma requisição em andamento, a liberação espera o seu fim
+	end := b.Begin()
+	_ = b.Close()
+	if err := dbB.Ping(); err != nil {
+		t.Errorf("Pool não deveria fechar com requisição em andamento: %v", err)
+	}
+	end()
+	if err := dbB.Ping(); err == nil {
+		t.Error("Pool deveria ser fechado após a liberação do último dono")
+	}
+}
+
+func TestBindNamed(t *testing.T) {
+	named := map[string]interface{}{"id": 7, "status": "ativo"}
+
+	t.Run("Postgres usa placeholders numerados e reaproveita nomes repetidos", func(t *testing.T) {
+		query, args, err := BindNamed("postgres", "SELECT * FROM t WHERE id = :id AND parent = :id AND status = :status AND created::date = ':literal'", named)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		expected := "SELECT * FROM t WHERE id = $1 AND parent = $1 AND status = $2 AND created::date = ':literal'"
+		if query != expected {
+			t.Errorf("Query esperada %q, recebida %q", expected, query)
+		}
+		if !reflect.DeepEqual(args, []interface{}{7, "ativo"}) {
+			t.Errorf("Args inesperados: %v", args)
+		}
+	})
+
+	t.Run("Demais drivers usam ?", func(t *testing.T) {
+		query, args, _ := BindNamed("mysql", "SELECT * FROM t WHERE id = :id OR parent = :id", named)
+		if query != "SELECT * FROM t WHERE id = ? OR parent = ?" || len(args) != 2 {
+			t.Errorf("Resultado inesperado: %q %v", query, args)
+		}
+	})
+
+	t.Run("Deve falhar com argumento ausente", func(t *testing.T) {
+		if _, _, err := BindNamed("postgres", "SELECT :missing", named); err == nil {
+			t.Error("Esperado erro para argumento ausente")
+		}
+	})
+}
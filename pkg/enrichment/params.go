@@ -0,0 +1,223 @@
+package enrichment
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commonParams são aceitos por qualquer tipo de source.
+var commonParams = []ParamSpec{
+	{Name: "timeout", Type: ParamString, Description: "Timeout da chamada (legado; prefira timeout na source)"},
+	{Name: "response_path", Type: ParamString, Description: "Caminho extraído da resposta (resolvers GraphQL)"},
+}
+
+// ParamIssue é um problema encontrado nos params de uma source.
+type ParamIssue struct {
+	Param   string // Parâmetro de primeiro nível envolvido; vazio quando o problema é da source
+	Message string
+	Warning bool // Não impede a execução (ex: parâmetro desconhecido)
+}
+
+func (i ParamIssue) Error() string { return i.Message }
+
+// CheckParams confere os params de uma source contra o schema do adapter: tipo de source,
+// parâmetros obrigatórios, tipo dos valores, parâmetros desconhecidos (aviso) e a sintaxe
+// das interpolações "${...}". Valores interpolados não têm o tipo conferido. Com
+// expressions, textos sem "${...}" também podem ser expressões CEL (resolvers GraphQL)
+// e só são conferidos quando o parâmetro espera texto.
+func CheckParams(srcType string, params map[string]interface{}, expressions bool) []ParamIssue {
+	a, ok := Lookup(srcType)
+	if !ok {
+		return []ParamIssue{{Message: fmt.Sprintf("tipo de source desconhecido: '%s' (disponíveis: %s)", srcType, strings.Join(Adapters(), ", "))}}
+	}
+
+	var issues []ParamIssue
+	specs := append(append([]ParamSpec{}, a.Params()...), commonParams...)
+	if missing := missingParams(specs, params); len(missing) > 0 {
+		issues = append(issues, ParamIssue{Message: fmt.Sprintf("source '%s': parâmetros obrigatórios ausentes: %s", srcType, strings.Join(missing, ", "))})
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := params[name]
+		walkParamStrings(value, func(s string) {
+			if err := CheckInterpolations(s); err != nil {
+				issues = append(issues, ParamIssue{Param: name, Message: fmt.Sprintf("parâmetro '%s': %v", name, err)})
+			}
+		})
+
+		spec, known := findParam(specs, name)
+		if !known {
+			if _, open := findParam(specs, AnyParam); open {
+				continue
+			}
+			msg := fmt.Sprintf("parâmetro desconhecido '%s' para source '%s'", name, srcType)
+			if suggestion := closestParam(specs, name); suggestion != "" {
+				msg += fmt.Sprintf(" (quis dizer '%s'?)", suggestion)
+			}
+			issues = append(issues, ParamIssue{Param: name, Message: msg, Warning: true})
+			continue
+		}
+		if s, isText := value.(string); isText && (strings.Contains(s, "${") || (expressions && spec.Type != ParamString)) {
+			continue
+		}
+		if value != nil && !matchesType(spec.Type, value) {
+			issues = append(issues, ParamIssue{Param: name, Message: fmt.Sprintf("parâmetro '%s' deve ser %s, recebido %s", name, spec.Type, describeValue(value))})
+		}
+	}
+	return issues
+}
+
+// CheckInterpolations confere se os blocos "${...}" do texto estão fechados e não vazios.
+// Blocos malformados não são interpolados e seguiriam como texto literal.
+func CheckInterpolations(s string) error {
+	rest := s
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return fmt.Errorf("interpolação sem '}' de fechamento em '%s'", s)
+		}
+		if strings.TrimSpace(rest[start+2:start+end]) == "" {
+			return fmt.Errorf("interpolação vazia em '%s'", s)
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+func missingParams(specs []ParamSpec, params map[string]interface{}) []string {
+	var missing []string
+	for _, spec := range specs {
+		if spec.Required && !hasParam(params, spec) {
+			missing = append(missing, spec.Name)
+		}
+	}
+	return missing
+}
+
+func findParam(specs []ParamSpec, name string) (ParamSpec, bool) {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return spec, true
+		}
+		for _, alias := range spec.Aliases {
+			if alias == name {
+				return spec, true
+			}
+		}
+	}
+	return ParamSpec{}, false
+}
+
+// closestParam sugere o parâmetro declarado mais próximo de um nome desconhecido,
+// tolerando até duas edições (ex: with_decription → with_decryption).
+func closestParam(specs []ParamSpec, name string) string {
+	best, bestDist := "", 3
+	for _, spec := range specs {
+		for _, candidate := range append([]string{spec.Name}, spec.Aliases...) {
+			if candidate == AnyParam {
+				continue
+			}
+			if d := editDistance(name, candidate); d < bestDist {
+				best, bestDist = candidate, d
+			}
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func matchesType(t ParamType, value interface{}) bool {
+	switch t {
+	case ParamString:
+		switch value.(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return false
+		}
+		return true
+	case ParamBool:
+		_, ok := value.(bool)
+		return ok
+	case ParamInt:
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		case string:
+			_, err := strconv.Atoi(v)
+			return err == nil
+		}
+		return false
+	case ParamMap:
+		switch value.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			return true
+		}
+		return false
+	case ParamList:
+		_, ok := value.([]interface{})
+		return ok
+	}
+	return true
+}
+
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	case string:
+		return fmt.Sprintf("'%s'", v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func walkParamStrings(v interface{}, fn func(string)) {
+	switch x := v.(type) {
+	case string:
+		fn(x)
+	case map[string]interface{}:
+		for _, val := range x {
+			walkParamStrings(val, fn)
+		}
+	case map[interface{}]interface{}:
+		for _, val := range x {
+			walkParamStrings(val, fn)
+		}
+	case []interface{}:
+		for _, val := range x {
+			walkParamStrings(val, fn)
+		}
+	}
+}
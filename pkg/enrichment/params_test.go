@@ -0,0 +1,52 @@
+package enrichment
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckParams(t *testing.T) {
+	cases := []struct {
+		name        string
+		srcType     string
+		params      map[string]interface{}
+		expressions bool
+		want        []string // Trechos esperados, na ordem das issues
+		warnings    int
+	}{
+		{"válido", "rest", map[string]interface{}{"url": "http://x/${input.id}", "timeout": "1s"}, false, nil, 0},
+		{"tipo desconhecido", "ftp", nil, false, []string{"tipo de source desconhecido: 'ftp'"}, 0},
+		{"obrigatórios ausentes", "aws_dynamodb", map[string]interface{}{"region": "us-east-1"}, false, []string{"parâmetros obrigatórios ausentes: table, key"}, 0},
+		{"typo com sugestão", "aws_parameter_store", map[string]interface{}{"path": "/p", "with_decription": true}, false,
+			[]string{"parâmetro desconhecido 'with_decription' para source 'aws_parameter_store' (quis dizer 'with_decryption'?)"}, 1},
+		{"desconhecido sem sugestão", "rest", map[string]interface{}{"url": "http://x", "body_type": "json"}, false, []string{"parâmetro desconhecido 'body_type'"}, 1},
+		{"tipos", "redis", map[string]interface{}{"addr": "h:6379", "key": "k", "db": "um", "pool_size": 5}, false, []string{"parâmetro 'db' deve ser int, recebido 'um'"}, 0},
+		{"map", "aws_dynamodb", map[string]interface{}{"table": "t", "key": "id"}, false, []string{"parâmetro 'key' deve ser map, recebido 'id'"}, 0},
+		{"interpolado não é conferido", "aws_dynamodb", map[string]interface{}{"table": "t", "key": "${vars.key}"}, false, nil, 0},
+		{"expressão GraphQL", "aws_dynamodb", map[string]interface{}{"table": "t", "key": "{'id': args.id}"}, true, nil, 0},
+		{"fixed aceita params livres", "fixed", map[string]interface{}{"tax_rate": 0.1}, false, nil, 0},
+		{"interpolação aberta", "rest", map[string]interface{}{"url": "http://x/${input.id"}, false, []string{"parâmetro 'url': interpolação sem '}' de fechamento"}, 0},
+		{"interpolação vazia", "rest", map[string]interface{}{"url": "http://x", "body": map[string]interface{}{"id": "${}"}}, false, []string{"parâmetro 'body': interpolação vazia"}, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			issues := CheckParams(c.srcType, c.params, c.expressions)
+			if len(issues) != len(c.want) {
+				t.Fatalf("Esperadas %d issues, recebidas %v", len(c.want), issues)
+			}
+			warnings := 0
+			for i, issue := range issues {
+				if !strings.Contains(issue.Message, c.want[i]) {
+					t.Errorf("Esperado '%s', recebido '%s'", c.want[i], issue.Message)
+				}
+				if issue.Warning {
+					warnings++
+				}
+			}
+			if warnings != c.warnings {
+				t.Errorf("Esperados %d avisos, recebidos %d", c.warnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+const (
+	// DefaultTimeout é aplicado a cada tentativa quando a source não define 'timeout'
+	// e a requisição não possui prazo próprio.
+	DefaultTimeout  = 10 * time.Second
+	defaultDelay    = 100 * time.Millisecond
+	defaultMaxDelay = 5 * time.Second
+
+	// MetricSourceAttempts é o histograma com o número de tentativas de cada chamada de source.
+	MetricSourceAttempts = "enrichment.source.attempts"
+)
+
+// defaultRetryOn é usado quando 'retries' é definido sem 'retry_on'.
+var defaultRetryOn = []string{"timeout", "network", "5xx", "429"}
+
+// HTTPError representa uma resposta HTTP de erro (status >= 400) de uma source.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http error %d: %s", e.StatusCode, e.Body)
+}
+
+// CallPolicy controla timeout e retentativas de uma chamada de source.
+type CallPolicy struct {
+	Timeout     time.Duration // 0: usa o prazo restante do contexto (ou DefaultTimeout)
+	Retries     int
+	Exponential bool
+	Delay       time.Duration
+	MaxDelay    time.Duration
+	Jitter      bool
+	RetryOn     []string
+}
+
+// NewCallPolicy converte a configuração declarada na source em uma CallPolicy.
+func NewCallPolicy(conf config.CallPolicyConf) (CallPolicy, error) {
+	p := CallPolicy{
+		Retries:  conf.Retries,
+		Delay:    defaultDelay,
+		MaxDelay: defaultMaxDelay,
+		Jitter:   conf.Backoff.Jitter,
+		RetryOn:  conf.RetryOn,
+	}
+
+	if conf.Retries < 0 {
+		return p, fmt.Errorf("retries não pode ser negativo: %d", conf.Retries)
+	}
+
+	var err error
+	if conf.Timeout != "" {
+		if p.Timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return p, fmt.Errorf("timeout inválido '%s': %w", conf.Timeout, err)
+		}
+	}
+	if conf.Backoff.Delay != "" {
+		if p.Delay, err = time.ParseDuration(conf.Backoff.Delay); err != nil {
+			return p, fmt.Errorf("backoff.delay inválido '%s': %w", conf.Backoff.Delay, err)
+		}
+	}
+	if conf.Backoff.MaxDelay != "" {
+		if p.MaxDelay, err = time.ParseDuration(conf.Backoff.MaxDelay); err != nil {
+			return p, fmt.Errorf("backoff.max_delay inválido '%s': %w", conf.Backoff.MaxDelay, err)
+		}
+		if p.MaxDelay < p.Delay {
+			return p, fmt.Errorf("backoff.max_delay (%s) não pode ser menor que backoff.delay (%s)", p.MaxDelay, p.Delay)
+		}
+	} else if p.MaxDelay < p.Delay {
+		// Sem max_delay declarado, um delay acima do default não é encurtado
+		p.MaxDelay = p.Delay
+	}
+
+	switch conf.Backoff.Type {
+	case "", "constant":
+	case "exponential":
+		p.Exponential = true
+	default:
+		return p, fmt.Errorf("backoff desconhecido: '%s' (use constant ou exponential)", conf.Backoff.Type)
+	}
+
+	for _, class := range p.RetryOn {
+		if !validRetryClass(class) {
+			return p, fmt.Errorf("retry_on inválido: '%s'", class)
+		}
+	}
+	if len(p.RetryOn) == 0 {
+		p.RetryOn = defaultRetryOn
+	}
+
+	return p, nil
+}
+
+// Do executa fn respeitando o timeout por tentativa e a política de retentativas.
+// Retorna o resultado, o número de tentativas realizadas e o último erro.
+func (p CallPolicy) Do(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, int, error) {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		result, err := p.attempt(ctx, fn)
+		if err == nil {
+			return result, attempt, nil
+		}
+		lastErr = err
+
+		if attempt > p.Retries || ctx.Err() != nil || !p.ShouldRetry(err) {
+			return nil, attempt, lastErr
+		}
+
+		wait := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			// Não há orçamento para outra tentativa
+			return nil, attempt, lastErr
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, attempt, lastErr
+		}
+	}
+}
+
+func (p CallPolicy) attempt(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		if _, hasDeadline := ctx.Deadline(); hasDeadline {
+			return fn(ctx)
+		}
+		timeout = DefaultTimeout
+	}
+	// O contexto filho nunca excede o prazo do pai
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// backoff calcula a espera após a tentativa informada (1-based).
+func (p CallPolicy) backoff(attempt int) time.Duration {
+	d := p.Delay
+	if p.Exponential {
+		// Compara antes de deslocar: com muitas tentativas o shift transbordaria
+		// e voltaria a produzir esperas curtas
+		shift := uint(attempt - 1)
+		if shift >= 63 || p.Delay > p.MaxDelay>>shift {
+			d = p.MaxDelay
+		} else {
+			d = p.Delay << shift
+		}
+	}
+	if p.Jitter && d > 0 {
+		// Equal jitter: metade fixa, metade aleatória
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// ShouldRetry indica se o erro pertence a alguma das classes configuradas em retry_on.
+func (p CallPolicy) ShouldRetry(err error) bool {
+	for _, class := range p.RetryOn {
+		if matchesRetryClass(class, err) {
+			return true
+		}
+	}
+	return false
+}
+
+func validRetryClass(class string) bool {
+	switch class {
+	case "any", "timeout", "network", "4xx", "5xx":
+		return true
+	}
+	code, err := strconv.Atoi(class)
+	return err == nil && code >= 400 && code < 600
+}
+
+func matchesRetryClass(class string, err error) bool {
+	var httpErr *HTTPError
+	isHTTP := errors.As(err, &httpErr)
+
+	switch class {
+	case "any":
+		return true
+	case "timeout":
+		if errors.Is(err, context.DeadlineExceeded) {
+			return true
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	case "network":
+		var urlErr *url.Error
+		var opErr *net.OpError
+		return !isHTTP && (errors.As(err, &urlErr) || errors.As(err, &opErr))
+	case "4xx":
+		return isHTTP && httpErr.StatusCode >= 400 && httpErr.StatusCode < 500
+	case "5xx":
+		return isHTTP && httpErr.StatusCode >= 500
+	default:
+		code, convErr := strconv.Atoi(class)
+		return convErr == nil && isHTTP && httpErr.StatusCode == code
+	}
+}
+
+// PolicyForSource monta a CallPolicy de uma source. Por compatibilidade, quando
+// 'timeout' não é declarado no nível da source, aceita 'params.timeout'.
+func PolicyForSource(conf config.CallPolicyConf, params map[string]interface{}) (CallPolicy, error) {
+	if conf.Timeout == "" {
+		if legacy, ok := params["timeout"].(string); ok {
+			conf.Timeout = legacy
+		}
+	}
+	return NewCallPolicy(conf)
+}
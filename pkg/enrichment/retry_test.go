@@ -0,0 +1,194 @@
+package enrichment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+func TestCallPolicy_Do(t *testing.T) {
+	policy, err := NewCallPolicy(config.CallPolicyConf{
+		Retries: 2,
+		Backoff: config.BackoffConf{Delay: "1ms"},
+	})
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	t.Run("Deve repetir em 5xx até obter sucesso", func(t *testing.T) {
+		calls := 0
+		Client = &MockClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls++
+				status, body := http.StatusServiceUnavailable, "indisponível"
+				if calls == 3 {
+					status, body = http.StatusOK, `{"ok": true}`
+				}
+				return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			},
+		}
+
+		res, attempts, err := policy.Do(context.Background(), func(ctx context.Context) (interface{}, error) {
+			return ProcessRest(ctx, "GET", "http://fake.com", nil, nil)
+		})
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if attempts != 3 {
+			t.Errorf("Esperado 3 tentativas, recebido %d", attempts)
+		}
+		if res.(map[string]interface{})["ok"] != true {
+			t.Errorf("Resultado inesperado: %v", res)
+		}
+	})
+
+	t.Run("Não deve repetir em 4xx", func(t *testing.T) {
+		calls := 0
+		Client = &MockClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls++
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString("not found"))}, nil
+			},
+		}
+
+		_, attempts, err := policy.Do(context.Background(), func(ctx context.Context) (interface{}, error) {
+			return ProcessRest(ctx, "GET", "http://fake.com", nil, nil)
+		})
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("Esperado HTTPError 404, recebido %v", err)
+		}
+		if attempts != 1 || calls != 1 {
+			t.Errorf("Esperado 1 tentativa, recebido %d (%d chamadas)", attempts, calls)
+		}
+	})
+
+	t.Run("Deve aplicar timeout por tentativa", func(t *testing.T) {
+		short, _ := NewCallPolicy(config.CallPolicyConf{Timeout: "10ms", Retries: 1, Backoff: config.BackoffConf{Delay: "1ms"}})
+		_, attempts, err := short.Do(context.Background(), func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Esperado DeadlineExceeded, recebido %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("Timeout deveria ser repetido, recebido %d tentativas", attempts)
+		}
+	})
+}
+
+func TestCallPolicy_ShouldRetry(t *testing.T) {
+	policy := CallPolicy{RetryOn: []string{"502", "timeout"}}
+
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Código específico", &HTTPError{StatusCode: 502}, true},
+		{"Outro 5xx", &HTTPError{StatusCode: 500}, false},
+		{"Timeout", context.DeadlineExceeded, true},
+		{"Erro genérico", errors.New("falha"), false},
+	}
+	for _, c := range cases {
+		if got := policy.ShouldRetry(c.err); got != c.want {
+			t.Errorf("%s: esperado %v, recebido %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestCallPolicy_Backoff(t *testing.T) {
+	policy := CallPolicy{Exponential: true, Delay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("Tentativa %d: esperado %v, recebido %v", i+1, want, got)
+		}
+	}
+
+	// Muitas tentativas não transbordam o shift
+	for _, attempt := range []int{40, 63, 64, 100} {
+		if got := policy.backoff(attempt); got != 300*time.Millisecond {
+			t.Errorf("Tentativa %d: esperado max_delay, recebido %v", attempt, got)
+		}
+	}
+
+	policy.Jitter = true
+	if got := policy.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+		t.Errorf("Jitter fora do intervalo esperado: %v", got)
+	}
+}
+
+func TestPolicyForSource(t *testing.T) {
+	t.Run("Deve aceitar params.timeout legado", func(t *testing.T) {
+		p, err := PolicyForSource(config.CallPolicyConf{}, map[string]interface{}{"timeout": "2s"})
+		if err != nil || p.Timeout != 2*time.Second {
+			t.Errorf("Esperado timeout 2s, recebido %v (%v)", p.Timeout, err)
+		}
+	})
+
+	t.Run("Sem max_delay, delay acima do default não é encurtado", func(t *testing.T) {
+		p, err := PolicyForSource(config.CallPolicyConf{Backoff: config.BackoffConf{Type: "exponential", Delay: "10s"}}, nil)
+		if err != nil || p.backoff(1) != 10*time.Second {
+			t.Errorf("Esperado 10s, recebido %v (%v)", p.backoff(1), err)
+		}
+	})
+
+	t.Run("Deve rejeitar configurações inválidas", func(t *testing.T) {
+		invalid := []config.CallPolicyConf{
+			{Timeout: "rápido"},
+			{Retries: -1},
+			{Backoff: config.BackoffConf{Type: "linear"}},
+			{RetryOn: []string{"3xx"}},
+			{Backoff: config.BackoffConf{Type: "exponential", MaxDelay: "0s"}},
+			{Backoff: config.BackoffConf{Delay: "1s", MaxDelay: "500ms"}},
+		}
+		for _, conf := range invalid {
+			if _, err := PolicyForSource(conf, nil); err == nil {
+				t.Errorf("Configuração %+v deveria ser rejeitada", conf)
+			}
+		}
+	})
+}
+
+func TestCallPolicy_Deadline(t *testing.T) {
+	t.Run("Sem timeout declarado usa o prazo restante do contexto", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+
+		var got time.Time
+		_, _, _ = CallPolicy{}.Do(ctx, func(ctx context.Context) (interface{}, error) {
+			got, _ = ctx.Deadline()
+			return nil, nil
+		})
+		want, _ := ctx.Deadline()
+		if !got.Equal(want) {
+			t.Errorf("Deadline esperado %v, recebido %v", want, got)
+		}
+	})
+
+	t.Run("Não aguarda backoff além do prazo", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		policy := CallPolicy{Retries: 3, Delay: time.Second, RetryOn: []string{"any"}}
+		start := time.Now()
+		_, attempts, err := policy.Do(ctx, func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("falha")
+		})
+		if err == nil || attempts != 1 {
+			t.Errorf("Esperada 1 tentativa com erro, recebido %d (%v)", attempts, err)
+		}
+		if time.Since(start) > 40*time.Millisecond {
+			t.Error("Não deveria aguardar um backoff maior que o prazo restante")
+		}
+	})
+}
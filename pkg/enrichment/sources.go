@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 )
 
 // HttpClientInterface permite mockar o cliente HTTP nos testes.
@@ -16,10 +16,16 @@ type HttpClientInterface interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-var Client HttpClientInterface = &http.Client{Timeout: 10 * time.Second} // Aumentei timeout para segurança
+// Client é compartilhado por todas as sources HTTP. O timeout de cada chamada é
+// controlado pelo contexto (CallPolicy), e não pelo cliente.
+var Client HttpClientInterface = &http.Client{}
 
 // ProcessFixed retorna dados estáticos definidos na configuração.
 func ProcessFixed(params map[string]interface{}) (interface{}, error) {
+	// 'error' simula uma falha da source (testes e desenvolvimento offline)
+	if msg, ok := params["error"].(string); ok && msg != "" {
+		return nil, errors.New(msg)
+	}
 	if val, ok := params["value"]; ok {
 		return val, nil
 	}
@@ -72,8 +78,8 @@ func ProcessRest(ctx context.Context, method, url string, headers map[string]str
 
 	// 3. Verifica Status de Erro HTTP
 	if resp.StatusCode >= 400 {
-		// Retorna erro formatado, mas inclui o corpo para debug se possível
-		return nil, fmt.Errorf("http error %d: %s", resp.StatusCode, string(respBytes))
+		// Retorna erro tipado (permite retry por status), incluindo o corpo para debug
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(respBytes)}
 	}
 
 	// 4. Parse Inteligente
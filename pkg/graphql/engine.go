@@ -9,7 +9,9 @@ import (
 	"github.com/graphql-go/graphql"
 	"github.com/raywall/fast-service-toolkit/pkg/config"
 	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
+	"github.com/raywall/fast-service-toolkit/pkg/metrics"
 	"github.com/raywall/fast-service-toolkit/pkg/rules"
+	"github.com/rs/zerolog/log"
 )
 
 var interpolationRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
@@ -17,6 +19,7 @@ var interpolationRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
 type GraphQLEngine struct {
 	Schema      graphql.Schema
 	RuleManager *rules.RuleManager
+	Metrics     metrics.Provider // Opcional: recebe o número de tentativas das sources
 }
 
 func NewGraphQLEngine(cfg config.GraphQLConf, rm *rules.RuleManager) (*GraphQLEngine, error) {
@@ -58,19 +61,23 @@ func (ge *GraphQLEngine) buildSchema(cfg config.GraphQLConf) (graphql.Schema, er
 	// 2. Preenche campos
 	for name, typeDef := range cfg.Types {
 		obj := objects[name]
-		fields := graphql.Fields{}
 		for fieldName, fieldDef := range typeDef.Fields {
-			fields[fieldName] = ge.buildField(fieldDef, objects)
-		}
-		for k, v := range fields {
-			obj.AddFieldConfig(k, v)
+			field, err := ge.buildField(fieldDef, objects)
+			if err != nil {
+				return graphql.Schema{}, fmt.Errorf("tipo %s, campo %s: %w", name, fieldName, err)
+			}
+			obj.AddFieldConfig(fieldName, field)
 		}
 	}
 
 	// 3. Root Query
 	rootQueryFields := graphql.Fields{}
 	for name, fieldDef := range cfg.Query {
-		rootQueryFields[name] = ge.buildField(fieldDef, objects)
+		field, err := ge.buildField(fieldDef, objects)
+		if err != nil {
+			return graphql.Schema{}, fmt.Errorf("query %s: %w", name, err)
+		}
+		rootQueryFields[name] = field
 	}
 
 	// 4. Root Mutation
@@ -78,7 +85,11 @@ func (ge *GraphQLEngine) buildSchema(cfg config.GraphQLConf) (graphql.Schema, er
 	if len(cfg.Mutation) > 0 {
 		rootMutationFields := graphql.Fields{}
 		for name, fieldDef := range cfg.Mutation {
-			rootMutationFields[name] = ge.buildField(fieldDef, objects)
+			field, err := ge.buildField(fieldDef, objects)
+			if err != nil {
+				return graphql.Schema{}, fmt.Errorf("mutation %s: %w", name, err)
+			}
+			rootMutationFields[name] = field
 		}
 		rootMutation = graphql.NewObject(graphql.ObjectConfig{
 			Name:   "Mutation",
@@ -95,14 +106,19 @@ func (ge *GraphQLEngine) buildSchema(cfg config.GraphQLConf) (graphql.Schema, er
 	})
 }
 
-func (ge *GraphQLEngine) buildField(def config.GQLField, objects map[string]*graphql.Object) *graphql.Field {
-	gqlType := ge.resolveType(def.Type, objects)
+func (ge *GraphQLEngine) buildField(def config.GQLField, objects map[string]*graphql.Object) (*graphql.Field, error) {
+	gqlType, err := resolveTypeRef(def.Type, objects, false)
+	if err != nil {
+		return nil, err
+	}
 
 	args := graphql.FieldConfigArgument{}
 	for argName, argTypeStr := range def.Args {
-		args[argName] = &graphql.ArgumentConfig{
-			Type: ge.resolveType(argTypeStr, objects),
+		argType, err := resolveTypeRef(argTypeStr, objects, true)
+		if err != nil {
+			return nil, fmt.Errorf("argumento %s: %w", argName, err)
 		}
+		args[argName] = &graphql.ArgumentConfig{Type: argType}
 	}
 
 	return &graphql.Field{
@@ -110,39 +126,55 @@ func (ge *GraphQLEngine) buildField(def config.GQLField, objects map[string]*gra
 		Description: def.Description,
 		Args:        args,
 		Resolve:     ge.createResolver(def.Source),
-	}
+	}, nil
 }
 
-func (ge *GraphQLEngine) resolveType(typeStr string, objects map[string]*graphql.Object) graphql.Output {
+// scalarTypes são os escalares nativos aceitos em 'type' e 'args'.
+var scalarTypes = map[string]*graphql.Scalar{
+	"String":  graphql.String,
+	"Int":     graphql.Int,
+	"Float":   graphql.Float,
+	"Boolean": graphql.Boolean,
+	"ID":      graphql.ID,
+}
+
+// resolveTypeRef converte uma referência de tipo ("[User!]!") no tipo GraphQL. Com input,
+// apenas escalares são aceitos: os tipos declarados em 'types' são objetos de saída.
+func resolveTypeRef(typeStr string, objects map[string]*graphql.Object, input bool) (graphql.Type, error) {
 	typeStr = strings.TrimSpace(typeStr)
 
 	if strings.HasPrefix(typeStr, "[") && strings.HasSuffix(typeStr, "]") {
-		innerType := typeStr[1 : len(typeStr)-1]
-		return graphql.NewList(ge.resolveType(innerType, objects))
+		inner, err := resolveTypeRef(typeStr[1:len(typeStr)-1], objects, input)
+		if err != nil {
+			return nil, err
+		}
+		return graphql.NewList(inner), nil
 	}
 
 	if strings.HasSuffix(typeStr, "!") {
-		innerType := typeStr[:len(typeStr)-1]
-		return graphql.NewNonNull(ge.resolveType(innerType, objects))
+		inner, err := resolveTypeRef(typeStr[:len(typeStr)-1], objects, input)
+		if err != nil {
+			return nil, err
+		}
+		if _, nested := inner.(*graphql.NonNull); nested {
+			return nil, fmt.Errorf("tipo inválido: '%s!'", typeStr)
+		}
+		return graphql.NewNonNull(inner), nil
 	}
 
-	switch typeStr {
-	case "String":
-		return graphql.String
-	case "Int":
-		return graphql.Int
-	case "Float":
-		return graphql.Float
-	case "Boolean":
-		return graphql.Boolean
-	case "ID":
-		return graphql.ID
-	default:
-		if obj, ok := objects[typeStr]; ok {
-			return obj
+	if scalar, ok := scalarTypes[typeStr]; ok {
+		return scalar, nil
+	}
+	if obj, ok := objects[typeStr]; ok {
+		if input {
+			return nil, fmt.Errorf("'%s' é um tipo objeto; argumentos aceitam apenas escalares (String, Int, Float, Boolean, ID)", typeStr)
 		}
-		return graphql.String
+		return obj, nil
+	}
+	if typeStr == "" {
+		return nil, fmt.Errorf("tipo não declarado")
 	}
+	return nil, fmt.Errorf("tipo desconhecido: '%s'", typeStr)
 }
 
 func (ge *GraphQLEngine) createResolver(src *config.EnrichmentSourceConfig) graphql.FieldResolveFn {
@@ -171,36 +203,15 @@ func (ge *GraphQLEngine) createResolver(src *config.EnrichmentSourceConfig) grap
 			return nil, err
 		}
 
-		// Variáveis para capturar o resultado
-		var result interface{}
-		var resErr error
-
-		switch src.Type {
-		case "fixed":
-			result, resErr = enrichment.ProcessFixed(resolvedParams)
-
-		case "rest":
-			method := toString(resolvedParams["method"])
-			url := toString(resolvedParams["url"])
-			body := resolvedParams["body"]
-			result, resErr = enrichment.ProcessRest(ctx, method, url, resolvedHeaders, body)
-
-		case "graphql":
-			endpoint := toString(resolvedParams["endpoint"])
-			query := toString(resolvedParams["query"])
-			vars := toMap(resolvedParams["variables"])
-			result, resErr = enrichment.ProcessGraphQL(ctx, endpoint, query, vars, resolvedHeaders)
-
-		case "aws_dynamodb":
-			region := toString(resolvedParams["region"])
-			table := toString(resolvedParams["table"])
-			key := toMap(resolvedParams["key"])
-			result, resErr = enrichment.ProcessDynamoDB(ctx, region, table, key)
-
-		default:
-			return nil, fmt.Errorf("adapter desconhecido: %s", src.Type)
+		policy, err := enrichment.PolicyForSource(src.CallPolicyConf, src.Params)
+		if err != nil {
+			return nil, err
 		}
 
+		result, attempts, resErr := policy.Do(ctx, func(ctx context.Context) (interface{}, error) {
+			return enrichment.Fetch(ctx, src.Type, resolvedParams, resolvedHeaders)
+		})
+		recordAttempts(ctx, ge.Metrics, p.Info.FieldName, src.Type, attempts, resErr)
 		if resErr != nil {
 			return nil, resErr
 		}
@@ -222,6 +233,21 @@ func (ge *GraphQLEngine) createResolver(src *config.EnrichmentSourceConfig) grap
 	}
 }
 
+// recordAttempts registra em log e métrica quantas tentativas a chamada do campo consumiu.
+func recordAttempts(ctx context.Context, provider metrics.Provider, field, srcType string, attempts int, callErr error) {
+	status := "ok"
+	if callErr != nil {
+		status = "error"
+	}
+	if attempts > 1 {
+		log.Ctx(ctx).Info().Str("field", field).Int("attempts", attempts).Str("status", status).Msg("Resolver executado com retentativas")
+	}
+	if provider != nil {
+		tags := []string{"source:" + field, "type:" + srcType, "status:" + status}
+		_ = provider.Histogram(enrichment.MetricSourceAttempts, float64(attempts), tags)
+	}
+}
+
 // --- Helpers de Resolução Recursiva (Mantidos) ---
 
 func (ge *GraphQLEngine) resolveMap(raw map[string]interface{}, ctx map[string]interface{}) (map[string]interface{}, error) {
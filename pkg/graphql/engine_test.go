@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/enrichment"
 	"github.com/raywall/fast-service-toolkit/pkg/rules"
 )
 
@@ -66,3 +67,38 @@ func TestNewGraphQLEngine(t *testing.T) {
 		t.Errorf("Erro na introspecção: %v", res.Errors)
 	}
 }
+
+func TestGraphQLEngine_UsesAdapterRegistry(t *testing.T) {
+	// Um adapter registrado fica disponível para os resolvers sem alterar o engine
+	enrichment.Register(enrichment.NewAdapter("test_greeting", nil, func(ctx context.Context, req enrichment.Request) (interface{}, error) {
+		return "olá, " + req.Params["name"].(string), nil
+	}))
+
+	rm, _ := rules.NewRuleManager()
+	cfg := config.GraphQLConf{
+		Enabled: true,
+		Query: map[string]config.GQLField{
+			"greet": {
+				Type: "String",
+				Args: map[string]string{"name": "String"},
+				Source: &config.EnrichmentSourceConfig{
+					Type:   "test_greeting",
+					Params: map[string]interface{}{"name": "${args.name}"},
+				},
+			},
+		},
+	}
+
+	engine, err := NewGraphQLEngine(cfg, rm)
+	if err != nil {
+		t.Fatalf("Erro ao criar engine: %v", err)
+	}
+
+	res := engine.Execute(context.Background(), `{ greet(name: "Ana") }`, nil)
+	if len(res.Errors) > 0 {
+		t.Fatalf("Erros inesperados: %v", res.Errors)
+	}
+	if got := res.Data.(map[string]interface{})["greet"]; got != "olá, Ana" {
+		t.Errorf("Esperado 'olá, Ana', recebido %v", got)
+	}
+}
@@ -63,25 +63,17 @@ func makeResolver(fieldDef config.GQLField, rm *rules.RuleManager) graphql.Field
 				ctxIO = context.Background()
 			}
 
-			var res interface{}
-			var execErr error
-
-			switch fieldDef.Source.Type {
-			case "fixed":
-				res, execErr = enrichment.ProcessFixed(resolvedParams)
-			case "rest":
-				res, execErr = enrichment.ProcessRest(ctxIO, toString(resolvedParams["method"]), toString(resolvedParams["url"]), headers, resolvedParams["body"])
-			case "dynamodb":
-				keyMap := toMap(resolvedParams["key"])
-				if len(keyMap) == 0 {
-					execErr = fmt.Errorf("chave vazia")
-				} else {
-					res, execErr = enrichment.ProcessDynamoDB(ctxIO, toString(resolvedParams["region"]), toString(resolvedParams["table"]), keyMap)
-				}
-			default:
-				execErr = fmt.Errorf("adapter desconhecido: %s", fieldDef.Source.Type)
+			policy, err := enrichment.PolicyForSource(fieldDef.Source.CallPolicyConf, fieldDef.Source.Params)
+			if err != nil {
+				resultChan <- err
+				return
 			}
 
+			res, attempts, execErr := policy.Do(ctxIO, func(ctxIO context.Context) (interface{}, error) {
+				return enrichment.Fetch(ctxIO, fieldDef.Source.Type, resolvedParams, headers)
+			})
+			recordAttempts(ctxIO, nil, p.Info.FieldName, fieldDef.Source.Type, attempts, execErr)
+
 			if execErr != nil {
 				resultChan <- execErr
 			} else {
@@ -180,23 +172,6 @@ func getEnvVars() map[string]string {
 	return map[string]string{}
 }
 
-func toMap(v interface{}) map[string]interface{} {
-	if v == nil {
-		return nil
-	}
-	if m, ok := v.(map[string]interface{}); ok {
-		return m
-	}
-	if mGen, ok := v.(map[interface{}]interface{}); ok {
-		out := make(map[string]interface{})
-		for k, val := range mGen {
-			out[fmt.Sprintf("%v", k)] = val
-		}
-		return out
-	}
-	return nil
-}
-
 func toString(v interface{}) string {
 	return fmt.Sprintf("%v", v)
 }
@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// SDL imprime o schema gerado em GraphQL SDL, para codegen de clientes e checagens em
+// schema registries. Query e Mutation vêm primeiro; os demais tipos, em ordem alfabética.
+// Escalares nativos e tipos de introspecção são omitidos.
+func (ge *GraphQLEngine) SDL() string {
+	var names []string
+	for name, t := range ge.Schema.TypeMap() {
+		if _, isObject := t.(*graphql.Object); !isObject || strings.HasPrefix(name, "__") {
+			continue
+		}
+		if name != "Query" && name != "Mutation" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if ge.Schema.MutationType() != nil {
+		names = append([]string{"Mutation"}, names...)
+	}
+	names = append([]string{"Query"}, names...)
+
+	var sb strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		writeObject(&sb, ge.Schema.Type(name).(*graphql.Object))
+	}
+	return sb.String()
+}
+
+func writeObject(sb *strings.Builder, obj *graphql.Object) {
+	writeDescription(sb, "", obj.Description())
+	sb.WriteString("type " + obj.Name() + " {\n")
+
+	fields := obj.Fields()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := fields[name]
+		writeDescription(sb, "  ", field.Description)
+		sb.WriteString("  " + name)
+		if len(field.Args) > 0 {
+			args := make([]string, len(field.Args))
+			for i, arg := range field.Args {
+				args[i] = arg.Name() + ": " + arg.Type.String()
+			}
+			sort.Strings(args)
+			sb.WriteString("(" + strings.Join(args, ", ") + ")")
+		}
+		sb.WriteString(": " + field.Type.String() + "\n")
+	}
+	sb.WriteString("}\n")
+}
+
+func writeDescription(sb *strings.Builder, indent, desc string) {
+	if desc == "" {
+		return
+	}
+	if !strings.Contains(desc, "\n") {
+		sb.WriteString(indent + strconv.Quote(desc) + "\n")
+		return
+	}
+	sb.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(desc, "\n") {
+		sb.WriteString(indent + strings.ReplaceAll(line, `"""`, `\"""`) + "\n")
+	}
+	sb.WriteString(indent + `"""` + "\n")
+}
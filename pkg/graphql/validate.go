@@ -0,0 +1,118 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+// Issue é um problema encontrado no schema GraphQL da configuração.
+type Issue struct {
+	Path    string // Caminho do nó no YAML (ex: "graphql.types.User.fields.plan.type")
+	Where   string // Rótulo usado nas mensagens (ex: "GraphQL.Types.User.plan")
+	Message string
+	Warning bool // Não impede a execução (ex: tipo inalcançável)
+}
+
+// Validate confere o schema declarado em 'graphql': tipos desconhecidos ou com sintaxe
+// inválida, argumentos com tipos objeto, tipos sem campos ou com nomes reservados, campos
+// raiz sem source e tipos que não são alcançáveis a partir de Query ou Mutation (avisos).
+// Os params das sources são conferidos pelo analisador da engine.
+func Validate(conf config.GraphQLConf) []Issue {
+	var issues []Issue
+	add := func(path, where, format string, args ...interface{}) {
+		issues = append(issues, Issue{Path: path, Where: where, Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(path, where, format string, args ...interface{}) {
+		issues = append(issues, Issue{Path: path, Where: where, Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+
+	objects := make(map[string]*graphql.Object, len(conf.Types))
+	for name := range conf.Types {
+		objects[name] = graphql.NewObject(graphql.ObjectConfig{Name: name, Fields: graphql.Fields{}})
+	}
+
+	checkFields := func(scope, path string, fields map[string]config.GQLField, root bool) {
+		for _, name := range sortedKeys(fields) {
+			field := fields[name]
+			where := fmt.Sprintf("GraphQL.%s.%s", scope, name)
+			if _, err := resolveTypeRef(field.Type, objects, false); err != nil {
+				add(path+"."+name+".type", where, "%v", err)
+			}
+			for _, arg := range sortedKeys(field.Args) {
+				if _, err := resolveTypeRef(field.Args[arg], objects, true); err != nil {
+					add(path+"."+name+".args."+arg, where, "argumento '%s': %v", arg, err)
+				}
+			}
+			if root && field.Source == nil {
+				warn(path+"."+name, where, "campo raiz sem source sempre resolve null")
+			}
+		}
+	}
+
+	if len(conf.Query) == 0 {
+		add("graphql", "GraphQL.Query", "nenhuma query declarada: o schema exige ao menos um campo em 'query'")
+	}
+	for _, name := range sortedKeys(conf.Types) {
+		path := "graphql.types." + name
+		switch {
+		case scalarTypes[name] != nil || name == "Query" || name == "Mutation":
+			add(path, "GraphQL.Types."+name, "nome de tipo reservado")
+		case len(conf.Types[name].Fields) == 0:
+			add(path, "GraphQL.Types."+name, "tipo sem campos")
+		}
+		checkFields("Types."+name, path+".fields", conf.Types[name].Fields, false)
+	}
+	checkFields("Query", "graphql.query", conf.Query, true)
+	checkFields("Mutation", "graphql.mutation", conf.Mutation, true)
+
+	// Tipos fora do grafo a partir das raízes não entram no schema gerado
+	reachable := make(map[string]bool)
+	var visit func(fields map[string]config.GQLField)
+	visit = func(fields map[string]config.GQLField) {
+		for _, field := range fields {
+			name := baseTypeName(field.Type)
+			typeDef, ok := conf.Types[name]
+			if !ok || reachable[name] {
+				continue
+			}
+			reachable[name] = true
+			visit(typeDef.Fields)
+		}
+	}
+	visit(conf.Query)
+	visit(conf.Mutation)
+	for _, name := range sortedKeys(conf.Types) {
+		if !reachable[name] {
+			warn("graphql.types."+name, "GraphQL.Types."+name, "tipo não é alcançável a partir de Query ou Mutation e fica fora do schema")
+		}
+	}
+
+	for _, issue := range issues {
+		if !issue.Warning {
+			return issues
+		}
+	}
+	// Sem erros na declaração, a montagem do schema confere o restante (ex: nomes inválidos)
+	if _, err := NewGraphQLEngine(conf, nil); err != nil {
+		add("graphql", "GraphQL", "schema inválido: %v", err)
+	}
+	return issues
+}
+
+// baseTypeName retorna o nome do tipo sem os modificadores de lista e não nulo.
+func baseTypeName(typeStr string) string {
+	return strings.Trim(strings.TrimSpace(typeStr), "[]! ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	src := &config.EnrichmentSourceConfig{Type: "fixed"}
+	conf := config.GraphQLConf{
+		Enabled: true,
+		Types: map[string]config.GQLType{
+			"User": {Fields: map[string]config.GQLField{
+				"name":    {Type: "String!"},
+				"plan":    {Type: "Plan"},
+				"friends": {Type: "[User]", Args: map[string]string{"filter": "User"}},
+			}},
+			"Orphan": {Fields: map[string]config.GQLField{"id": {Type: "ID"}}},
+			"Empty":  {},
+		},
+		Query: map[string]config.GQLField{
+			"user":  {Type: "User", Args: map[string]string{"id": "ID!"}, Source: src},
+			"users": {Type: "[User"},
+			"ping":  {Type: "String"},
+		},
+	}
+
+	var errs, warnings []string
+	for _, issue := range Validate(conf) {
+		msg := issue.Where + ": " + issue.Message
+		if issue.Warning {
+			warnings = append(warnings, msg)
+		} else {
+			errs = append(errs, msg)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"GraphQL.Types.Empty: tipo sem campos",
+		"GraphQL.Types.User.friends: argumento 'filter': 'User' é um tipo objeto; argumentos aceitam apenas escalares (String, Int, Float, Boolean, ID)",
+		"GraphQL.Types.User.plan: tipo desconhecido: 'Plan'",
+		"GraphQL.Query.users: tipo desconhecido: '[User'",
+	}, errs)
+	assert.Equal(t, []string{
+		"GraphQL.Query.ping: campo raiz sem source sempre resolve null",
+		"GraphQL.Query.users: campo raiz sem source sempre resolve null",
+		"GraphQL.Types.Empty: tipo não é alcançável a partir de Query ou Mutation e fica fora do schema",
+		"GraphQL.Types.Orphan: tipo não é alcançável a partir de Query ou Mutation e fica fora do schema",
+	}, warnings)
+
+	issues := Validate(config.GraphQLConf{Enabled: true})
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "graphql", issues[0].Path)
+		assert.Contains(t, issues[0].Message, "nenhuma query declarada")
+	}
+}
+
+func TestNewGraphQLEngine_UnknownType(t *testing.T) {
+	_, err := NewGraphQLEngine(config.GraphQLConf{
+		Query: map[string]config.GQLField{"user": {Type: "Usr"}},
+	}, nil)
+	assert.ErrorContains(t, err, "query user: tipo desconhecido: 'Usr'")
+}
+
+func TestGraphQLEngine_SDL(t *testing.T) {
+	conf := config.GraphQLConf{
+		Types: map[string]config.GQLType{
+			"User": {Description: "Cliente", Fields: map[string]config.GQLField{
+				"name": {Type: "String!", Description: "Nome completo"},
+				"tags": {Type: "[String]"},
+			}},
+		},
+		Query: map[string]config.GQLField{
+			"user": {Type: "User", Args: map[string]string{"id": "ID!", "active": "Boolean"}},
+		},
+		Mutation: map[string]config.GQLField{
+			"rename": {Type: "User", Args: map[string]string{"name": "String!"}},
+		},
+	}
+	ge, err := NewGraphQLEngine(conf, nil)
+	if err != nil {
+		t.Fatalf("Erro ao criar engine: %v", err)
+	}
+
+	assert.Equal(t, `type Query {
+  user(active: Boolean, id: ID!): User
+}
+
+type Mutation {
+  rename(name: String!): User
+}
+
+"Cliente"
+type User {
+  "Nome completo"
+  name: String!
+  tags: [String]
+}
+`, ge.SDL())
+}
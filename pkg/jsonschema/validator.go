@@ -0,0 +1,880 @@
+// Package jsonschema valida payloads contra um JSON Schema. Implementa o subconjunto
+// usado para contratos de API: type, properties, required, additionalProperties, items,
+// enum/const, limites numéricos e de tamanho, pattern, format, composição (allOf, anyOf,
+// oneOf, not) e $ref para #/$defs e #/definitions. Qualquer outra palavra-chave (ou
+// format fora da lista) é rejeitada na compilação, para que nenhuma regra do contrato
+// seja ignorada em silêncio.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Violation é um campo do payload que não atende ao schema.
+type Violation struct {
+	Path    string `json:"path"` // Caminho do campo (ex: "$.items[1].qty"); "$" é a raiz
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// Schema é um JSON Schema compilado, pronto para validar payloads.
+type Schema struct {
+	never bool // schema booleano false
+
+	types    []string
+	nullable bool
+
+	enum     []interface{}
+	hasConst bool
+	constVal interface{}
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	format               string
+
+	items               *Schema
+	tupleItems          []*Schema
+	minItems, maxItems  *int
+	uniqueItems         bool
+	properties          map[string]*Schema
+	required            []string
+	additional          *Schema
+	minProps, maxProps  *int
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+
+	ref  string
+	defs map[string]*Schema // Compartilhado por todos os nós: destino dos $ref
+}
+
+// keywords são as palavras-chave validadas pelo pacote.
+var keywords = map[string]bool{
+	"type": true, "nullable": true, "enum": true, "const": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+	"minLength": true, "maxLength": true, "pattern": true, "format": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"properties": true, "required": true, "additionalProperties": true, "minProperties": true, "maxProperties": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true, "$ref": true,
+}
+
+// annotations são aceitas em qualquer nível, mas não afetam a validação.
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// validTypes são os valores aceitos em 'type'.
+var validTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "integer": true, "number": true, "boolean": true, "null": true,
+}
+
+// Compile interpreta um JSON Schema (mapa do YAML ou do JSON). Erros apontam o caminho
+// da palavra-chave inválida dentro do schema.
+func Compile(raw interface{}) (*Schema, error) {
+	raw = normalize(raw)
+	defs := make(map[string]*Schema)
+	root, err := compile(raw, "", defs)
+	if err != nil {
+		return nil, err
+	}
+	defs["#"] = root
+
+	if m, ok := raw.(map[string]interface{}); ok {
+		for _, key := range []string{"$defs", "definitions"} {
+			group, ok := m[key].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for name, def := range group {
+				s, err := compile(def, key+"."+name, defs)
+				if err != nil {
+					return nil, err
+				}
+				defs["#/"+key+"/"+name] = s
+			}
+		}
+	}
+
+	// Referências só são verificadas depois que todas as definições existem (recursão)
+	if err := root.checkRefs(map[*Schema]bool{}); err != nil {
+		return nil, err
+	}
+	for _, s := range defs {
+		if err := s.checkRefs(map[*Schema]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkCycles(defs); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func compile(raw interface{}, at string, defs map[string]*Schema) (*Schema, error) {
+	s := &Schema{defs: defs}
+	switch x := raw.(type) {
+	case bool:
+		s.never = !x
+		return s, nil
+	case map[string]interface{}:
+		return s, s.load(x, at)
+	default:
+		return nil, schemaError(at, fmt.Errorf("schema deve ser um objeto ou booleano"))
+	}
+}
+
+func (s *Schema) load(m map[string]interface{}, at string) error {
+	var err error
+	fail := func(key string, e error) error {
+		return schemaError(join(at, key), e)
+	}
+
+	names := make([]string, 0, len(m))
+	for key := range m {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		switch {
+		case keywords[key] || annotations[key]:
+		case (key == "$defs" || key == "definitions") && at == "":
+			// Definições só são lidas na raiz (ver Compile)
+		case key == "$defs" || key == "definitions":
+			return fail(key, fmt.Errorf("definições só são suportadas na raiz do schema"))
+		default:
+			return fail(key, fmt.Errorf("palavra-chave não suportada"))
+		}
+	}
+
+	if ref, ok := m["$ref"]; ok {
+		r, isStr := ref.(string)
+		if !isStr {
+			return fail("$ref", fmt.Errorf("deve ser texto"))
+		}
+		s.ref = r
+	}
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, _ := item.(string)
+			s.types = append(s.types, name)
+		}
+	default:
+		return fail("type", fmt.Errorf("deve ser texto ou lista"))
+	}
+	for _, t := range s.types {
+		if !validTypes[t] {
+			return fail("type", fmt.Errorf("tipo desconhecido: '%v'", t))
+		}
+	}
+	if n, ok := m["nullable"].(bool); ok {
+		s.nullable = n
+	}
+
+	if e, ok := m["enum"]; ok {
+		list, isList := e.([]interface{})
+		if !isList {
+			return fail("enum", fmt.Errorf("deve ser uma lista"))
+		}
+		s.enum = list
+	}
+	if c, ok := m["const"]; ok {
+		s.hasConst, s.constVal = true, c
+	}
+
+	for key, dst := range map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum, "multipleOf": &s.multipleOf,
+	} {
+		if *dst, err = number(m, key); err != nil {
+			return fail(key, err)
+		}
+	}
+	// exclusiveMinimum/Maximum: número (draft 6+) ou booleano aplicado a minimum/maximum (draft 4)
+	for key, pair := range map[string][2]**float64{
+		"exclusiveMinimum": {&s.exclusiveMinimum, &s.minimum},
+		"exclusiveMaximum": {&s.exclusiveMaximum, &s.maximum},
+	} {
+		if b, ok := m[key].(bool); ok {
+			if b {
+				*pair[0], *pair[1] = *pair[1], nil
+			}
+			continue
+		}
+		if *pair[0], err = number(m, key); err != nil {
+			return fail(key, err)
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf <= 0 {
+		return fail("multipleOf", fmt.Errorf("deve ser maior que zero"))
+	}
+
+	for key, dst := range map[string]**int{
+		"minLength": &s.minLength, "maxLength": &s.maxLength, "minItems": &s.minItems,
+		"maxItems": &s.maxItems, "minProperties": &s.minProps, "maxProperties": &s.maxProps,
+	} {
+		if *dst, err = count(m, key); err != nil {
+			return fail(key, err)
+		}
+	}
+
+	if p, ok := m["pattern"]; ok {
+		expr, isStr := p.(string)
+		if !isStr {
+			return fail("pattern", fmt.Errorf("deve ser texto"))
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return fail("pattern", fmt.Errorf("regex inválida: %w", err))
+		}
+	}
+	if f, ok := m["format"]; ok {
+		name, isStr := f.(string)
+		if _, known := formats[name]; !isStr || !known {
+			return fail("format", fmt.Errorf("formato não suportado: '%v' (aceitos: %s)", f, formatNames()))
+		}
+		s.format = name
+	}
+	if u, ok := m["uniqueItems"].(bool); ok {
+		s.uniqueItems = u
+	}
+
+	switch items := m["items"].(type) {
+	case nil:
+	case []interface{}:
+		for i, item := range items {
+			sub, err := compile(item, fmt.Sprintf("%s[%d]", join(at, "items"), i), s.defs)
+			if err != nil {
+				return err
+			}
+			s.tupleItems = append(s.tupleItems, sub)
+		}
+	default:
+		if s.items, err = compile(items, join(at, "items"), s.defs); err != nil {
+			return err
+		}
+	}
+
+	if props, ok := m["properties"]; ok {
+		pm, isMap := props.(map[string]interface{})
+		if !isMap {
+			return fail("properties", fmt.Errorf("deve ser um objeto"))
+		}
+		s.properties = make(map[string]*Schema, len(pm))
+		for name, prop := range pm {
+			if s.properties[name], err = compile(prop, join(join(at, "properties"), name), s.defs); err != nil {
+				return err
+			}
+		}
+	}
+	if req, ok := m["required"]; ok {
+		list, isList := req.([]interface{})
+		if !isList {
+			return fail("required", fmt.Errorf("deve ser uma lista"))
+		}
+		for _, name := range list {
+			s.required = append(s.required, fmt.Sprintf("%v", name))
+		}
+	}
+	if add, ok := m["additionalProperties"]; ok {
+		if s.additional, err = compile(add, join(at, "additionalProperties"), s.defs); err != nil {
+			return err
+		}
+	}
+
+	for key, dst := range map[string]*[]*Schema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf} {
+		raw, ok := m[key]
+		if !ok {
+			continue
+		}
+		list, isList := raw.([]interface{})
+		if !isList || len(list) == 0 {
+			return fail(key, fmt.Errorf("deve ser uma lista não vazia"))
+		}
+		for i, item := range list {
+			sub, err := compile(item, fmt.Sprintf("%s[%d]", join(at, key), i), s.defs)
+			if err != nil {
+				return err
+			}
+			*dst = append(*dst, sub)
+		}
+	}
+	if not, ok := m["not"]; ok {
+		if s.not, err = compile(not, join(at, "not"), s.defs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRefs garante que todo $ref aponta para uma definição existente.
+func (s *Schema) checkRefs(seen map[*Schema]bool) error {
+	if s == nil || seen[s] {
+		return nil
+	}
+	seen[s] = true
+	if s.ref != "" {
+		if _, ok := s.defs[s.ref]; !ok {
+			return fmt.Errorf("$ref não encontrado: '%s' (use #/$defs/<nome> ou #/definitions/<nome>)", s.ref)
+		}
+	}
+	for _, c := range s.children() {
+		if err := c.checkRefs(seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) children() []*Schema {
+	children := append([]*Schema{s.items, s.additional}, s.tupleItems...)
+	children = append(children, s.sameInstance()...)
+	for _, p := range s.properties {
+		children = append(children, p)
+	}
+	return children
+}
+
+// sameInstance retorna os schemas aplicados ao mesmo valor ($ref e composição), sem
+// descer no payload.
+func (s *Schema) sameInstance() []*Schema {
+	var out []*Schema
+	if s.ref != "" {
+		out = append(out, s.defs[s.ref])
+	}
+	out = append(out, s.allOf...)
+	out = append(out, s.anyOf...)
+	out = append(out, s.oneOf...)
+	return append(out, s.not)
+}
+
+// checkCycles rejeita ciclos de $ref que não consomem o payload (ex: {$ref: "#"} ou
+// duas definições que apontam uma para a outra): a validação recursaria indefinidamente.
+// Ciclos que passam por properties ou items são recursão legítima e terminam com o payload.
+func checkCycles(defs map[string]*Schema) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*Schema]int)
+	var visit func(s *Schema) error
+	visit = func(s *Schema) error {
+		if s == nil || state[s] == done {
+			return nil
+		}
+		if state[s] == visiting {
+			return fmt.Errorf("$ref circular: o schema referencia a si mesmo sem descer em properties ou items")
+		}
+		state[s] = visiting
+		for _, next := range s.sameInstance() {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		state[s] = done
+		return nil
+	}
+
+	all := make(map[*Schema]bool)
+	var collect func(s *Schema)
+	collect = func(s *Schema) {
+		if s == nil || all[s] {
+			return
+		}
+		all[s] = true
+		for _, c := range s.children() {
+			collect(c)
+		}
+	}
+	for _, s := range defs {
+		collect(s)
+	}
+	for s := range all {
+		if err := visit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate retorna todas as violações do valor, ordenadas pelo caminho dos campos.
+// Textos numéricos ou booleanos são aceitos onde o schema pede number, integer ou
+// boolean, já que path params, query strings e formulários sempre chegam como texto.
+func (s *Schema) Validate(v interface{}) []Violation {
+	var out []Violation
+	s.validate(normalize(v), "$", &out)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+func (s *Schema) validate(v interface{}, path string, out *[]Violation) {
+	add := func(format string, args ...interface{}) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.never {
+		add("campo não permitido")
+		return
+	}
+	if s.ref != "" {
+		s.defs[s.ref].validate(v, path, out)
+	}
+	if v == nil && s.nullable {
+		return
+	}
+
+	if len(s.types) > 0 {
+		converted, ok := s.matchType(v)
+		if !ok {
+			add("tipo inválido: esperado %s, recebido %s", strings.Join(s.types, " ou "), typeName(v))
+			return
+		}
+		v = converted
+	}
+
+	if s.hasConst && !equal(v, s.constVal) {
+		add("deve ser igual a %s", render(s.constVal))
+	}
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if equal(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("valor não permitido; aceitos: %s", render(s.enum))
+		}
+	}
+
+	switch x := v.(type) {
+	case string:
+		s.validateString(x, add)
+	case []interface{}:
+		s.validateArray(x, path, out, add)
+	case map[string]interface{}:
+		s.validateObject(x, path, out, add)
+	default:
+		if n, ok := toFloat(v); ok {
+			s.validateNumber(n, add)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, out)
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.valid(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add("não atende a nenhuma das alternativas (anyOf)")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if sub.valid(v) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			add("deve atender a exatamente uma alternativa (oneOf); atende a %d", matches)
+		}
+	}
+	if s.not != nil && s.not.valid(v) {
+		add("não deve atender ao schema em 'not'")
+	}
+}
+
+func (s *Schema) valid(v interface{}) bool {
+	var out []Violation
+	s.validate(v, "$", &out)
+	return len(out) == 0
+}
+
+// matchType verifica 'type', convertendo textos para number, integer ou boolean quando
+// o schema não aceita string.
+func (s *Schema) matchType(v interface{}) (interface{}, bool) {
+	str, isStr := v.(string)
+	for _, t := range s.types {
+		if isType(v, t) {
+			return v, true
+		}
+	}
+	if !isStr || s.accepts("string") {
+		return v, false
+	}
+	for _, t := range s.types {
+		switch t {
+		case "integer":
+			if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+				return float64(i), true
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(str, 64); err == nil {
+				return f, true
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(str); err == nil {
+				return b, true
+			}
+		}
+	}
+	return v, false
+}
+
+func (s *Schema) accepts(t string) bool {
+	for _, name := range s.types {
+		if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) validateNumber(n float64, add func(string, ...interface{})) {
+	if s.minimum != nil && n < *s.minimum {
+		add("deve ser maior ou igual a %v", *s.minimum)
+	}
+	if s.maximum != nil && n > *s.maximum {
+		add("deve ser menor ou igual a %v", *s.maximum)
+	}
+	if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+		add("deve ser maior que %v", *s.exclusiveMinimum)
+	}
+	if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+		add("deve ser menor que %v", *s.exclusiveMaximum)
+	}
+	if s.multipleOf != nil {
+		q := n / *s.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			add("deve ser múltiplo de %v", *s.multipleOf)
+		}
+	}
+}
+
+func (s *Schema) validateString(str string, add func(string, ...interface{})) {
+	length := len([]rune(str))
+	if s.minLength != nil && length < *s.minLength {
+		add("deve ter no mínimo %d caracteres", *s.minLength)
+	}
+	if s.maxLength != nil && length > *s.maxLength {
+		add("deve ter no máximo %d caracteres", *s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		add("não corresponde ao padrão '%s'", s.pattern.String())
+	}
+	if check, ok := formats[s.format]; ok && !check(str) {
+		add("formato inválido: esperado %s", s.format)
+	}
+}
+
+func (s *Schema) validateArray(list []interface{}, path string, out *[]Violation, add func(string, ...interface{})) {
+	if s.minItems != nil && len(list) < *s.minItems {
+		add("deve ter no mínimo %d itens", *s.minItems)
+	}
+	if s.maxItems != nil && len(list) > *s.maxItems {
+		add("deve ter no máximo %d itens", *s.maxItems)
+	}
+	if s.uniqueItems {
+		for i := 1; i < len(list); i++ {
+			for j := 0; j < i; j++ {
+				if equal(list[i], list[j]) {
+					add("itens duplicados nas posições %d e %d", j, i)
+					i = len(list)
+					break
+				}
+			}
+		}
+	}
+	for i, item := range list {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i < len(s.tupleItems):
+			s.tupleItems[i].validate(item, itemPath, out)
+		case s.items != nil:
+			s.items.validate(item, itemPath, out)
+		}
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, path string, out *[]Violation, add func(string, ...interface{})) {
+	if s.minProps != nil && len(obj) < *s.minProps {
+		add("deve ter no mínimo %d campos", *s.minProps)
+	}
+	if s.maxProps != nil && len(obj) > *s.maxProps {
+		add("deve ter no máximo %d campos", *s.maxProps)
+	}
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			*out = append(*out, Violation{Path: fieldPath(path, name), Message: "campo obrigatório"})
+		}
+	}
+	for name, val := range obj {
+		if prop, ok := s.properties[name]; ok {
+			prop.validate(val, fieldPath(path, name), out)
+		} else if s.additional != nil {
+			s.additional.validate(val, fieldPath(path, name), out)
+		}
+	}
+}
+
+// formats são os valores aceitos em 'format'.
+var formats = map[string]func(string) bool{
+	"email": func(s string) bool { return emailPattern.MatchString(s) },
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	},
+	"time": func(s string) bool {
+		_, err := time.Parse("15:04:05Z07:00", s)
+		if err != nil {
+			_, err = time.Parse("15:04:05", s)
+		}
+		return err == nil
+	},
+	"uuid": func(s string) bool {
+		_, err := uuid.Parse(s)
+		return err == nil && len(s) == 36
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	},
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	},
+	"ipv6": func(s string) bool {
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	},
+}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+func formatNames() string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func isType(v interface{}, t string) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "integer":
+		n, ok := toFloat(v)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compara valores do JSON ignorando a representação numérica (1 == 1.0).
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, val := range x {
+			other, exists := y[k]
+			if !exists || !equal(val, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func render(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// fieldPath acrescenta um campo ao caminho, usando ["campo"] para nomes que não são identificadores.
+func fieldPath(path, name string) string {
+	if identPattern.MatchString(name) {
+		return path + "." + name
+	}
+	return fmt.Sprintf("%s[%q]", path, name)
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func number(m map[string]interface{}, key string) (*float64, error) {
+	raw, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	n, isNum := toFloat(raw)
+	if !isNum {
+		return nil, fmt.Errorf("deve ser numérico")
+	}
+	return &n, nil
+}
+
+func count(m map[string]interface{}, key string) (*int, error) {
+	raw, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	n, isNum := toFloat(raw)
+	if !isNum || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("deve ser um inteiro não negativo")
+	}
+	i := int(n)
+	return &i, nil
+}
+
+func join(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+func schemaError(at string, err error) error {
+	if at == "" {
+		return err
+	}
+	return fmt.Errorf("'%s': %w", at, err)
+}
+
+// normalize converte os mapas do YAML (map[interface{}]interface{}) em map[string]interface{}.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[fmt.Sprintf("%v", k)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[k] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, val := range x {
+			l[i] = normalize(val)
+		}
+		return l
+	default:
+		return v
+	}
+}
@@ -0,0 +1,167 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const proposalSchema = `
+$schema: "https://json-schema.org/draft/2020-12/schema"
+type: object
+required: [customer, amount, items]
+additionalProperties: false
+properties:
+  customer:
+    type: object
+    required: [document, email]
+    properties:
+      document: {type: string, pattern: "^[0-9]{11}$"}
+      email: {type: string, format: email}
+      birth_date: {type: string, format: date}
+  amount: {type: number, exclusiveMinimum: 0, maximum: 50000}
+  installments: {type: integer, minimum: 1, maximum: 24}
+  channel: {enum: [app, web, agency]}
+  notes: {type: [string, "null"], maxLength: 10}
+  items:
+    type: array
+    minItems: 1
+    items: {$ref: "#/$defs/item"}
+  tags: {type: array, items: {type: string}, uniqueItems: true}
+$defs:
+  item:
+    type: object
+    required: [sku, qty]
+    properties:
+      sku: {type: string, minLength: 3}
+      qty: {type: integer, minimum: 1}
+`
+
+func mustCompile(t *testing.T, src string) *Schema {
+	t.Helper()
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(src), &raw); err != nil {
+		t.Fatalf("YAML inválido: %v", err)
+	}
+	s, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("Erro ao compilar schema: %v", err)
+	}
+	return s
+}
+
+func decode(t *testing.T, payload string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(payload), &v); err != nil {
+		t.Fatalf("JSON inválido: %v", err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	s := mustCompile(t, proposalSchema)
+
+	valid := `{
+		"customer": {"document": "52998224725", "email": "ana@example.com", "birth_date": "1990-05-20"},
+		"amount": 1500.5, "installments": 12, "channel": "app", "notes": null,
+		"items": [{"sku": "abc", "qty": 2}], "tags": ["pf", "vip"]
+	}`
+	assert.Empty(t, s.Validate(decode(t, valid)))
+
+	// Anotações são aceitas sem efeito na validação
+	annotated := mustCompile(t, `{title: Proposta, description: "Pedido de crédito", properties: {a: {type: string, default: x, examples: [y], deprecated: true}}}`)
+	assert.Empty(t, annotated.Validate(decode(t, `{"a": "z"}`)))
+
+	invalid := `{
+		"customer": {"document": "529.982.247-25", "birth_date": "20/05/1990"},
+		"amount": 0, "installments": 12.5, "channel": "phone", "notes": "texto longo demais",
+		"items": [{"sku": "ab", "qty": 0}, {"qty": "x"}], "tags": ["pf", "pf"], "extra": true
+	}`
+	assert.Equal(t, []Violation{
+		{Path: "$.amount", Message: "deve ser maior que 0"},
+		{Path: "$.channel", Message: `valor não permitido; aceitos: ["app","web","agency"]`},
+		{Path: "$.customer.birth_date", Message: "formato inválido: esperado date"},
+		{Path: "$.customer.document", Message: "não corresponde ao padrão '^[0-9]{11}$'"},
+		{Path: "$.customer.email", Message: "campo obrigatório"},
+		{Path: "$.extra", Message: "campo não permitido"},
+		{Path: "$.installments", Message: "tipo inválido: esperado integer, recebido number"},
+		{Path: "$.items[0].qty", Message: "deve ser maior ou igual a 1"},
+		{Path: "$.items[0].sku", Message: "deve ter no mínimo 3 caracteres"},
+		{Path: "$.items[1].qty", Message: "tipo inválido: esperado integer, recebido string"},
+		{Path: "$.items[1].sku", Message: "campo obrigatório"},
+		{Path: "$.notes", Message: "deve ter no máximo 10 caracteres"},
+		{Path: "$.tags", Message: "itens duplicados nas posições 0 e 1"},
+	}, s.Validate(decode(t, invalid)))
+
+	assert.Equal(t, []Violation{{Path: "$", Message: "tipo inválido: esperado object, recebido array"}}, s.Validate(decode(t, `[]`)))
+}
+
+func TestValidate_TextValues(t *testing.T) {
+	// Path params, query strings e formulários chegam como texto
+	s := mustCompile(t, `
+type: object
+properties:
+  id: {type: integer, minimum: 10}
+  active: {type: boolean}
+  code: {type: string, maxLength: 2}
+`)
+	assert.Empty(t, s.Validate(map[string]interface{}{"id": "42", "active": "true", "code": "10"}))
+	assert.Equal(t, []Violation{
+		{Path: "$.active", Message: "tipo inválido: esperado boolean, recebido string"},
+		{Path: "$.id", Message: "deve ser maior ou igual a 10"},
+	}, s.Validate(map[string]interface{}{"id": "7", "active": "sim"}))
+}
+
+func TestValidate_Composition(t *testing.T) {
+	s := mustCompile(t, `
+type: object
+properties:
+  contact:
+    oneOf:
+      - {type: string, format: email}
+      - {type: string, pattern: "^[0-9]{10,11}$"}
+  id: {anyOf: [{type: string, format: uuid}, {type: integer}]}
+  status: {not: {const: blocked}}
+  node: {$ref: "#/definitions/node"}
+definitions:
+  node:
+    type: object
+    properties:
+      child: {$ref: "#/definitions/node"}
+      value: {type: integer}
+`)
+	assert.Empty(t, s.Validate(decode(t, `{"contact": "11987654321", "id": 3, "node": {"child": {"value": 1}}}`)))
+	assert.Equal(t, []Violation{
+		{Path: "$.contact", Message: "deve atender a exatamente uma alternativa (oneOf); atende a 0"},
+		{Path: "$.id", Message: "não atende a nenhuma das alternativas (anyOf)"},
+		{Path: "$.node.child.child.value", Message: "tipo inválido: esperado integer, recebido string"},
+		{Path: "$.status", Message: "não deve atender ao schema em 'not'"},
+	}, s.Validate(decode(t, `{"contact": "x", "id": "abc", "status": "blocked", "node": {"child": {"child": {"value": "um"}}}}`)))
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for src, want := range map[string]string{
+		"type: text":                                 "'type': tipo desconhecido: 'text'",
+		"properties: {a: {minLength: -1}}":           "'properties.a.minLength': deve ser um inteiro não negativo",
+		"properties: {a: {pattern: '('}}":            "'properties.a.pattern': regex inválida",
+		"properties: {a: {$ref: '#/$defs/missing'}}": "$ref não encontrado: '#/$defs/missing'",
+		"items: 10": "'items': schema deve ser um objeto ou booleano",
+		"anyOf: []": "'anyOf': deve ser uma lista não vazia",
+		"properties: {a: {type: number, maximum: 'x'}}": "'properties.a.maximum': deve ser numérico",
+		"$ref: '#'": "$ref circular",
+		// Palavras-chave fora do subconjunto suportado não são ignoradas em silêncio
+		"properties: {a: {type: object, patternProperties: {'^x': {}}}}":                          "'properties.a.patternProperties': palavra-chave não suportada",
+		"{if: {required: [a]}, then: {required: [b]}}":                                            "'if': palavra-chave não suportada",
+		"properties: {a: {type: string, format: int32}}":                                          "'properties.a.format': formato não suportado: 'int32'",
+		"properties: {a: {$defs: {b: {type: string}}}}":                                           "'properties.a.$defs': definições só são suportadas na raiz",
+		"{$ref: '#/$defs/a', $defs: {a: {$ref: '#/$defs/b'}, b: {allOf: [{$ref: '#/$defs/a'}]}}}": "$ref circular",
+	} {
+		var raw interface{}
+		assert.NoError(t, yaml.Unmarshal([]byte(src), &raw))
+		_, err := Compile(raw)
+		assert.ErrorContains(t, err, want, src)
+	}
+}
@@ -20,6 +20,11 @@ type DatadogProvider struct {
 	client *statsd.Client
 }
 
+// Close envia as métricas pendentes no buffer e encerra o cliente statsd.
+func (d *DatadogProvider) Close() error {
+	return d.client.Close()
+}
+
 func (d *DatadogProvider) Count(name string, value float64, tags []string) error {
 	return d.client.Count(name, int64(value), tags, 1)
 }
@@ -17,22 +17,31 @@ type Response struct {
 	Body       []byte
 }
 
-// Client reutilizável para pooling de conexões
-var client = &http.Client{
-	Timeout: 30 * time.Second, // Timeout padrão seguro
-}
+// DefaultTimeout é aplicado quando nem o target nem o contexto definem um prazo.
+const DefaultTimeout = 30 * time.Second
+
+// Client reutilizável para pooling de conexões. O prazo vem sempre do contexto.
+var client = &http.Client{}
 
 // ForwardRequest envia a requisição enriquecida para o serviço de destino.
 func ForwardRequest(ctx context.Context, method, url string, body []byte, headers map[string]string, timeoutStr string) (*Response, error) {
 	// 1. Configura Timeout específico se fornecido
+	// O prazo efetivo é o menor entre o timeout do target e o restante do contexto
 	reqCtx := ctx
+	timeout := time.Duration(0)
 	if timeoutStr != "" {
 		if dur, err := time.ParseDuration(timeoutStr); err == nil {
-			var cancel context.CancelFunc
-			reqCtx, cancel = context.WithTimeout(ctx, dur)
-			defer cancel()
+			timeout = dur
 		}
 	}
+	if _, hasDeadline := ctx.Deadline(); timeout == 0 && !hasDeadline {
+		timeout = DefaultTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// 2. Prepara Request
 	req, err := http.NewRequestWithContext(reqCtx, strings.ToUpper(method), url, bytes.NewBuffer(body))
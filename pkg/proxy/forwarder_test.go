@@ -92,6 +92,27 @@ func TestForwardRequest_Timeout(t *testing.T) {
 	}
 }
 
+func TestForwardRequest_ContextDeadline(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(200)
+	}))
+	defer mockServer.Close()
+
+	// O target declara 1s, mas a requisição só possui 10ms restantes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ForwardRequest(ctx, "GET", mockServer.URL, nil, nil, "1s")
+	if err == nil {
+		t.Fatal("Esperava erro de timeout, recebeu sucesso")
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Errorf("O prazo do contexto deveria prevalecer, chamada levou %v", elapsed)
+	}
+}
+
 func TestForwardRequest_ConnectionError(t *testing.T) {
 	// Tenta conectar em uma porta onde não tem nada rodando
 	_, err := ForwardRequest(context.Background(), "GET", "http://localhost:54321/nada", nil, nil, "1s")
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval define a frequência de limpeza de buckets ociosos.
+const sweepInterval = time.Minute
+
+// Decision é o resultado de uma consulta ao limitador.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Tempo até o próximo token (apenas quando rejeitado)
+	Reset      time.Duration // Tempo até o bucket voltar a ficar cheio
+}
+
+// bucket guarda o estado de um token bucket individual.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter implementa um token bucket thread-safe, com um bucket por chave.
+// A chave vazia representa o bucket global.
+type Limiter struct {
+	rate  float64 // tokens por segundo
+	burst float64 // capacidade máxima do bucket
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New cria um limitador com a taxa (rps) e capacidade (burst) informadas.
+// Se burst for menor ou igual a zero, assume o valor de rps.
+func New(rps, burst int) *Limiter {
+	if burst <= 0 {
+		burst = rps
+	}
+	return &Limiter{
+		rate:    float64(rps),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow consome um token do bucket da chave e retorna a decisão.
+func (l *Limiter) Allow(key string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+
+	d := Decision{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.durationFor(1 - b.tokens)
+	}
+	d.Remaining = int(math.Floor(b.tokens))
+	d.Reset = l.durationFor(l.burst - b.tokens)
+	return d
+}
+
+// durationFor calcula o tempo necessário para acumular n tokens.
+func (l *Limiter) durationFor(tokens float64) time.Duration {
+	if l.rate <= 0 || tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep remove buckets que já estariam cheios, evitando crescimento ilimitado
+// do mapa quando a chave é derivada do chamador (IP, API Key).
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	full := l.durationFor(l.burst)
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
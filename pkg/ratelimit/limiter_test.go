@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(2, 3)
+	l.now = func() time.Time { return now }
+
+	t.Run("Deve permitir até o burst e rejeitar o excedente", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if d := l.Allow("a"); !d.Allowed {
+				t.Fatalf("Requisição %d deveria ser permitida", i)
+			}
+		}
+		d := l.Allow("a")
+		if d.Allowed {
+			t.Fatal("Requisição acima do burst deveria ser rejeitada")
+		}
+		if d.Limit != 3 || d.Remaining != 0 {
+			t.Errorf("Limit/Remaining incorretos: %d/%d", d.Limit, d.Remaining)
+		}
+		if d.RetryAfter != 500*time.Millisecond {
+			t.Errorf("RetryAfter esperado 500ms, recebido %v", d.RetryAfter)
+		}
+	})
+
+	t.Run("Chaves diferentes possuem buckets independentes", func(t *testing.T) {
+		if d := l.Allow("b"); !d.Allowed {
+			t.Error("Bucket da chave 'b' não deveria ser afetado pela chave 'a'")
+		}
+	})
+
+	t.Run("Deve recarregar tokens com o tempo", func(t *testing.T) {
+		now = now.Add(time.Second)
+		d := l.Allow("a")
+		if !d.Allowed {
+			t.Fatal("Após 1s deveria haver tokens disponíveis")
+		}
+		if d.Remaining != 1 {
+			t.Errorf("Remaining esperado 1, recebido %d", d.Remaining)
+		}
+	})
+
+	t.Run("Deve remover buckets ociosos", func(t *testing.T) {
+		now = now.Add(2 * sweepInterval)
+		l.Allow("c")
+		if _, ok := l.buckets["a"]; ok {
+			t.Error("Bucket ocioso 'a' deveria ter sido removido")
+		}
+	})
+}
+
+func TestNew_DefaultBurst(t *testing.T) {
+	l := New(5, 0)
+	if d := l.Allow(""); d.Limit != 5 {
+		t.Errorf("Burst padrão deveria ser igual ao rps, recebido %d", d.Limit)
+	}
+}
@@ -1,48 +1,107 @@
 package responder
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
-	"github.com/google/cel-go/cel"
+	"github.com/raywall/fast-service-toolkit/pkg/codec"
 	"github.com/raywall/fast-service-toolkit/pkg/config"
 	"github.com/raywall/fast-service-toolkit/pkg/rules"
 )
 
 type ResponseBuilder struct {
-	statusCode     int
-	bodyTemplate   interface{}
-	headerPrograms map[string]cel.Program
-	ruleManager    *rules.RuleManager
+	base        *variant   // status_code, body e headers do output (fallback)
+	variants    []*variant // output.variants, avaliadas em ordem
+	ruleManager *rules.RuleManager
+	formats     []format // formats[0] é o formato padrão (output.format)
+}
+
+// format é um encoder oferecido pelo output, com o Content-Type que ele produz.
+type format struct {
+	encoder     codec.Encoder
+	contentType string
+}
+
+// Response é o output montado: status, body codificado, headers e o body avaliado
+// antes da codificação (usado, por exemplo, pelas métricas de output).
+type Response struct {
+	StatusCode int
+	Body       []byte
+	Headers    map[string]string
+	Data       interface{}
 }
 
 func NewResponseBuilder(cfg config.OutputStep, rm *rules.RuleManager) (*ResponseBuilder, error) {
-	cleanBody := sanitize(cfg.Body)
+	rb := &ResponseBuilder{ruleManager: rm}
 
-	rb := &ResponseBuilder{
-		statusCode:     cfg.StatusCode,
-		bodyTemplate:   cleanBody,
-		headerPrograms: make(map[string]cel.Program),
-		ruleManager:    rm,
+	base, err := rb.compileVariant("", cfg.StatusCode, cfg.Body, cfg.Headers)
+	if err != nil {
+		return nil, err
 	}
+	if base.status.prg == nil && base.status.code == 0 {
+		base.status.code = 200
+	}
+	rb.base = base
 
-	for headerName, rawExpr := range cfg.Headers {
-		expr := normalizeExpression(rawExpr)
-		prg, err := rm.CompileProgram(expr)
+	for i, vc := range cfg.Variants {
+		if vc.When == "" {
+			return nil, fmt.Errorf("variants[%d]: 'when' é obrigatório", i)
+		}
+		v, err := rb.compileVariant(vc.When, vc.StatusCode, vc.Body, vc.Headers)
 		if err != nil {
-			return nil, fmt.Errorf("erro ao compilar header '%s': %w", headerName, err)
+			return nil, fmt.Errorf("variants[%d]: %w", i, err)
 		}
-		rb.headerPrograms[headerName] = prg
+		v.inherit(base)
+		rb.variants = append(rb.variants, v)
 	}
 
-	if err := rb.validateBodyExpressions(rb.bodyTemplate); err != nil {
+	if err := rb.buildFormats(cfg); err != nil {
 		return nil, err
 	}
 
 	return rb, nil
 }
 
+// buildFormats cria o encoder principal e os adicionais de 'formats'. Sem 'formats',
+// o header Accept é ignorado e a resposta sempre usa o formato principal. Dois formatos
+// com o mesmo media type (ex: text e template) são rejeitados: o Accept não os distingue.
+func (rb *ResponseBuilder) buildFormats(cfg config.OutputStep) error {
+	names := []string{cfg.Format}
+	if cfg.Format == "" {
+		names[0] = codec.DefaultFormat
+	}
+	for _, name := range cfg.Formats {
+		if name != names[0] {
+			names = append(names, name)
+		}
+	}
+
+	offered := make(map[string]string, len(names))
+	for i, name := range names {
+		enc, err := codec.NewEncoder(name, cfg)
+		if err != nil {
+			return err
+		}
+		f := format{encoder: enc, contentType: enc.ContentType()}
+		if i == 0 && cfg.ContentType != "" {
+			f.contentType = cfg.ContentType
+		}
+		media := mediaType(f.contentType)
+		if other, dup := offered[media]; dup {
+			return fmt.Errorf("formats '%s' e '%s' produzem o mesmo Content-Type (%s); use content_type no formato principal para diferenciá-los", other, name, media)
+		}
+		offered[media] = name
+		rb.formats = append(rb.formats, f)
+	}
+	return nil
+}
+
+// mediaType retorna o tipo do Content-Type sem parâmetros (ex: charset), em minúsculas.
+func mediaType(contentType string) string {
+	media, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(media))
+}
+
 func sanitize(input interface{}) interface{} {
 	switch x := input.(type) {
 	case map[interface{}]interface{}:
@@ -113,27 +172,65 @@ func (rb *ResponseBuilder) validateBodyExpressions(data interface{}) error {
 	return nil
 }
 
+// Build monta a resposta no formato principal do output.
 func (rb *ResponseBuilder) Build(ctx map[string]interface{}) (int, []byte, map[string]string, error) {
-	processedBody, err := rb.processRecursive(rb.bodyTemplate, ctx)
+	resp, err := rb.BuildFor(ctx, "")
 	if err != nil {
 		return 500, nil, nil, err
 	}
+	return resp.StatusCode, resp.Body, resp.Headers, nil
+}
+
+// BuildFor monta a resposta no formato negociado com o header Accept. Quando nenhum
+// formato oferecido é aceito, retorna um erro que envolve codec.ErrNotAcceptable.
+func (rb *ResponseBuilder) BuildFor(ctx map[string]interface{}, accept string) (*Response, error) {
+	f := rb.formats[0]
+	if len(rb.formats) > 1 {
+		offers := make([]string, len(rb.formats))
+		for i, of := range rb.formats {
+			offers[i] = of.contentType
+		}
+		idx, err := codec.Negotiate(accept, offers)
+		if err != nil {
+			return nil, fmt.Errorf("accept '%s': %w", accept, err)
+		}
+		f = rb.formats[idx]
+	}
+
+	v, err := rb.selectVariant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status, err := v.status.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	bytes, err := json.Marshal(processedBody)
+	processedBody, err := rb.processRecursive(v.bodyTemplate, ctx)
 	if err != nil {
-		return 500, nil, nil, fmt.Errorf("erro json marshal: %w", err)
+		return nil, err
 	}
+	processedBody = rules.Native(processedBody)
 
-	respHeaders := make(map[string]string)
-	for name, prg := range rb.headerPrograms {
+	body, err := f.encoder.Encode(processedBody)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao codificar output: %w", err)
+	}
+
+	// Content-Type vem do encoder; headers declarados no output prevalecem
+	respHeaders := map[string]string{"Content-Type": f.contentType}
+	if len(rb.formats) > 1 {
+		respHeaders["Vary"] = "Accept"
+	}
+	for name, prg := range v.headerPrograms {
 		out, _, err := prg.Eval(ctx)
 		if err != nil {
-			return 500, nil, nil, fmt.Errorf("erro eval header '%s': %w", name, err)
+			return nil, fmt.Errorf("erro eval header '%s': %w", name, err)
 		}
 		respHeaders[name] = fmt.Sprintf("%v", out.Value())
 	}
 
-	return rb.statusCode, bytes, respHeaders, nil
+	return &Response{StatusCode: status, Body: body, Headers: respHeaders, Data: processedBody}, nil
 }
 
 func (rb *ResponseBuilder) processRecursive(template interface{}, ctx map[string]interface{}) (interface{}, error) {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/raywall/fast-service-toolkit/pkg/codec"
 	"github.com/raywall/fast-service-toolkit/pkg/config"
 	"github.com/raywall/fast-service-toolkit/pkg/rules"
 	"github.com/stretchr/testify/assert"
@@ -44,3 +45,261 @@ func TestResponseBuilder_Build(t *testing.T) {
 	assert.Equal(t, "Processado com sucesso", bodyMap["mensagem"])
 	assert.Equal(t, true, bodyMap["ativo"])
 }
+
+func TestResponseBuilder_BuildFor_Formats(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+
+	cfg := config.OutputStep{
+		StatusCode: 200,
+		Format:     "json",
+		Formats:    []string{"csv", "xml"},
+		Body: map[string]interface{}{
+			"items": "${input.items}",
+			"total": "${size(input.items)}",
+			"tags":  "${['a', 'b']}",
+		},
+		CSV: config.CSVConf{Rows: "items", Columns: []string{"id"}},
+		XML: config.XMLConf{Root: "export"},
+	}
+	builder, err := NewResponseBuilder(cfg, rm)
+	assert.NoError(t, err)
+
+	ctx := map[string]interface{}{
+		"input": map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"id": "1"}, map[string]interface{}{"id": "2"}},
+		},
+	}
+
+	resp, err := builder.BuildFor(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", resp.Headers["Content-Type"])
+	assert.Equal(t, "Accept", resp.Headers["Vary"])
+	// Listas literais do CEL são convertidas para tipos Go antes da codificação
+	assert.JSONEq(t, `{"items":[{"id":"1"},{"id":"2"}],"total":2,"tags":["a","b"]}`, string(resp.Body))
+
+	resp, err = builder.BuildFor(ctx, "text/csv, application/json;q=0.9")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Headers["Content-Type"])
+	assert.Equal(t, "id\n1\n2\n", string(resp.Body))
+	assert.EqualValues(t, 2, resp.Data.(map[string]interface{})["total"])
+
+	resp, err = builder.BuildFor(ctx, "application/xml")
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp.Body), "<export><items><id>1</id></items><items><id>2</id></items>")
+
+	_, err = builder.BuildFor(ctx, "text/html")
+	assert.ErrorIs(t, err, codec.ErrNotAcceptable)
+}
+
+func TestResponseBuilder_TemplateContentType(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+
+	cfg := config.OutputStep{
+		StatusCode:  200,
+		Format:      "template",
+		ContentType: "text/html; charset=utf-8",
+		Template:    "<h1>{{.title}}</h1>",
+		Body:        map[string]interface{}{"title": "${input.title}"},
+		Headers:     map[string]string{"Content-Type": "text/html"},
+	}
+	builder, err := NewResponseBuilder(cfg, rm)
+	assert.NoError(t, err)
+
+	// Sem 'formats', o Accept é ignorado
+	resp, err := builder.BuildFor(map[string]interface{}{"input": map[string]interface{}{"title": "Relatório"}}, "application/json")
+	assert.NoError(t, err)
+	assert.Equal(t, "<h1>Relatório</h1>", string(resp.Body))
+	// Headers declarados no output prevalecem sobre o do encoder
+	assert.Equal(t, "text/html", resp.Headers["Content-Type"])
+	assert.NotContains(t, resp.Headers, "Vary")
+
+	_, err = NewResponseBuilder(config.OutputStep{Format: "yaml", Body: map[string]interface{}{}}, rm)
+	assert.ErrorContains(t, err, "formato de output desconhecido")
+}
+
+func TestResponseBuilder_DuplicateMediaType(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+
+	cfg := config.OutputStep{
+		StatusCode: 200,
+		Format:     "template",
+		Formats:    []string{"text"},
+		Template:   "{{.msg}}",
+		Body:       map[string]interface{}{"msg": "ok"},
+	}
+	_, err := NewResponseBuilder(cfg, rm)
+	assert.ErrorContains(t, err, "mesmo Content-Type (text/plain)")
+
+	// Com content_type explícito no principal, os dois formatos ficam distinguíveis
+	cfg.ContentType = "text/html; charset=utf-8"
+	builder, err := NewResponseBuilder(cfg, rm)
+	assert.NoError(t, err)
+
+	resp, err := builder.BuildFor(map[string]interface{}{}, "text/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Headers["Content-Type"])
+
+	resp, err = builder.BuildFor(map[string]interface{}{}, "text/html")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Headers["Content-Type"])
+	assert.Equal(t, "ok", string(resp.Body))
+}
+
+func TestResponseBuilder_Variants(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+
+	cfg := config.OutputStep{
+		StatusCode: 200,
+		Body:       map[string]interface{}{"status": "approved", "id": "${input.id}"},
+		Headers:    map[string]string{"X-Decision": "auto", "X-Id": "${input.id}"},
+		Variants: []config.OutputVariant{
+			{
+				When:       "input.score < 300",
+				StatusCode: "${input.score < 100 ? 403 : 422}",
+				Body:       map[string]interface{}{"status": "rejected"},
+			},
+			{
+				When:       "${input.score < 700}",
+				StatusCode: 202,
+				Headers:    map[string]string{"X-Decision": "manual"},
+			},
+		},
+	}
+	builder, err := NewResponseBuilder(cfg, rm)
+	assert.NoError(t, err)
+
+	build := func(score int) (int, map[string]interface{}, map[string]string) {
+		code, body, headers, err := builder.Build(map[string]interface{}{
+			"input": map[string]interface{}{"id": "C-1", "score": score},
+		})
+		assert.NoError(t, err)
+		var m map[string]interface{}
+		_ = json.Unmarshal(body, &m)
+		return code, m, headers
+	}
+
+	code, body, headers := build(50)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, map[string]interface{}{"status": "rejected"}, body)
+	assert.Equal(t, "auto", headers["X-Decision"], "Headers do output são herdados")
+
+	code, _, _ = build(250)
+	assert.Equal(t, 422, code)
+
+	code, body, headers = build(500)
+	assert.Equal(t, 202, code)
+	assert.Equal(t, "approved", body["status"], "Body omitido é herdado do output")
+	assert.Equal(t, "manual", headers["X-Decision"])
+	assert.Equal(t, "C-1", headers["X-Id"])
+
+	code, body, headers = build(800)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "approved", body["status"])
+	assert.Equal(t, "auto", headers["X-Decision"])
+}
+
+func TestResponseBuilder_StatusCode(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+	body := map[string]interface{}{}
+
+	build := func(status interface{}, ctx map[string]interface{}) (int, error) {
+		builder, err := NewResponseBuilder(config.OutputStep{StatusCode: status, Body: body}, rm)
+		if err != nil {
+			return 0, err
+		}
+		code, _, _, err := builder.Build(ctx)
+		return code, err
+	}
+
+	code, err := build("201", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 201, code)
+
+	code, err = build("vars.created ? 201 : 200", map[string]interface{}{"vars": map[string]interface{}{"created": true}})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, code)
+
+	code, err = build(nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code, "Sem status_code, o default é 200")
+
+	_, err = build(700, nil)
+	assert.ErrorContains(t, err, "fora do intervalo")
+
+	_, err = build("input.code", map[string]interface{}{"input": map[string]interface{}{"code": 99}})
+	assert.ErrorContains(t, err, "fora do intervalo")
+
+	_, err = build("'abc'", nil)
+	assert.ErrorContains(t, err, "não numérico")
+
+	_, err = build(true, nil)
+	assert.ErrorContains(t, err, "inteiro ou expressão CEL")
+
+	_, err = NewResponseBuilder(config.OutputStep{Body: body, Variants: []config.OutputVariant{{StatusCode: 202}}}, rm)
+	assert.ErrorContains(t, err, "'when' é obrigatório")
+}
+
+func TestResponseBuilder_Functions(t *testing.T) {
+	rm, _ := rules.NewRuleManager()
+
+	cfg := config.OutputStep{
+		Body: map[string]interface{}{
+			"proposal_id":  "${uuid.new()}",
+			"processed_at": "${time.format(timestamp('2025-03-10T17:30:00Z'), 'datetime', 'America/Sao_Paulo')}",
+			"document":     "${regex.replace(input.document, '\\\\D', '')}",
+			"limits":       "${json.parse(input.payload).limits}",
+			"city":         "${get(input, 'address.city', 'desconhecida')}",
+		},
+		Headers: map[string]string{
+			"X-Signature": "${hash.hmac(input.document, 'secret').upper()}",
+		},
+	}
+
+	builder, err := NewResponseBuilder(cfg, rm)
+	assert.NoError(t, err)
+
+	ctx := map[string]interface{}{
+		"input": map[string]interface{}{"document": "529.982.247-25", "payload": `{"limits":[100,250]}`},
+	}
+	_, bodyBytes, headers, err := builder.Build(ctx)
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9A-F]{64}$", headers["X-Signature"])
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(bodyBytes, &body))
+	assert.Len(t, body["proposal_id"], 36)
+	assert.Equal(t, "2025-03-10 14:30:00", body["processed_at"])
+	assert.Equal(t, "52998224725", body["document"])
+	assert.Equal(t, []interface{}{100.0, 250.0}, body["limits"])
+	assert.Equal(t, "desconhecida", body["city"])
+}
+
+func TestExpressions(t *testing.T) {
+	out := config.OutputStep{
+		StatusCode: "${vars.ok ? 200 : 422}",
+		Body: map[string]interface{}{
+			"tier":  "${vars.tier}",
+			"label": "fixo",
+			"customer": map[interface{}]interface{}{
+				"scores": []interface{}{"${detection.a.score}", 10},
+			},
+		},
+		Headers: map[string]string{"X-Tier": "${vars.tier}"},
+		Variants: []config.OutputVariant{
+			{When: "${vars.blocked}", StatusCode: 403},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"status_code":             "vars.ok ? 200 : 422",
+		"body.tier":               "vars.tier",
+		"body.label":              "'fixo'",
+		"body.customer.scores[0]": "detection.a.score",
+		"headers[X-Tier]":         "vars.tier",
+		"variants[0].when":        "vars.blocked",
+	}, Expressions(out))
+
+	rm, _ := rules.NewRuleManager()
+	assert.NoError(t, ValidateBody(out.Body, out.Headers, rm))
+	assert.ErrorContains(t, ValidateBody(map[string]interface{}{"a": []interface{}{"${vars.}"}}, nil, rm), "campo 'a'")
+}
@@ -0,0 +1,224 @@
+package responder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// variant é uma forma compilada de resposta: condição, status, body e headers.
+// A variante base (output sem 'when') é o fallback quando nenhuma outra casa.
+type variant struct {
+	when           cel.Program // nil: sempre aplica
+	status         statusCode
+	bodyTemplate   interface{}
+	headerPrograms map[string]cel.Program
+}
+
+// statusCode é um código fixo ou uma expressão CEL avaliada a cada resposta.
+type statusCode struct {
+	code int
+	prg  cel.Program
+}
+
+func (rb *ResponseBuilder) compileVariant(when string, status interface{}, body map[string]interface{}, headers map[string]string) (*variant, error) {
+	v := &variant{headerPrograms: make(map[string]cel.Program)}
+
+	if when != "" {
+		prg, err := rb.ruleManager.CompileProgram(stripInterpolation(when))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao compilar when '%s': %w", when, err)
+		}
+		v.when = prg
+	}
+
+	sc, err := compileStatusCode(status, rb.ruleManager)
+	if err != nil {
+		return nil, err
+	}
+	v.status = sc
+
+	if body != nil {
+		v.bodyTemplate = sanitize(body)
+		if err := rb.validateBodyExpressions(v.bodyTemplate); err != nil {
+			return nil, err
+		}
+	}
+
+	for headerName, rawExpr := range headers {
+		prg, err := rb.ruleManager.CompileProgram(normalizeExpression(rawExpr))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao compilar header '%s': %w", headerName, err)
+		}
+		v.headerPrograms[headerName] = prg
+	}
+	return v, nil
+}
+
+// inherit completa a variante com o status, o body e os headers do output.
+func (v *variant) inherit(base *variant) {
+	if v.status.prg == nil && v.status.code == 0 {
+		v.status = base.status
+	}
+	if v.bodyTemplate == nil {
+		v.bodyTemplate = base.bodyTemplate
+	}
+	for name, prg := range base.headerPrograms {
+		if _, ok := v.headerPrograms[name]; !ok {
+			v.headerPrograms[name] = prg
+		}
+	}
+}
+
+// selectVariant retorna a primeira variante cujo 'when' é verdadeiro, ou a base.
+func (rb *ResponseBuilder) selectVariant(ctx map[string]interface{}) (*variant, error) {
+	for i, v := range rb.variants {
+		out, _, err := v.when.Eval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("erro eval variants[%d].when: %w", i, err)
+		}
+		if matched, ok := out.Value().(bool); ok && matched {
+			return v, nil
+		}
+	}
+	return rb.base, nil
+}
+
+func (s statusCode) eval(ctx map[string]interface{}) (int, error) {
+	if s.prg == nil {
+		return s.code, nil
+	}
+	out, _, err := s.prg.Eval(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("erro eval status_code: %w", err)
+	}
+	var code int
+	switch val := out.Value().(type) {
+	case int64:
+		code = int(val)
+	case uint64:
+		code = int(val)
+	case float64:
+		code = int(val)
+	case string:
+		if code, err = strconv.Atoi(val); err != nil {
+			return 0, fmt.Errorf("status_code não numérico: '%s'", val)
+		}
+	default:
+		return 0, fmt.Errorf("status_code deve ser inteiro, recebido %T", val)
+	}
+	if !validStatus(code) {
+		return 0, fmt.Errorf("status_code fora do intervalo 200-599: %d", code)
+	}
+	return code, nil
+}
+
+// compileStatusCode aceita um inteiro (ou string numérica) ou uma expressão CEL,
+// com ou sem "${...}". Nil indica status não declarado (código 0).
+func compileStatusCode(raw interface{}, rm *rules.RuleManager) (statusCode, error) {
+	switch val := raw.(type) {
+	case nil:
+		return statusCode{}, nil
+	case int:
+		if !validStatus(val) {
+			return statusCode{}, fmt.Errorf("status_code fora do intervalo 200-599: %d", val)
+		}
+		return statusCode{code: val}, nil
+	case string:
+		expr := stripInterpolation(val)
+		if code, err := strconv.Atoi(expr); err == nil {
+			return compileStatusCode(code, rm)
+		}
+		prg, err := rm.CompileProgram(expr)
+		if err != nil {
+			return statusCode{}, fmt.Errorf("erro ao compilar status_code '%s': %w", val, err)
+		}
+		return statusCode{prg: prg}, nil
+	default:
+		return statusCode{}, fmt.Errorf("status_code deve ser inteiro ou expressão CEL, recebido %T", raw)
+	}
+}
+
+// ValidateVariant compila condição, status, body e headers de uma variante do output.
+func ValidateVariant(vc config.OutputVariant, rm *rules.RuleManager) error {
+	if vc.When == "" {
+		return fmt.Errorf("'when' é obrigatório")
+	}
+	rb := &ResponseBuilder{ruleManager: rm}
+	_, err := rb.compileVariant(vc.When, vc.StatusCode, vc.Body, vc.Headers)
+	return err
+}
+
+// ValidateBody compila as expressões do body e dos headers do output. Valores do body
+// podem ser objetos e listas aninhados; textos sem "${...}" são literais.
+func ValidateBody(body map[string]interface{}, headers map[string]string, rm *rules.RuleManager) error {
+	rb := &ResponseBuilder{ruleManager: rm}
+	_, err := rb.compileVariant("", nil, body, headers)
+	return err
+}
+
+// Expressions retorna as expressões CEL do output (status, body, headers e variantes),
+// normalizadas como são avaliadas, indexadas pelo local onde aparecem (ex: "body.customer.tier").
+func Expressions(out config.OutputStep) map[string]string {
+	exprs := make(map[string]string)
+	collect := func(prefix string, status interface{}, body map[string]interface{}, headers map[string]string) {
+		if s, ok := status.(string); ok {
+			if _, err := strconv.Atoi(stripInterpolation(s)); err != nil {
+				exprs[prefix+"status_code"] = stripInterpolation(s)
+			}
+		}
+		if body != nil {
+			collectBody(prefix+"body", sanitize(body), exprs)
+		}
+		for name, raw := range headers {
+			exprs[prefix+"headers["+name+"]"] = normalizeExpression(raw)
+		}
+	}
+
+	collect("", out.StatusCode, out.Body, out.Headers)
+	for i, v := range out.Variants {
+		prefix := fmt.Sprintf("variants[%d].", i)
+		if v.When != "" {
+			exprs[prefix+"when"] = stripInterpolation(v.When)
+		}
+		collect(prefix, v.StatusCode, v.Body, v.Headers)
+	}
+	return exprs
+}
+
+func collectBody(path string, data interface{}, exprs map[string]string) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			collectBody(path+"."+k, val, exprs)
+		}
+	case []interface{}:
+		for i, val := range v {
+			collectBody(fmt.Sprintf("%s[%d]", path, i), val, exprs)
+		}
+	case string:
+		exprs[path] = normalizeExpression(v)
+	}
+}
+
+// ValidateStatusCode verifica um status_code declarado (inteiro ou expressão CEL).
+func ValidateStatusCode(raw interface{}, rm *rules.RuleManager) error {
+	_, err := compileStatusCode(raw, rm)
+	return err
+}
+
+func validStatus(code int) bool {
+	return code >= 200 && code < 600
+}
+
+func stripInterpolation(expr string) string {
+	trimmed := strings.TrimSpace(expr)
+	if strings.HasPrefix(trimmed, "${") && strings.HasSuffix(trimmed, "}") {
+		return trimmed[2 : len(trimmed)-1]
+	}
+	return trimmed
+}
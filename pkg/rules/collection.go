@@ -0,0 +1,334 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+// Tipos de transformação de coleção (TransformationRule.Type). Sem tipo, a
+// transformação calcula um único valor.
+const (
+	TransformForeach = "foreach" // Executa as transformações aninhadas; resulta na lista do que cada item gravou
+	TransformMap     = "map"     // Resulta na lista de 'value' avaliado para cada item
+	TransformFilter  = "filter"  // Resulta nos itens em que 'value' é verdadeiro
+	TransformReduce  = "reduce"  // Acumula 'value' a partir de 'initial'
+)
+
+// Aliases padrão das transformações de coleção.
+const (
+	DefaultItemAlias        = "item"
+	DefaultIndexAlias       = "index"
+	DefaultAccumulatorAlias = "acc"
+)
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// collectionAliases retorna os nomes declarados no escopo de cada item.
+func collectionAliases(rule config.TransformationRule) (item, index, acc string) {
+	item, index, acc = rule.As, rule.IndexAs, rule.Accumulator
+	if item == "" {
+		item = DefaultItemAlias
+	}
+	if index == "" {
+		index = DefaultIndexAlias
+	}
+	if acc == "" {
+		acc = DefaultAccumulatorAlias
+	}
+	return item, index, acc
+}
+
+// scopeVar é uma variável declarada no escopo de cada item da coleção.
+type scopeVar struct {
+	name string
+	typ  *cel.Type
+}
+
+// scopeVars declara os aliases do item (com o tipo dos elementos de 'items', quando
+// conhecido), da posição e, no reduce, do acumulador.
+func (rm *RuleManager) scopeVars(rule config.TransformationRule) []scopeVar {
+	item, index, acc := collectionAliases(rule)
+	itemType := cel.DynType
+	if list := rm.outputType(rule.Items); list.Kind() == types.ListKind && len(list.Parameters()) == 1 {
+		itemType = list.Parameters()[0]
+	}
+	vars := []scopeVar{{item, itemType}, {index, cel.IntType}}
+	if rule.Type == TransformReduce {
+		vars = append(vars, scopeVar{acc, cel.DynType})
+	}
+	return vars
+}
+
+// scoped retorna um RuleManager cujo ambiente declara também as variáveis informadas.
+// Os ambientes derivados (e seus programas) ficam em cache no manager de origem.
+func (rm *RuleManager) scoped(vars ...scopeVar) (*RuleManager, error) {
+	keys := make([]string, len(vars))
+	for i, v := range vars {
+		keys[i] = v.name + ":" + v.typ.String()
+	}
+	key := strings.Join(keys, ",")
+
+	rm.mu.RLock()
+	child, ok := rm.scopes[key]
+	rm.mu.RUnlock()
+	if ok {
+		return child, nil
+	}
+
+	root := rm.root
+	if root == nil {
+		root = rm
+	}
+	seen := make(map[string]bool, len(vars))
+	declarations := make([]cel.EnvOption, 0, len(vars))
+	for _, v := range vars {
+		if !identifierRegex.MatchString(v.name) {
+			return nil, fmt.Errorf("alias inválido: '%s'", v.name)
+		}
+		if seen[v.name] {
+			return nil, fmt.Errorf("alias repetido: '%s'", v.name)
+		}
+		seen[v.name] = true
+		// Nomes já conhecidos pelo ambiente padrão (input, vars, int, true...) não podem ser redeclarados
+		if _, issues := root.env.Compile(v.name); issues == nil || issues.Err() == nil {
+			return nil, fmt.Errorf("alias '%s' conflita com um nome do contexto CEL", v.name)
+		}
+		declarations = append(declarations, cel.Variable(v.name, v.typ))
+	}
+
+	// Aliases de coleções externas continuam visíveis, exceto os redeclarados (que passam a
+	// ter o tipo do item interno). Por isso o ambiente deriva sempre do padrão.
+	aliases := append([]scopeVar(nil), vars...)
+	for _, outer := range rm.aliases {
+		if !seen[outer.name] {
+			aliases = append(aliases, outer)
+			declarations = append(declarations, cel.Variable(outer.name, outer.typ))
+		}
+	}
+	env, err := root.env.Extend(declarations...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao declarar aliases %v: %w", keys, err)
+	}
+	child = &RuleManager{
+		env:      env,
+		root:     root,
+		aliases:  aliases,
+		programs: make(map[string]programEntry),
+		scopes:   make(map[string]*RuleManager),
+	}
+	for _, v := range vars {
+		// Palavras reservadas (in, null...) passam pela regex mas não são identificadores
+		if _, err := child.compile(v.name); err != nil {
+			return nil, fmt.Errorf("alias inválido: '%s'", v.name)
+		}
+	}
+
+	rm.mu.Lock()
+	rm.scopes[key] = child
+	rm.mu.Unlock()
+	return child, nil
+}
+
+// executeCollection avalia 'items' e aplica a transformação a cada elemento. No escopo
+// do item ficam o alias do item, o da posição e, no reduce, o do acumulador.
+func (rm *RuleManager) executeCollection(rule config.TransformationRule, ctx map[string]interface{}) (interface{}, error) {
+	scope, err := rm.scoped(rm.scopeVars(rule)...)
+	if err != nil {
+		return nil, err
+	}
+	itemAlias, indexAlias, accAlias := collectionAliases(rule)
+
+	raw, err := rm.EvaluateValue(rule.Items, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao avaliar items: %w", err)
+	}
+	items, err := listItems(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc interface{}
+	if rule.Type == TransformReduce {
+		if acc, err = rm.EvaluateValue(rule.Initial, ctx); err != nil {
+			return nil, fmt.Errorf("falha ao avaliar initial: %w", err)
+		}
+	}
+
+	results := make([]interface{}, 0, len(items))
+	for i, item := range items {
+		itemCtx := make(map[string]interface{}, len(ctx)+3)
+		for k, v := range ctx {
+			itemCtx[k] = v
+		}
+		itemCtx[itemAlias] = item
+		itemCtx[indexAlias] = int64(i)
+		if rule.Type == TransformReduce {
+			itemCtx[accAlias] = acc
+		}
+
+		written, err := scope.applyNested(rule.Transformations, itemCtx)
+		if err != nil {
+			return nil, fmt.Errorf("item[%d]: %w", i, err)
+		}
+
+		switch rule.Type {
+		case TransformForeach:
+			results = append(results, written)
+		case TransformMap:
+			val, err := scope.EvaluateValue(rule.Value, itemCtx)
+			if err != nil {
+				return nil, fmt.Errorf("item[%d]: %w", i, err)
+			}
+			results = append(results, val)
+		case TransformFilter:
+			keep, err := scope.EvaluateBool(rule.Value, itemCtx)
+			if err != nil {
+				return nil, fmt.Errorf("item[%d]: %w", i, err)
+			}
+			if keep {
+				results = append(results, item)
+			}
+		case TransformReduce:
+			if acc, err = scope.EvaluateValue(rule.Value, itemCtx); err != nil {
+				return nil, fmt.Errorf("item[%d]: %w", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("tipo de transformação desconhecido: '%s'", rule.Type)
+		}
+	}
+
+	if rule.Type == TransformReduce {
+		return acc, nil
+	}
+	return results, nil
+}
+
+// applyNested executa as transformações aninhadas de um item. No escopo do item, 'vars'
+// são as variáveis externas acrescidas do que for gravado; o retorno traz apenas as
+// variáveis gravadas pelo item.
+func (rm *RuleManager) applyNested(nested []config.TransformationRule, ctx map[string]interface{}) (map[string]interface{}, error) {
+	written := make(map[string]interface{})
+	for _, rule := range nested {
+		res, err := rm.ExecuteTransformation(rule, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !res.Applied {
+			continue
+		}
+		target, err := ParseTarget(res.Target)
+		if err != nil {
+			return nil, err
+		}
+		if target.Root != TargetVars {
+			return nil, fmt.Errorf("transformação '%s': dentro de coleções o target deve estar em vars", rule.Name)
+		}
+		// SetPath copia os contêineres do caminho: as vars externas não são alteradas
+		vars, _ := ctx["vars"].(map[string]interface{})
+		if ctx["vars"], err = SetPath(vars, target.Path, res.Value); err != nil {
+			return nil, fmt.Errorf("transformação '%s': %w", rule.Name, err)
+		}
+		if written, err = SetPath(written, target.Path, res.Value); err != nil {
+			return nil, fmt.Errorf("transformação '%s': %w", rule.Name, err)
+		}
+	}
+	return written, nil
+}
+
+// listItems normaliza o resultado de 'items' (listas do input ou criadas no CEL).
+func listItems(v interface{}) ([]interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return x, nil
+	case []ref.Val:
+		items := make([]interface{}, len(x))
+		for i, item := range x {
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("items deve resultar em uma lista, recebido %T", v)
+	}
+}
+
+// ValidateTransformation confere a estrutura e o target da transformação e compila suas
+// expressões (inclusive das aninhadas), declarando os aliases de item, posição e acumulador.
+func (rm *RuleManager) ValidateTransformation(rule config.TransformationRule) []error {
+	return rm.validateTransformation(rule, false)
+}
+
+func (rm *RuleManager) validateTransformation(rule config.TransformationRule, nested bool) []error {
+	var errs []error
+	if rule.Target == "" {
+		errs = append(errs, fmt.Errorf("target é obrigatório"))
+	} else if target, err := ParseTarget(rule.Target); err != nil {
+		errs = append(errs, err)
+	} else if nested && target.Root != TargetVars {
+		errs = append(errs, fmt.Errorf("target deve estar em vars dentro de coleções: '%s'", rule.Target))
+	}
+
+	compile := func(scope *RuleManager, field, expr string) {
+		if expr == "" {
+			return
+		}
+		if _, err := scope.program(expr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	compile(rm, "condition", rule.Condition)
+	compile(rm, "else_value", rule.ElseValue)
+
+	switch rule.Type {
+	case "":
+		if rule.Value == "" {
+			errs = append(errs, fmt.Errorf("value é obrigatório"))
+		}
+		if len(rule.Transformations) > 0 {
+			errs = append(errs, fmt.Errorf("transformations aninhadas exigem type foreach, map, filter ou reduce"))
+		}
+		compile(rm, "value", rule.Value)
+		return errs
+	case TransformForeach:
+		if len(rule.Transformations) == 0 {
+			errs = append(errs, fmt.Errorf("foreach exige transformations aninhadas"))
+		}
+	case TransformMap, TransformFilter, TransformReduce:
+		if rule.Value == "" {
+			errs = append(errs, fmt.Errorf("value é obrigatório no %s", rule.Type))
+		}
+	default:
+		return append(errs, fmt.Errorf("tipo de transformação desconhecido: '%s'", rule.Type))
+	}
+
+	if rule.Items == "" {
+		errs = append(errs, fmt.Errorf("items é obrigatório no %s", rule.Type))
+	}
+	compile(rm, "items", rule.Items)
+	if rule.Type == TransformReduce {
+		if rule.Initial == "" {
+			errs = append(errs, fmt.Errorf("initial é obrigatório no reduce"))
+		}
+		compile(rm, "initial", rule.Initial)
+	}
+
+	scope, err := rm.scoped(rm.scopeVars(rule)...)
+	if err != nil {
+		return append(errs, err)
+	}
+	compile(scope, "value", rule.Value)
+	for _, child := range rule.Transformations {
+		for _, err := range scope.validateTransformation(child, true) {
+			errs = append(errs, fmt.Errorf("transformations[%s].%w", child.Name, err))
+		}
+	}
+	return errs
+}
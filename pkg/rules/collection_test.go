@@ -0,0 +1,170 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func collectionContext() map[string]interface{} {
+	return map[string]interface{}{
+		"detection": map[string]interface{}{
+			"orders": []interface{}{
+				map[string]interface{}{"id": "a", "total": 10.0, "status": "paid"},
+				map[string]interface{}{"id": "b", "total": 25.5, "status": "open"},
+				map[string]interface{}{"id": "c", "total": 4.5, "status": "paid"},
+			},
+		},
+		"vars": map[string]interface{}{"rate": 2.0},
+	}
+}
+
+func TestExecuteTransformation_Collections(t *testing.T) {
+	rm, err := NewRuleManager()
+	if err != nil {
+		t.Fatalf("Erro ao criar manager: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		rule config.TransformationRule
+		want interface{}
+	}{
+		{
+			name: "map com alias",
+			rule: config.TransformationRule{Type: TransformMap, Items: "detection.orders", As: "order", Value: "order.id"},
+			want: []interface{}{"a", "b", "c"},
+		},
+		{
+			name: "filter",
+			rule: config.TransformationRule{Type: TransformFilter, Items: "detection.orders", Value: "item.status == 'paid'"},
+			want: []interface{}{
+				map[string]interface{}{"id": "a", "total": 10.0, "status": "paid"},
+				map[string]interface{}{"id": "c", "total": 4.5, "status": "paid"},
+			},
+		},
+		{
+			name: "reduce",
+			rule: config.TransformationRule{Type: TransformReduce, Items: "detection.orders", Initial: "0.0", Value: "acc + item.total"},
+			want: 40.0,
+		},
+		{
+			name: "reduce com acumulador nomeado",
+			rule: config.TransformationRule{Type: TransformReduce, Items: "[1, 2, 3]", As: "n", Accumulator: "sum", Initial: "0", Value: "sum + n"},
+			want: int64(6),
+		},
+		{
+			name: "foreach com transformações aninhadas",
+			rule: config.TransformationRule{
+				Type:  TransformForeach,
+				Items: "detection.orders.filter(o, o.status == 'paid')",
+				As:    "order",
+				Transformations: []config.TransformationRule{
+					{Name: "pos", Value: "index", Target: "vars.position"},
+					{Name: "total", Value: "order.total * vars.rate", Target: "vars.total"},
+					{Name: "big", Condition: "vars.total > 10.0", Value: "true", ElseValue: "false", Target: "vars.big"},
+				},
+			},
+			want: []interface{}{
+				map[string]interface{}{"position": int64(0), "total": 20.0, "big": true},
+				map[string]interface{}{"position": int64(1), "total": 9.0, "big": false},
+			},
+		},
+		{
+			name: "map aninhado reutiliza os aliases",
+			rule: config.TransformationRule{
+				Type:  TransformMap,
+				Items: "[[1, 2], [4]]",
+				Transformations: []config.TransformationRule{
+					{Name: "sum", Type: TransformReduce, Items: "item", Initial: "0", Value: "acc + item", Target: "vars.sum"},
+				},
+				Value: "vars.sum * 10",
+			},
+			want: []interface{}{int64(30), int64(40)},
+		},
+		{
+			name: "lista vazia",
+			rule: config.TransformationRule{Type: TransformMap, Items: "[]", Value: "item"},
+			want: []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Name = "t"
+			tt.rule.Target = "vars.out"
+			ctx := collectionContext()
+
+			res, err := rm.ExecuteTransformation(tt.rule, ctx)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, res.Applied)
+			assert.Equal(t, "vars.out", res.Target)
+			assert.Equal(t, tt.want, res.Value)
+			assert.Equal(t, map[string]interface{}{"rate": 2.0}, ctx["vars"], "vars externas não devem ser alteradas pelos itens")
+		})
+	}
+}
+
+func TestExecuteTransformation_CollectionErrors(t *testing.T) {
+	rm, _ := NewRuleManager()
+
+	_, err := rm.ExecuteTransformation(config.TransformationRule{
+		Name: "t", Type: TransformMap, Items: "vars.rate", Value: "item", Target: "vars.x",
+	}, collectionContext())
+	assert.ErrorContains(t, err, "items deve resultar em uma lista")
+
+	_, err = rm.ExecuteTransformation(config.TransformationRule{
+		Name: "t", Type: TransformFilter, Items: "detection.orders", Value: "item.total", Target: "vars.x",
+	}, collectionContext())
+	assert.ErrorContains(t, err, "item[0]")
+
+	// Condição falsa: else_value é avaliado como valor escalar
+	res, err := rm.ExecuteTransformation(config.TransformationRule{
+		Name: "t", Type: TransformMap, Condition: "false", Items: "detection.orders", Value: "item.id", ElseValue: "[]", Target: "vars.x",
+	}, collectionContext())
+	assert.NoError(t, err)
+	assert.True(t, res.Applied)
+}
+
+func TestValidateTransformation(t *testing.T) {
+	rm, _ := NewRuleManager()
+
+	valid := config.TransformationRule{
+		Name: "t", Type: TransformForeach, Items: "input.items", As: "line", Target: "vars.lines",
+		Transformations: []config.TransformationRule{
+			{Name: "qty", Type: TransformReduce, Items: "line.parts", As: "part", Initial: "0", Value: "acc + part.qty", Target: "vars.qty"},
+		},
+	}
+	assert.Empty(t, rm.ValidateTransformation(valid))
+
+	cases := []struct {
+		rule config.TransformationRule
+		want string
+	}{
+		{config.TransformationRule{Name: "t"}, "value é obrigatório"},
+		{config.TransformationRule{Name: "t", Value: "1", Transformations: valid.Transformations}, "exigem type"},
+		{config.TransformationRule{Name: "t", Type: "sort", Items: "input.items"}, "tipo de transformação desconhecido"},
+		{config.TransformationRule{Name: "t", Type: TransformMap, Value: "item"}, "items é obrigatório"},
+		{config.TransformationRule{Name: "t", Type: TransformReduce, Items: "input.items", Value: "acc"}, "initial é obrigatório"},
+		{config.TransformationRule{Name: "t", Type: TransformForeach, Items: "input.items"}, "foreach exige"},
+		{config.TransformationRule{Name: "t", Type: TransformMap, Items: "input.items", As: "input", Value: "input"}, "conflita"},
+		{config.TransformationRule{Name: "t", Type: TransformMap, Items: "input.items", As: "in", Value: "1"}, "alias inválido"},
+		{config.TransformationRule{Name: "t", Type: TransformMap, Items: "input.items", As: "x", IndexAs: "x", Value: "1"}, "alias repetido"},
+		{config.TransformationRule{Name: "t", Type: TransformMap, Items: "input.items", Value: "order.id"}, "value:"},
+		{config.TransformationRule{Name: "t", Type: TransformForeach, Items: "input.items", Transformations: []config.TransformationRule{
+			{Name: "inner", Value: "item.id +", Target: "vars.id"},
+		}}, "transformations[inner].value:"},
+	}
+	for _, tc := range cases {
+		errs := rm.ValidateTransformation(tc.rule)
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		assert.Contains(t, strings.Join(msgs, "\n"), tc.want, "%+v", tc.rule)
+	}
+}
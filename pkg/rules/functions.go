@@ -0,0 +1,507 @@
+package rules
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Fusos horários embutidos: runtimes como o Lambda não trazem o zoneinfo
+	"unicode/utf8"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/uuid"
+)
+
+// toolkitLib registra as funções extras do toolkit no ambiente CEL. Elas ficam
+// disponíveis em validações, transformações, output, métricas e parâmetros GraphQL.
+type toolkitLib struct{}
+
+// timeLayouts são nomes amigáveis aceitos por time.format e time.parse, além dos layouts Go.
+var timeLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"date":        "2006-01-02",
+	"datetime":    "2006-01-02 15:04:05",
+	"time":        "15:04:05",
+}
+
+var emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s.]+$`)
+
+// regexCache evita recompilar os padrões usados em regex.* a cada avaliação. É limitado
+// (LRU): padrões montados a partir da requisição (regex.extract(x, input.p)) não podem
+// crescer a memória indefinidamente.
+var regexCache = newRegexLRU(256)
+
+// maxPadWidth limita a largura de padLeft/padRight, que pode vir da requisição.
+const maxPadWidth = 10000
+
+type regexEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+type regexLRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // Mais recente na frente
+	items map[string]*list.Element
+}
+
+func newRegexLRU(size int) *regexLRU {
+	return &regexLRU{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *regexLRU) get(pattern string) (*regexp.Regexp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[pattern]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(regexEntry).re, true
+}
+
+func (c *regexLRU) put(pattern string, re *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[pattern]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[pattern] = c.order.PushFront(regexEntry{pattern: pattern, re: re})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(regexEntry).pattern)
+	}
+}
+
+func (toolkitLib) ProgramOptions() []cel.ProgramOption { return nil }
+
+func (toolkitLib) CompileOptions() []cel.EnvOption {
+	str, dyn, listDyn := cel.StringType, cel.DynType, cel.ListType(cel.DynType)
+	listStr := cel.ListType(cel.StringType)
+
+	return []cel.EnvOption{
+		// --- UUID e tempo ---
+		cel.Function("uuid.new",
+			cel.Overload("uuid_new", nil, str, cel.FunctionBinding(func(...ref.Val) ref.Val {
+				return types.String(uuid.NewString())
+			}))),
+		cel.Function("time.now",
+			cel.Overload("time_now", nil, cel.TimestampType, cel.FunctionBinding(func(...ref.Val) ref.Val {
+				return types.Timestamp{Time: time.Now().UTC()}
+			}))),
+		cel.Function("time.format",
+			cel.Overload("time_format_timestamp_string", []*cel.Type{cel.TimestampType, str}, str,
+				cel.BinaryBinding(func(ts, layout ref.Val) ref.Val {
+					return formatTime(ts, layout, types.String("UTC"))
+				})),
+			cel.Overload("time_format_timestamp_string_string", []*cel.Type{cel.TimestampType, str, str}, str,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					return formatTime(args[0], args[1], args[2])
+				}))),
+		cel.Function("time.parse",
+			cel.Overload("time_parse_string_string", []*cel.Type{str, str}, cel.TimestampType,
+				cel.BinaryBinding(func(value, layout ref.Val) ref.Val {
+					return parseTime(value, layout, types.String("UTC"))
+				})),
+			cel.Overload("time_parse_string_string_string", []*cel.Type{str, str, str}, cel.TimestampType,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					return parseTime(args[0], args[1], args[2])
+				}))),
+
+		// --- Hash e codificação ---
+		cel.Function("hash.sha256",
+			cel.Overload("hash_sha256_string", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				sum := sha256.Sum256([]byte(v.(types.String)))
+				return types.String(hex.EncodeToString(sum[:]))
+			}))),
+		cel.Function("hash.hmac",
+			cel.Overload("hash_hmac_string_string", []*cel.Type{str, str}, str, cel.BinaryBinding(func(msg, key ref.Val) ref.Val {
+				mac := hmac.New(sha256.New, []byte(key.(types.String)))
+				mac.Write([]byte(msg.(types.String)))
+				return types.String(hex.EncodeToString(mac.Sum(nil)))
+			}))),
+		cel.Function("base64.encode",
+			cel.Overload("base64_encode_string", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.String(base64.StdEncoding.EncodeToString([]byte(v.(types.String))))
+			}))),
+		cel.Function("base64.decode",
+			cel.Overload("base64_decode_string", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				s := string(v.(types.String))
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					// Aceita também a variante URL-safe sem padding (ex: payload de JWT)
+					if b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "=")); err != nil {
+						return types.NewErr("base64.decode: %v", err)
+					}
+				}
+				return types.String(b)
+			}))),
+		cel.Function("hex.encode",
+			cel.Overload("hex_encode_string", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.String(hex.EncodeToString([]byte(v.(types.String))))
+			}))),
+		cel.Function("hex.decode",
+			cel.Overload("hex_decode_string", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				b, err := hex.DecodeString(string(v.(types.String)))
+				if err != nil {
+					return types.NewErr("hex.decode: %v", err)
+				}
+				return types.String(b)
+			}))),
+
+		// --- Regex ---
+		cel.Function("regex.extract",
+			cel.Overload("regex_extract_string_string", []*cel.Type{str, str}, str, cel.BinaryBinding(func(s, pattern ref.Val) ref.Val {
+				re, err := cachedRegex(pattern)
+				if err != nil {
+					return err
+				}
+				match := re.FindStringSubmatch(string(s.(types.String)))
+				switch {
+				case match == nil:
+					return types.String("")
+				case len(match) > 1:
+					return types.String(match[1]) // Primeiro grupo de captura, quando houver
+				default:
+					return types.String(match[0])
+				}
+			}))),
+		cel.Function("regex.extractAll",
+			cel.Overload("regex_extract_all_string_string", []*cel.Type{str, str}, listStr, cel.BinaryBinding(func(s, pattern ref.Val) ref.Val {
+				re, err := cachedRegex(pattern)
+				if err != nil {
+					return err
+				}
+				var out []string
+				for _, match := range re.FindAllStringSubmatch(string(s.(types.String)), -1) {
+					if len(match) > 1 {
+						out = append(out, match[1])
+					} else {
+						out = append(out, match[0])
+					}
+				}
+				return stringList(out)
+			}))),
+		cel.Function("regex.replace",
+			cel.Overload("regex_replace_string_string_string", []*cel.Type{str, str, str}, str, cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				re, err := cachedRegex(args[1])
+				if err != nil {
+					return err
+				}
+				return types.String(re.ReplaceAllString(string(args[0].(types.String)), string(args[2].(types.String))))
+			}))),
+
+		// --- Strings ---
+		cel.Function("upper",
+			cel.MemberOverload("string_upper", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.String(strings.ToUpper(string(v.(types.String))))
+			}))),
+		cel.Function("lower",
+			cel.MemberOverload("string_lower", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.String(strings.ToLower(string(v.(types.String))))
+			}))),
+		cel.Function("trim",
+			cel.MemberOverload("string_trim", []*cel.Type{str}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.String(strings.TrimSpace(string(v.(types.String))))
+			}))),
+		cel.Function("split",
+			cel.MemberOverload("string_split_string", []*cel.Type{str, str}, listStr, cel.BinaryBinding(func(s, sep ref.Val) ref.Val {
+				return stringList(strings.Split(string(s.(types.String)), string(sep.(types.String))))
+			}))),
+		cel.Function("join",
+			cel.MemberOverload("list_join_string", []*cel.Type{listDyn, str}, str, cel.BinaryBinding(func(list, sep ref.Val) ref.Val {
+				lister, ok := list.(traits.Lister)
+				if !ok {
+					return types.MaybeNoSuchOverloadErr(list)
+				}
+				var parts []string
+				for it := lister.Iterator(); it.HasNext() == types.True; {
+					parts = append(parts, fmt.Sprintf("%v", Native(it.Next())))
+				}
+				return types.String(strings.Join(parts, string(sep.(types.String))))
+			}))),
+		cel.Function("padLeft",
+			cel.MemberOverload("string_pad_left_int", []*cel.Type{str, cel.IntType}, str, cel.BinaryBinding(func(s, n ref.Val) ref.Val {
+				return pad(s, n, types.String(" "), true)
+			})),
+			cel.MemberOverload("string_pad_left_int_string", []*cel.Type{str, cel.IntType, str}, str, cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				return pad(args[0], args[1], args[2], true)
+			}))),
+		cel.Function("padRight",
+			cel.MemberOverload("string_pad_right_int", []*cel.Type{str, cel.IntType}, str, cel.BinaryBinding(func(s, n ref.Val) ref.Val {
+				return pad(s, n, types.String(" "), false)
+			})),
+			cel.MemberOverload("string_pad_right_int_string", []*cel.Type{str, cel.IntType, str}, str, cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				return pad(args[0], args[1], args[2], false)
+			}))),
+
+		// --- Matemática ---
+		cel.Function("math.round",
+			cel.Overload("math_round_double", []*cel.Type{cel.DoubleType}, cel.DoubleType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.Double(math.Round(float64(v.(types.Double))))
+			})),
+			cel.Overload("math_round_int", []*cel.Type{cel.IntType}, cel.IntType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return v
+			})),
+			cel.Overload("math_round_double_int", []*cel.Type{cel.DoubleType, cel.IntType}, cel.DoubleType, cel.BinaryBinding(func(v, digits ref.Val) ref.Val {
+				factor := math.Pow(10, float64(digits.(types.Int)))
+				return types.Double(math.Round(float64(v.(types.Double))*factor) / factor)
+			}))),
+		cel.Function("math.min",
+			cel.Overload("math_min_list", []*cel.Type{listDyn}, dyn, cel.UnaryBinding(func(list ref.Val) ref.Val {
+				return extreme(list, -1)
+			})),
+			cel.Overload("math_min_dyn_dyn", []*cel.Type{dyn, dyn}, dyn, cel.BinaryBinding(func(a, b ref.Val) ref.Val {
+				return extreme(types.NewRefValList(types.DefaultTypeAdapter, []ref.Val{a, b}), -1)
+			}))),
+		cel.Function("math.max",
+			cel.Overload("math_max_list", []*cel.Type{listDyn}, dyn, cel.UnaryBinding(func(list ref.Val) ref.Val {
+				return extreme(list, 1)
+			})),
+			cel.Overload("math_max_dyn_dyn", []*cel.Type{dyn, dyn}, dyn, cel.BinaryBinding(func(a, b ref.Val) ref.Val {
+				return extreme(types.NewRefValList(types.DefaultTypeAdapter, []ref.Val{a, b}), 1)
+			}))),
+
+		// --- JSON e navegação segura ---
+		cel.Function("json.parse",
+			cel.Overload("json_parse_string", []*cel.Type{str}, dyn, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				var out interface{}
+				if err := json.Unmarshal([]byte(v.(types.String)), &out); err != nil {
+					return types.NewErr("json.parse: %v", err)
+				}
+				return types.DefaultTypeAdapter.NativeToValue(out)
+			}))),
+		cel.Function("json.stringify",
+			cel.Overload("json_stringify_dyn", []*cel.Type{dyn}, str, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				b, err := json.Marshal(Native(v))
+				if err != nil {
+					return types.NewErr("json.stringify: %v", err)
+				}
+				return types.String(b)
+			}))),
+		cel.Function("get",
+			cel.Overload("get_dyn_string", []*cel.Type{dyn, str}, dyn, cel.BinaryBinding(func(v, path ref.Val) ref.Val {
+				return getPath(v, path, types.NullValue)
+			})),
+			cel.Overload("get_dyn_string_dyn", []*cel.Type{dyn, str, dyn}, dyn, cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				return getPath(args[0], args[1], args[2])
+			}))),
+
+		// --- Validadores ---
+		cel.Function("valid.cpf",
+			cel.Overload("valid_cpf_string", []*cel.Type{str}, cel.BoolType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.Bool(validCPF(string(v.(types.String))))
+			}))),
+		cel.Function("valid.cnpj",
+			cel.Overload("valid_cnpj_string", []*cel.Type{str}, cel.BoolType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.Bool(validCNPJ(string(v.(types.String))))
+			}))),
+		cel.Function("valid.email",
+			cel.Overload("valid_email_string", []*cel.Type{str}, cel.BoolType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+				return types.Bool(emailRegex.MatchString(string(v.(types.String))))
+			}))),
+	}
+}
+
+func timeLayout(layout ref.Val) string {
+	l := string(layout.(types.String))
+	if named, ok := timeLayouts[l]; ok {
+		return named
+	}
+	return l
+}
+
+func formatTime(ts, layout, tz ref.Val) ref.Val {
+	loc, err := time.LoadLocation(string(tz.(types.String)))
+	if err != nil {
+		return types.NewErr("time.format: fuso horário inválido: %v", err)
+	}
+	return types.String(ts.(types.Timestamp).In(loc).Format(timeLayout(layout)))
+}
+
+// parseTime interpreta o valor no fuso informado (usado quando o layout não traz offset).
+func parseTime(value, layout, tz ref.Val) ref.Val {
+	loc, err := time.LoadLocation(string(tz.(types.String)))
+	if err != nil {
+		return types.NewErr("time.parse: fuso horário inválido: %v", err)
+	}
+	t, err := time.ParseInLocation(timeLayout(layout), string(value.(types.String)), loc)
+	if err != nil {
+		return types.NewErr("time.parse: %v", err)
+	}
+	return types.Timestamp{Time: t}
+}
+
+func cachedRegex(pattern ref.Val) (*regexp.Regexp, ref.Val) {
+	p := string(pattern.(types.String))
+	if re, ok := regexCache.get(p); ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, types.NewErr("regex inválida '%s': %v", p, err)
+	}
+	regexCache.put(p, re)
+	return re, nil
+}
+
+// stringList cria uma lista CEL de valores ref.Val, que Native converte em []interface{}
+// como as demais listas (permitindo, por exemplo, gravar posições com SetPath).
+func stringList(items []string) ref.Val {
+	vals := make([]ref.Val, len(items))
+	for i, item := range items {
+		vals[i] = types.String(item)
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, vals)
+}
+
+func pad(s, n, fill ref.Val, left bool) ref.Val {
+	str, width, f := []rune(string(s.(types.String))), int(n.(types.Int)), string(fill.(types.String))
+	if width > maxPadWidth {
+		return types.NewErr("largura de padding %d excede o máximo de %d", width, maxPadWidth)
+	}
+	if f == "" || len(str) >= width {
+		return s
+	}
+	missing := width - len(str)
+	padding := []rune(strings.Repeat(f, missing/utf8.RuneCountInString(f)+1))[:missing]
+	if left {
+		return types.String(string(padding) + string(str))
+	}
+	return types.String(string(str) + string(padding))
+}
+
+// extreme retorna o menor (sign -1) ou o maior (sign 1) elemento de uma lista de
+// valores comparáveis (int, uint, double, string, timestamp...).
+func extreme(list ref.Val, sign int64) ref.Val {
+	lister, ok := list.(traits.Lister)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(list)
+	}
+	var best ref.Val
+	for it := lister.Iterator(); it.HasNext() == types.True; {
+		item := it.Next()
+		if best == nil {
+			best = item
+			continue
+		}
+		cmp, ok := item.(traits.Comparer)
+		if !ok {
+			return types.NewErr("valor não comparável: %v", item.Type())
+		}
+		res := cmp.Compare(best)
+		if types.IsError(res) {
+			return res
+		}
+		if int64(res.(types.Int)) == sign {
+			best = item
+		}
+	}
+	if best == nil {
+		return types.NewErr("lista vazia")
+	}
+	return best
+}
+
+// getPath navega por um caminho como 'a.b[0].c', retornando o default quando algum
+// trecho não existe ou é nulo.
+func getPath(v, path, def ref.Val) ref.Val {
+	p := string(path.(types.String))
+	if p != "" && !strings.HasPrefix(p, "[") {
+		p = "." + p
+	}
+	segments, err := parsePath(p)
+	if err != nil {
+		return types.NewErr("get: caminho inválido '%s': %v", path, err)
+	}
+
+	cur := v
+	for _, seg := range segments {
+		if seg.IsIndex {
+			lister, ok := cur.(traits.Lister)
+			if !ok || int64(lister.Size().(types.Int)) <= int64(seg.Index) {
+				return def
+			}
+			cur = lister.Get(types.Int(seg.Index))
+			continue
+		}
+		mapper, ok := cur.(traits.Mapper)
+		if !ok {
+			return def
+		}
+		val, found := mapper.Find(types.String(seg.Key))
+		if !found || types.IsError(val) {
+			return def
+		}
+		cur = val
+	}
+	if cur == nil || cur.Type() == types.NullType {
+		return def
+	}
+	return cur
+}
+
+func onlyDigits(s string) []int {
+	var digits []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	return digits
+}
+
+func repeated(digits []int) bool {
+	for _, d := range digits[1:] {
+		if d != digits[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDigit calcula o dígito verificador (módulo 11) com os pesos informados.
+func checkDigit(digits, weights []int) int {
+	sum := 0
+	for i, w := range weights {
+		sum += digits[i] * w
+	}
+	if rest := sum % 11; rest >= 2 {
+		return 11 - rest
+	}
+	return 0
+}
+
+func validCPF(s string) bool {
+	d := onlyDigits(s)
+	if len(d) != 11 || repeated(d) {
+		return false
+	}
+	return checkDigit(d, []int{10, 9, 8, 7, 6, 5, 4, 3, 2}) == d[9] &&
+		checkDigit(d, []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}) == d[10]
+}
+
+func validCNPJ(s string) bool {
+	d := onlyDigits(s)
+	if len(d) != 14 || repeated(d) {
+		return false
+	}
+	return checkDigit(d, []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}) == d[12] &&
+		checkDigit(d, []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}) == d[13]
+}
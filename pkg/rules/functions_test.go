@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolkitFunctions(t *testing.T) {
+	rm, err := NewRuleManager()
+	if err != nil {
+		t.Fatalf("Erro ao criar manager: %v", err)
+	}
+	ctx := map[string]interface{}{
+		"input": map[string]interface{}{
+			"name":     "  Ana Souza ",
+			"document": "529.982.247-25",
+			"payload":  `{"customer":{"tier":"gold","limits":[100,250]}}`,
+			"customer": map[string]interface{}{"address": map[string]interface{}{"city": "Recife"}},
+			"orders":   []interface{}{map[string]interface{}{"total": 10.5}, map[string]interface{}{"total": 3.25}},
+			"values":   []interface{}{int64(7), int64(3), int64(9)},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		// Strings
+		{"input.name.trim().upper()", "ANA SOUZA"},
+		{"'ABC'.lower()", "abc"},
+		{"'a,b,c'.split(',')", []interface{}{"a", "b", "c"}},
+		{"['a', 1, true].join('-')", "a-1-true"},
+		{"'42'.padLeft(6, '0')", "000042"},
+		{"'ab'.padRight(5, '.')", "ab..."},
+		{"'abc'.padLeft(2)", "abc"},
+		{"'7'.padLeft(4, 'ab')", "aba7"},
+		// Hash e codificação
+		{"hash.sha256('abc')", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"hash.hmac('The quick brown fox jumps over the lazy dog', 'key')", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"base64.encode('fast')", "ZmFzdA=="},
+		{"base64.decode('ZmFzdA==')", "fast"},
+		{"hex.encode('ok')", "6f6b"},
+		{"hex.decode('6f6b')", "ok"},
+		// Regex
+		{`regex.extract('pedido-1234', '(\\d+)')`, "1234"},
+		{`regex.extract('abc', '\\d+')`, ""},
+		{`regex.extractAll('a1b22c333', '\\d+')`, []interface{}{"1", "22", "333"}},
+		{`regex.replace(input.document, '\\D', '')`, "52998224725"},
+		// Matemática
+		{"math.round(2.5)", 3.0},
+		{"math.round(3.14159, 2)", 3.14},
+		{"math.round(7)", int64(7)},
+		{"math.min(input.values)", int64(3)},
+		{"math.max(input.values)", int64(9)},
+		{"math.max(1.5, 0.5)", 1.5},
+		{"math.min(input.orders.map(o, o.total))", 3.25},
+		// JSON e get
+		{"json.parse(input.payload).customer.tier", "gold"},
+		{"get(json.parse(input.payload), 'customer.limits[1]')", 250.0},
+		{"json.stringify({'a': [1, 2]})", `{"a":[1,2]}`},
+		{"get(input, 'customer.address.city')", "Recife"},
+		{"get(input, 'customer.phone.number', 'n/a')", "n/a"},
+		{"get(input, 'orders[5].total', 0.0)", 0.0},
+		{"get(input, 'name.first', 'x')", "x"},
+		// Tempo
+		{"time.format(time.parse('2025-03-10 14:30:00', 'datetime', 'America/Sao_Paulo'), 'RFC3339')", "2025-03-10T17:30:00Z"},
+		{"time.format(timestamp('2025-03-10T17:30:00Z'), 'date', 'Asia/Tokyo')", "2025-03-11"},
+		{"time.format(timestamp('2025-03-10T17:30:00Z'), '02/01/2006 15:04', 'America/Sao_Paulo')", "10/03/2025 14:30"},
+		{"time.now() > timestamp('2020-01-01T00:00:00Z')", true},
+		// Validadores
+		{"valid.cpf(input.document)", true},
+		{"valid.cpf('111.111.111-11')", false},
+		{"valid.cpf('529.982.247-24')", false},
+		{"valid.cnpj('11.222.333/0001-81')", true},
+		{"valid.cnpj('11222333000182')", false},
+		{"valid.email('ana@example.com')", true},
+		{"valid.email('ana@example')", false},
+		{"valid.email('ana souza@example.com')", false},
+	}
+
+	for _, tc := range tests {
+		got, err := rm.EvaluateValue(tc.expr, ctx)
+		if !assert.NoError(t, err, tc.expr) {
+			continue
+		}
+		assert.Equal(t, tc.want, Native(got), tc.expr)
+	}
+
+	id, err := rm.EvaluateValue("uuid.new()", ctx)
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`), id)
+
+	now, err := rm.EvaluateValue("time.now()", ctx)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), now.(time.Time), time.Minute)
+}
+
+func TestToolkitFunctions_Errors(t *testing.T) {
+	rm, err := NewRuleManager()
+	if err != nil {
+		t.Fatalf("Erro ao criar manager: %v", err)
+	}
+
+	runtime := map[string]string{
+		"json.parse('{x')":                       "json.parse",
+		"base64.decode('***')":                   "base64.decode",
+		"hex.decode('zz')":                       "hex.decode",
+		"regex.extract('a', '(')":                "regex inválida",
+		"time.parse('10/03', 'date')":            "time.parse",
+		"time.format(time.now(), 'date', 'X/Y')": "fuso horário inválido",
+		"math.max([])":                           "lista vazia",
+		"math.min(1, 'a')":                       "no such overload",
+		"'a'.padLeft(2000000000)":                "excede o máximo",
+	}
+	for expr, want := range runtime {
+		_, err := rm.EvaluateValue(expr, nil)
+		assert.ErrorContains(t, err, want, expr)
+	}
+
+	// Tipos incorretos são detectados na compilação
+	for _, expr := range []string{"hash.sha256(1)", "'a'.padLeft('2')", "valid.cpf(123)", "time.format('2025', 'date')"} {
+		_, err := rm.CompileProgram(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestRegexLRU(t *testing.T) {
+	c := newRegexLRU(2)
+	for _, p := range []string{"a", "b"} {
+		c.put(p, regexp.MustCompile(p))
+	}
+	c.get("a") // "b" passa a ser o menos recente
+	c.put("c", regexp.MustCompile("c"))
+
+	_, okA := c.get("a")
+	_, okB := c.get("b")
+	assert.True(t, okA)
+	assert.False(t, okB)
+	assert.Equal(t, 2, c.order.Len())
+}
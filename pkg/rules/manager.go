@@ -2,37 +2,85 @@ package rules
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/google/cel-go/cel"
-	"github.com/google/cel-go/checker/decls"
 )
 
 // RuleManager gerencia a compilação e avaliação de expressões CEL.
+// Os programas compilados ficam em cache (chaveados pela expressão), de forma
+// que cada expressão da configuração é compilada uma única vez por ambiente.
 type RuleManager struct {
-	env *cel.Env
+	env     *cel.Env
+	root    *RuleManager // Ambiente padrão (nil quando este já é o padrão)
+	aliases []scopeVar   // Variáveis de coleção declaradas sobre o ambiente padrão
+
+	mu       sync.RWMutex
+	programs map[string]programEntry
+	scopes   map[string]*RuleManager // Ambientes derivados com aliases de coleção
+}
+
+// programEntry guarda o resultado da compilação, inclusive falhas,
+// para que expressões inválidas não sejam recompiladas a cada requisição.
+type programEntry struct {
+	prg cel.Program
+	out *cel.Type // Tipo do resultado inferido na compilação
+	err error
+}
+
+// contextVars são as variáveis do contexto CEL. Todas são dinâmicas, salvo as que
+// recebem um schema (ver NewTypedRuleManager).
+var contextVars = []string{
+	"input",     // O JSON de entrada
+	"vars",      // Variáveis temporárias
+	"env",       // Variáveis de ambiente
+	"detection", // Resultado de middlewares
+	"args",      // Argumentos GraphQL
+	"source",    // Source GraphQL
+	"auth",      // Dados de Autenticação
+	"header",    // Dados de Header
+	"request",   // Metadados da requisição (client_ip, method, path)
+	"error",     // Problema da resposta de erro (errors.template)
+	"response",  // Resposta da operação (métricas de output e asserções do toolkit test)
+}
+
+func isContextVar(name string) bool {
+	for _, v := range contextVars {
+		if v == name {
+			return true
+		}
+	}
+	return false
 }
 
 // NewRuleManager inicializa o ambiente CEL com as variáveis padrão esperadas.
 func NewRuleManager() (*RuleManager, error) {
-	// Cria o ambiente CEL com suporte a tipos dinâmicos (Dyn)
-	env, err := cel.NewEnv(
+	return newRuleManager(nil)
+}
+
+func newRuleManager(typed map[string]*cel.Type, opts ...cel.EnvOption) (*RuleManager, error) {
+	opts = append(opts,
 		cel.StdLib(),
-		cel.Declarations(
-			decls.NewVar("input", decls.Dyn),     // O JSON de entrada
-			decls.NewVar("vars", decls.Dyn),      // Variáveis temporárias
-			decls.NewVar("env", decls.Dyn),       // Variáveis de ambiente
-			decls.NewVar("detection", decls.Dyn), // Resultado de middlewares
-			decls.NewVar("args", decls.Dyn),      // Argumentos GraphQL
-			decls.NewVar("source", decls.Dyn),    // Source GraphQL
-			decls.NewVar("auth", decls.Dyn),      // Dados de Autenticação
-			decls.NewVar("header", decls.Dyn),    // Dados de Header
-		),
+		cel.Lib(toolkitLib{}), // uuid, time, hash, regex, strings, math, json, get e validadores
 	)
+	for _, name := range contextVars {
+		typ, ok := typed[name]
+		if !ok {
+			typ = cel.DynType
+		}
+		opts = append(opts, cel.Variable(name, typ))
+	}
+
+	env, err := cel.NewEnv(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("erro fatal CEL init: %w", err)
 	}
 
-	return &RuleManager{env: env}, nil
+	return &RuleManager{
+		env:      env,
+		programs: make(map[string]programEntry),
+		scopes:   make(map[string]*RuleManager),
+	}, nil
 }
 
 // EvaluateBool processa regras de validação (deve retornar true/false).
@@ -41,14 +89,9 @@ func (rm *RuleManager) EvaluateBool(expression string, ctx map[string]interface{
 		return true, nil // Expressão vazia = aprova
 	}
 
-	ast, issues := rm.env.Compile(expression)
-	if issues != nil && issues.Err() != nil {
-		return false, fmt.Errorf("erro compilação CEL '%s': %s", expression, issues.Err())
-	}
-
-	prg, err := rm.env.Program(ast)
+	prg, err := rm.program(expression)
 	if err != nil {
-		return false, fmt.Errorf("erro programa CEL: %w", err)
+		return false, err
 	}
 
 	out, _, err := prg.Eval(ctx)
@@ -69,14 +112,9 @@ func (rm *RuleManager) EvaluateValue(expression string, ctx map[string]interface
 		return nil, nil
 	}
 
-	ast, issues := rm.env.Compile(expression)
-	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("erro compilação CEL '%s': %s", expression, issues.Err())
-	}
-
-	prg, err := rm.env.Program(ast)
+	prg, err := rm.program(expression)
 	if err != nil {
-		return nil, fmt.Errorf("erro programa CEL: %w", err)
+		return nil, err
 	}
 
 	out, _, err := prg.Eval(ctx)
@@ -88,28 +126,64 @@ func (rm *RuleManager) EvaluateValue(expression string, ctx map[string]interface
 }
 
 // CompileProgram expõe a compilação do CEL.
+// O programa retornado é compartilhado via cache e é seguro para uso concorrente.
 func (rm *RuleManager) CompileProgram(expr string) (cel.Program, error) {
-	ast, issues := rm.env.Compile(expr)
-	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("erro de compilação CEL: %w", issues.Err())
+	return rm.program(expr)
+}
+
+// CachedPrograms retorna a quantidade de expressões presentes no cache.
+func (rm *RuleManager) CachedPrograms() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return len(rm.programs)
+}
+
+// program busca a expressão no cache e, se ausente, compila e armazena o resultado.
+func (rm *RuleManager) program(expr string) (cel.Program, error) {
+	entry := rm.entry(expr)
+	return entry.prg, entry.err
+}
+
+// outputType retorna o tipo do resultado da expressão (dyn quando não compila).
+func (rm *RuleManager) outputType(expr string) *cel.Type {
+	if entry := rm.entry(expr); entry.err == nil && entry.out != nil {
+		return entry.out
 	}
-	prg, err := rm.env.Program(ast)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao gerar programa CEL: %w", err)
+	return cel.DynType
+}
+
+func (rm *RuleManager) entry(expr string) programEntry {
+	rm.mu.RLock()
+	entry, ok := rm.programs[expr]
+	rm.mu.RUnlock()
+	if ok {
+		return entry
 	}
-	return prg, nil
+
+	entry = rm.compileEntry(expr)
+
+	rm.mu.Lock()
+	rm.programs[expr] = entry
+	rm.mu.Unlock()
+
+	return entry
 }
 
 // compile é um helper interno para compilar a string em um programa executável.
 func (rm *RuleManager) compile(expr string) (cel.Program, error) {
+	entry := rm.compileEntry(expr)
+	return entry.prg, entry.err
+}
+
+func (rm *RuleManager) compileEntry(expr string) programEntry {
 	ast, issues := rm.env.Compile(expr)
 	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("erro de compilação CEL: %w", issues.Err())
+		return programEntry{err: fmt.Errorf("erro de compilação CEL '%s': %w", expr, issues.Err())}
 	}
 
 	prg, err := rm.env.Program(ast)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao gerar programa CEL: %w", err)
+		return programEntry{err: fmt.Errorf("erro ao gerar programa CEL: %w", err)}
 	}
-	return prg, nil
+	return programEntry{prg: prg, out: ast.OutputType()}
 }
@@ -39,3 +39,86 @@ func TestEvaluateValue(t *testing.T) {
 		t.Errorf("Esperado 200, recebido %v", res)
 	}
 }
+
+func TestProgramCache(t *testing.T) {
+	rm, _ := NewRuleManager()
+	data := map[string]interface{}{
+		"input": map[string]interface{}{"val": 10},
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := rm.EvaluateValue("input.val + 1", data); err != nil {
+			t.Fatalf("Erro ao avaliar: %v", err)
+		}
+		if _, err := rm.EvaluateBool("input.val > 1", data); err != nil {
+			t.Fatalf("Erro ao avaliar: %v", err)
+		}
+	}
+
+	if n := rm.CachedPrograms(); n != 2 {
+		t.Errorf("Esperado 2 programas em cache, encontrados %d", n)
+	}
+
+	// Falhas de compilação também ficam em cache
+	for i := 0; i < 2; i++ {
+		if _, err := rm.EvaluateValue("input.val +", data); err == nil {
+			t.Error("Esperado erro de compilação")
+		}
+	}
+	if n := rm.CachedPrograms(); n != 3 {
+		t.Errorf("Esperado 3 programas em cache, encontrados %d", n)
+	}
+}
+
+func BenchmarkEvaluateBool_Cached(b *testing.B) {
+	rm, _ := NewRuleManager()
+	data := map[string]interface{}{
+		"input": map[string]interface{}{"age": 20, "type": "admin"},
+	}
+	expr := "input.age >= 18 && input.type == 'admin'"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rm.EvaluateBool(expr, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateBool_Uncached(b *testing.B) {
+	rm, _ := NewRuleManager()
+	data := map[string]interface{}{
+		"input": map[string]interface{}{"age": 20, "type": "admin"},
+	}
+	expr := "input.age >= 18 && input.type == 'admin'"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		prg, err := rm.compile(expr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := prg.Eval(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateValue_Parallel(b *testing.B) {
+	rm, _ := NewRuleManager()
+	data := map[string]interface{}{
+		"input": map[string]interface{}{"val": 100.0},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := rm.EvaluateValue("input.val * 0.1", data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+var interpolationRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Precompile aquece o cache de programas com todas as expressões da configuração.
+// Expressões obrigatoriamente CEL (validações, transformações, métricas e interpolações
+// "${...}") que não compilam são reportadas como erro. Parâmetros de fontes GraphQL,
+// que podem ser literais, são apenas pré-compilados sem falhar o boot.
+// Corpo e headers de output são compilados pelo próprio ResponseBuilder.
+func (rm *RuleManager) Precompile(cfg *config.ServiceConfig) error {
+	errs := rm.precompileSteps("", cfg.Steps)
+	for _, op := range cfg.Operations {
+		errs = append(errs, rm.precompileSteps("operation["+op.ID+"].", op.Steps)...)
+	}
+
+	strict := func(where, expr string) {
+		if expr == "" {
+			return
+		}
+		if _, err := rm.program(expr); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+		}
+	}
+
+	for _, mw := range cfg.Middlewares {
+		switch mw.Type {
+		case "enrichment":
+			walkStrings(mw.Config["sources"], func(s string) {
+				for _, expr := range Interpolations(s) {
+					strict("middleware["+mw.ID+"]", expr)
+				}
+			})
+		case "rate_limit":
+			if key, ok := mw.Config["key"].(string); ok {
+				strict("middleware["+mw.ID+"].key", key)
+				errs = append(errs, rm.checkRateLimitKey("middleware["+mw.ID+"].key", key)...)
+			}
+		}
+	}
+
+	if cfg.GraphQL.Enabled {
+		warm := func(fields map[string]config.GQLField) {
+			for _, field := range fields {
+				if field.Source == nil {
+					continue
+				}
+				walkStrings(field.Source.Params, rm.warmLoose)
+				for _, h := range field.Source.Headers {
+					rm.warmLoose(h)
+				}
+			}
+		}
+		for _, typeDef := range cfg.GraphQL.Types {
+			warm(typeDef.Fields)
+		}
+		warm(cfg.GraphQL.Query)
+		warm(cfg.GraphQL.Mutation)
+	}
+
+	return joinPrecompileErrors(errs)
+}
+
+// rateLimitVars são as variáveis disponíveis na chave do rate_limit, avaliada antes da
+// leitura do corpo: o payload só é decodificado para requisições dentro do limite.
+var rateLimitVars = map[string]bool{"header": true, "request": true, "env": true}
+
+func (rm *RuleManager) checkRateLimitKey(where, key string) []string {
+	refs, err := rm.References(key)
+	if err != nil {
+		return nil // Já reportado pela compilação
+	}
+	var errs []string
+	for _, ref := range refs {
+		if !rateLimitVars[ref.Root] {
+			errs = append(errs, fmt.Sprintf("%s: '%s' indisponível; a chave do rate_limit é avaliada antes da leitura do corpo e só pode usar header, request e env", where, ref))
+		}
+	}
+	return errs
+}
+
+// PrecompileSteps compila as expressões de um bloco de steps. Usado pelas operações com
+// schema, cujo ambiente tipado pode rejeitar expressões aceitas pelo ambiente dinâmico.
+func (rm *RuleManager) PrecompileSteps(steps *config.StepsConf) error {
+	return joinPrecompileErrors(rm.precompileSteps("", steps))
+}
+
+func (rm *RuleManager) precompileSteps(prefix string, st *config.StepsConf) []string {
+	if st == nil {
+		return nil
+	}
+	var errs []string
+	strict := func(where, expr string) {
+		if expr == "" {
+			return
+		}
+		if _, err := rm.program(expr); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+		}
+	}
+
+	for _, rule := range st.Input.Validations {
+		strict(prefix+"input.validation["+rule.ID+"]", rule.Expr)
+	}
+	for _, rule := range st.Processing.Validations {
+		strict(prefix+"processing.validation["+rule.ID+"]", rule.Expr)
+	}
+	for _, trans := range st.Processing.Transformations {
+		for _, err := range rm.ValidateTransformation(trans) {
+			errs = append(errs, fmt.Sprintf("%sprocessing.transformation[%s].%v", prefix, trans.Name, err))
+		}
+	}
+	for _, rule := range st.Output.Validations {
+		strict(prefix+"output.validation["+rule.ID+"]", rule.Expr)
+	}
+	for _, metric := range st.Output.Metrics {
+		strict(prefix+"output.metric["+metric.MetricID+"].value", metric.Value)
+		for tag, expr := range metric.Tags {
+			strict(prefix+"output.metric["+metric.MetricID+"].tag["+tag+"]", expr)
+		}
+	}
+	for _, expr := range Interpolations(st.Output.Target.URL) {
+		strict(prefix+"output.target.url", expr)
+	}
+	return errs
+}
+
+func joinPrecompileErrors(errs []string) error {
+	if len(errs) > 0 {
+		return fmt.Errorf("expressões CEL inválidas:\n- %s", strings.Join(errs, "\n- "))
+	}
+	return nil
+}
+
+// Interpolations extrai as expressões contidas em blocos "${...}" de uma string.
+func Interpolations(s string) []string {
+	if !strings.Contains(s, "${") {
+		return nil
+	}
+	var exprs []string
+	for _, match := range interpolationRegex.FindAllStringSubmatch(s, -1) {
+		exprs = append(exprs, match[1])
+	}
+	return exprs
+}
+
+// warmLoose compila interpolações ou a string inteira, ignorando falhas
+// (a string pode ser um literal estático).
+func (rm *RuleManager) warmLoose(s string) {
+	if exprs := Interpolations(s); len(exprs) > 0 {
+		for _, expr := range exprs {
+			_, _ = rm.program(expr)
+		}
+		return
+	}
+	_, _ = rm.program(s)
+}
+
+func walkStrings(v interface{}, fn func(string)) {
+	switch x := v.(type) {
+	case string:
+		fn(x)
+	case map[string]interface{}:
+		for _, val := range x {
+			walkStrings(val, fn)
+		}
+	case map[interface{}]interface{}:
+		for _, val := range x {
+			walkStrings(val, fn)
+		}
+	case map[string]string:
+		for _, val := range x {
+			fn(val)
+		}
+	case []interface{}:
+		for _, val := range x {
+			walkStrings(val, fn)
+		}
+	}
+}
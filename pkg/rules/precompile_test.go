@@ -0,0 +1,65 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+)
+
+func TestPrecompile(t *testing.T) {
+	rm, _ := NewRuleManager()
+
+	cfg := &config.ServiceConfig{
+		Middlewares: []config.MiddlewareConf{
+			{
+				Type: "enrichment",
+				ID:   "enrich",
+				Config: map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{
+							"name": "api",
+							"type": "rest",
+							"params": map[string]interface{}{
+								"url": "http://api/${input.id}",
+							},
+						},
+					},
+				},
+			},
+		},
+		Steps: &config.StepsConf{
+			Input: config.InputStep{
+				Validations: []config.ValidationRule{{ID: "v1", Expr: "input.id > 0"}},
+			},
+			Processing: config.ProcessingStep{
+				Transformations: []config.TransformationRule{
+					{Name: "t1", Condition: "true", Value: "input.id * 2", Target: "vars.x"},
+				},
+			},
+		},
+	}
+
+	if err := rm.Precompile(cfg); err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	// input.id > 0, true, input.id * 2, input.id
+	if n := rm.CachedPrograms(); n != 4 {
+		t.Errorf("Esperado 4 programas em cache, encontrados %d", n)
+	}
+
+	cfg.Steps.Input.Validations[0].Expr = "input.id >"
+	if err := rm.Precompile(cfg); err == nil {
+		t.Error("Esperado erro para expressão inválida")
+	}
+}
+
+func TestInterpolations(t *testing.T) {
+	exprs := Interpolations("http://api/${input.id}/x/${vars.y}")
+	if len(exprs) != 2 || exprs[0] != "input.id" || exprs[1] != "vars.y" {
+		t.Errorf("Interpolações incorretas: %v", exprs)
+	}
+	if Interpolations("sem interpolacao") != nil {
+		t.Error("Esperado nil para string sem interpolação")
+	}
+}
@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"fmt"
+
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+)
+
+// Reference é o acesso a uma variável do contexto CEL, como vars.tier ou
+// detection.bureau.score.
+type Reference struct {
+	Root string   // Variável do contexto (input, vars, detection, auth...)
+	Path []string // Campos acessados a partir da raiz, até o primeiro acesso dinâmico
+	Test bool     // Acesso apenas verificado com has()
+}
+
+func (r Reference) String() string {
+	s := r.Root
+	for _, key := range r.Path {
+		s += "." + key
+	}
+	return s
+}
+
+// References analisa a expressão (sem checagem de tipos) e retorna os acessos às
+// variáveis do contexto. Variáveis de comprehensions (x em list.exists(x, ...)) e
+// aliases de coleção não fazem parte do contexto e são ignorados. Um acesso com
+// índice dinâmico (vars[key]) é reportado como uso da raiz inteira.
+func (rm *RuleManager) References(expr string) ([]Reference, error) {
+	parsed, issues := rm.env.Parse(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("erro de sintaxe CEL '%s': %w", expr, issues.Err())
+	}
+	var refs []Reference
+	collectReferences(parsed.NativeRep().Expr(), nil, &refs)
+	return refs, nil
+}
+
+func collectReferences(e celast.Expr, shadowed map[string]bool, refs *[]Reference) {
+	if root, path, test, ok := selectionChain(e); ok {
+		if isContextVar(root) && !shadowed[root] {
+			*refs = append(*refs, Reference{Root: root, Path: path, Test: test})
+		}
+		return
+	}
+
+	switch e.Kind() {
+	case celast.SelectKind:
+		collectReferences(e.AsSelect().Operand(), shadowed, refs)
+	case celast.CallKind:
+		call := e.AsCall()
+		if call.IsMemberFunction() {
+			collectReferences(call.Target(), shadowed, refs)
+		}
+		for _, arg := range call.Args() {
+			collectReferences(arg, shadowed, refs)
+		}
+	case celast.ListKind:
+		for _, el := range e.AsList().Elements() {
+			collectReferences(el, shadowed, refs)
+		}
+	case celast.MapKind:
+		for _, entry := range e.AsMap().Entries() {
+			collectReferences(entry.AsMapEntry().Key(), shadowed, refs)
+			collectReferences(entry.AsMapEntry().Value(), shadowed, refs)
+		}
+	case celast.StructKind:
+		for _, field := range e.AsStruct().Fields() {
+			collectReferences(field.AsStructField().Value(), shadowed, refs)
+		}
+	case celast.ComprehensionKind:
+		comp := e.AsComprehension()
+		collectReferences(comp.IterRange(), shadowed, refs)
+		collectReferences(comp.AccuInit(), shadowed, refs)
+
+		inner := make(map[string]bool, len(shadowed)+3)
+		for name := range shadowed {
+			inner[name] = true
+		}
+		inner[comp.IterVar()] = true
+		inner[comp.AccuVar()] = true
+		if comp.HasIterVar2() {
+			inner[comp.IterVar2()] = true
+		}
+		collectReferences(comp.LoopCondition(), inner, refs)
+		collectReferences(comp.LoopStep(), inner, refs)
+		collectReferences(comp.Result(), inner, refs)
+	}
+}
+
+// selectionChain percorre seleções (a.b) e índices com texto literal (a['b']) até um
+// identificador. Falha quando a cadeia tem outro tipo de operando ou índice dinâmico.
+func selectionChain(e celast.Expr) (root string, path []string, test bool, ok bool) {
+	var reversed []string
+	for {
+		switch e.Kind() {
+		case celast.SelectKind:
+			sel := e.AsSelect()
+			reversed = append(reversed, sel.FieldName())
+			test = test || sel.IsTestOnly()
+			e = sel.Operand()
+		case celast.CallKind:
+			call := e.AsCall()
+			if call.FunctionName() != operators.Index || len(call.Args()) != 2 {
+				return "", nil, false, false
+			}
+			key := call.Args()[1]
+			if key.Kind() != celast.LiteralKind {
+				return "", nil, false, false
+			}
+			text, isText := key.AsLiteral().Value().(string)
+			if !isText {
+				return "", nil, false, false
+			}
+			reversed = append(reversed, text)
+			e = call.Args()[0]
+		case celast.IdentKind:
+			path = make([]string, len(reversed))
+			for i, key := range reversed {
+				path[len(reversed)-1-i] = key
+			}
+			return e.AsIdent(), path, test, true
+		default:
+			return "", nil, false, false
+		}
+	}
+}
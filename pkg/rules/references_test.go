@@ -0,0 +1,48 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferences(t *testing.T) {
+	rm, _ := NewRuleManager()
+
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"vars.tier == 'gold' && input.amount > 0", []string{"vars.tier", "input.amount"}},
+		{"detection.bureau.score >= 700", []string{"detection.bureau.score"}},
+		{"auth['sts'].token", []string{"auth.sts.token"}},
+		{"vars.items[0].qty", []string{"vars.items"}},                       // Índice numérico interrompe o caminho
+		{"vars[input.key]", []string{"vars", "input.key"}},                  // Índice dinâmico usa a raiz inteira
+		{"size(detection) > 0", []string{"detection"}},                      // Raiz inteira
+		{"input.items.exists(vars, vars.qty > 0)", []string{"input.items"}}, // Variável da comprehension
+		{"input.items.map(i, i.price * vars.rate)", []string{"input.items", "vars.rate"}},
+		{"{'tier': vars.tier, 'list': [detection.a]}", []string{"vars.tier", "detection.a"}},
+		{"item.price * 2", nil}, // Alias de coleção não é contexto
+		{"'texto'", nil},
+	}
+	for _, c := range cases {
+		refs, err := rm.References(c.expr)
+		if !assert.NoError(t, err, c.expr) {
+			continue
+		}
+		var got []string
+		for _, ref := range refs {
+			got = append(got, ref.String())
+		}
+		assert.Equal(t, c.want, got, c.expr)
+	}
+
+	refs, _ := rm.References("has(vars.bonus) ? vars.bonus : 0")
+	if assert.Len(t, refs, 2) {
+		assert.True(t, refs[0].Test)
+		assert.False(t, refs[1].Test)
+	}
+
+	_, err := rm.References("vars.")
+	assert.ErrorContains(t, err, "erro de sintaxe CEL")
+}
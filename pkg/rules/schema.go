@@ -0,0 +1,502 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// Tipos aceitos nos schemas.
+const (
+	SchemaDyn    = "dyn"
+	SchemaString = "string"
+	SchemaInt    = "int"
+	SchemaDouble = "double"
+	SchemaBool   = "bool"
+	SchemaList   = "list"
+	SchemaMap    = "map"
+	SchemaObject = "object"
+)
+
+// schemaTypePrefix nomeia os tipos de objeto registrados no ambiente CEL (ex: "toolkit.input.customer").
+const schemaTypePrefix = "toolkit."
+
+// Schema descreve o formato de uma variável do contexto CEL (input, detection...).
+// Objetos viram tipos com campos conhecidos, de forma que a compilação acusa campos
+// inexistentes (input.ammount) e operações entre tipos incompatíveis.
+type Schema struct {
+	Kind   string
+	Fields map[string]*Schema // object: campos declarados
+	Elem   *Schema            // list e map: tipo dos elementos
+}
+
+// ObjectSchema cria um objeto com os campos informados (campos nil são dinâmicos).
+func ObjectSchema(fields map[string]*Schema) *Schema {
+	return &Schema{Kind: SchemaObject, Fields: fields}
+}
+
+// Valores do marcador 'format' na raiz do schema, que declara o formato explicitamente.
+const (
+	FormatJSONSchema = "jsonschema"
+	FormatCompact    = "compact"
+)
+
+// jsonSchemaKeywords são as palavras-chave do JSON Schema, usadas apenas para sugerir o
+// marcador quando um schema sem marcador não é um formato compacto válido.
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "properties": true, "items": true, "required": true, "additionalProperties": true,
+	"description": true, "title": true, "format": true, "enum": true, "const": true, "default": true,
+	"examples": true, "minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"multipleOf": true, "minLength": true, "maxLength": true, "pattern": true, "minItems": true,
+	"maxItems": true, "uniqueItems": true, "minProperties": true, "maxProperties": true, "oneOf": true,
+	"anyOf": true, "allOf": true, "not": true, "nullable": true, "$schema": true, "$id": true,
+	"$ref": true, "$defs": true, "definitions": true,
+}
+
+// ParseSchema interpreta um schema declarado no YAML. O formato é declarado na raiz:
+// JSON Schema com $schema ou format: jsonschema; sem marcador (ou com format: compact),
+// o formato compacto, um mapa de campo → tipo ("string", "int", "double", "bool", "dyn",
+// "list<T>", "map<T>", [T] ou um mapa aninhado para objetos).
+func ParseSchema(raw interface{}) (*Schema, error) {
+	if m, ok := AsJSONSchema(raw); ok {
+		return parseJSONSchema(m, "")
+	}
+	body := unmarkedSchema(sanitizeSchema(raw))
+	s, err := parseCompact(body, "")
+	if err != nil {
+		if m, ok := body.(map[string]interface{}); ok && looksLikeJSONSchema(m) {
+			return nil, fmt.Errorf("%w (para JSON Schema, declare $schema ou format: %s na raiz)", err, FormatJSONSchema)
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// AsJSONSchema indica se o schema declarado é um JSON Schema (e não o formato compacto)
+// e, nesse caso, o retorna sem o marcador 'format'.
+func AsJSONSchema(raw interface{}) (map[string]interface{}, bool) {
+	m, ok := sanitizeSchema(raw).(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if format, _ := m["format"].(string); format == FormatJSONSchema {
+		return unmarkedSchema(m).(map[string]interface{}), true
+	}
+	if _, ok := m["$schema"]; ok && m["format"] != FormatCompact {
+		return m, true
+	}
+	return nil, false
+}
+
+// unmarkedSchema remove da raiz o marcador 'format: jsonschema|compact'. Outros valores
+// de 'format' são mantidos (no formato compacto, um campo chamado format).
+func unmarkedSchema(raw interface{}) interface{} {
+	m, ok := raw.(map[string]interface{})
+	if !ok || (m["format"] != FormatJSONSchema && m["format"] != FormatCompact) {
+		return raw
+	}
+	body := make(map[string]interface{}, len(m)-1)
+	for k, v := range m {
+		if k != "format" {
+			body[k] = v
+		}
+	}
+	return body
+}
+
+// looksLikeJSONSchema indica se o mapa contém apenas palavras-chave do JSON Schema e
+// declara type ou properties.
+func looksLikeJSONSchema(m map[string]interface{}) bool {
+	for key := range m {
+		if !jsonSchemaKeywords[key] {
+			return false
+		}
+	}
+	return m["type"] != nil || m["properties"] != nil
+}
+
+func parseCompact(raw interface{}, at string) (*Schema, error) {
+	switch x := raw.(type) {
+	case nil:
+		return &Schema{Kind: SchemaDyn}, nil
+	case string:
+		s, err := parseTypeName(x)
+		if err != nil {
+			return nil, schemaError(at, err)
+		}
+		return s, nil
+	case []interface{}:
+		if len(x) != 1 {
+			return nil, schemaError(at, fmt.Errorf("listas devem declarar um único tipo de elemento"))
+		}
+		elem, err := parseCompact(x[0], at+"[]")
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Kind: SchemaList, Elem: elem}, nil
+	case map[string]interface{}:
+		fields := make(map[string]*Schema, len(x))
+		for name, val := range x {
+			field, err := parseCompact(val, at+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = field
+		}
+		return ObjectSchema(fields), nil
+	default:
+		return nil, schemaError(at, fmt.Errorf("tipo inválido: %v", raw))
+	}
+}
+
+func parseTypeName(name string) (*Schema, error) {
+	name = strings.TrimSpace(name)
+	switch name {
+	case "string":
+		return &Schema{Kind: SchemaString}, nil
+	case "int", "integer":
+		return &Schema{Kind: SchemaInt}, nil
+	case "double", "number", "float":
+		return &Schema{Kind: SchemaDouble}, nil
+	case "bool", "boolean":
+		return &Schema{Kind: SchemaBool}, nil
+	case "dyn", "any":
+		return &Schema{Kind: SchemaDyn}, nil
+	case "list", "array":
+		return &Schema{Kind: SchemaList, Elem: &Schema{Kind: SchemaDyn}}, nil
+	case "map", "object":
+		return &Schema{Kind: SchemaMap, Elem: &Schema{Kind: SchemaDyn}}, nil
+	}
+
+	for _, kind := range []string{SchemaList, SchemaMap} {
+		if strings.HasPrefix(name, kind+"<") && strings.HasSuffix(name, ">") {
+			elem, err := parseTypeName(name[len(kind)+1 : len(name)-1])
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{Kind: kind, Elem: elem}, nil
+		}
+	}
+	if strings.HasPrefix(name, "[]") {
+		elem, err := parseTypeName(name[2:])
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Kind: SchemaList, Elem: elem}, nil
+	}
+	return nil, fmt.Errorf("tipo desconhecido: '%s'", name)
+}
+
+func parseJSONSchema(m map[string]interface{}, at string) (*Schema, error) {
+	typ, _ := m["type"].(string)
+	if list, ok := m["type"].([]interface{}); ok {
+		// ["string", "null"] equivale a string; uniões de tipos diferentes ficam dinâmicas
+		var kinds []string
+		for _, t := range list {
+			if s, _ := t.(string); s != "null" {
+				kinds = append(kinds, fmt.Sprintf("%v", t))
+			}
+		}
+		if len(kinds) != 1 {
+			return &Schema{Kind: SchemaDyn}, nil
+		}
+		typ = kinds[0]
+	}
+	if typ == "" {
+		switch {
+		case m["properties"] != nil:
+			typ = "object"
+		case m["items"] != nil:
+			typ = "array"
+		default:
+			return &Schema{Kind: SchemaDyn}, nil
+		}
+	}
+
+	switch typ {
+	case "string":
+		return &Schema{Kind: SchemaString}, nil
+	case "integer":
+		return &Schema{Kind: SchemaInt}, nil
+	case "number":
+		return &Schema{Kind: SchemaDouble}, nil
+	case "boolean":
+		return &Schema{Kind: SchemaBool}, nil
+	case "null":
+		return &Schema{Kind: SchemaDyn}, nil
+	case "array":
+		elem := &Schema{Kind: SchemaDyn}
+		if items, ok := m["items"].(map[string]interface{}); ok {
+			var err error
+			if elem, err = parseJSONSchema(items, at+"[]"); err != nil {
+				return nil, err
+			}
+		}
+		return &Schema{Kind: SchemaList, Elem: elem}, nil
+	case "object":
+		props, ok := m["properties"].(map[string]interface{})
+		if !ok {
+			// Sem properties: mapa cujos valores seguem additionalProperties
+			elem := &Schema{Kind: SchemaDyn}
+			if extra, ok := m["additionalProperties"].(map[string]interface{}); ok {
+				var err error
+				if elem, err = parseJSONSchema(extra, at+".*"); err != nil {
+					return nil, err
+				}
+			}
+			return &Schema{Kind: SchemaMap, Elem: elem}, nil
+		}
+		fields := make(map[string]*Schema, len(props))
+		for name, val := range props {
+			prop, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, schemaError(at+"."+name, fmt.Errorf("propriedade deve ser um schema"))
+			}
+			field, err := parseJSONSchema(prop, at+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = field
+		}
+		return ObjectSchema(fields), nil
+	default:
+		return nil, schemaError(at, fmt.Errorf("type desconhecido: '%s'", typ))
+	}
+}
+
+func schemaError(at string, err error) error {
+	if at == "" {
+		return err
+	}
+	return fmt.Errorf("'%s': %w", at, err)
+}
+
+// sanitizeSchema converte os mapas do YAML (map[interface{}]interface{}) em map[string]interface{}.
+func sanitizeSchema(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[fmt.Sprintf("%v", k)] = sanitizeSchema(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[k] = sanitizeSchema(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, val := range x {
+			l[i] = sanitizeSchema(val)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// schemaProvider expõe os objetos dos schemas ao compilador CEL como tipos com campos
+// conhecidos. Em tempo de execução os valores continuam sendo mapas: os campos são lidos
+// do mapa e convertidos para o tipo declarado (ex: 10.0 do JSON → int, "42" do path → int).
+type schemaProvider struct {
+	types.Provider
+	objects map[string]map[string]*Schema
+}
+
+func newSchemaProvider() (*schemaProvider, error) {
+	base, err := types.NewRegistry()
+	if err != nil {
+		return nil, err
+	}
+	return &schemaProvider{
+		Provider: base,
+		objects:  make(map[string]map[string]*Schema),
+	}, nil
+}
+
+// celType registra os objetos do schema (nomeados a partir de 'name') e retorna o tipo CEL.
+func (p *schemaProvider) celType(s *Schema, name string) *types.Type {
+	if s == nil {
+		return types.DynType
+	}
+	switch s.Kind {
+	case SchemaString:
+		return types.StringType
+	case SchemaInt:
+		return types.IntType
+	case SchemaDouble:
+		return types.DoubleType
+	case SchemaBool:
+		return types.BoolType
+	case SchemaList:
+		return types.NewListType(p.celType(s.Elem, name))
+	case SchemaMap:
+		return types.NewMapType(types.StringType, p.celType(s.Elem, name))
+	case SchemaObject:
+		p.objects[name] = s.Fields
+		for field, fs := range s.Fields {
+			p.celType(fs, name+"."+field)
+		}
+		return types.NewObjectType(name)
+	default:
+		return types.DynType
+	}
+}
+
+func (p *schemaProvider) FindStructType(name string) (*types.Type, bool) {
+	if _, ok := p.objects[name]; ok {
+		return types.NewTypeTypeWithParam(types.NewObjectType(name)), true
+	}
+	return p.Provider.FindStructType(name)
+}
+
+func (p *schemaProvider) FindStructFieldNames(name string) ([]string, bool) {
+	fields, ok := p.objects[name]
+	if !ok {
+		return p.Provider.FindStructFieldNames(name)
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+func (p *schemaProvider) FindStructFieldType(name, field string) (*types.FieldType, bool) {
+	fields, ok := p.objects[name]
+	if !ok {
+		return p.Provider.FindStructFieldType(name, field)
+	}
+	fs, ok := fields[field]
+	if !ok {
+		return nil, false
+	}
+	return &types.FieldType{
+		Type: p.celType(fs, name+"."+field),
+		IsSet: func(obj any) bool {
+			_, found := lookupField(obj, field)
+			return found
+		},
+		GetFrom: func(obj any) (any, error) {
+			val, found := lookupField(obj, field)
+			if !found {
+				return nil, fmt.Errorf("no such key: %s", field)
+			}
+			return coerce(val, fs), nil
+		},
+	}, true
+}
+
+func (p *schemaProvider) NewValue(name string, fields map[string]ref.Val) ref.Val {
+	if _, ok := p.objects[name]; ok {
+		return types.NewErr("o tipo '%s' não pode ser instanciado", name)
+	}
+	return p.Provider.NewValue(name, fields)
+}
+
+// lookupField lê o campo de um objeto em tempo de execução (mapa Go ou mapa CEL).
+func lookupField(obj any, field string) (interface{}, bool) {
+	if m, ok := obj.(map[string]interface{}); ok {
+		val, found := m[field]
+		return val, found
+	}
+	mapper, ok := types.DefaultTypeAdapter.NativeToValue(obj).(traits.Mapper)
+	if !ok {
+		return nil, false
+	}
+	val, found := mapper.Find(types.String(field))
+	if !found || types.IsError(val) {
+		return nil, false
+	}
+	return val, true
+}
+
+// coerce converte o valor lido para o tipo declarado, quando a conversão não perde
+// informação. Valores incompatíveis seguem como estão e falham na avaliação.
+func coerce(v interface{}, s *Schema) interface{} {
+	if s == nil {
+		return v
+	}
+	if rv, ok := v.(ref.Val); ok && s.Kind != SchemaObject && s.Kind != SchemaDyn {
+		v = Native(rv)
+	}
+	switch s.Kind {
+	case SchemaInt:
+		switch x := v.(type) {
+		case float64:
+			if x == float64(int64(x)) {
+				return int64(x)
+			}
+		case int:
+			return int64(x)
+		case string:
+			if i, err := strconv.ParseInt(x, 10, 64); err == nil {
+				return i
+			}
+		}
+	case SchemaDouble:
+		switch x := v.(type) {
+		case int:
+			return float64(x)
+		case int64:
+			return float64(x)
+		case string:
+			if f, err := strconv.ParseFloat(x, 64); err == nil {
+				return f
+			}
+		}
+	case SchemaBool:
+		if x, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(x); err == nil {
+				return b
+			}
+		}
+	case SchemaList:
+		if x, ok := v.([]interface{}); ok {
+			out := make([]interface{}, len(x))
+			for i, item := range x {
+				out[i] = coerce(item, s.Elem)
+			}
+			return out
+		}
+	case SchemaMap:
+		if x, ok := v.(map[string]interface{}); ok {
+			out := make(map[string]interface{}, len(x))
+			for k, item := range x {
+				out[k] = coerce(item, s.Elem)
+			}
+			return out
+		}
+	}
+	return v
+}
+
+// NewTypedRuleManager cria um RuleManager em que as variáveis informadas (input,
+// detection...) têm o tipo derivado do schema; as demais continuam dinâmicas.
+func NewTypedRuleManager(schemas map[string]*Schema) (*RuleManager, error) {
+	if len(schemas) == 0 {
+		return NewRuleManager()
+	}
+	provider, err := newSchemaProvider()
+	if err != nil {
+		return nil, fmt.Errorf("erro fatal CEL init: %w", err)
+	}
+
+	typed := make(map[string]*cel.Type, len(schemas))
+	for name, schema := range schemas {
+		if !isContextVar(name) {
+			return nil, fmt.Errorf("schema para variável desconhecida: '%s'", name)
+		}
+		typed[name] = provider.celType(schema, schemaTypePrefix+name)
+	}
+	return newRuleManager(typed, cel.CustomTypeProvider(provider))
+}
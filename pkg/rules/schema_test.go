@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func mustSchema(t *testing.T, src string) *Schema {
+	t.Helper()
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(src), &raw); err != nil {
+		t.Fatalf("YAML inválido: %v", err)
+	}
+	s, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("Erro ao interpretar schema: %v", err)
+	}
+	return s
+}
+
+func TestParseSchema(t *testing.T) {
+	compact := mustSchema(t, `
+amount: double
+installments: int
+tags: list<string>
+scores: [int]
+limits: map<double>
+customer:
+  name: string
+  vip: bool
+`)
+	assert.Equal(t, SchemaObject, compact.Kind)
+	assert.Equal(t, SchemaDouble, compact.Fields["amount"].Kind)
+	assert.Equal(t, SchemaInt, compact.Fields["installments"].Kind)
+	assert.Equal(t, &Schema{Kind: SchemaList, Elem: &Schema{Kind: SchemaString}}, compact.Fields["tags"])
+	assert.Equal(t, &Schema{Kind: SchemaList, Elem: &Schema{Kind: SchemaInt}}, compact.Fields["scores"])
+	assert.Equal(t, &Schema{Kind: SchemaMap, Elem: &Schema{Kind: SchemaDouble}}, compact.Fields["limits"])
+	assert.Equal(t, SchemaBool, compact.Fields["customer"].Fields["vip"].Kind)
+
+	jsonSchema := mustSchema(t, `
+format: jsonschema
+type: object
+required: [amount]
+properties:
+  amount: {type: number, minimum: 0}
+  document: {type: [string, "null"]}
+  items:
+    type: array
+    items:
+      type: object
+      properties:
+        qty: {type: integer}
+  extra: {type: object, additionalProperties: {type: string}}
+  anything: {}
+`)
+	assert.Equal(t, SchemaDouble, jsonSchema.Fields["amount"].Kind)
+	assert.Equal(t, SchemaString, jsonSchema.Fields["document"].Kind)
+	assert.Equal(t, SchemaInt, jsonSchema.Fields["items"].Elem.Fields["qty"].Kind)
+	assert.Equal(t, &Schema{Kind: SchemaMap, Elem: &Schema{Kind: SchemaString}}, jsonSchema.Fields["extra"])
+	assert.Equal(t, SchemaDyn, jsonSchema.Fields["anything"].Kind)
+
+	withSchemaKey := mustSchema(t, "$schema: https://json-schema.org/draft/2020-12/schema\ntype: object\nproperties: {id: {type: integer}}")
+	assert.Equal(t, SchemaInt, withSchemaKey.Fields["id"].Kind)
+
+	// Sem marcador, o schema é compacto mesmo que as chaves coincidam com palavras-chave
+	withType := mustSchema(t, "type: string\nid: int")
+	assert.Equal(t, SchemaObject, withType.Kind)
+	assert.Equal(t, SchemaString, withType.Fields["type"].Kind)
+	keywords := mustSchema(t, "type: string\ndescription: string\nformat: string")
+	assert.Equal(t, ObjectSchema(map[string]*Schema{
+		"type":        {Kind: SchemaString},
+		"description": {Kind: SchemaString},
+		"format":      {Kind: SchemaString},
+	}), keywords)
+	marked := mustSchema(t, "format: compact\ntype: string\nproperties: map<int>")
+	assert.Equal(t, SchemaMap, marked.Fields["properties"].Kind)
+	assert.NotContains(t, marked.Fields, "format")
+
+	for src, want := range map[string]string{
+		"amount: decimal":                                "'.amount': tipo desconhecido: 'decimal'",
+		"tags: [string, int]":                            "'.tags': listas devem declarar",
+		"a: {b: list<money>}":                            "'.a.b': tipo desconhecido: 'money'",
+		"format: jsonschema\nproperties: {a: {type: x}}": "'.a': type desconhecido: 'x'",
+		"type: object\nrequired: [a, b]":                 "declare $schema ou format: jsonschema",
+	} {
+		var raw interface{}
+		assert.NoError(t, yaml.Unmarshal([]byte(src), &raw))
+		_, err := ParseSchema(raw)
+		assert.ErrorContains(t, err, want, src)
+	}
+}
+
+func TestTypedRuleManager(t *testing.T) {
+	input := mustSchema(t, `
+amount: double
+installments: int
+name: string
+tags: list<string>
+customer:
+  tier: string
+  limits: [double]
+`)
+	rm, err := NewTypedRuleManager(map[string]*Schema{
+		"input":     input,
+		"detection": ObjectSchema(map[string]*Schema{"bureau": mustSchema(t, "score: int"), "legacy": nil}),
+	})
+	if err != nil {
+		t.Fatalf("Erro ao criar manager: %v", err)
+	}
+
+	// Erros detectados na compilação
+	invalid := map[string]string{
+		"input.ammount > 10":              "undefined field 'ammount'",
+		"input.name > 5":                  "no matching overload",
+		"input.amount * 2":                "no matching overload",
+		"input.customer.tierr == 'gold'":  "undefined field 'tierr'",
+		"detection.bureu.score > 500":     "undefined field 'bureu'",
+		"detection.bureau.score + 'x'":    "no matching overload",
+		"input.tags.exists(t, t > 1)":     "no matching overload",
+		"has(input.customer.rank)":        "undefined field 'rank'",
+		"input.customer.limits[0] == 'a'": "no matching overload",
+	}
+	for expr, want := range invalid {
+		_, err := rm.CompileProgram(expr)
+		assert.ErrorContains(t, err, want, expr)
+	}
+
+	// Expressões válidas continuam funcionando com os mapas do JSON
+	ctx := map[string]interface{}{
+		"input": map[string]interface{}{
+			"amount":       1500.0,
+			"installments": 12.0, // JSON: números chegam como float64
+			"name":         "Ana",
+			"tags":         []interface{}{"pf", "vip"},
+			"customer":     map[string]interface{}{"tier": "gold", "limits": []interface{}{1000.0, 2500.5}},
+		},
+		"detection": map[string]interface{}{
+			"bureau": map[string]interface{}{"score": "720"}, // Convertido para o tipo declarado
+			"legacy": map[string]interface{}{"anything": true},
+		},
+	}
+	valid := map[string]interface{}{
+		"input.amount * 2.0":                        3000.0,
+		"input.amount / double(input.installments)": 125.0,
+		"input.installments * 2":                    int64(24),
+		"input.customer.tier.upper()":               "GOLD",
+		"input.customer.limits.map(l, l * 2.0)[1]":  5001.0,
+		"'vip' in input.tags":                       true,
+		"detection.bureau.score >= 700":             true,
+		"detection.legacy.anything":                 true,
+		"has(input.customer.tier)":                  true,
+	}
+	for expr, want := range valid {
+		got, err := rm.EvaluateValue(expr, ctx)
+		if assert.NoError(t, err, expr) {
+			assert.Equal(t, want, Native(got), expr)
+		}
+	}
+
+	// Campos declarados, mas ausentes no payload, falham como no mapa dinâmico
+	_, err = rm.EvaluateValue("input.amount > 1.0", map[string]interface{}{"input": map[string]interface{}{}})
+	assert.ErrorContains(t, err, "no such key: amount")
+	ok, err := rm.EvaluateBool("has(input.amount)", map[string]interface{}{"input": map[string]interface{}{}})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// O alias do item recebe o tipo dos elementos de 'items'
+	errs := rm.ValidateTransformation(config.TransformationRule{
+		Name: "limits", Type: TransformMap, Items: "input.customer.limits", As: "limit", Value: "limit + 'x'", Target: "vars.limits",
+	})
+	if assert.Len(t, errs, 1) {
+		assert.ErrorContains(t, errs[0], "no matching overload")
+	}
+	res, err := rm.ExecuteTransformation(config.TransformationRule{
+		Name: "total", Type: TransformReduce, Items: "[input]", Initial: "0", Value: "acc + item.installments", Target: "vars.total",
+	}, ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(12), Native(res.Value))
+	}
+
+	_, err = NewTypedRuleManager(map[string]*Schema{"inputs": input})
+	assert.ErrorContains(t, err, "variável desconhecida: 'inputs'")
+}
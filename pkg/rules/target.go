@@ -0,0 +1,223 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Raízes aceitas no 'target' das transformações.
+const (
+	TargetVars            = "vars"             // Variáveis da requisição
+	TargetDetection       = "detection"        // Sobrescreve dados carregados pelos middlewares
+	TargetResponseHeaders = "response.headers" // Header da resposta (valor convertido para texto)
+)
+
+// PathSegment é um trecho do caminho: uma chave de objeto ou uma posição de lista.
+type PathSegment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+func (s PathSegment) String() string {
+	if s.IsIndex {
+		return fmt.Sprintf("[%d]", s.Index)
+	}
+	return "." + s.Key
+}
+
+// Target é o destino de uma transformação, como "vars.customer.risk.tier" ou "vars.items[0].qty".
+type Target struct {
+	Root string
+	Path []PathSegment
+}
+
+// ParseTarget interpreta o destino de uma transformação. Chaves são separadas por '.',
+// posições de lista usam [n] e chaves com caracteres especiais podem vir entre aspas
+// (vars["a.b"]). Headers aceitam apenas um nome: response.headers.X-Risk-Tier.
+func ParseTarget(target string) (Target, error) {
+	var t Target
+	rest := ""
+	switch {
+	case strings.HasPrefix(target, TargetResponseHeaders+"."):
+		t.Root, rest = TargetResponseHeaders, target[len(TargetResponseHeaders):]
+	case strings.HasPrefix(target, TargetVars+".") || strings.HasPrefix(target, TargetVars+"["):
+		t.Root, rest = TargetVars, target[len(TargetVars):]
+	case strings.HasPrefix(target, TargetDetection+".") || strings.HasPrefix(target, TargetDetection+"["):
+		t.Root, rest = TargetDetection, target[len(TargetDetection):]
+	default:
+		return t, fmt.Errorf("target '%s' deve começar com %s., %s. ou %s.", target, TargetVars, TargetDetection, TargetResponseHeaders)
+	}
+
+	path, err := parsePath(rest)
+	if err != nil {
+		return t, fmt.Errorf("target '%s' inválido: %w", target, err)
+	}
+	t.Path = path
+
+	if t.Root == TargetResponseHeaders && (len(path) != 1 || path[0].IsIndex) {
+		return t, fmt.Errorf("target '%s' inválido: informe apenas o nome do header", target)
+	}
+	return t, nil
+}
+
+func parsePath(s string) ([]PathSegment, error) {
+	var path []PathSegment
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			j := i + 1
+			for j < len(s) && isKeyChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("chave vazia na posição %d", i+1)
+			}
+			path = append(path, PathSegment{Key: s[i+1 : j]})
+			i = j
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("'[' sem ']' na posição %d", i)
+			}
+			inner := s[i+1 : i+end]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				path = append(path, PathSegment{Key: inner[1 : len(inner)-1]})
+			} else {
+				idx, err := strconv.Atoi(inner)
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("índice inválido: [%s]", inner)
+				}
+				path = append(path, PathSegment{Index: idx, IsIndex: true})
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("caractere inesperado '%c' na posição %d", s[i], i)
+		}
+	}
+	return path, nil
+}
+
+func isKeyChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// SetPath grava o valor no caminho informado, criando objetos e listas intermediários.
+// Os contêineres do caminho são copiados (copy-on-write), preservando dados compartilhados
+// como respostas de sources em cache. Objetos gravados sobre objetos são mesclados em
+// profundidade; demais valores substituem o anterior.
+func SetPath(root map[string]interface{}, path []PathSegment, value interface{}) (map[string]interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("caminho vazio")
+	}
+	updated, err := setIn(root, path, Native(value), "")
+	if err != nil {
+		return nil, err
+	}
+	return updated.(map[string]interface{}), nil
+}
+
+func setIn(current interface{}, path []PathSegment, value interface{}, at string) (interface{}, error) {
+	if len(path) == 0 {
+		return deepMerge(current, value), nil
+	}
+	seg, rest := path[0], path[1:]
+	at += seg.String()
+
+	if seg.IsIndex {
+		var list []interface{}
+		switch x := current.(type) {
+		case nil:
+		case []interface{}:
+			list = x
+		default:
+			return nil, fmt.Errorf("'%s' não é uma lista (%T)", strings.TrimSuffix(at, seg.String()), current)
+		}
+		size := len(list)
+		if seg.Index >= size {
+			size = seg.Index + 1
+		}
+		out := make([]interface{}, size)
+		copy(out, list)
+		val, err := setIn(out[seg.Index], rest, value, at)
+		if err != nil {
+			return nil, err
+		}
+		out[seg.Index] = val
+		return out, nil
+	}
+
+	var m map[string]interface{}
+	switch x := current.(type) {
+	case nil:
+	case map[string]interface{}:
+		m = x
+	default:
+		return nil, fmt.Errorf("'%s' não é um objeto (%T)", strings.TrimSuffix(at, seg.String()), current)
+	}
+	out := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	val, err := setIn(out[seg.Key], rest, value, at)
+	if err != nil {
+		return nil, err
+	}
+	out[seg.Key] = val
+	return out, nil
+}
+
+// deepMerge mescla objetos recursivamente (as chaves de 'override' prevalecem).
+func deepMerge(base, override interface{}) interface{} {
+	b, okBase := base.(map[string]interface{})
+	o, okOverride := override.(map[string]interface{})
+	if !okBase || !okOverride {
+		return override
+	}
+	out := make(map[string]interface{}, len(b)+len(o))
+	for k, v := range b {
+		out[k] = v
+	}
+	for k, v := range o {
+		out[k] = deepMerge(b[k], v)
+	}
+	return out
+}
+
+// Native converte listas e mapas produzidos pelo CEL (ref.Val) em tipos Go simples,
+// para que possam ser mesclados, serializados e lidos novamente pelo CEL.
+func Native(v interface{}) interface{} {
+	switch x := v.(type) {
+	case ref.Val:
+		return Native(x.Value())
+	case []ref.Val:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			out[i] = Native(item)
+		}
+		return out
+	case map[ref.Val]ref.Val:
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			out[fmt.Sprintf("%v", k.Value())] = Native(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			out[i] = Native(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			out[k] = Native(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
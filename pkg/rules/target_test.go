@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTarget(t *testing.T) {
+	cases := []struct {
+		target string
+		root   string
+		path   []PathSegment
+	}{
+		{"vars.total", TargetVars, []PathSegment{{Key: "total"}}},
+		{"vars.customer.risk.tier", TargetVars, []PathSegment{{Key: "customer"}, {Key: "risk"}, {Key: "tier"}}},
+		{"vars.items[2].qty", TargetVars, []PathSegment{{Key: "items"}, {Index: 2, IsIndex: true}, {Key: "qty"}}},
+		{`vars["a.b"].c`, TargetVars, []PathSegment{{Key: "a.b"}, {Key: "c"}}},
+		{"detection.bureau.score", TargetDetection, []PathSegment{{Key: "bureau"}, {Key: "score"}}},
+		{"response.headers.X-Risk-Tier", TargetResponseHeaders, []PathSegment{{Key: "X-Risk-Tier"}}},
+	}
+	for _, tc := range cases {
+		target, err := ParseTarget(tc.target)
+		if !assert.NoError(t, err, tc.target) {
+			continue
+		}
+		assert.Equal(t, tc.root, target.Root, tc.target)
+		assert.Equal(t, tc.path, target.Path, tc.target)
+	}
+
+	invalid := map[string]string{
+		"total":                   "deve começar com",
+		"input.amount":            "deve começar com",
+		"vars":                    "deve começar com",
+		"vars.":                   "chave vazia",
+		"vars.a..b":               "chave vazia",
+		"vars.items[x]":           "índice inválido",
+		"vars.items[-1]":          "índice inválido",
+		"vars.items[0":            "sem ']'",
+		"vars.a b":                "caractere inesperado",
+		"response.headers.X.Y":    "apenas o nome do header",
+		"response.headers.X[0]":   "apenas o nome do header",
+		"response.status_code.xx": "deve começar com",
+	}
+	for target, want := range invalid {
+		_, err := ParseTarget(target)
+		assert.ErrorContains(t, err, want, target)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	set := func(root map[string]interface{}, target string, value interface{}) (map[string]interface{}, error) {
+		parsed, err := ParseTarget(target)
+		if err != nil {
+			t.Fatalf("Target inválido '%s': %v", target, err)
+		}
+		return SetPath(root, parsed.Path, value)
+	}
+
+	vars, err := set(nil, "vars.customer.risk.tier", "gold")
+	assert.NoError(t, err)
+	vars, err = set(vars, "vars.customer.name", "Ana")
+	assert.NoError(t, err)
+	vars, err = set(vars, "vars.items[1].qty", int64(2))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"customer": map[string]interface{}{"risk": map[string]interface{}{"tier": "gold"}, "name": "Ana"},
+		"items":    []interface{}{nil, map[string]interface{}{"qty": int64(2)}},
+	}, vars)
+
+	// Objetos são mesclados em profundidade; demais valores substituem
+	vars, err = set(vars, "vars.customer", map[string]interface{}{"risk": map[string]interface{}{"score": 800.0}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"risk": map[string]interface{}{"tier": "gold", "score": 800.0}, "name": "Ana"}, vars["customer"])
+	vars, err = set(vars, "vars.customer.risk", "n/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "n/a", vars["customer"].(map[string]interface{})["risk"])
+
+	// Copy-on-write: o mapa original (ex: resposta de source em cache) não é alterado
+	cached := map[string]interface{}{"score": 500.0, "flags": []interface{}{"a"}}
+	detection := map[string]interface{}{"bureau": cached}
+	updated, err := set(detection, "detection.bureau.flags[0]", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a"}, cached["flags"])
+	assert.Equal(t, []interface{}{"b"}, updated["bureau"].(map[string]interface{})["flags"])
+	assert.Equal(t, 500.0, updated["bureau"].(map[string]interface{})["score"])
+
+	_, err = set(map[string]interface{}{"total": 10.0}, "vars.total.value", 1)
+	assert.ErrorContains(t, err, "'.total' não é um objeto")
+	_, err = set(map[string]interface{}{"items": "x"}, "vars.items[0]", 1)
+	assert.ErrorContains(t, err, "'.items' não é uma lista")
+}
@@ -14,7 +14,8 @@ type TransformationResult struct {
 }
 
 // ExecuteTransformation processa uma regra de transformação completa.
-// Verifica a condição e, se atendida, calcula o valor. Se não, verifica o ElseValue.
+// Verifica a condição e, se atendida, calcula o valor (ou percorre a coleção, nos tipos
+// foreach/map/filter/reduce). Se não, verifica o ElseValue.
 func (rm *RuleManager) ExecuteTransformation(rule config.TransformationRule, ctx map[string]interface{}) (*TransformationResult, error) {
 	// 1. Avaliar a Condição (Deve retornar booleano)
 	conditionMet, err := rm.EvaluateBool(rule.Condition, ctx)
@@ -25,6 +26,13 @@ func (rm *RuleManager) ExecuteTransformation(rule config.TransformationRule, ctx
 	var exprToEvaluate string
 
 	if conditionMet {
+		if rule.Type != "" {
+			val, err := rm.executeCollection(rule, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("falha na transformação '%s': %w", rule.Name, err)
+			}
+			return &TransformationResult{Target: rule.Target, Value: val, Applied: true}, nil
+		}
 		exprToEvaluate = rule.Value
 	} else {
 		// Se a condição falhou e existe um ElseValue, usamos ele
@@ -0,0 +1,116 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formatos de saída do relatório.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Write grava o relatório no formato informado (text, json ou junit).
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "", FormatText:
+		return r.WriteText(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatJUnit:
+		return r.WriteJUnit(w)
+	default:
+		return fmt.Errorf("formato desconhecido: '%s' (use text, json ou junit)", format)
+	}
+}
+
+// WriteText grava um resumo legível, com as divergências de cada caso que falhou.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧪 Suíte: %s\n", r.Suite)
+	for _, res := range r.Results {
+		if res.Passed {
+			fmt.Fprintf(&b, "✅ %s (%.1fms)\n", res.Name, res.DurationMs)
+			continue
+		}
+		fmt.Fprintf(&b, "❌ %s (%.1fms)\n", res.Name, res.DurationMs)
+		for _, f := range res.Failures {
+			fmt.Fprintf(&b, "   - %s\n", f)
+		}
+	}
+	fmt.Fprintf(&b, "%d casos: %d passaram, %d falharam (%.1fms)\n", r.Total, r.Passed, r.Failed, r.DurationMs)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON grava o relatório em JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit grava o relatório no formato JUnit XML, aceito pelos servidores de CI.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitSuite{
+		Name:     r.Suite,
+		Tests:    r.Total,
+		Failures: r.Failed,
+		Time:     seconds(r.DurationMs),
+	}
+	for _, res := range r.Results {
+		c := junitCase{Name: res.Name, Classname: r.Suite, Time: seconds(res.DurationMs)}
+		if !res.Passed {
+			c.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d divergência(s)", len(res.Failures)),
+				Text:    strings.Join(res.Failures, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
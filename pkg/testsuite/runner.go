@@ -0,0 +1,336 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/engine"
+	"github.com/raywall/fast-service-toolkit/pkg/rules"
+)
+
+// Response é a resposta de uma execução em processo.
+type Response struct {
+	Status  int
+	Headers map[string]string
+	Body    []byte
+}
+
+// Result é o resultado de um caso de teste.
+type Result struct {
+	Name       string   `json:"name"`
+	Passed     bool     `json:"passed"`
+	Status     int      `json:"status,omitempty"`
+	Failures   []string `json:"failures,omitempty"`
+	DurationMs float64  `json:"duration_ms"`
+}
+
+// Report consolida a execução de uma suíte.
+type Report struct {
+	Suite      string   `json:"suite"`
+	Total      int      `json:"total"`
+	Passed     int      `json:"passed"`
+	Failed     int      `json:"failed"`
+	DurationMs float64  `json:"duration_ms"`
+	Results    []Result `json:"results"`
+}
+
+// OK indica se todos os casos passaram.
+func (r *Report) OK() bool {
+	return r.Failed == 0
+}
+
+// Run executa os casos em sequência. Cada caso recebe uma engine própria, criada a
+// partir da configuração com os seus stubs e sem acesso à rede.
+func Run(ctx context.Context, cfg *config.ServiceConfig, suite *Suite) (*Report, error) {
+	rm, err := rules.NewRuleManager()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	report := &Report{Suite: suite.Name, Results: []Result{}}
+	for _, c := range suite.Cases {
+		res := runCase(ctx, cfg, c, rm)
+		report.Results = append(report.Results, res)
+		report.Total++
+		if res.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+	report.DurationMs = durationMs(time.Since(start))
+	return report, nil
+}
+
+func runCase(ctx context.Context, cfg *config.ServiceConfig, c Case, rm *rules.RuleManager) (res Result) {
+	start := time.Now()
+	res.Name = c.Name
+	defer func() {
+		res.Passed = len(res.Failures) == 0
+		res.DurationMs = durationMs(time.Since(start))
+	}()
+
+	stubs := Stubs{Sources: c.Sources, Auth: c.Auth, NoNetwork: true}
+	stubbed, err := Apply(cfg, stubs)
+	if err != nil {
+		res.Failures = []string{err.Error()}
+		return res
+	}
+	stubbed.Service.Logging.Enabled = false
+
+	svc, err := engine.NewServiceEngine(stubbed, "testsuite", EngineOptions(stubbed, stubs)...)
+	if err != nil {
+		res.Failures = []string{fmt.Sprintf("falha ao iniciar engine: %v", err)}
+		return res
+	}
+	defer svc.Shutdown(ctx)
+
+	resp, err := Execute(ctx, svc, c.Operation, c.Request)
+	if err != nil {
+		res.Failures = []string{err.Error()}
+		return res
+	}
+	res.Status = resp.Status
+	res.Failures = check(c.Expect, resp, rm)
+	return res
+}
+
+// Execute monta a requisição como o transport HTTP (headers, metadados, path e query
+// params) e a executa na operação informada, na roteada por request.path ou na padrão.
+func Execute(ctx context.Context, svc *engine.ServiceEngine, operation string, req Request) (*Response, error) {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	headers := make(map[string]string, len(req.Headers)+1)
+	for k, v := range req.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	payload, err := requestBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if _, isText := req.Body.(string); req.Body != nil && !isText && headers["Content-Type"] == "" {
+		headers["Content-Type"] = "application/json"
+	}
+
+	info := map[string]interface{}{"client_ip": "127.0.0.1", "method": method, "path": req.Path}
+	ctx = context.WithValue(ctx, "request_headers", headers)
+	ctx = context.WithValue(ctx, "request_info", info)
+
+	params := make(map[string]string)
+	for k, v := range req.Query {
+		params[k] = v
+	}
+
+	opID := operation
+	if opID == "" && req.Path != "" {
+		matched, pathParams, err := svc.MatchOperation(method, req.Path)
+		if err != nil {
+			status, body, respHeaders := svc.ErrorResponse(ctx, engine.RouteStatus(err), engine.StepRouting, err.Error())
+			return &Response{Status: status, Headers: respHeaders, Body: body}, nil
+		}
+		opID = matched
+		for k, v := range pathParams {
+			params[k] = v
+		}
+	}
+	if opID == "" {
+		opID = engine.DefaultOperationID
+	}
+	info["operation"] = opID
+	for k, v := range req.PathParams {
+		params[k] = v
+	}
+	ctx = context.WithValue(ctx, "request_params", params)
+
+	status, body, respHeaders, err := svc.ExecuteOperation(ctx, opID, payload)
+	if err != nil {
+		return nil, fmt.Errorf("falha na execução: %w", err)
+	}
+	return &Response{Status: status, Headers: respHeaders, Body: body}, nil
+}
+
+// ExecuteGraphQL executa uma query no GraphQL mesh como o transport HTTP: os
+// middlewares rodam antes e uma rejeição deles vira a resposta.
+func ExecuteGraphQL(ctx context.Context, svc *engine.ServiceEngine, req Request, query string, variables map[string]interface{}) (*Response, error) {
+	gql := svc.GetGraphQLEngine()
+	if gql == nil {
+		return nil, fmt.Errorf("graphql não está habilitado na configuração")
+	}
+
+	headers := make(map[string]string, len(req.Headers))
+	for k, v := range req.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	ctx = context.WithValue(ctx, "request_headers", headers)
+	ctx = context.WithValue(ctx, "request_info", map[string]interface{}{
+		"client_ip": "127.0.0.1", "method": http.MethodPost, "path": svc.Config.GraphQL.Route,
+	})
+
+	respHeaders := map[string]string{"Content-Type": "application/json"}
+	mwCtx, err := svc.RunMiddlewares(ctx)
+	if err != nil {
+		code, body, headers := svc.MiddlewareErrorResponse(ctx, err)
+		return &Response{Status: code, Headers: headers, Body: body}, nil
+	}
+	if mwHeaders, ok := mwCtx.Value("response_headers").(map[string]string); ok {
+		for k, v := range mwHeaders {
+			respHeaders[k] = v
+		}
+	}
+
+	body, err := json.Marshal(gql.Execute(mwCtx, query, variables))
+	if err != nil {
+		return nil, fmt.Errorf("falha ao serializar resultado: %w", err)
+	}
+	return &Response{Status: http.StatusOK, Headers: respHeaders, Body: body}, nil
+}
+
+func requestBody(body interface{}) ([]byte, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(b), nil
+	default:
+		data, err := json.Marshal(deepCopy(b))
+		if err != nil {
+			return nil, fmt.Errorf("body inválido: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// check compara a resposta com o esperado e retorna as divergências.
+func check(expect Expect, resp *Response, rm *rules.RuleManager) []string {
+	var failures []string
+	if expect.Status != 0 && expect.Status != resp.Status {
+		failures = append(failures, fmt.Sprintf("status: esperado %d, recebido %d", expect.Status, resp.Status))
+	}
+
+	names := make([]string, 0, len(expect.Headers))
+	for name := range expect.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got, _ := lookupHeader(resp.Headers, name)
+		if got != expect.Headers[name] {
+			failures = append(failures, fmt.Sprintf("header '%s': esperado '%s', recebido '%s'", name, expect.Headers[name], got))
+		}
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		body = string(resp.Body)
+	}
+	if expect.Body != nil {
+		failures = append(failures, matchFragment("body", normalize(expect.Body), body)...)
+	}
+
+	if len(expect.Assertions) > 0 {
+		vars := map[string]interface{}{
+			"response": map[string]interface{}{
+				"status":  resp.Status,
+				"headers": resp.Headers,
+				"body":    body,
+			},
+		}
+		for _, expr := range expect.Assertions {
+			ok, err := rm.EvaluateBool(expr, vars)
+			switch {
+			case err != nil:
+				failures = append(failures, fmt.Sprintf("assertion '%s': %v", expr, err))
+			case !ok:
+				failures = append(failures, fmt.Sprintf("assertion falhou: %s", expr))
+			}
+		}
+	}
+	return failures
+}
+
+// matchFragment verifica se 'got' contém 'want': objetos são comparados apenas nos
+// campos esperados; listas e valores simples devem ser iguais.
+func matchFragment(path string, want, got interface{}) []string {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: esperado objeto, recebido %s", path, render(got))}
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var failures []string
+		for _, k := range keys {
+			val, exists := g[k]
+			if !exists {
+				failures = append(failures, fmt.Sprintf("%s.%s: campo ausente", path, k))
+				continue
+			}
+			failures = append(failures, matchFragment(path+"."+k, w[k], val)...)
+		}
+		return failures
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return []string{fmt.Sprintf("%s: esperado %s, recebido %s", path, render(want), render(got))}
+		}
+		var failures []string
+		for i := range w {
+			failures = append(failures, matchFragment(fmt.Sprintf("%s[%d]", path, i), w[i], g[i])...)
+		}
+		return failures
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return []string{fmt.Sprintf("%s: esperado %s, recebido %s", path, render(want), render(got))}
+		}
+		return nil
+	}
+}
+
+// normalize converte o valor esperado (YAML) para a representação do JSON decodificado.
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(deepCopy(v))
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func render(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
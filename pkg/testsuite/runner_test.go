@@ -0,0 +1,290 @@
+package testsuite
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+const serviceYAML = `
+version: "1.0"
+service:
+  name: "customers"
+  runtime: "lambda"
+  route: "/customers"
+  timeout: "1s"
+  on_timeout: { code: 504, msg: "Timeout" }
+  logging: { enabled: false, level: "info", format: "json" }
+middlewares:
+  - type: "auth_provider"
+    id: "sts"
+    config:
+      provider: "oauth2"
+      token_url: "http://127.0.0.1:1/token"
+      client_id: "app"
+      client_secret: "secret"
+      output_var: "token"
+  - type: "enrichment"
+    id: "enrich"
+    config:
+      sources:
+        - name: "profile"
+          type: "rest"
+          params: { url: "http://127.0.0.1:1/profile/${input.id}" }
+steps:
+  output: { status_code: 200, body: { ok: true } }
+operations:
+  - id: "get_customer"
+    method: "GET"
+    route: "/customers/{id}"
+    steps:
+      input:
+        validations:
+          - id: "id"
+            expr: "input.id != '0'"
+            on_fail: { code: 404, msg: "Customer not found" }
+      output:
+        status_code: 200
+        headers: { X-Tier: "${detection.profile.tier}" }
+        body:
+          id: "${input.id}"
+          tier: "${detection.profile.tier}"
+          token: "${auth.sts.token}"
+`
+
+const suiteYAML = `
+name: "customers"
+cases:
+  - name: "cliente gold"
+    request:
+      method: "GET"
+      path: "/customers/42"
+    sources:
+      profile: { value: { tier: "gold" } }
+    auth:
+      sts: "tk-1"
+    expect:
+      status: 200
+      headers: { x-tier: "gold" }
+      body: { id: "42", tier: "gold" }
+      assertions:
+        - "response.body.token == 'tk-1'"
+  - name: "cliente inexistente"
+    operation: "get_customer"
+    request:
+      path_params: { id: "0" }
+    expect:
+      status: 404
+      body: { detail: "Customer not found" }
+  - name: "falha da source"
+    request: { method: "GET", path: "/customers/7" }
+    sources:
+      profile: { error: "timeout" }
+    expect:
+      status: 500
+  - name: "divergente"
+    request: { method: "GET", path: "/customers/42" }
+    sources:
+      profile: { value: { tier: "basic" } }
+    expect:
+      status: 201
+      body: { tier: "gold", extra: 1 }
+      assertions:
+        - "response.status == 200"
+        - "response.body.tier == 'gold'"
+`
+
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Erro escrevendo arquivo temp: %v", err)
+	}
+	return path
+}
+
+func loadService(t *testing.T) *config.ServiceConfig {
+	t.Helper()
+	cfg, err := engine.NewUniversalLoader().Load(context.Background(), writeTemp(t, "svc.yaml", serviceYAML))
+	if err != nil {
+		t.Fatalf("Erro carregando config: %v", err)
+	}
+	return cfg
+}
+
+func TestRun(t *testing.T) {
+	cfg := loadService(t)
+	suite, err := Load(writeTemp(t, "tests.yaml", suiteYAML))
+	if err != nil {
+		t.Fatalf("Erro carregando suíte: %v", err)
+	}
+
+	report, err := Run(context.Background(), cfg, suite)
+	if err != nil {
+		t.Fatalf("Erro executando suíte: %v", err)
+	}
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 3, report.Passed)
+	assert.False(t, report.OK())
+
+	for _, res := range report.Results[:3] {
+		assert.True(t, res.Passed, "%s: %v", res.Name, res.Failures)
+	}
+	failed := report.Results[3]
+	assert.Equal(t, 200, failed.Status)
+	assert.Equal(t, []string{
+		"status: esperado 201, recebido 200",
+		"body.extra: campo ausente",
+		`body.tier: esperado "gold", recebido "basic"`,
+		"assertion falhou: response.body.tier == 'gold'",
+	}, failed.Failures)
+
+	// A configuração original não é alterada pelos stubs
+	src := cfg.Middlewares[1].Config["sources"].([]interface{})[0]
+	assert.Equal(t, "rest", src.(map[interface{}]interface{})["type"])
+}
+
+func TestRun_UnknownStub(t *testing.T) {
+	suite := &Suite{Name: "s", Cases: []Case{{
+		Name:    "stub inválido",
+		Request: Request{Path: "/customers/1"},
+		Sources: map[string]SourceStub{"outra": {Value: 1}},
+	}}}
+
+	report, err := Run(context.Background(), loadService(t), suite)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stub para source inexistente: 'outra'"}, report.Results[0].Failures)
+}
+
+func TestEngineOptions_Auth(t *testing.T) {
+	ctx := context.Background()
+	cfg := loadService(t)
+
+	// O token do stub chega à engine sem passar pela configuração
+	stubs := Stubs{Auth: map[string]string{"sts": "tk-2"}}
+	stubbed, err := Apply(cfg, stubs)
+	if err != nil {
+		t.Fatalf("Erro aplicando stubs: %v", err)
+	}
+	assert.NotContains(t, stubbed.Middlewares[0].Config, "token")
+	svc, err := engine.NewServiceEngine(stubbed, "testsuite", EngineOptions(stubbed, stubs)...)
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	defer svc.Shutdown(ctx)
+	token, err := svc.AuthManagers["sts"].Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "tk-2", token)
+
+	// Com NoNetwork, auth_provider sem stub usa DefaultAuthToken; sem ele, nenhuma opção
+	assert.Len(t, EngineOptions(cfg, Stubs{NoNetwork: true}), 1)
+	assert.Empty(t, EngineOptions(cfg, Stubs{}))
+
+	// Um 'token' declarado no YAML não dispensa o token_url
+	cfg.Middlewares[0].Config["token"] = "hard-coded"
+	_, err = engine.NewServiceEngine(cfg, "testsuite")
+	assert.ErrorContains(t, err, "erro fatal iniciando auth 'sts'")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]string{
+		"cases: []":              "suíte sem casos de teste",
+		"cases: [{request: {}}]": "caso #1 sem 'name'",
+		"cases: [{name: a, sources: {s: {value: 1, error: x}}}]": "declara 'value' e 'error'",
+	}
+	for content, want := range cases {
+		_, err := Load(writeTemp(t, "tests.yaml", content))
+		assert.ErrorContains(t, err, want, content)
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := &Report{Suite: "customers", Total: 2, Passed: 1, Failed: 1, Results: []Result{
+		{Name: "ok", Passed: true, Status: 200},
+		{Name: "falha", Status: 500, Failures: []string{"status: esperado 200, recebido 500"}},
+	}}
+
+	var junit bytes.Buffer
+	assert.NoError(t, report.Write(&junit, FormatJUnit))
+	assert.Contains(t, junit.String(), `<testsuite name="customers" tests="2" failures="1"`)
+	assert.Contains(t, junit.String(), `<failure message="1 divergência(s)">status: esperado 200, recebido 500</failure>`)
+
+	var js bytes.Buffer
+	assert.NoError(t, report.Write(&js, FormatJSON))
+	assert.Contains(t, js.String(), `"failed": 1`)
+
+	var text bytes.Buffer
+	assert.NoError(t, report.Write(&text, FormatText))
+	assert.True(t, strings.HasSuffix(text.String(), "2 casos: 1 passaram, 1 falharam (0.0ms)\n"))
+
+	assert.ErrorContains(t, report.Write(&text, "xml"), "formato desconhecido")
+}
+
+func TestExecuteGraphQL_Stubs(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "mesh", Timeout: "1s"},
+		GraphQL: config.GraphQLConf{
+			Enabled: true,
+			Route:   "/graphql",
+			Types: map[string]config.GQLType{
+				"User": {Fields: map[string]config.GQLField{
+					"name": {Type: "String"},
+					"plan": {Type: "String", Source: &config.EnrichmentSourceConfig{
+						Type: "rest", Params: map[string]interface{}{"url": "http://127.0.0.1:1/plan"},
+					}},
+				}},
+			},
+			Query: map[string]config.GQLField{
+				"user": {Type: "User", Args: map[string]string{"id": "ID"}, Source: &config.EnrichmentSourceConfig{
+					Type: "rest", Params: map[string]interface{}{"url": "http://127.0.0.1:1/users/${args.id}"},
+				}},
+			},
+		},
+	}
+
+	stubbed, err := Apply(cfg, Stubs{Sources: map[string]SourceStub{
+		"Query.user": {Value: map[string]interface{}{"name": "Ana"}},
+		"User.plan":  {Value: "gold"},
+	}})
+	if err != nil {
+		t.Fatalf("Erro aplicando stubs: %v", err)
+	}
+	assert.Equal(t, "rest", cfg.GraphQL.Query["user"].Source.Type)
+
+	svc, err := engine.NewServiceEngine(stubbed, "testsuite")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	resp, err := ExecuteGraphQL(context.Background(), svc, Request{}, `{ user(id: "1") { name plan } }`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.Status)
+	assert.JSONEq(t, `{"data": {"user": {"name": "Ana", "plan": "gold"}}}`, string(resp.Body))
+
+	_, err = Apply(cfg, Stubs{Sources: map[string]SourceStub{"Query.users": {Value: 1}}})
+	assert.ErrorContains(t, err, "stub para source inexistente: 'Query.users'")
+
+	// Sem rede, o mesh continua ativo e os campos sem stub falham sem chamada externa
+	partial := Stubs{Sources: map[string]SourceStub{"Query.user": {Value: map[string]interface{}{"name": "Ana"}}}}
+	assert.Equal(t, []string{"campo GraphQL 'User.plan' (rest)"}, Unstubbed(cfg, partial))
+	partial.NoNetwork = true
+	assert.Empty(t, Unstubbed(cfg, partial))
+	offline, err := Apply(cfg, partial)
+	if err != nil {
+		t.Fatalf("Erro aplicando stubs: %v", err)
+	}
+	assert.Equal(t, "fixed", offline.GraphQL.Types["User"].Fields["plan"].Source.Type)
+	svc, err = engine.NewServiceEngine(offline, "testsuite")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	resp, err = ExecuteGraphQL(context.Background(), svc, Request{}, `{ user(id: "1") { name plan } }`, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp.Body), "sem stub (execução sem rede)")
+}
@@ -0,0 +1,249 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/engine"
+)
+
+// DefaultAuthToken é o token dos middlewares auth_provider sem stub quando NoNetwork está ativo.
+const DefaultAuthToken = "test-token"
+
+// Stubs são as substituições aplicadas a uma configuração antes de criar a engine.
+type Stubs struct {
+	// Nome da source de enrichment (ou campo GraphQL, ex: "Query.customer") → resultado simulado
+	Sources map[string]SourceStub
+	Auth    map[string]string // ID do middleware auth_provider → token
+	// NoNetwork impede qualquer chamada externa: sources sem stub falham (exceto as
+	// do tipo fixed), inclusive as dos resolvers GraphQL, auth_provider sem stub usa
+	// DefaultAuthToken e o interceptor (output.target) fica desativado.
+	NoNetwork bool
+}
+
+// Apply retorna uma cópia da configuração com os stubs aplicados. As sources passam a
+// ser do tipo fixed e métricas são desativadas. Os tokens dos auth_provider não fazem
+// parte da configuração: são injetados na engine por EngineOptions. A configuração
+// original não é alterada.
+func Apply(cfg *config.ServiceConfig, stubs Stubs) (*config.ServiceConfig, error) {
+	out := *cfg
+	out.Service.Metrics.Datadog.Enabled = false
+
+	used := make(map[string]bool)
+	out.Middlewares = make([]config.MiddlewareConf, len(cfg.Middlewares))
+	for i, mw := range cfg.Middlewares {
+		mw.Config, _ = deepCopy(mw.Config).(map[string]interface{})
+		switch mw.Type {
+		case "enrichment":
+			sources, _ := mw.Config["sources"].([]interface{})
+			for _, raw := range sources {
+				src, ok := raw.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name := fmt.Sprintf("%v", src["name"])
+				if stub, ok := stubs.Sources[name]; ok {
+					src["type"] = "fixed"
+					src["params"] = stubParams(stub)
+					used[name] = true
+				} else if stubs.NoNetwork && src["type"] != "fixed" {
+					src["type"] = "fixed"
+					src["params"] = map[string]interface{}{"error": fmt.Sprintf("source '%s' sem stub (execução sem rede)", name)}
+				}
+			}
+		case "auth_provider":
+			if _, ok := stubs.Auth[mw.ID]; ok {
+				used["auth:"+mw.ID] = true
+			}
+		}
+		out.Middlewares[i] = mw
+	}
+
+	if cfg.GraphQL.Enabled {
+		out.GraphQL = stubGraphQL(cfg.GraphQL, stubs, used)
+	}
+
+	for name := range stubs.Sources {
+		if !used[name] {
+			return nil, fmt.Errorf("stub para source inexistente: '%s'", name)
+		}
+	}
+	for id := range stubs.Auth {
+		if !used["auth:"+id] {
+			return nil, fmt.Errorf("stub para auth_provider inexistente: '%s'", id)
+		}
+	}
+
+	if stubs.NoNetwork {
+		out.Steps = withoutTarget(cfg.Steps)
+		out.Operations = make([]config.OperationConf, len(cfg.Operations))
+		for i, op := range cfg.Operations {
+			op.Steps = withoutTarget(op.Steps)
+			out.Operations[i] = op
+		}
+	}
+	return &out, nil
+}
+
+// EngineOptions retorna as opções da engine que substituem o token_url dos auth_provider
+// por um token fixo: o do stub ou, com NoNetwork, DefaultAuthToken.
+func EngineOptions(cfg *config.ServiceConfig, stubs Stubs) []engine.Option {
+	var opts []engine.Option
+	for _, mw := range cfg.Middlewares {
+		if mw.Type != "auth_provider" {
+			continue
+		}
+		token, ok := stubs.Auth[mw.ID]
+		if !ok && !stubs.NoNetwork {
+			continue
+		}
+		if !ok {
+			token = DefaultAuthToken
+		}
+		opts = append(opts, engine.WithTokenFetcher(mw.ID, func(context.Context) (string, time.Duration, error) {
+			return token, 0, nil
+		}))
+	}
+	return opts
+}
+
+// stubGraphQL copia a configuração GraphQL substituindo as sources dos campos com stub.
+// Os campos são identificados por "Tipo.campo" (Query, Mutation ou um dos types).
+func stubGraphQL(gql config.GraphQLConf, stubs Stubs, used map[string]bool) config.GraphQLConf {
+	stubFields := func(typeName string, fields map[string]config.GQLField) map[string]config.GQLField {
+		copied := make(map[string]config.GQLField, len(fields))
+		for name, field := range fields {
+			key := typeName + "." + name
+			if stub, ok := stubs.Sources[key]; ok && field.Source != nil {
+				field.Source = &config.EnrichmentSourceConfig{Type: "fixed", Params: stubParams(stub)}
+				used[key] = true
+			} else if stubs.NoNetwork && field.Source != nil && field.Source.Type != "fixed" {
+				field.Source = &config.EnrichmentSourceConfig{Type: "fixed", Params: map[string]interface{}{
+					"error": fmt.Sprintf("campo '%s' sem stub (execução sem rede)", key),
+				}}
+			}
+			copied[name] = field
+		}
+		return copied
+	}
+
+	out := gql
+	out.Query = stubFields("Query", gql.Query)
+	out.Mutation = stubFields("Mutation", gql.Mutation)
+	out.Types = make(map[string]config.GQLType, len(gql.Types))
+	for name, t := range gql.Types {
+		t.Fields = stubFields(name, t.Fields)
+		out.Types[name] = t
+	}
+	return out
+}
+
+// Unstubbed lista as chamadas externas que a configuração fará com os stubs informados:
+// sources de enrichment e de resolvers GraphQL sem stub, auth_provider sem token e
+// interceptors. Com NoNetwork, nenhuma.
+func Unstubbed(cfg *config.ServiceConfig, stubs Stubs) []string {
+	if stubs.NoNetwork {
+		return nil
+	}
+	var calls []string
+	for _, mw := range cfg.Middlewares {
+		switch mw.Type {
+		case "enrichment":
+			sources, _ := deepCopy(mw.Config["sources"]).([]interface{})
+			for _, raw := range sources {
+				src, _ := raw.(map[string]interface{})
+				name := fmt.Sprintf("%v", src["name"])
+				if _, ok := stubs.Sources[name]; !ok && src["type"] != "fixed" {
+					calls = append(calls, fmt.Sprintf("source '%s' (%v)", name, src["type"]))
+				}
+			}
+		case "auth_provider":
+			if _, ok := stubs.Auth[mw.ID]; !ok {
+				calls = append(calls, fmt.Sprintf("auth_provider '%s'", mw.ID))
+			}
+		}
+	}
+
+	if cfg.GraphQL.Enabled {
+		check := func(typeName string, fields map[string]config.GQLField) {
+			for _, name := range sortedKeys(fields) {
+				key := typeName + "." + name
+				if src := fields[name].Source; src != nil && src.Type != "fixed" {
+					if _, ok := stubs.Sources[key]; !ok {
+						calls = append(calls, fmt.Sprintf("campo GraphQL '%s' (%s)", key, src.Type))
+					}
+				}
+			}
+		}
+		check("Query", cfg.GraphQL.Query)
+		check("Mutation", cfg.GraphQL.Mutation)
+		for _, name := range sortedKeys(cfg.GraphQL.Types) {
+			check(name, cfg.GraphQL.Types[name].Fields)
+		}
+	}
+
+	if cfg.Steps != nil && cfg.Steps.Output.Target.URL != "" {
+		calls = append(calls, "interceptor da operação padrão")
+	}
+	for _, op := range cfg.Operations {
+		if op.Steps != nil && op.Steps.Output.Target.URL != "" {
+			calls = append(calls, fmt.Sprintf("interceptor da operação '%s'", op.ID))
+		}
+	}
+	return calls
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stubParams(stub SourceStub) map[string]interface{} {
+	if stub.Error != "" {
+		return map[string]interface{}{"error": stub.Error}
+	}
+	return map[string]interface{}{"value": deepCopy(stub.Value)}
+}
+
+// withoutTarget copia os steps sem o interceptor: a resposta verificada é a montada pelo output.
+func withoutTarget(steps *config.StepsConf) *config.StepsConf {
+	if steps == nil || steps.Output.Target.URL == "" {
+		return steps
+	}
+	copied := *steps
+	copied.Output.Target = config.TargetConf{}
+	return &copied
+}
+
+// deepCopy copia mapas e listas, convertendo os mapas do YAML em map[string]interface{}.
+func deepCopy(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[fmt.Sprintf("%v", k)] = deepCopy(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[k] = deepCopy(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, val := range x {
+			l[i] = deepCopy(val)
+		}
+		return l
+	default:
+		return v
+	}
+}
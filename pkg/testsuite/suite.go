@@ -0,0 +1,80 @@
+// Package testsuite executa suítes declarativas de testes (toolkit test) contra a
+// ServiceEngine, em processo e sem rede: as sources de enrichment e os tokens dos
+// middlewares auth_provider são substituídos por stubs declarados em cada caso.
+package testsuite
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Suite é o arquivo de testes de uma configuração.
+type Suite struct {
+	Name  string `yaml:"name"`
+	Cases []Case `yaml:"cases"`
+}
+
+// Case descreve uma requisição, os stubs usados por ela e o resultado esperado.
+type Case struct {
+	Name      string                `yaml:"name"`
+	Operation string                `yaml:"operation"` // ID da operação; alternativa a request.path
+	Request   Request               `yaml:"request"`
+	Sources   map[string]SourceStub `yaml:"sources"` // Nome da source → resultado simulado
+	Auth      map[string]string     `yaml:"auth"`    // ID do middleware auth_provider → token
+	Expect    Expect                `yaml:"expect"`
+}
+
+// Request é a requisição simulada.
+type Request struct {
+	Method     string            `yaml:"method"` // Default: POST
+	Path       string            `yaml:"path"`   // Usado no roteamento entre operations
+	PathParams map[string]string `yaml:"path_params"`
+	Query      map[string]string `yaml:"query"`
+	Headers    map[string]string `yaml:"headers"`
+	Body       interface{}       `yaml:"body"` // Objeto ou lista (enviado como JSON) ou texto bruto
+}
+
+// SourceStub substitui a chamada de uma source: retorna 'value' ou falha com 'error'.
+type SourceStub struct {
+	Value interface{} `yaml:"value"`
+	Error string      `yaml:"error"`
+}
+
+// Expect é o resultado esperado. Campos omitidos não são verificados.
+type Expect struct {
+	Status     int               `yaml:"status"`
+	Body       interface{}       `yaml:"body"`       // Fragmento: os campos informados devem ser iguais na resposta
+	Headers    map[string]string `yaml:"headers"`    // Comparação sem diferenciar maiúsculas no nome
+	Assertions []string          `yaml:"assertions"` // Expressões CEL sobre response.status, response.headers e response.body
+}
+
+// Load lê uma suíte em YAML.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler suíte: %w", err)
+	}
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("suíte malformada: %w", err)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("suíte sem casos de teste")
+	}
+	for i, c := range suite.Cases {
+		if c.Name == "" {
+			return nil, fmt.Errorf("caso #%d sem 'name'", i+1)
+		}
+		for name, stub := range c.Sources {
+			if stub.Value != nil && stub.Error != "" {
+				return nil, fmt.Errorf("caso '%s': source '%s' declara 'value' e 'error'", c.Name, name)
+			}
+		}
+	}
+	if suite.Name == "" {
+		suite.Name = path
+	}
+	return &suite, nil
+}
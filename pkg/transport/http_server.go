@@ -3,13 +3,18 @@ package transport
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
-	"regexp"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/raywall/fast-service-toolkit/pkg/config"
 	"github.com/raywall/fast-service-toolkit/pkg/engine"
 	"github.com/rs/zerolog/log"
 )
@@ -20,10 +25,24 @@ const (
 	ContextKeyCorrID    = "correlation_id"
 )
 
-// Regex para identificar parâmetros na rota (ex: {id})
-var routeParamRegex = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+// Rotas de probe registradas automaticamente pelo servidor HTTP.
+const (
+	LivenessRoute  = "/health"
+	ReadinessRoute = "/ready"
+)
 
+// StartHTTPServer inicia o servidor e bloqueia até receber SIGTERM/SIGINT,
+// realizando então o encerramento gracioso.
 func StartHTTPServer(svc *engine.ServiceEngine) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	return ServeHTTP(ctx, svc)
+}
+
+// ServeHTTP atende requisições até o cancelamento de ctx. No encerramento, a readiness
+// passa a falhar, aguarda o drain_period, conclui as requisições em andamento dentro de
+// shutdown.timeout e por fim libera os recursos da engine.
+func ServeHTTP(ctx context.Context, svc *engine.ServiceEngine) error {
 	mux := http.NewServeMux()
 
 	if svc.Config.GraphQL.Enabled {
@@ -31,33 +50,120 @@ func StartHTTPServer(svc *engine.ServiceEngine) error {
 		mux.HandleFunc(svc.Config.GraphQL.Route, createGraphQLHandler(svc))
 	}
 
-	if svc.Config.Service.Route != "" && svc.Config.Service.Route != svc.Config.GraphQL.Route {
-		svc.Logger.Info().Msgf("Registrando Service REST em %s", svc.Config.Service.Route)
-		mux.HandleFunc(svc.Config.Service.Route, createRESTHandler(svc))
+	// As operações REST são roteadas pela engine (método + path), em um handler único.
+	// graphql.route "/" com rotas REST é rejeitado na carga da configuração.
+	if svc.HasOperations() {
+		svc.Logger.Info().Msg("Registrando operações REST")
+		mux.HandleFunc("/", createRESTHandler(svc))
+	}
+
+	registerProbes(mux, svc)
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", svc.Config.Service.Port),
+		Handler: ObservabilityMiddleware(mux),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		svc.Logger.Info().Msgf("Servidor HTTP ouvindo em %s", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// Falha ao abrir a porta ou encerramento inesperado
+		return err
+	case <-ctx.Done():
 	}
 
-	handler := ObservabilityMiddleware(mux)
+	shutdownConf := svc.Config.Service.Shutdown
+	svc.Drain()
+	drain := shutdownConf.GetDrainPeriod()
+	svc.Logger.Info().Dur("drain_period", drain).Msg("Sinal de encerramento recebido, drenando tráfego")
+	time.Sleep(drain)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownConf.GetTimeout())
+	defer cancel()
 
-	addr := fmt.Sprintf(":%d", svc.Config.Service.Port)
-	svc.Logger.Info().Msgf("Servidor HTTP ouvindo em %s", addr)
+	var errs []error
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("falha ao encerrar servidor HTTP: %w", err))
+	}
+	if err := svc.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
 
-	return http.ListenAndServe(addr, handler)
+	svc.Logger.Info().Msg("Servidor HTTP encerrado")
+	return errors.Join(errs...)
+}
+
+// registerProbes expõe liveness e readiness, exceto quando o path já é atendido pelo
+// serviço: rota GraphQL ou qualquer operação REST que o case, inclusive por parâmetro
+// (/{name}) ou subárvore (/, /{rest...}). Nesses casos a operação prevalece.
+func registerProbes(mux *http.ServeMux, svc *engine.ServiceEngine) {
+	cfg := svc.GetConfig()
+	taken := func(path string) bool {
+		if cfg.GraphQL.Enabled && cfg.GraphQL.Route == path {
+			return true
+		}
+		return svc.ServesPath(path)
+	}
+
+	if taken(LivenessRoute) {
+		svc.Logger.Warn().Str("path", LivenessRoute).Msg("Probe de liveness não registrado: path atendido pelo serviço")
+	} else {
+		mux.HandleFunc(LivenessRoute, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	if taken(ReadinessRoute) {
+		svc.Logger.Warn().Str("path", ReadinessRoute).Msg("Probe de readiness não registrado: path atendido pelo serviço")
+	} else {
+		mux.HandleFunc(ReadinessRoute, func(w http.ResponseWriter, r *http.Request) {
+			if !svc.Ready() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+	}
 }
 
 func createGraphQLHandler(svc *engine.ServiceEngine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		mwCtx, err := svc.RunMiddlewares(r.Context())
+		cfg := svc.GetConfig()
+		ctx := context.WithValue(r.Context(), "request_headers", flattenHeaders(r.Header))
+		ctx = context.WithValue(ctx, "request_info", httpRequestInfo(r, cfg.Service.TrustedProxies))
+
+		mwCtx, err := svc.RunMiddlewares(ctx)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Middleware error: %v", err), 429)
+			code, body, headers := svc.MiddlewareErrorResponse(ctx, err)
+			writeResponse(w, code, body, headers)
 			return
 		}
+		if mwHeaders, ok := mwCtx.Value("response_headers").(map[string]string); ok {
+			for k, v := range mwHeaders {
+				w.Header().Set(k, v)
+			}
+		}
 
 		var p struct {
 			Query     string                 `json:"query"`
 			Variables map[string]interface{} `json:"variables"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-			http.Error(w, "Invalid JSON Body", 400)
+		body := http.MaxBytesReader(w, r.Body, cfg.Service.GetMaxBodyBytes())
+		if err := json.NewDecoder(body).Decode(&p); err != nil {
+			status, msg := http.StatusBadRequest, "Invalid JSON Body"
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status, msg = http.StatusRequestEntityTooLarge, "Request body too large"
+			}
+			code, body, headers := svc.ErrorResponse(ctx, status, engine.StepInput, msg)
+			writeResponse(w, code, body, headers)
 			return
 		}
 
@@ -71,76 +177,132 @@ func createGraphQLHandler(svc *engine.ServiceEngine) http.HandlerFunc {
 // createRESTHandler evoluído para suportar Path e Query Params
 func createRESTHandler(svc *engine.ServiceEngine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// 1. Prepara Mapa de Input Unificado
-		inputData := make(map[string]interface{})
-
-		// A. Parse Body (se houver)
-		bodyBytes, err := io.ReadAll(r.Body)
-		if err == nil && len(bodyBytes) > 0 {
-			// Tenta fazer unmarshal se for JSON, senão ignora (ou poderia tratar como string)
-			_ = json.Unmarshal(bodyBytes, &inputData)
+		// 0. Headers e Metadados de Entrada (também usados nas respostas de erro)
+		// O prazo (service.timeout / on_timeout) é aplicado pela própria engine
+		cfg := svc.GetConfig()
+		ctx := context.WithValue(r.Context(), "request_headers", flattenHeaders(r.Header))
+		info := httpRequestInfo(r, cfg.Service.TrustedProxies)
+		ctx = context.WithValue(ctx, "request_info", info)
+
+		// 1. Roteamento (método + path) para a operação correspondente
+		opID, pathParams, err := svc.MatchOperation(r.Method, r.URL.Path)
+		if err != nil {
+			code, body, headers := svc.ErrorResponse(ctx, engine.RouteStatus(err), engine.StepRouting, err.Error())
+			writeResponse(w, code, body, headers)
+			return
 		}
-		defer r.Body.Close()
+		info["operation"] = opID
 
-		// B. Parse Query Params (?id=5&filter=abc)
+		// 2. Query Params (?id=5&filter=abc) e Path Params (/customer/{id}), que
+		// prevalecem sobre os campos do body
+		params := make(map[string]string)
 		for k, v := range r.URL.Query() {
 			if len(v) > 0 {
-				inputData[k] = v[0] // Pega o primeiro valor
+				params[k] = v[0] // Pega o primeiro valor
 			}
 		}
-
-		// C. Parse Path Params (/customer/{id})
-		// Identificamos as variáveis configuradas na rota (ex: {id}) e extraímos da URL atual
-		matches := routeParamRegex.FindAllStringSubmatch(svc.Config.Service.Route, -1)
-		for _, match := range matches {
-			if len(match) > 1 {
-				paramName := match[1]
-				// Go 1.22+: r.PathValue extrai o valor do wildcard
-				paramValue := r.PathValue(paramName)
-				if paramValue != "" {
-					inputData[paramName] = paramValue
-				}
+		for name, value := range pathParams {
+			if value != "" {
+				params[name] = value
 			}
 		}
 
-		// 2. Recria o Payload para o Engine
-		// O Engine espera []byte. Marshalizamos o mapa unificado.
-		finalPayload, _ := json.Marshal(inputData)
-
-		// 3. Configura Timeout e Contexto
-		timeoutDuration, _ := time.ParseDuration(svc.Config.Service.Timeout)
-		if timeoutDuration == 0 {
-			timeoutDuration = 30 * time.Second
-		}
-		ctx, cancel := context.WithTimeout(r.Context(), timeoutDuration)
-		defer cancel()
-
-		// 4. Injeta Headers de Entrada
-		reqHeaders := make(map[string]string)
-		for k, v := range r.Header {
-			if len(v) > 0 {
-				reqHeaders[k] = v[0]
-			}
-		}
-		ctx = context.WithValue(ctx, "request_headers", reqHeaders)
+		ctx = context.WithValue(ctx, "request_params", params)
 
-		// 5. Executa Engine
-		code, resp, headers, err := svc.Execute(ctx, finalPayload)
+		// 3. Executa Engine. O body bruto só é lido após o rate limit, até service.max_body_bytes;
+		// a engine o decodifica conforme o Content-Type (JSON, form, XML...)
+		body := http.MaxBytesReader(w, r.Body, cfg.Service.GetMaxBodyBytes())
+		defer body.Close()
+		code, resp, headers, err := svc.ExecuteOperationReader(ctx, opID, body)
 
 		if err != nil {
 			log.Ctx(ctx).Error().Err(err).Msg("Erro crítico na execução REST")
-			http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
+			code, body, headers := svc.ErrorResponse(ctx, http.StatusInternalServerError, "", "Internal Server Error")
+			writeResponse(w, code, body, headers)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		for k, v := range headers {
-			w.Header().Set(k, v)
+		// Content-Type definido pela engine (encoder do output ou problem+json nos erros)
+		writeResponse(w, code, resp, headers)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, code int, body []byte, headers map[string]string) {
+	for k, v := range headers {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// flattenHeaders converte http.Header para um mapa simples (primeiro valor de cada header).
+func flattenHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range h {
+		if len(v) > 0 {
+			headers[k] = v[0]
 		}
+	}
+	return headers
+}
 
-		w.WriteHeader(code)
-		w.Write(resp)
+// httpRequestInfo monta os metadados da requisição expostos ao CEL como 'request'.
+func httpRequestInfo(r *http.Request, proxies config.TrustedProxiesConf) map[string]interface{} {
+	return map[string]interface{}{
+		"client_ip": clientIP(r, proxies),
+		"method":    r.Method,
+		"path":      r.URL.Path,
+	}
+}
+
+// clientIP usa o endereço da conexão. O X-Forwarded-For só é considerado com proxies
+// confiáveis configurados, pois as entradas à esquerda são controladas pelo cliente:
+// com 'cidrs', vale a entrada mais à direita que não pertence às redes dos proxies (se a
+// conexão vier de um deles); com 'count', a entrada adicionada pelo último proxy confiável.
+func clientIP(r *http.Request, proxies config.TrustedProxiesConf) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(h, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				hops = append(hops, ip)
+			}
+		}
+	}
+
+	switch {
+	case len(proxies.CIDRs) > 0:
+		var nets []*net.IPNet
+		for _, cidr := range proxies.CIDRs {
+			if _, n, err := net.ParseCIDR(cidr); err == nil {
+				nets = append(nets, n)
+			}
+		}
+		trusted := func(ip string) bool {
+			parsed := net.ParseIP(ip)
+			for _, n := range nets {
+				if parsed != nil && n.Contains(parsed) {
+					return true
+				}
+			}
+			return false
+		}
+		if !trusted(remote) {
+			return remote
+		}
+		for i := len(hops) - 1; i >= 0; i-- {
+			if !trusted(hops[i]) {
+				return hops[i]
+			}
+		}
+	case proxies.Count > 0 && len(hops) >= proxies.Count:
+		return hops[len(hops)-proxies.Count]
 	}
+	return remote
 }
 
 // --- MIDDLEWARE DE OBSERVABILIDADE (Mantido igual) ---
@@ -0,0 +1,256 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raywall/fast-service-toolkit/pkg/config"
+	"github.com/raywall/fast-service-toolkit/pkg/engine"
+)
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Falha ao reservar porta: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func waitStatus(url string, want int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	got := 0
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get(url); err == nil {
+			got = resp.StatusCode
+			resp.Body.Close()
+			if got == want {
+				return got
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return got
+}
+
+func TestServeHTTP_GracefulShutdown(t *testing.T) {
+	port := freePort(t)
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{
+			Name:     "shutdown-test",
+			Runtime:  "local",
+			Port:     port,
+			Route:    "/api",
+			Timeout:  "1s",
+			Shutdown: config.ShutdownConf{DrainPeriod: "300ms", Timeout: "1s"},
+		},
+		Steps: &config.StepsConf{
+			Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{}},
+		},
+	}
+	svc, err := engine.NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ServeHTTP(ctx, svc) }()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if code := waitStatus(base+ReadinessRoute, http.StatusOK, 2*time.Second); code != http.StatusOK {
+		t.Fatalf("Readiness deveria responder 200, recebido %d", code)
+	}
+
+	cancel()
+
+	// Durante o drain a porta continua aberta, mas a readiness falha
+	if code := waitStatus(base+ReadinessRoute, http.StatusServiceUnavailable, time.Second); code != http.StatusServiceUnavailable {
+		t.Errorf("Readiness deveria responder 503 durante o drain, recebido %d", code)
+	}
+	if code := waitStatus(base+LivenessRoute, http.StatusOK, time.Second); code != http.StatusOK {
+		t.Errorf("Liveness deveria continuar 200 durante o drain, recebido %d", code)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Encerramento não deveria falhar: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Servidor não encerrou dentro do prazo")
+	}
+}
+
+func TestRESTHandler_Operations(t *testing.T) {
+	steps := func(body map[string]interface{}) *config.StepsConf {
+		return &config.StepsConf{Output: config.OutputStep{StatusCode: 200, Body: body}}
+	}
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "http-ops", Timeout: "1s", Route: "/legacy"},
+		Steps:   steps(map[string]interface{}{"op": "${request.operation}"}),
+		Operations: []config.OperationConf{
+			{ID: "get_customer", Method: "GET", Route: "/customers/{id}", Steps: steps(map[string]interface{}{"op": "${request.operation}", "id": "${input.id}"})},
+			{ID: "create_customer", Method: "POST", Route: "/customers", Steps: steps(map[string]interface{}{"op": "${request.operation}", "name": "${input.name}"})},
+		},
+	}
+	svc, err := engine.NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	handler := createRESTHandler(svc)
+
+	cases := []struct {
+		method, path, body string
+		wantCode           int
+		wantBody           string
+	}{
+		{"GET", "/legacy", "", 200, `{"op":"default"}`},
+		{"GET", "/customers/42", "", 200, `{"op":"get_customer","id":"42"}`},
+		{"POST", "/customers", `{"name":"Ana"}`, 200, `{"op":"create_customer","name":"Ana"}`},
+		{"PUT", "/customers", "", 405, `{"type":"about:blank","title":"Method Not Allowed","status":405,"step":"routing","instance":"/customers","detail":"método não permitido"}`},
+		{"GET", "/orders", "", 404, `{"type":"about:blank","title":"Not Found","status":404,"step":"routing","instance":"/orders","detail":"rota não encontrada"}`},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.wantCode {
+			t.Errorf("%s %s: status esperado %d, recebido %d (%s)", tc.method, tc.path, tc.wantCode, rec.Code, rec.Body.String())
+			continue
+		}
+		var got, want interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &got)
+		_ = json.Unmarshal([]byte(tc.wantBody), &want)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s %s: body esperado %s, recebido %s", tc.method, tc.path, tc.wantBody, rec.Body.String())
+		}
+	}
+
+	// Corpos não-JSON são decodificados pela engine conforme o Content-Type
+	req := httptest.NewRequest("POST", "/customers?source=partner", strings.NewReader("name=Bia"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"name":"Bia"`) {
+		t.Errorf("Formulário: esperado 200 com name=Bia, recebido %d (%s)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRegisterProbes(t *testing.T) {
+	// Probes registrados conforme a rota do serviço: a operação prevalece sobre o probe
+	cases := []struct {
+		route      string
+		wantProbes map[string]bool
+	}{
+		{"/customers/{id}", map[string]bool{LivenessRoute: true, ReadinessRoute: true}},
+		{"/health", map[string]bool{LivenessRoute: false, ReadinessRoute: true}},
+		{"/{name}", map[string]bool{LivenessRoute: false, ReadinessRoute: false}},
+		{"/", map[string]bool{LivenessRoute: false, ReadinessRoute: false}},
+		{"/{rest...}", map[string]bool{LivenessRoute: false, ReadinessRoute: false}},
+		{"POST /{name}", map[string]bool{LivenessRoute: false, ReadinessRoute: false}},
+	}
+	for _, tc := range cases {
+		cfg := &config.ServiceConfig{
+			Service: config.ServiceDetails{Name: "probes", Timeout: "1s", Route: tc.route},
+			Steps:   &config.StepsConf{Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{"op": "true"}}},
+		}
+		svc, err := engine.NewServiceEngine(cfg, "memory")
+		if err != nil {
+			t.Fatalf("%s: erro init engine: %v", tc.route, err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", createRESTHandler(svc))
+		registerProbes(mux, svc)
+
+		for _, path := range []string{LivenessRoute, ReadinessRoute} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			gotProbe := rec.Code == http.StatusOK && rec.Body.Len() == 0
+			if gotProbe != tc.wantProbes[path] {
+				t.Errorf("Rota %s, GET %s: probe esperado %v, recebido %d (%s)", tc.route, path, tc.wantProbes[path], rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+// trackingBody registra se o handler chegou a ler o body.
+type trackingBody struct {
+	io.Reader
+	read bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.Reader.Read(p)
+}
+
+func TestRESTHandler_BodyAfterRateLimit(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "http-body", Timeout: "1s", Route: "/items", MaxBodyBytes: 16},
+		Middlewares: []config.MiddlewareConf{
+			{Type: "rate_limit", ID: "limiter", Config: map[string]interface{}{"rps": 1, "burst": 2}},
+		},
+		Steps: &config.StepsConf{Output: config.OutputStep{StatusCode: 200, Body: map[string]interface{}{}}},
+	}
+	svc, err := engine.NewServiceEngine(cfg, "memory")
+	if err != nil {
+		t.Fatalf("Erro init engine: %v", err)
+	}
+	handler := createRESTHandler(svc)
+
+	// Body acima de service.max_body_bytes
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"nome muito longo"}`)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Body grande: esperado 413, recebido %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/items", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Esperado 200, recebido %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	// Requisição excedente é rejeitada sem que o body seja lido
+	body := &trackingBody{Reader: strings.NewReader(`{}`)}
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/items", body))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Esperado 429, recebido %d (%s)", rec.Code, rec.Body.String())
+	}
+	if body.read {
+		t.Error("O body não deveria ser lido quando o rate limit rejeita a requisição")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name    string
+		remote  string
+		xff     string
+		proxies config.TrustedProxiesConf
+		want    string
+	}{
+		{"sem proxies ignora o header", "10.0.0.5:4000", "1.2.3.4", config.TrustedProxiesConf{}, "10.0.0.5"},
+		{"count usa a entrada do último proxy", "10.0.0.5:4000", "6.6.6.6, 1.2.3.4", config.TrustedProxiesConf{Count: 1}, "1.2.3.4"},
+		{"count maior que o header", "10.0.0.5:4000", "1.2.3.4", config.TrustedProxiesConf{Count: 2}, "10.0.0.5"},
+		{"cidrs pula os proxies", "10.0.0.5:4000", "6.6.6.6, 1.2.3.4, 10.0.1.9", config.TrustedProxiesConf{CIDRs: []string{"10.0.0.0/16"}}, "1.2.3.4"},
+		{"cidrs com conexão não confiável", "8.8.8.8:4000", "1.2.3.4", config.TrustedProxiesConf{CIDRs: []string{"10.0.0.0/16"}}, "8.8.8.8"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		r.Header.Set("X-Forwarded-For", c.xff)
+		if got := clientIP(r, c.proxies); got != c.want {
+			t.Errorf("%s: esperado %s, recebido %s", c.name, c.want, got)
+		}
+	}
+}
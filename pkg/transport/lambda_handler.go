@@ -2,6 +2,7 @@ package transport
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -49,7 +50,7 @@ func (h *LambdaHandler) Handle(ctx context.Context, req events.APIGatewayProxyRe
 	var err error
 
 	// Verifica se a rota batida corresponde à rota GraphQL configurada
-	if h.svc.Config.GraphQL.Enabled && req.Path == h.svc.Config.GraphQL.Route {
+	if gql := h.svc.GetConfig().GraphQL; gql.Enabled && req.Path == gql.Route {
 		response, err = h.handleGraphQL(ctx, req)
 	} else {
 		response, err = h.handleREST(ctx, req)
@@ -75,11 +76,16 @@ func (h *LambdaHandler) Handle(ctx context.Context, req events.APIGatewayProxyRe
 
 func (h *LambdaHandler) handleGraphQL(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// 1. Executa Middlewares de Negócio
+	ctx = context.WithValue(ctx, "request_headers", req.Headers)
+	ctx = context.WithValue(ctx, "request_info", lambdaRequestInfo(req))
+
 	mwCtx, err := h.svc.RunMiddlewares(ctx)
 	if err != nil {
+		code, body, headers := h.svc.MiddlewareErrorResponse(ctx, err)
 		return events.APIGatewayProxyResponse{
-			StatusCode: 429,
-			Body:       `{"error": "Middleware rejected request"}`,
+			StatusCode: code,
+			Headers:    headers,
+			Body:       string(body),
 		}, nil
 	}
 
@@ -89,10 +95,7 @@ func (h *LambdaHandler) handleGraphQL(ctx context.Context, req events.APIGateway
 		Variables map[string]interface{} `json:"variables"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &p); err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Body:       `{"error": "Invalid JSON Body"}`,
-		}, nil
+		return h.restError(ctx, http.StatusBadRequest, engine.StepInput, "Invalid JSON Body"), nil
 	}
 
 	// 3. Executa GraphQL
@@ -100,46 +103,98 @@ func (h *LambdaHandler) handleGraphQL(ctx context.Context, req events.APIGateway
 
 	responseBody, _ := json.Marshal(result)
 
+	respHeaders := map[string]string{"Content-Type": "application/json"}
+	if mwHeaders, ok := mwCtx.Value("response_headers").(map[string]string); ok {
+		for k, v := range mwHeaders {
+			respHeaders[k] = v
+		}
+	}
+
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
-		Headers:    map[string]string{"Content-Type": "application/json"},
+		Headers:    respHeaders,
 		Body:       string(responseBody),
 	}, nil
 }
 
 func (h *LambdaHandler) handleREST(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Configura Timeout baseado na config (ou default lambda context, mas vamos respeitar a config do toolkit)
-	timeoutDuration, _ := time.ParseDuration(h.svc.Config.Service.Timeout)
-	if timeoutDuration > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, timeoutDuration)
-		defer cancel()
-	}
+	// O prazo (service.timeout / on_timeout) é aplicado pela própria engine,
+	// limitado também pelo deadline da invocação Lambda presente no ctx.
 
-	// 1. Injeta Headers de Entrada
+	// 1. Injeta Headers e Metadados de Entrada (também usados nas respostas de erro)
 	// APIGatewayProxyRequest já tem Headers map[string]string
+	info := lambdaRequestInfo(req)
 	ctx = context.WithValue(ctx, "request_headers", req.Headers)
+	ctx = context.WithValue(ctx, "request_info", info)
+
+	// 2. Roteamento: com 'operations', casa método + path; no formato de rota única,
+	// todo evento REST segue para a operação padrão (compatível com o proxy do API Gateway).
+	opID := engine.DefaultOperationID
+	var pathParams map[string]string
+	if len(h.svc.GetConfig().Operations) > 0 {
+		var err error
+		opID, pathParams, err = h.svc.MatchOperation(req.HTTPMethod, req.Path)
+		if err != nil {
+			return h.restError(ctx, engine.RouteStatus(err), engine.StepRouting, err.Error()), nil
+		}
+	}
+	info["operation"] = opID
+
+	// Body bruto: a engine decodifica conforme o Content-Type. Multipart e outros
+	// binários chegam em base64 pelo API Gateway.
+	payload := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return h.restError(ctx, http.StatusBadRequest, engine.StepInput, "Invalid request body"), nil
+		}
+		payload = decoded
+	}
+
+	// Query string e path params prevalecem sobre os campos do body, como no servidor HTTP
+	params := make(map[string]string, len(req.QueryStringParameters)+len(pathParams))
+	for k, v := range req.QueryStringParameters {
+		params[k] = v
+	}
+	for k, v := range pathParams {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	ctx = context.WithValue(ctx, "request_params", params)
 
-	// 2. Executa Engine (NOVA ASSINATURA)
-	code, resp, headers, err := h.svc.Execute(ctx, []byte(req.Body))
+	// 3. Executa Engine
+	code, resp, headers, err := h.svc.ExecuteOperation(ctx, opID, payload)
 
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Erro crítico na execução REST Lambda")
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       `{"error": "internal server error"}`,
-		}, nil
-	}
-
-	// 3. Prepara Resposta com Headers
-	respHeaders := map[string]string{"Content-Type": "application/json"}
-	for k, v := range headers {
-		respHeaders[k] = v
+		return h.restError(ctx, http.StatusInternalServerError, "", "Internal Server Error"), nil
 	}
 
+	// 4. Prepara Resposta: o Content-Type vem da engine (encoder do output ou problem+json nos erros)
 	return events.APIGatewayProxyResponse{
 		StatusCode: code,
-		Headers:    respHeaders,
+		Headers:    headers,
 		Body:       string(resp),
 	}, nil
 }
+
+// lambdaRequestInfo monta os metadados da requisição expostos ao CEL como 'request'.
+func lambdaRequestInfo(req events.APIGatewayProxyRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"client_ip": req.RequestContext.Identity.SourceIP,
+		"method":    req.HTTPMethod,
+		"path":      req.Path,
+	}
+}
+
+// restError responde falhas fora do pipeline da engine no contrato de erro do serviço.
+func (h *LambdaHandler) restError(ctx context.Context, status int, step, msg string) events.APIGatewayProxyResponse {
+	code, body, headers := h.svc.ErrorResponse(ctx, status, step, msg)
+	return events.APIGatewayProxyResponse{
+		StatusCode: code,
+		Headers:    headers,
+		Body:       string(body),
+	}
+}
@@ -44,3 +44,45 @@ func TestLambdaHandler_REST(t *testing.T) {
 	assert.Equal(t, 200, resp.StatusCode)
 	assert.Contains(t, resp.Body, "hello lambda")
 }
+
+func TestLambdaHandler_Operations(t *testing.T) {
+	cfg := &config.ServiceConfig{
+		Service: config.ServiceDetails{Name: "lambda-ops", Timeout: "1s"},
+		Operations: []config.OperationConf{
+			{
+				ID:     "get_customer",
+				Method: "GET",
+				Route:  "/customers/{id}",
+				Steps: &config.StepsConf{
+					Output: config.OutputStep{
+						StatusCode: 200,
+						Body: map[string]interface{}{
+							"id":        "${input.id}",
+							"expand":    "${input.expand}",
+							"operation": "${request.operation}",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	eng, err := engine.NewServiceEngine(cfg, "memory")
+	assert.NoError(t, err)
+	handler := NewLambdaHandler(eng)
+
+	resp, err := handler.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/customers/42",
+		QueryStringParameters: map[string]string{"expand": "accounts"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.JSONEq(t, `{"id":"42","expand":"accounts","operation":"get_customer"}`, resp.Body)
+
+	resp, _ = handler.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/customers/42"})
+	assert.Equal(t, 405, resp.StatusCode)
+
+	resp, _ = handler.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/orders"})
+	assert.Equal(t, 404, resp.StatusCode)
+}